@@ -0,0 +1,105 @@
+/*
+TokenList shows the tokens of a device, and allows revoking them. Tokens are restricted keys which log in as the device,
+but can only read or write the data of specific streams. They are created from the shell or the REST API.
+*/
+
+import React, { Component } from "react";
+import PropTypes from "prop-types";
+
+import {
+  Table,
+  TableBody,
+  TableHeader,
+  TableHeaderColumn,
+  TableRow,
+  TableRowColumn
+} from "material-ui/Table";
+import FontIcon from "material-ui/FontIcon";
+import IconButton from "material-ui/IconButton";
+
+import storage from "../storage";
+
+class TokenList extends Component {
+  static propTypes = {
+    path: PropTypes.string.isRequired
+  };
+  constructor(props) {
+    super(props);
+    this.state = {
+      tokens: [],
+      error: ""
+    };
+  }
+  componentDidMount() {
+    this.reload();
+  }
+  reload() {
+    storage.cdb
+      ._doRequest("crud/" + this.props.path + "?q=tokens", "GET")
+      .then(tokens => this.setState({ tokens: tokens || [], error: "" }))
+      .catch(err => this.setState({ error: err.toString() }));
+  }
+  revoke(t) {
+    if (!confirm("Revoke the token '" + t.name + "'?")) {
+      return;
+    }
+    storage.cdb
+      ._doRequest("crud/" + this.props.path + "?q=tokens&id=" + t.id, "DELETE")
+      .then(() => this.reload())
+      .catch(err => this.setState({ error: err.toString() }));
+  }
+
+  render() {
+    if (this.state.error != "") {
+      return <p style={{ color: "red" }}>{this.state.error}</p>;
+    }
+    if (this.state.tokens.length == 0) {
+      return null;
+    }
+    return (
+      <div style={{ marginTop: "20px" }}>
+        <h4 style={{ color: "rgba(0, 0, 0, 0.541176)" }}>Tokens</h4>
+        <Table selectable={false}>
+          <TableHeader
+            enableSelectAll={false}
+            displaySelectAll={false}
+            adjustForCheckbox={false}
+          >
+            <TableRow>
+              <TableHeaderColumn>Name</TableHeaderColumn>
+              <TableHeaderColumn>Access</TableHeaderColumn>
+              <TableHeaderColumn>Streams</TableHeaderColumn>
+              <TableHeaderColumn>Expires</TableHeaderColumn>
+              <TableHeaderColumn />
+            </TableRow>
+          </TableHeader>
+          <TableBody displayRowCheckbox={false}>
+            {this.state.tokens.map(t =>
+              <TableRow key={t.id}>
+                <TableRowColumn>{t.name}</TableRowColumn>
+                <TableRowColumn>{t.access}</TableRowColumn>
+                <TableRowColumn>
+                  {t.streams == "" ? "all" : t.streams}
+                </TableRowColumn>
+                <TableRowColumn>
+                  {t.expires === undefined
+                    ? "never"
+                    : new Date(t.expires * 1000).toLocaleString()}
+                </TableRowColumn>
+                <TableRowColumn>
+                  <IconButton
+                    tooltip="Revoke Token"
+                    onTouchTap={() => this.revoke(t)}
+                  >
+                    <FontIcon className="material-icons">delete</FontIcon>
+                  </IconButton>
+                </TableRowColumn>
+              </TableRow>
+            )}
+          </TableBody>
+        </Table>
+      </div>
+    );
+  }
+}
+export default TokenList;
@@ -19,6 +19,7 @@ import { go } from "../actions";
 import TimeDifference from "../components/TimeDifference";
 import ObjectCard from "../components/ObjectCard";
 import ObjectList from "../components/ObjectList";
+import TokenList from "../components/TokenList";
 
 import { objectFilter } from "../util";
 
@@ -120,6 +121,9 @@ class DeviceView extends Component {
                     />}
               </div>
             : null}
+          {device.apikey !== undefined && device.apikey != ""
+            ? <TokenList path={user.name + "/" + device.name} />
+            : null}
 
         </ObjectCard>
         <Subheader
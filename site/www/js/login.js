@@ -1,10 +1,11 @@
-$('.message a').click(function(){
+$('.message:not(.link) a').click(function(){
    $('form').animate({height: "toggle", opacity: "toggle"}, "slow");
 });
 //login attempts to log into ConnectorDB. If successful, it refreshes the site. if not, it notifies the user.
 function login() {
 	usrname = $("#username").val()
 	pass = $("#password").val()
+	totp = $("#totp").val()
 
 	if (usrname == "") {
 		alert("Please type in a username!");
@@ -28,15 +29,30 @@ function login() {
 					location.reload(true);
 			},
 			error: function(request, textStatus, errorThrown) {
+				if (request.getResponseHeader("X-TOTP-Required") == "true" && !$("#totp").is(":visible")) {
+					// The password was right, but the user has two-factor authentication - ask for the code
+					$("#username").prop('disabled', false);
+					$("#password").prop('disabled', false);
+					$("#totp").show().focus();
+					return;
+				}
+				if (request.status == 429) {
+					// Too many failed logins - let the user know why the login was refused
+					alert(JSON.parse(request.responseText).msg);
+				}
 				$(".login-form").effect("shake");
         $("#loginbtn").animate({backgroundColor: "red"}).animate({backgroundColor: "#005c9e"});
 				$("#username").prop('disabled', false);
 				$("#password").prop('disabled', false);
 				$("#password").val("");
+				$("#totp").val("");
 				$("#password").focus();
 			},
 			beforeSend: function (xhr) {
 		        xhr.setRequestHeader('Authorization', 'Basic ' + btoa(usrname + ":" + pass));
+				if (totp != "") {
+					xhr.setRequestHeader('X-TOTP', totp);
+				}
 		    }
 		});
 
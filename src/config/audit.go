@@ -0,0 +1,47 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package config
+
+import (
+	"errors"
+	"path/filepath"
+)
+
+// Audit sets up the audit log, which records the accesses of devices to the data of streams,
+// including the accesses that were denied
+type Audit struct {
+	Enabled bool `json:"enabled"`
+
+	// The sink is either "stream", which writes the accesses to the meta/audit stream of the user owning
+	// the accessed stream, or "file", which writes them to the given file, one json entry per line.
+	// Only the user's own user device can read the meta/audit stream, and no device can write to it.
+	Sink string `json:"sink"`
+	File string `json:"file"`
+
+	// Once the file reaches MaxFileSize bytes, it is rotated, keeping up to MaxFiles old files
+	MaxFileSize int64 `json:"max_file_size"`
+	MaxFiles    int   `json:"max_files"`
+}
+
+// Validate ensures that the audit log is set up correctly
+func (a *Audit) Validate() (err error) {
+	if !a.Enabled {
+		return nil
+	}
+	switch a.Sink {
+	case "stream":
+		return nil
+	case "file":
+		if a.File == "" {
+			return errors.New("The audit log needs a file to write to")
+		}
+		if a.MaxFileSize < 1 || a.MaxFiles < 0 {
+			return errors.New("The audit log file needs a positive size and a non-negative number of old files")
+		}
+		a.File, err = filepath.Abs(a.File)
+		return err
+	}
+	return errors.New("The audit log sink must be one of 'stream' or 'file'")
+}
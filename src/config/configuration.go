@@ -57,6 +57,10 @@ type Configuration struct {
 	BatchSize int `json:"batchsize"` // BatchSize is the number of datapoints per database entry
 	ChunkSize int `json:"chunksize"` // ChunkSize is number of batches per database insert transaction
 
+	// Merge and dataset queries open the ranges of their streams in parallel, and read ahead from each of them in the background
+	QueryWorkers  int `json:"query_workers"`  // The maximum number of streams that a single query opens at once (0 or 1 opens them one after another)
+	QueryPrefetch int `json:"query_prefetch"` // The number of datapoints buffered for each stream of a merge or dataset (0 disables reading ahead)
+
 	// The cache sizes for users/devices/streams
 	UseCache        bool  `json:"cache"`         // Whether or not to enable caching
 	CacheTimeout    int64 `json:"cache_timeout"` // Whether the cache times out in seconds
@@ -64,6 +68,16 @@ type Configuration struct {
 	DeviceCacheSize int64 `json:"device_cache_size"`
 	StreamCacheSize int64 `json:"stream_cache_size"`
 
+	// The memory budget in bytes for caching the results of repeated stream queries.
+	// The cache is invalidated by inserts, so it mainly helps dashboards that poll the same data. 0 disables it.
+	QueryCacheSize int64 `json:"query_cache_size"`
+
+	// The audit log records which devices read, wrote and subscribed to the data of each stream
+	Audit Audit `json:"audit"`
+
+	// Webhooks post the data inserted into streams to the urls that users register on them
+	Webhooks Webhooks `json:"webhooks"`
+
 	// The default algorithm to use for hashing passwords. Options are SHA512 and bcrypt
 	// This can be changed during runtime, and the user passwords will upgrade when they log in
 	PasswordHash string `json:"password_hash"`
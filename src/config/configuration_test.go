@@ -5,6 +5,9 @@ Licensed under the MIT license.
 package config
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -17,6 +20,88 @@ func TestValidate(t *testing.T) {
 	cfg.Permissions = "boo"
 	require.Error(t, cfg.Validate())
 	cfg.Permissions = "default"
+
+	// The metrics are only served with a token
+	require.False(t, cfg.Metrics.Enabled)
+	cfg.Metrics.Enabled = true
+	require.Error(t, cfg.Validate())
+	cfg.Metrics.Token = "secret"
+	require.NoError(t, cfg.Validate())
+}
+
+func TestValidateOAuth(t *testing.T) {
+	cfg := NewConfiguration()
+	cfg.OAuth.Clients["myapp"] = &OAuthClient{RedirectURIs: []string{"https://myapp.com/callback"}}
+	require.NoError(t, cfg.Validate())
+	require.Equal(t, "myapp", cfg.OAuth.Clients["myapp"].Name)
+	require.Equal(t, "oauth", cfg.OAuth.Clients["myapp"].Role)
+
+	cfg.OAuth.Clients["my app"] = &OAuthClient{RedirectURIs: []string{"https://myapp.com/callback"}}
+	require.Error(t, cfg.Validate())
+	delete(cfg.OAuth.Clients, "my app")
+
+	cfg.OAuth.Clients["noredirect"] = &OAuthClient{}
+	require.Error(t, cfg.Validate())
+	delete(cfg.OAuth.Clients, "noredirect")
+
+	cfg.OAuth.Clients["badrole"] = &OAuthClient{RedirectURIs: []string{"https://myapp.com/callback"}, Role: "notarole"}
+	require.Error(t, cfg.Validate())
+}
+
+func TestValidateOIDC(t *testing.T) {
+	cfg := NewConfiguration()
+	cfg.OIDC["myidp"] = &OIDCProvider{Issuer: "https://id.example.com/", ClientID: "connectordb"}
+	require.NoError(t, cfg.Validate())
+	require.Equal(t, "https://id.example.com", cfg.OIDC["myidp"].Issuer)
+	require.Equal(t, "myidp", cfg.OIDC["myidp"].Name)
+
+	cfg.OIDC["noissuer"] = &OIDCProvider{ClientID: "connectordb"}
+	require.Error(t, cfg.Validate())
+}
+
+func TestMail(t *testing.T) {
+	dir, err := ioutil.TempDir("", "connectordb_mail")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cfg := NewConfiguration()
+	require.False(t, cfg.Mail.Enabled())
+	_, err = cfg.Mail.GetSender()
+	require.Error(t, err)
+
+	cfg.Mail.Sender = "file"
+	require.Error(t, cfg.Validate())
+	cfg.Mail.From = "connectordb@localhost"
+	cfg.Mail.Directory = dir
+	require.NoError(t, cfg.Validate())
+
+	s, err := cfg.Mail.GetSender()
+	require.NoError(t, err)
+	require.NoError(t, s.Send("user@localhost", "Hello", "Hi there!\nBye"))
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	msg, err := ioutil.ReadFile(filepath.Join(dir, files[0].Name()))
+	require.NoError(t, err)
+	require.Contains(t, string(msg), "To: user@localhost\r\nSubject: Hello\r\n")
+	require.Contains(t, string(msg), "Hi there!\r\nBye")
+
+	// Addresses and subjects can't add headers to the email
+	require.Error(t, s.Send("user@localhost\r\nBcc: other@localhost", "Hello", "Hi"))
+	require.Error(t, s.Send("user@localhost\nBcc: other@localhost", "Hello", "Hi"))
+	require.Error(t, s.Send("not an address", "Hello", "Hi"))
+	require.Error(t, s.Send("user@localhost", "Hello\r\nBcc: other@localhost", "Hi"))
+	files, err = ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	cfg.Mail.From = "connectordb@localhost\r\nBcc: other@localhost"
+	require.Error(t, cfg.Validate())
+	cfg.Mail.From = "ConnectorDB <connectordb@localhost>"
+	require.NoError(t, cfg.Validate())
+
+	cfg.Mail.Sender = "carrierpigeon"
+	require.Error(t, cfg.Validate())
 }
 
 func TestSave(t *testing.T) {
@@ -72,6 +72,24 @@ func NewConfiguration() *Configuration {
 				Enabled: false,
 			},
 
+			// OAuth is disabled until apps are registered. Access tokens are refreshed hourly.
+			OAuth: OAuth{
+				Enabled:           false,
+				Clients:           map[string]*OAuthClient{},
+				CodeExpire:        60,
+				AccessTokenExpire: 60 * 60,
+			},
+
+			// Emails are not sent until a sender is set up. Password reset links are valid for an hour,
+			// and email verification links for a week.
+			Mail: Mail{
+				ResetExpire:  60 * 60,
+				VerifyExpire: 7 * 24 * 60 * 60,
+			},
+
+			// No external identity providers are set up by default
+			OIDC: map[string]*OIDCProvider{},
+
 			// Set up the default TLS options
 			TLS: TLS{
 				Enabled: false,
@@ -128,17 +146,64 @@ func NewConfiguration() *Configuration {
 
 			//wait a while between failed login attempts
 			FailedLoginDelay: 300,
+
+			// Failed logins back off from 300ms up to 30s, and 10 failures in a row lock out logins for 15 minutes.
+			// A proxy on the same machine, such as nginx, gives the address of the client.
+			LoginLimit: LoginLimit{
+				Enabled:         true,
+				Backoff:         300,
+				MaxBackoff:      30 * 1000,
+				LockoutAttempts: 10,
+				LockoutTime:     15 * 60,
+				TrustedProxies:  []string{"127.0.0.1", "::1"},
+			},
+
+			// The MQTT listener is off by default. When enabled, it runs on the standard MQTT port,
+			// with the same limit on packets as on websocket messages.
+			MQTT: MQTT{
+				Enabled:          false,
+				Port:             1883,
+				MaxPacketBytes:   1024 * 1024,
+				DefaultKeepAlive: 5 * 60,
+			},
+
+			// The metrics are off until they are enabled along with a token for scrapers
+			Metrics: Metrics{
+				Enabled: false,
+			},
 		},
 
 		//The defaults to use for the batch and chunks
 		BatchSize: 250,
 		ChunkSize: 10,
 
+		QueryWorkers:  8,
+		QueryPrefetch: 500,
+
 		UseCache:        true,
 		CacheTimeout:    30 * 1000, // Seems like a reasonable timeout to me
 		UserCacheSize:   1000,
 		DeviceCacheSize: 10000,
 		StreamCacheSize: 10000,
+		QueryCacheSize:  64 * 1024 * 1024,
+
+		// The audit log is off by default. When writing to a file, keep 5 files of 10MB.
+		Audit: Audit{
+			Enabled:     false,
+			Sink:        "stream",
+			File:        "audit.log",
+			MaxFileSize: 10 * 1024 * 1024,
+			MaxFiles:    5,
+		},
+
+		// Retry failed webhooks 5 times over about a minute
+		Webhooks: Webhooks{
+			Enabled:   true,
+			Retries:   5,
+			Backoff:   2,
+			Timeout:   10,
+			QueueSize: 100,
+		},
 
 		// No reason not to use bcrypt
 		PasswordHash: "bcrypt",
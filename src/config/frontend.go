@@ -50,6 +50,15 @@ type Frontend struct {
 
 	Captcha Captcha `json:"captcha"`
 
+	// Allows third party apps to get access to users' streams
+	OAuth OAuth `json:"oauth"`
+
+	// How emails such as password reset links are sent to users
+	Mail Mail `json:"mail"`
+
+	// The OpenID Connect identity providers that users can log in with, by their id
+	OIDC map[string]*OIDCProvider `json:"oidc"`
+
 	// The QueryDisplayTimer is how often to display aggregate query numbers (is seconds) in the log
 	// This is a simple one-line summary of how many requests were processed.
 	// Note that the change will not come into effect immediately if modified during runtime, there will be a delay before
@@ -80,8 +89,18 @@ type Frontend struct {
 	// Whether or not to gzip static data
 	GzipStatic bool `json:"gzip_static"`
 
-	// Amount of time in millisencods to wait after a failed login attempt
+	// Amount of time in millisencods to wait after a failed login attempt. It is only used
+	// when the login limit is disabled.
 	FailedLoginDelay time.Duration `json:"failed_login_delay"`
+
+	// Limits the number of failed logins, locking out users, api keys and clients that fail too often
+	LoginLimit LoginLimit `json:"login_limit"`
+
+	// The optional MQTT listener, through which devices can insert and subscribe
+	MQTT MQTT `json:"mqtt"`
+
+	// The Prometheus metrics of the server
+	Metrics Metrics `json:"metrics"`
 }
 
 // TLSEnabled returns whether or not TLS os enabled for the frontend
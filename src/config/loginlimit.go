@@ -0,0 +1,70 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+)
+
+// LoginLimit sets up the limits on failed logins, which protect users and devices from having their
+// passwords and api keys guessed. Failures are counted by username, by api key prefix and by client ip.
+type LoginLimit struct {
+	Enabled bool `json:"enabled"`
+
+	// After a failed login, further logins are refused without being checked for Backoff milliseconds.
+	// The time doubles with each consecutive failure, up to MaxBackoff milliseconds.
+	Backoff    int64 `json:"backoff"`
+	MaxBackoff int64 `json:"max_backoff"`
+
+	// After LockoutAttempts consecutive failures, logins are locked out for LockoutTime seconds,
+	// unless an admin unlocks them earlier.
+	LockoutAttempts int   `json:"lockout_attempts"`
+	LockoutTime     int64 `json:"lockout_time"`
+
+	// The addresses or CIDR ranges of the reverse proxies (such as nginx) in front of ConnectorDB. The failures
+	// of logins which come through them are counted by the client ip in their X-Real-IP header, rather than by
+	// the address of the proxy. The header of any other client is ignored, since it could be forged.
+	TrustedProxies []string `json:"trusted_proxies"`
+}
+
+// ProxyNets returns the networks of the trusted proxies
+func (l *LoginLimit) ProxyNets() ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(l.TrustedProxies))
+	for _, p := range l.TrustedProxies {
+		if ip := net.ParseIP(p); ip != nil {
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(p)
+		if err != nil {
+			return nil, fmt.Errorf("Trusted proxy '%s' is not an ip address or CIDR range", p)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// Validate ensures that the login limits are valid
+func (l *LoginLimit) Validate() error {
+	if _, err := l.ProxyNets(); err != nil {
+		return err
+	}
+	if !l.Enabled {
+		return nil
+	}
+	if l.Backoff < 0 || l.MaxBackoff < l.Backoff {
+		return errors.New("The login backoff must be positive, and at most the maximum backoff")
+	}
+	if l.LockoutAttempts < 1 || l.LockoutTime < 1 {
+		return errors.New("Login lockouts need at least 1 attempt and last at least 1 second")
+	}
+	return nil
+}
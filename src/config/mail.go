@@ -0,0 +1,191 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MailSender sends emails to users, such as password reset links and email verification links
+type MailSender interface {
+	Send(to, subject, body string) error
+}
+
+// MailSenders are the available ways of sending emails, by the name used in the "sender" option.
+// Other senders can be added before the configuration is loaded.
+var MailSenders = map[string]func(m *Mail) (MailSender, error){
+	"smtp": newSMTPSender,
+	"file": newFileSender,
+}
+
+// SMTP holds the options of the SMTP server through which emails are sent
+type SMTP struct {
+	Host string `json:"host"`
+	Port int    `json:"port"`
+
+	// The login to the SMTP server. No login is used if the username is empty.
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// Mail sets up how ConnectorDB sends emails to its users
+type Mail struct {
+	// The way that emails are sent: "smtp" sends them through an SMTP server, "file" writes them to
+	// files in Directory, which is useful for testing. If empty, no emails are sent, so users can't
+	// reset their passwords or verify their email addresses.
+	Sender string `json:"sender"`
+
+	// The address from which emails are sent
+	From string `json:"from"`
+
+	SMTP      SMTP   `json:"smtp"`
+	Directory string `json:"directory"`
+
+	// The number of seconds that password reset links and email verification links are valid
+	ResetExpire  int64 `json:"reset_expire"`
+	VerifyExpire int64 `json:"verify_expire"`
+}
+
+// Enabled returns whether ConnectorDB can send emails
+func (m *Mail) Enabled() bool {
+	return m.Sender != ""
+}
+
+// Validate ensures that the mail options are valid
+func (m *Mail) Validate() (err error) {
+	if m.ResetExpire < 1 || m.VerifyExpire < 1 {
+		return errors.New("Password reset and email verification links must be valid for at least 1 second")
+	}
+	if !m.Enabled() {
+		return nil
+	}
+	if _, ok := MailSenders[m.Sender]; !ok {
+		return fmt.Errorf("Unknown mail sender '%s'", m.Sender)
+	}
+	if m.From == "" {
+		return errors.New("The address from which emails are sent was not given")
+	}
+	if _, err = mailAddress(m.From); err != nil {
+		return fmt.Errorf("The address from which emails are sent is invalid: %s", err.Error())
+	}
+	if m.Sender == "smtp" && m.SMTP.Host == "" {
+		return errors.New("The SMTP server was not given")
+	}
+	if m.Sender == "file" {
+		if m.Directory == "" {
+			return errors.New("The directory to which emails are written was not given")
+		}
+		m.Directory, err = filepath.Abs(m.Directory)
+	}
+	return err
+}
+
+// GetSender returns the MailSender set up by the options
+func (m *Mail) GetSender() (MailSender, error) {
+	newSender, ok := MailSenders[m.Sender]
+	if !ok {
+		return nil, errors.New("Sending emails is not set up on this server")
+	}
+	return newSender(m)
+}
+
+// mailAddress parses an email address, refusing line breaks so that it can't add headers to the email
+func mailAddress(address string) (*mail.Address, error) {
+	if strings.ContainsAny(address, "\r\n") {
+		return nil, fmt.Errorf("The email address '%s' holds a line break", strings.TrimSpace(address))
+	}
+	return mail.ParseAddress(address)
+}
+
+// mailMessage returns the full message of an email, including its headers
+func mailMessage(from, to *mail.Address, subject, body string) ([]byte, error) {
+	if strings.ContainsAny(subject, "\r\n") {
+		return nil, errors.New("The subject of an email can't hold a line break")
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from.String())
+	fmt.Fprintf(&b, "To: %s\r\n", to.Address)
+	fmt.Fprintf(&b, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n")
+	b.WriteString(strings.Replace(body, "\n", "\r\n", -1))
+	return b.Bytes(), nil
+}
+
+// smtpSender sends emails through an SMTP server
+type smtpSender struct {
+	from *mail.Address
+	addr string
+	auth smtp.Auth
+}
+
+func newSMTPSender(m *Mail) (MailSender, error) {
+	port := m.SMTP.Port
+	if port == 0 {
+		port = 587
+	}
+	from, err := mailAddress(m.From)
+	if err != nil {
+		return nil, err
+	}
+	s := &smtpSender{from: from, addr: net.JoinHostPort(m.SMTP.Host, strconv.Itoa(port))}
+	if m.SMTP.Username != "" {
+		s.auth = smtp.PlainAuth("", m.SMTP.Username, m.SMTP.Password, m.SMTP.Host)
+	}
+	return s, nil
+}
+
+func (s *smtpSender) Send(to, subject, body string) error {
+	addr, err := mailAddress(to)
+	if err != nil {
+		return err
+	}
+	msg, err := mailMessage(s.from, addr, subject, body)
+	if err != nil {
+		return err
+	}
+	return smtp.SendMail(s.addr, s.auth, s.from.Address, []string{addr.Address}, msg)
+}
+
+// fileSender writes emails to files in a directory instead of sending them
+type fileSender struct {
+	from *mail.Address
+	dir  string
+}
+
+func newFileSender(m *Mail) (MailSender, error) {
+	from, err := mailAddress(m.From)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.MkdirAll(m.Directory, 0700); err != nil {
+		return nil, err
+	}
+	return &fileSender{from: from, dir: m.Directory}, nil
+}
+
+func (s *fileSender) Send(to, subject, body string) error {
+	addr, err := mailAddress(to)
+	if err != nil {
+		return err
+	}
+	msg, err := mailMessage(s.from, addr, subject, body)
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%d_%s.eml", time.Now().UnixNano(), strings.Replace(addr.Address, "/", "_", -1))
+	return ioutil.WriteFile(filepath.Join(s.dir, name), msg, 0600)
+}
@@ -0,0 +1,24 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package config
+
+import "errors"
+
+// Metrics sets up the /metrics endpoint, which exports the statistics of the server in the Prometheus text format
+type Metrics struct {
+	Enabled bool `json:"enabled"`
+
+	// The bearer token that scrapers give in their Authorization header. It is needed even on localhost,
+	// since requests passed on by a proxy on the same machine also come from localhost.
+	Token string `json:"token"`
+}
+
+// Validate ensures that the metrics are only enabled along with a token
+func (m *Metrics) Validate() error {
+	if m.Enabled && m.Token == "" {
+		return errors.New("The metrics can't be enabled without the token that scrapers give to read them")
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package config
+
+import "errors"
+
+// MQTT sets up the built-in MQTT listener, which allows devices to insert into and subscribe to streams
+// over MQTT. Devices log in with their API key as the password. Topics are stream paths (user/device/stream),
+// and the downlink of a stream is at user/device/stream/downlink.
+type MQTT struct {
+	Enabled bool `json:"enabled"`
+
+	// The hostname and port on which to listen. The hostname defaults to the frontend's hostname.
+	Hostname string `json:"hostname"`
+	Port     uint16 `json:"port"`
+
+	// Whether to use the frontend's TLS certificate. MQTT over TLS is usually run on port 8883.
+	TLS bool `json:"tls"`
+
+	// The maximum size of a single MQTT packet in bytes
+	MaxPacketBytes int64 `json:"max_packet_bytes"`
+
+	// The maximum number of seconds between the packets of a client that doesn't give a keepalive
+	DefaultKeepAlive int64 `json:"default_keepalive"`
+}
+
+// Validate ensures that the MQTT options are valid
+func (m *MQTT) Validate() error {
+	if !m.Enabled {
+		return nil
+	}
+	if m.Port == 0 {
+		return errors.New("The MQTT listener needs a port")
+	}
+	if m.MaxPacketBytes < 100 {
+		return errors.New("The limit of an MQTT packet has to be at least 100 bytes.")
+	}
+	if m.DefaultKeepAlive < 1 {
+		return errors.New("The default MQTT keepalive must be at least 1 second")
+	}
+	return nil
+}
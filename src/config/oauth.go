@@ -0,0 +1,64 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package config
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// The ids of OAuth clients and identity providers are used in device names and urls, so they are restricted to safe characters
+var idValidator = regexp.MustCompile("^[a-zA-Z0-9_-]{1,20}$")
+
+// OAuthClient is a third party app which can ask users for access to their streams
+type OAuthClient struct {
+	// The name of the app, which is shown to users when they are asked for consent
+	Name string `json:"name"`
+
+	// The client secret. Public clients (such as mobile and javascript apps) can't keep a secret,
+	// so they leave it empty, and are required to use PKCE.
+	Secret string `json:"secret"`
+
+	// The URIs to which users can be redirected after giving consent
+	RedirectURIs []string `json:"redirect_uris"`
+
+	// The device role given to the devices which are created when users give consent to the app
+	Role string `json:"role"`
+}
+
+// OAuth sets up ConnectorDB as an OAuth2 authorization server
+type OAuth struct {
+	Enabled bool `json:"enabled"`
+
+	// The registered apps, by their client id
+	Clients map[string]*OAuthClient `json:"clients"`
+
+	// The number of seconds that an authorization code and an access token are valid
+	CodeExpire        int64 `json:"code_expire"`
+	AccessTokenExpire int64 `json:"access_token_expire"`
+}
+
+// Validate ensures that the OAuth options are valid
+func (o *OAuth) Validate() error {
+	if o.CodeExpire < 1 || o.AccessTokenExpire < 1 {
+		return errors.New("OAuth codes and access tokens must be valid for at least 1 second")
+	}
+	for id, c := range o.Clients {
+		if !idValidator.MatchString(id) {
+			return fmt.Errorf("OAuth client id '%s' must be at most 20 letters, numbers, dashes or underscores", id)
+		}
+		if c == nil || len(c.RedirectURIs) == 0 {
+			return fmt.Errorf("OAuth client '%s' must have at least one redirect uri", id)
+		}
+		if c.Name == "" {
+			c.Name = id
+		}
+		if c.Role == "" {
+			c.Role = "oauth"
+		}
+	}
+	return nil
+}
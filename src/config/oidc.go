@@ -0,0 +1,53 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// OIDCProvider is an external OpenID Connect identity provider which users can log in with
+type OIDCProvider struct {
+	// The name of the provider, which is shown on the login page
+	Name string `json:"name"`
+
+	// The issuer URL of the provider. The provider's endpoints are discovered from
+	// <issuer>/.well-known/openid-configuration
+	Issuer string `json:"issuer"`
+
+	// The client credentials that ConnectorDB was registered with at the provider
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+
+	// The scopes to request. The openid scope is always requested.
+	Scopes []string `json:"scopes"`
+
+	// The claim of the ID token which holds the user's roles (or groups) at the provider,
+	// and the map from the values of that claim to ConnectorDB user roles. If no value is mapped,
+	// new users get the join role of permissions, and the role of existing users is left alone.
+	RoleClaim string            `json:"role_claim"`
+	Roles     map[string]string `json:"roles"`
+}
+
+// validateOIDC ensures that the identity providers are valid
+func validateOIDC(providers map[string]*OIDCProvider) error {
+	for id, p := range providers {
+		if !idValidator.MatchString(id) {
+			return fmt.Errorf("OpenID Connect provider id '%s' must be at most 20 letters, numbers, dashes or underscores", id)
+		}
+		if p == nil || !strings.HasPrefix(p.Issuer, "http") || p.ClientID == "" {
+			return fmt.Errorf("OpenID Connect provider '%s' must have an issuer url and a client id", id)
+		}
+		p.Issuer = strings.TrimSuffix(p.Issuer, "/")
+		if p.Name == "" {
+			p.Name = id
+		}
+		if len(p.Scopes) == 0 {
+			p.Scopes = []string{"profile", "email"}
+		}
+	}
+	return nil
+}
@@ -26,6 +26,10 @@ type Options struct {
 	CacheEnabled    bool
 	CacheTimeout    int64
 
+	QueryCacheSize int64 // The memory budget in bytes of the query result cache (0 is disabled)
+
+	Audit Audit // The audit log of accesses to the data of streams
+
 	BatchSize int // BatchSize is the number of datapoints per batch of data in a stream
 	ChunkSize int // ChunkSize is the number of batches to queue up before writing to storage
 }
@@ -65,6 +69,8 @@ func (c *Configuration) Options() *Options {
 	opt.UserCacheSize = c.UserCacheSize
 	opt.StreamCacheSize = c.StreamCacheSize
 	opt.CacheTimeout = c.CacheTimeout
+	opt.QueryCacheSize = c.QueryCacheSize
+	opt.Audit = c.Audit
 
 	return &opt
 }
@@ -7,7 +7,7 @@ var Default = Permissions{
 	Watch:   true,
 
 	// Here we disallow names that would conflict with the ConnectorDB frontend
-	DisallowedNames: []string{"support", "www", "api", "app", "favicon.ico", "robots.txt", "sitemap.xml", "join", "login", "user", "admin", "nobody", "root"},
+	DisallowedNames: []string{"support", "www", "api", "app", "favicon.ico", "robots.txt", "sitemap.xml", "join", "login", "user", "admin", "nobody", "root", "oauth", "oidc", "account", "metrics", "health", "ready"},
 
 	// Allow an arbitrary number of users by default
 	MaxUsers: -1,
@@ -20,7 +20,8 @@ var Default = Permissions{
 	//     Can't access anything - is greeted with the landing page and a login prompt
 	// - user:
 	//     Can create its own streams/devices, can read "public" users/devices, but cannot write
-	//     anything public, and cannot read anything private.
+	//     anything public, and cannot read anything private. Members of a group can read the group's devices
+	//     and each other's group-public streams, and write to their downlinks.
 	// - admin:
 	//     Total control: Can read/write anything anywhere. Also has permissions to create new users.
 	//
@@ -39,6 +40,7 @@ var Default = Permissions{
 				PublicAccessLevel:  "none",
 				UserAccessLevel:    "none",
 				SelfAccessLevel:    "none",
+				GroupAccessLevel:   "none",
 			},
 		},
 		"user": &UserRole{
@@ -57,10 +59,17 @@ var Default = Permissions{
 
 			// ... Not bad
 			DeviceRole: DeviceRole{
+				CanShare:           true,
 				PrivateAccessLevel: "none",
 				PublicAccessLevel:  "userpublic",
 				UserAccessLevel:    "userself",
 				SelfAccessLevel:    "userself",
+				GroupAccessLevel:   "groupmember",
+
+				// Users can run large queries, but can't tie up the server indefinitely
+				MaxQueryDatapoints: 50000000,
+				MaxQueryTime:       5 * 60 * 1000,
+				MaxQueryStreams:    50,
 			},
 		},
 		"admin": &UserRole{
@@ -87,10 +96,12 @@ var Default = Permissions{
 				CanCountUsers:      true,
 				CanCountDevices:    true,
 				CanCountStreams:    true,
+				CanShare:           true,
 				PrivateAccessLevel: "fullnp",
 				PublicAccessLevel:  "fullnp",
 				UserAccessLevel:    "fullnp",
 				SelfAccessLevel:    "fullnp",
+				GroupAccessLevel:   "fullnp",
 			},
 		},
 	},
@@ -101,27 +112,39 @@ var Default = Permissions{
 			PublicAccessLevel:  "none",
 			UserAccessLevel:    "none",
 			SelfAccessLevel:    "fulldevice",
+			GroupAccessLevel:   "none",
 		},
 		"reader": &DeviceRole{
 			PrivateAccessLevel: "devicereader",
 			PublicAccessLevel:  "devicereader",
 			UserAccessLevel:    "devicereader",
 			SelfAccessLevel:    "fulldevice",
+			GroupAccessLevel:   "devicereader",
 		},
 		"writer": &DeviceRole{
 			PrivateAccessLevel: "devicewriter",
 			PublicAccessLevel:  "devicewriter",
 			UserAccessLevel:    "devicewriter",
 			SelfAccessLevel:    "fulldevice",
+			GroupAccessLevel:   "devicewriter",
+		},
+		"oauth": &DeviceRole{
+			PrivateAccessLevel: "none",
+			PublicAccessLevel:  "devicereader",
+			UserAccessLevel:    "devicewriter",
+			SelfAccessLevel:    "fulldevice",
+			GroupAccessLevel:   "devicereader",
 		},
 		"user": &DeviceRole{
 			CanCountUsers:      true,
 			CanCountDevices:    true,
 			CanCountStreams:    true,
+			CanShare:           true,
 			PrivateAccessLevel: "fulldownlink",
 			PublicAccessLevel:  "fulldownlink",
 			UserAccessLevel:    "fulldownlink",
 			SelfAccessLevel:    "full",
+			GroupAccessLevel:   "fulldownlink",
 		},
 	},
 
@@ -156,6 +179,21 @@ var Default = Permissions{
 			ReadAccess:  "selfread",
 			WriteAccess: "selfwrite",
 		},
+		"groupmember": &AccessLevel{
+			CanCreateUser:   false,
+			CanCreateDevice: false,
+			CanCreateStream: false,
+			CanDeleteUser:   false,
+			CanDeleteDevice: false,
+			CanDeleteStream: false,
+
+			CanListUsers:   false,
+			CanListDevices: true,
+			CanListStreams: true,
+
+			ReadAccess:  "publicread",
+			WriteAccess: "groupwrite",
+		},
 		"devicereader": &AccessLevel{
 			CanCreateUser:   false,
 			CanCreateDevice: false,
@@ -259,6 +297,8 @@ var Default = Permissions{
 			DeviceUserEditable:              false,
 			DevicePublic:                    true,
 			DeviceRole:                      false,
+			DeviceAllowStreams:              false,
+			DeviceDenyStreams:               false,
 			StreamName:                      true,
 			StreamNickname:                  true,
 			StreamDescription:               true,
@@ -267,6 +307,7 @@ var Default = Permissions{
 			StreamDatatype:                  true,
 			StreamEphemeral:                 true,
 			StreamDownlink:                  true,
+			StreamGroupPublic:               true,
 		},
 		"selfwrite": &RWAccess{
 			CanAccessUser:                   true,
@@ -293,6 +334,8 @@ var Default = Permissions{
 			DeviceUserEditable:              false,
 			DevicePublic:                    true,
 			DeviceRole:                      true,
+			DeviceAllowStreams:              true,
+			DeviceDenyStreams:               true,
 			StreamName:                      false,
 			StreamNickname:                  true,
 			StreamDescription:               true,
@@ -301,6 +344,7 @@ var Default = Permissions{
 			StreamDatatype:                  true,
 			StreamEphemeral:                 true,
 			StreamDownlink:                  true,
+			StreamGroupPublic:               true,
 		},
 		"selfread": &RWAccess{
 			CanAccessUser:                   true,
@@ -327,6 +371,8 @@ var Default = Permissions{
 			DeviceUserEditable:              true,
 			DevicePublic:                    true,
 			DeviceRole:                      true,
+			DeviceAllowStreams:              true,
+			DeviceDenyStreams:               true,
 			StreamName:                      true,
 			StreamNickname:                  true,
 			StreamDescription:               true,
@@ -335,6 +381,7 @@ var Default = Permissions{
 			StreamDatatype:                  true,
 			StreamEphemeral:                 true,
 			StreamDownlink:                  true,
+			StreamGroupPublic:               true,
 		},
 		"deviceread": &RWAccess{
 			CanAccessUser:                   true,
@@ -361,6 +408,8 @@ var Default = Permissions{
 			DeviceUserEditable:              true,
 			DevicePublic:                    true,
 			DeviceRole:                      true,
+			DeviceAllowStreams:              true,
+			DeviceDenyStreams:               true,
 			StreamName:                      true,
 			StreamNickname:                  true,
 			StreamDescription:               true,
@@ -369,6 +418,44 @@ var Default = Permissions{
 			StreamDatatype:                  true,
 			StreamEphemeral:                 true,
 			StreamDownlink:                  true,
+			StreamGroupPublic:               true,
+		},
+		"groupwrite": &RWAccess{
+			CanAccessUser:                   true,
+			CanAccessDevice:                 true,
+			CanAccessStream:                 true,
+			CanAccessNonUserEditableDevices: false,
+			CanAccessStreamData:             false,
+			CanAccessStreamDownlink:         true,
+			UserName:                        false,
+			UserNickname:                    false,
+			UserEmail:                       false,
+			UserDescription:                 false,
+			UserIcon:                        false,
+			UserRole:                        false,
+			UserPublic:                      false,
+			UserPassword:                    false,
+			DeviceName:                      false,
+			DeviceNickname:                  false,
+			DeviceDescription:               false,
+			DeviceIcon:                      false,
+			DeviceAPIKey:                    false,
+			DeviceEnabled:                   false,
+			DeviceIsVisible:                 false,
+			DeviceUserEditable:              false,
+			DevicePublic:                    false,
+			DeviceRole:                      false,
+			DeviceAllowStreams:              false,
+			DeviceDenyStreams:               false,
+			StreamName:                      false,
+			StreamNickname:                  false,
+			StreamDescription:               false,
+			StreamIcon:                      false,
+			StreamSchema:                    false,
+			StreamDatatype:                  false,
+			StreamEphemeral:                 false,
+			StreamDownlink:                  false,
+			StreamGroupPublic:               false,
 		},
 		"devicewrite": &RWAccess{
 			CanAccessUser:                   true,
@@ -395,6 +482,8 @@ var Default = Permissions{
 			DeviceUserEditable:              true,
 			DevicePublic:                    true,
 			DeviceRole:                      false,
+			DeviceAllowStreams:              false,
+			DeviceDenyStreams:               false,
 			StreamName:                      true,
 			StreamNickname:                  true,
 			StreamDescription:               true,
@@ -403,6 +492,7 @@ var Default = Permissions{
 			StreamDatatype:                  true,
 			StreamEphemeral:                 true,
 			StreamDownlink:                  true,
+			StreamGroupPublic:               true,
 		},
 		"fulldevicewrite": &RWAccess{
 			CanAccessUser:                   true,
@@ -429,6 +519,8 @@ var Default = Permissions{
 			DeviceUserEditable:              true,
 			DevicePublic:                    true,
 			DeviceRole:                      false,
+			DeviceAllowStreams:              false,
+			DeviceDenyStreams:               false,
 			StreamName:                      false,
 			StreamNickname:                  true,
 			StreamDescription:               true,
@@ -437,6 +529,7 @@ var Default = Permissions{
 			StreamDatatype:                  true,
 			StreamEphemeral:                 true,
 			StreamDownlink:                  true,
+			StreamGroupPublic:               true,
 		},
 		"fulldownlinkwrite": &RWAccess{
 			CanAccessUser:                   true,
@@ -463,6 +556,8 @@ var Default = Permissions{
 			DeviceUserEditable:              true,
 			DevicePublic:                    true,
 			DeviceRole:                      true,
+			DeviceAllowStreams:              true,
+			DeviceDenyStreams:               true,
 			StreamName:                      true,
 			StreamNickname:                  true,
 			StreamDescription:               true,
@@ -471,6 +566,7 @@ var Default = Permissions{
 			StreamDatatype:                  true,
 			StreamEphemeral:                 true,
 			StreamDownlink:                  true,
+			StreamGroupPublic:               true,
 		},
 	},
 }
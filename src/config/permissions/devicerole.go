@@ -16,10 +16,27 @@ type DeviceRole struct {
 	CanCountDevices bool
 	CanCountStreams bool
 
+	// CanShare allows the device to give other users and devices access to the streams of its user
+	// through grants, and to manage the existing grants
+	CanShare bool `json:"can_share"`
+
 	PrivateAccessLevel string `json:"private_access_level"` // The access level to private users/devices/streams
 	PublicAccessLevel  string `json:"public_access_level"`  // The access level to public users/devices/streams
 	UserAccessLevel    string `json:"user_access_level"`    // The access level to devices/streams that belong to you and your own user
 	SelfAccessLevel    string `json:"self_access_level"`    // The access level to give to streams that belong to querying device and to its own device
+	GroupAccessLevel   string `json:"group_access_level"`   // The access level to the devices/streams of your groups, and to the group-public streams of their members
+
+	// Stream patterns limit the streams whose data the device can read, write and subscribe to, on top of
+	// its access levels. See MatchStreamPath for the format of the patterns.
+	AllowStreams []string `json:"allow_streams"` // If not empty, only the streams matching one of these patterns can be accessed
+	DenyStreams  []string `json:"deny_streams"`  // The streams matching one of these patterns can't be accessed
+
+	// Query budgets limit the resources that a single range, merge or dataset query can use.
+	// A value of 0 is unlimited. Just like access levels, a device's budget is limited by
+	// its owning user's budget.
+	MaxQueryDatapoints int64 `json:"max_query_datapoints"` // The maximum number of datapoints a query can read from the database
+	MaxQueryTime       int64 `json:"max_query_time"`       // The maximum time a query can run in milliseconds
+	MaxQueryStreams    int64 `json:"max_query_streams"`    // The maximum number of streams a query can read
 }
 
 // Validate ensures that the given permissions have all correct values
@@ -40,6 +57,25 @@ func (r *DeviceRole) Validate(p *Permissions) error {
 	if _, err := p.GetAccessLevel(r.SelfAccessLevel); err != nil {
 		return err
 	}
+	if _, err := p.GetAccessLevel(r.GroupAccessLevel); err != nil {
+		return err
+	}
+
+	if err := ValidateStreamPatterns(r.AllowStreams); err != nil {
+		return err
+	}
+	if err := ValidateStreamPatterns(r.DenyStreams); err != nil {
+		return err
+	}
+
+	if r.MaxQueryDatapoints < 0 || r.MaxQueryTime < 0 || r.MaxQueryStreams < 0 {
+		return errors.New("Query budgets can't be negative")
+	}
 
 	return nil
 }
+
+// CanAccessStream returns true if the role's stream patterns permit access to the stream at the given path
+func (r *DeviceRole) CanAccessStream(streampath string) bool {
+	return MatchStreamPath(r.AllowStreams, r.DenyStreams, streampath)
+}
@@ -52,6 +52,34 @@ func TestValidate(t *testing.T) {
 	cfg.UserRoles["user"] = p
 	require.NoError(t, cfg.Validate())
 
+	p.MaxQueryTime = -1
+	require.Error(t, cfg.Validate())
+	p.MaxQueryTime = 0
+	require.NoError(t, cfg.Validate())
+
+	p.AllowStreams = []string{"*/lights"}
+	require.Error(t, cfg.Validate())
+	p.AllowStreams = []string{"*/lights/[a"}
+	require.Error(t, cfg.Validate())
+	p.AllowStreams = []string{"*/lights/*"}
+	require.NoError(t, cfg.Validate())
+
 	delete(cfg.UserRoles, "nobody")
 	require.Error(t, cfg.Validate())
 }
+
+func TestMatchStreamPath(t *testing.T) {
+	require.True(t, MatchStreamPath(nil, nil, "usr/dev/strm"))
+
+	allow := []string{"*/lights/*", "usr/dev/temp*"}
+	require.True(t, MatchStreamPath(allow, nil, "usr/lights/kitchen"))
+	require.True(t, MatchStreamPath(allow, nil, "usr/dev/temperature"))
+	require.False(t, MatchStreamPath(allow, nil, "usr/dev/humidity"))
+	require.False(t, MatchStreamPath(allow, nil, "usr/lightswitch/kitchen"))
+
+	deny := []string{"*/lights/garage"}
+	require.False(t, MatchStreamPath(allow, deny, "usr/lights/garage"))
+	require.True(t, MatchStreamPath(allow, deny, "usr/lights/kitchen"))
+	require.False(t, MatchStreamPath(nil, deny, "usr/lights/garage"))
+	require.True(t, MatchStreamPath(nil, deny, "usr/dev/humidity"))
+}
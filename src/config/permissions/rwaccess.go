@@ -12,8 +12,8 @@ var (
 	// FullRWAccess is the RW permission to give a total administrator - everything is accessible
 	FullRWAccess = RWAccess{true, true, true, true, true,
 		true, true, true, true, true, true, true, true,
-		true, true, true, true, true, true, true, true, true,
-		true, true, true, true, true, true, true, true, true, true, nil}
+		true, true, true, true, true, true, true, true, true, true, true,
+		true, true, true, true, true, true, true, true, true, true, true, nil}
 )
 
 // RWAccess is a struct of boolean permissions given for a certain role.
@@ -56,6 +56,8 @@ type RWAccess struct {
 	DeviceUserEditable bool `json:"device_user_editable"`
 	DevicePublic       bool `json:"device_public"`
 	DeviceRole         bool `json:"device_role"`
+	DeviceAllowStreams bool `json:"device_allow_streams"`
+	DeviceDenyStreams  bool `json:"device_deny_streams"`
 
 	// Access of stream properties
 	StreamName        bool `json:"stream_name"`
@@ -66,6 +68,7 @@ type RWAccess struct {
 	StreamDatatype    bool `json:"stream_datatype"`
 	StreamEphemeral   bool `json:"stream_ephemeral"`
 	StreamDownlink    bool `json:"stream_downlink"`
+	StreamGroupPublic bool `json:"stream_group_public"`
 
 	// Internal: cached map of access levels (used in reflection)
 	cmap map[string]bool
@@ -0,0 +1,45 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package permissions
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// ValidateStreamPatterns ensures that all of the given stream patterns are valid
+func ValidateStreamPatterns(patterns []string) error {
+	for _, p := range patterns {
+		if len(strings.Split(p, "/")) != 3 {
+			return fmt.Errorf("Stream pattern '%s' must be of the form user/device/stream", p)
+		}
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("Invalid stream pattern '%s': %s", p, err.Error())
+		}
+	}
+	return nil
+}
+
+// MatchStreamPath returns true if the stream at the given path passes the allow and deny patterns. The patterns
+// are user/device/stream paths, where each part is matched as in path.Match, so that "*/lights/*" matches all
+// streams of devices named lights. The stream passes if allow is empty or it matches one of the allow patterns,
+// and it does not match any of the deny patterns.
+func MatchStreamPath(allow, deny []string, streampath string) bool {
+	for _, p := range deny {
+		if ok, _ := path.Match(p, streampath); ok {
+			return false
+		}
+	}
+	if len(allow) == 0 {
+		return true
+	}
+	for _, p := range allow {
+		if ok, _ := path.Match(p, streampath); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -64,6 +64,36 @@ func (f *Frontend) Validate(c *Configuration) (err error) {
 		return err
 	}
 
+	if err = f.LoginLimit.Validate(); err != nil {
+		return err
+	}
+
+	if err = f.MQTT.Validate(); err != nil {
+		return err
+	}
+	if f.MQTT.Hostname == "" {
+		f.MQTT.Hostname = f.Hostname
+	}
+	if f.MQTT.Enabled && f.MQTT.TLS && !f.TLS.Enabled {
+		return errors.New("MQTT over TLS needs the frontend's TLS to be set up")
+	}
+
+	if err = f.OAuth.Validate(); err != nil {
+		return err
+	}
+
+	if err = f.Mail.Validate(); err != nil {
+		return err
+	}
+
+	if err = f.Metrics.Validate(); err != nil {
+		return err
+	}
+
+	if err = validateOIDC(f.OIDC); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -95,6 +125,22 @@ func (c *Configuration) Validate() error {
 		}
 	}
 
+	if c.QueryWorkers < 0 {
+		return errors.New("Query workers must be >=0")
+	}
+	if c.QueryPrefetch < 0 {
+		return errors.New("Query prefetch must be >=0")
+	}
+	if c.QueryCacheSize < 0 {
+		return errors.New("Query cache size must be >=0")
+	}
+	if err := c.Audit.Validate(); err != nil {
+		return err
+	}
+	if err := c.Webhooks.Validate(); err != nil {
+		return err
+	}
+
 	// Validate PipeScript
 	if c.PipeScript == nil {
 		c.PipeScript = psconfig.Default()
@@ -107,7 +153,7 @@ func (c *Configuration) Validate() error {
 	}
 
 	// Try loading the permissions
-	_, err := permissions.Load(c.Permissions)
+	perm, err := permissions.Load(c.Permissions)
 	if err != nil {
 		return err
 	}
@@ -128,5 +174,12 @@ func (c *Configuration) Validate() error {
 	if err := c.Frontend.Validate(c); err != nil {
 		return err
 	}
+
+	// The devices of OAuth apps are given the app's role, so it must be one of the device roles
+	for id, client := range c.OAuth.Clients {
+		if _, ok := perm.DeviceRoles[client.Role]; !ok {
+			return fmt.Errorf("OAuth client '%s' has device role '%s', which does not exist", id, client.Role)
+		}
+	}
 	return nil
 }
@@ -0,0 +1,38 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package config
+
+import "errors"
+
+// Webhooks sets up the delivery of the data inserted into streams to the webhooks registered on them.
+// When running several ConnectorDB servers on one database, only one of them should deliver the webhooks.
+type Webhooks struct {
+	Enabled bool `json:"enabled"`
+
+	// A failed delivery is retried up to Retries times, waiting Backoff seconds before the first retry
+	// and doubling the wait with each following retry. Deliveries which still fail are written to the
+	// meta/webhooks stream of the webhook's owner.
+	Retries int   `json:"retries"`
+	Backoff int64 `json:"backoff"`
+
+	Timeout   int64 `json:"timeout"`    // The number of seconds to wait for the webhook's url to respond
+	QueueSize int   `json:"queue_size"` // The number of deliveries queued up for each webhook before they are dropped
+
+	// Webhooks are not delivered to loopback, link-local and private addresses, since users could otherwise
+	// reach the services of the server's own network through them. AllowPrivate lifts this restriction,
+	// which is only safe if all of the users are trusted.
+	AllowPrivate bool `json:"allow_private"`
+}
+
+// Validate ensures that the webhooks are set up correctly
+func (w *Webhooks) Validate() error {
+	if w.Retries < 0 || w.Backoff < 0 {
+		return errors.New("Webhook retries and backoff must be >=0")
+	}
+	if w.Timeout < 1 || w.QueueSize < 1 {
+		return errors.New("Webhook timeout and queue size must be >=1")
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+
+Package audit records the accesses of devices to the data of streams, so that users can see who read,
+wrote and subscribed to their data, and which of the accesses were denied.
+**/
+package audit
+
+import (
+	"config"
+	"connectordb/operator"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// The operations that are audited
+const (
+	Length      = "length"
+	TimeToIndex = "timetoindex"
+	Read        = "read"
+	Insert      = "insert"
+	Subscribe   = "subscribe"
+)
+
+// ResultOK is the result of an access which succeeded
+const ResultOK = "ok"
+
+// Entry is a single access to the data of a stream
+type Entry struct {
+	Timestamp float64 `json:"t"`
+	Accessor  string  `json:"accessor"`        // The path of the device which accessed the stream
+	Token     string  `json:"token,omitempty"` // The name of the token that the device logged in with, if any
+	Stream    string  `json:"stream"`          // The path of the accessed stream
+	Substream string  `json:"substream"`       // The accessed substream ("" or "downlink")
+	Operation string  `json:"operation"`       // One of the audited operations
+	Range     string  `json:"range,omitempty"` // The range of data that was read, or the number of datapoints inserted
+	Result    string  `json:"result"`          // ResultOK, or the error that the access was denied with
+}
+
+// Owner returns the name of the user who owns the accessed stream
+func (e *Entry) Owner() string {
+	return strings.Split(e.Stream, "/")[0]
+}
+
+// Matches returns true if the entry is an access to the data of the given user within the time range (t1, t2].
+// A t2 of 0 means that the range has no end.
+func (e *Entry) Matches(owner string, t1, t2 float64) bool {
+	return e.Owner() == owner && e.Timestamp > t1 && (t2 <= 0 || e.Timestamp <= t2)
+}
+
+// TimeRange describes a read of the data in the time range (t1, t2] in an entry
+func TimeRange(t1, t2 float64, limit int64) string {
+	return fmt.Sprintf("t=(%v,%v] limit=%d", t1, t2, limit)
+}
+
+// ShiftedRange describes a read of the data in a time range shifted by an index in an entry
+func ShiftedRange(t1, t2 float64, shift, limit int64) string {
+	return fmt.Sprintf("t=(%v,%v] shift=%d limit=%d", t1, t2, shift, limit)
+}
+
+// IndexRange describes a read of the data in the index range [i1, i2) in an entry
+func IndexRange(i1, i2 int64) string {
+	return fmt.Sprintf("i=[%d,%d)", i1, i2)
+}
+
+// Sink is where the audit entries are written
+type Sink interface {
+	// Write records the entry
+	Write(e *Entry) error
+
+	// Query returns the entries of accesses to the given user's streams in the time range (t1, t2].
+	// A t2 of 0 means that the range has no end, and a limit of 0 returns all of the entries.
+	Query(owner string, t1, t2 float64, limit int64) ([]*Entry, error)
+
+	// Close releases the resources of the sink
+	Close() error
+}
+
+// Open sets up the sink given in the configuration. The stream sink writes through the given
+// administrative operator. It returns nil if the audit log is disabled.
+func Open(c *config.Audit, o operator.PathOperator) (Sink, error) {
+	if !c.Enabled {
+		return nil, nil
+	}
+	switch c.Sink {
+	case "stream":
+		return NewStreamSink(o), nil
+	case "file":
+		return OpenFileSink(c.File, c.MaxFileSize, c.MaxFiles)
+	}
+	return nil, errors.New("Unrecognized audit sink " + c.Sink)
+}
@@ -0,0 +1,159 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// ErrClosed is returned when writing to a closed audit log
+var ErrClosed = errors.New("The audit log is closed")
+
+// FileSink writes the entries to a file, one json entry per line. Once the file grows past its maximum size,
+// it is renamed to file.1 (with older files shifted to file.2 and so on), and a new file is started.
+type FileSink struct {
+	sync.Mutex
+
+	Filename string
+	MaxSize  int64 // The size in bytes at which the file is rotated
+	MaxFiles int   // The number of old files to keep
+
+	file *os.File
+	size int64
+}
+
+// OpenFileSink opens the given file for appending audit entries
+func OpenFileSink(filename string, maxsize int64, maxfiles int) (*FileSink, error) {
+	f := &FileSink{Filename: filename, MaxSize: maxsize, MaxFiles: maxfiles}
+	return f, f.open()
+}
+
+func (f *FileSink) open() error {
+	file, err := os.OpenFile(f.Filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("Could not open audit log %s: %s", f.Filename, err.Error())
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+// oldFile returns the name of the i-th rotated file
+func (f *FileSink) oldFile(i int) string {
+	return fmt.Sprintf("%s.%d", f.Filename, i)
+}
+
+// rotate moves the current file to file.1, removing the oldest file. If the file can't be moved,
+// the entries keep being appended to it.
+func (f *FileSink) rotate() error {
+	f.file.Close()
+	f.file = nil
+
+	var err error
+	if f.MaxFiles > 0 {
+		os.Remove(f.oldFile(f.MaxFiles))
+		for i := f.MaxFiles - 1; i > 0; i-- {
+			os.Rename(f.oldFile(i), f.oldFile(i+1))
+		}
+		err = os.Rename(f.Filename, f.oldFile(1))
+	} else {
+		err = os.Remove(f.Filename)
+	}
+	if err != nil {
+		log.WithField("file", f.Filename).Error("Could not rotate audit log: ", err)
+	}
+	return f.open()
+}
+
+// Write appends the entry to the file, rotating the file if it is full
+func (f *FileSink) Write(e *Entry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	f.Lock()
+	defer f.Unlock()
+	if f.file == nil {
+		return ErrClosed
+	}
+	if f.size > 0 && f.size+int64(len(b)) > f.MaxSize {
+		if err = f.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := f.file.Write(b)
+	f.size += int64(n)
+	return err
+}
+
+// Query reads the entries from the current and rotated files, oldest first
+func (f *FileSink) Query(owner string, t1, t2 float64, limit int64) ([]*Entry, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	entries := []*Entry{}
+	for i := f.MaxFiles; i >= 0; i-- {
+		filename := f.Filename
+		if i > 0 {
+			filename = f.oldFile(i)
+		}
+		file, err := os.Open(filename)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			var e Entry
+			if err = json.Unmarshal(scanner.Bytes(), &e); err != nil {
+				// A partially written line is skipped, since the rest of the log is still useful
+				log.WithField("file", filename).Warn("Skipping invalid audit entry: ", err)
+				continue
+			}
+			if e.Matches(owner, t1, t2) {
+				entries = append(entries, &e)
+				if limit > 0 && int64(len(entries)) >= limit {
+					file.Close()
+					return entries, nil
+				}
+			}
+		}
+		err = scanner.Err()
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+// Close closes the file
+func (f *FileSink) Close() error {
+	f.Lock()
+	defer f.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
@@ -0,0 +1,72 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package audit
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "audit.log")
+
+	f, err := OpenFileSink(filename, 400, 2)
+	require.NoError(t, err)
+
+	for i := 1; i <= 10; i++ {
+		owner := "usr"
+		if i%2 == 0 {
+			owner = "other"
+		}
+		require.NoError(t, f.Write(&Entry{Timestamp: float64(i), Accessor: "usr/dev", Stream: owner + "/dev/strm", Operation: Read, Result: ResultOK}))
+	}
+
+	// The file was rotated, and only two old files are kept
+	_, err = os.Stat(filename + ".1")
+	require.NoError(t, err)
+	_, err = os.Stat(filename + ".2")
+	require.NoError(t, err)
+	_, err = os.Stat(filename + ".3")
+	require.True(t, os.IsNotExist(err))
+
+	entries, err := f.Query("usr", 0, 0, 0)
+	require.NoError(t, err)
+	require.NotEmpty(t, entries)
+	for i, e := range entries {
+		require.Equal(t, "usr", e.Owner())
+		if i > 0 {
+			require.True(t, e.Timestamp > entries[i-1].Timestamp)
+		}
+	}
+	require.Equal(t, 9.0, entries[len(entries)-1].Timestamp)
+
+	entries, err = f.Query("other", 5, 8, 0)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, 6.0, entries[0].Timestamp)
+	require.Equal(t, 8.0, entries[1].Timestamp)
+
+	entries, err = f.Query("other", 5, 0, 1)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	require.NoError(t, f.Close())
+	require.Equal(t, ErrClosed, f.Write(&Entry{Stream: "usr/dev/strm"}))
+
+	// Reopening the sink keeps the existing entries
+	f, err = OpenFileSink(filename, 400, 2)
+	require.NoError(t, err)
+	entries, err = f.Query("other", 9, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.NoError(t, f.Close())
+}
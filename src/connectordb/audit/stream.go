@@ -0,0 +1,93 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package audit
+
+import (
+	"connectordb/datastream"
+	"connectordb/operator"
+	"connectordb/users"
+	"encoding/json"
+)
+
+// StreamName is the path of the audit stream within the user, which holds the accesses to the user's streams
+const StreamName = "meta/audit"
+
+// StreamSink writes each entry to the audit stream of the user who owns the accessed stream,
+// so that users can read the accesses to their data just like any other stream
+type StreamSink struct {
+	o operator.PathOperator
+}
+
+// NewStreamSink creates a sink which writes the audit streams through the given administrative operator
+func NewStreamSink(o operator.PathOperator) *StreamSink {
+	return &StreamSink{o}
+}
+
+// Write inserts the entry into the audit stream of the owner, creating the stream if it does not exist yet
+func (s *StreamSink) Write(e *Entry) error {
+	dp := datastream.Datapoint{
+		Timestamp: e.Timestamp,
+		Data: map[string]string{
+			"accessor":  e.Accessor,
+			"token":     e.Token,
+			"stream":    e.Stream,
+			"substream": e.Substream,
+			"operation": e.Operation,
+			"range":     e.Range,
+			"result":    e.Result,
+		},
+	}
+	streampath := e.Owner() + "/" + StreamName
+	dpa := datastream.DatapointArray{dp}
+
+	if err := s.o.InsertStream(streampath, dpa, true); err == nil {
+		return nil
+	}
+	// The stream is only created once the user's data is first accessed
+	if err := s.o.CreateStream(streampath, &users.StreamMaker{Stream: users.Stream{
+		Description: "A log of all reads, writes and subscriptions to the data of this user's streams",
+		Schema:      `{"type": "object", "properties": {"accessor": {"type": "string"},"stream": {"type": "string"},"operation": {"type": "string"},"result": {"type": "string"}},"required": ["accessor","stream","operation","result"]}`,
+		Icon:        "material:visibility",
+	}}); err != nil {
+		return err
+	}
+	return s.o.InsertStream(streampath, dpa, true)
+}
+
+// Query reads the entries from the owner's audit stream
+func (s *StreamSink) Query(owner string, t1, t2 float64, limit int64) ([]*Entry, error) {
+	entries := []*Entry{}
+	dr, err := s.o.GetStreamTimeRange(owner+"/"+StreamName, t1, t2, limit, "")
+	if err == users.ErrStreamNotFound {
+		// Nobody accessed the user's data yet
+		return entries, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer dr.Close()
+
+	dp, err := dr.Next()
+	for dp != nil && err == nil {
+		var e Entry
+		var b []byte
+		if b, err = json.Marshal(dp.Data); err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal(b, &e); err != nil {
+			return nil, err
+		}
+		e.Timestamp = dp.Timestamp
+		entries = append(entries, &e)
+
+		dp, err = dr.Next()
+	}
+	return entries, err
+}
+
+// Close does nothing, since the sink writes through the database
+func (s *StreamSink) Close() error {
+	return nil
+}
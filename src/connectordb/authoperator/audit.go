@@ -0,0 +1,139 @@
+package authoperator
+
+import (
+	"connectordb/audit"
+	"connectordb/authoperator/permissions"
+	"errors"
+	"strings"
+	"time"
+	"util"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// ErrAuditDisabled is returned when reading the audit log while it is disabled
+var ErrAuditDisabled = errors.New("The audit log is disabled")
+
+// SetAuditor sets the sink of the audit log, to which the operator's accesses to the data of streams are
+// written. A nil sink disables the audit log.
+func (a *AuthOperator) SetAuditor(s audit.Sink) {
+	a.auditor = s
+}
+
+// audit writes the access to the data of the given stream to the audit log. The result of the access
+// is the error that it was denied with, or nil if it was permitted.
+func (a *AuthOperator) audit(streamID int64, substream, operation, datarange string, err error) {
+	if a.auditor == nil {
+		return
+	}
+	streampath, perr := a.streamPath(streamID)
+	if perr != nil {
+		// The stream doesn't exist, so there is no user whose data was accessed
+		return
+	}
+	if strings.HasSuffix(streampath, "/"+audit.StreamName) && strings.Count(streampath, "/") == 2 {
+		// Reading the audit stream is not audited, since otherwise the log would grow each time it is read
+		return
+	}
+
+	e := &audit.Entry{
+		Timestamp: float64(time.Now().UnixNano()) * 1e-9,
+		Accessor:  a.Name(),
+		Stream:    streampath,
+		Substream: substream,
+		Operation: operation,
+		Range:     datarange,
+		Result:    audit.ResultOK,
+	}
+	if a.token != nil {
+		e.Token = a.token.Name
+	}
+	if err != nil {
+		e.Result = err.Error()
+	}
+	if err = a.auditor.Write(e); err != nil {
+		log.WithFields(log.Fields{"stream": streampath, "o": a.Name()}).Error("Audit log write failed: ", err)
+	}
+}
+
+// AuditStreamRead writes a read of the data of the given stream to the audit log. It records the reads
+// whose results come from the query cache, since these are returned without reading the stream.
+func (a *AuthOperator) AuditStreamRead(streampath, datarange string) {
+	if a.auditor == nil {
+		return
+	}
+	_, _, streampath, _, substream, err := util.SplitStreamPath(streampath)
+	if err != nil {
+		return
+	}
+	s, err := a.Operator.ReadStream(streampath)
+	if err != nil {
+		return
+	}
+	a.audit(s.StreamID, substream, audit.Read, datarange, nil)
+}
+
+// errorIfAuditStreamDenied returns an error if the stream is the audit stream of a user, and the operator is not
+// that user logged in with its own device. The audit stream is kept out of the permissions of roles and grants:
+// only its user can read it, and nothing other than the audit log can write to it, so that entries can't be forged.
+func (a *AuthOperator) errorIfAuditStreamDenied(streamID int64, write bool) error {
+	s, err := a.Operator.ReadStreamByID(streamID)
+	if err != nil {
+		return permissions.ErrNoAccess
+	}
+	if !strings.HasSuffix(audit.StreamName, "/"+s.Name) {
+		return nil
+	}
+	dev, err := a.Operator.ReadDeviceByID(s.DeviceID)
+	if err != nil {
+		return permissions.ErrNoAccess
+	}
+	if dev.Name+"/"+s.Name != audit.StreamName {
+		return nil
+	}
+	if write {
+		return permissions.ErrNoAccess
+	}
+	return a.errorIfNotAuditReader(dev.UserID)
+}
+
+// errorIfNotAuditReader returns an error unless the operator is the user device of the given user,
+// logged in without a token. Only the user itself can read the accesses to its data.
+func (a *AuthOperator) errorIfNotAuditReader(userID int64) error {
+	if err := a.errorIfToken(); err != nil {
+		return err
+	}
+	u, d, err := a.UserAndDevice()
+	if err != nil {
+		return err
+	}
+	if u.UserID != userID || d.Name != "user" {
+		return permissions.ErrNoAccess
+	}
+	return nil
+}
+
+// ReadUserAuditByID reads the accesses to the data of the user's streams in the time range (t1, t2] from the
+// audit log. Only the user itself can read its audit log.
+func (a *AuthOperator) ReadUserAuditByID(userID int64, t1, t2 float64, limit int64) ([]*audit.Entry, error) {
+	if a.auditor == nil {
+		return nil, ErrAuditDisabled
+	}
+	if err := a.errorIfNotAuditReader(userID); err != nil {
+		return nil, err
+	}
+	u, err := a.User()
+	if err != nil {
+		return nil, err
+	}
+	return a.auditor.Query(u.Name, t1, t2, limit)
+}
+
+// ReadUserAudit is the same as ReadUserAuditByID, but it is given the user's name
+func (a *AuthOperator) ReadUserAudit(username string, t1, t2 float64, limit int64) ([]*audit.Entry, error) {
+	u, err := a.Operator.ReadUser(username)
+	if err != nil {
+		return nil, permissions.ErrNoAccess
+	}
+	return a.ReadUserAuditByID(u.UserID, t1, t2, limit)
+}
@@ -0,0 +1,111 @@
+package authoperator_test
+
+import (
+	"connectordb/audit"
+	"connectordb/authoperator"
+	"connectordb/datastream"
+	"connectordb/query"
+	"connectordb/users"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthAudit(t *testing.T) {
+	db.Clear()
+	require.NoError(t, db.CreateUser(&users.UserMaker{User: users.User{Name: "tst", Email: "root@localhost", Password: "mypass", Role: "user", Public: false}}))
+	require.NoError(t, db.CreateUser(&users.UserMaker{User: users.User{Name: "tst2", Email: "root2@localhost", Password: "mypass", Role: "user", Public: false}}))
+	require.NoError(t, db.CreateDevice("tst/dev", &users.DeviceMaker{Device: users.Device{Role: "user"}}))
+	require.NoError(t, db.CreateDevice("tst2/dev", &users.DeviceMaker{Device: users.Device{Role: "user"}}))
+	require.NoError(t, db.CreateStream("tst/dev/strm", &users.StreamMaker{Stream: users.Stream{Schema: `{"type": "integer"}`}}))
+
+	o, err := db.AsDevice("tst/dev")
+	require.NoError(t, err)
+	o2, err := db.AsDevice("tst2/dev")
+	require.NoError(t, err)
+	ou, err := db.AsUser("tst")
+	require.NoError(t, err)
+	ou2, err := db.AsUser("tst2")
+	require.NoError(t, err)
+
+	_, err = ou.ReadUserAudit("tst", 0, 0, 0)
+	require.Equal(t, authoperator.ErrAuditDisabled, err)
+
+	sink := audit.NewStreamSink(db)
+	for _, ao := range []*authoperator.AuthOperator{o, o2, ou, ou2} {
+		ao.SetAuditor(sink)
+	}
+
+	require.NoError(t, o.InsertStream("tst/dev/strm", datastream.DatapointArray{datastream.Datapoint{Timestamp: 1, Data: 1}}, false))
+	dr, err := o.GetStreamIndexRange("tst/dev/strm", 0, 1, "")
+	require.NoError(t, err)
+	dr.Close()
+	_, err = o2.GetStreamTimeRange("tst/dev/strm", 0, 0, 0, "")
+	require.Error(t, err)
+
+	entries, err := ou.ReadUserAudit("tst", 0, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+
+	require.Equal(t, "tst/dev", entries[0].Accessor)
+	require.Equal(t, "tst/dev/strm", entries[0].Stream)
+	require.Equal(t, audit.Insert, entries[0].Operation)
+	require.Equal(t, audit.ResultOK, entries[0].Result)
+
+	require.Equal(t, audit.Read, entries[1].Operation)
+	require.Equal(t, "i=[0,1)", entries[1].Range)
+	require.Equal(t, audit.ResultOK, entries[1].Result)
+
+	// The denied access is recorded along with its error
+	require.Equal(t, "tst2/dev", entries[2].Accessor)
+	require.Equal(t, audit.Read, entries[2].Operation)
+	require.NotEqual(t, audit.ResultOK, entries[2].Result)
+
+	// Reading the audit stream is not itself audited
+	dr, err = ou.GetStreamTimeRange("tst/"+audit.StreamName, 0, 0, 0, "")
+	require.NoError(t, err)
+	dr.Close()
+	entries, err = ou.ReadUserAudit("tst", 0, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+
+	// Only the user can read its audit log, and not through its other devices
+	_, err = o.ReadUserAudit("tst", 0, 0, 0)
+	require.Error(t, err)
+	_, err = o.GetStreamTimeRange("tst/"+audit.StreamName, 0, 0, 0, "")
+	require.Error(t, err)
+	_, err = o.LengthStream("tst/" + audit.StreamName)
+	require.Error(t, err)
+	_, err = ou2.ReadUserAudit("tst", 0, 0, 0)
+	require.Error(t, err)
+	entries, err = ou2.ReadUserAudit("tst2", 0, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, entries, 0)
+
+	// Entries can't be forged, even by the user
+	forged := datastream.DatapointArray{datastream.Datapoint{Timestamp: 2, Data: map[string]string{"accessor": "tst2/dev", "stream": "tst/dev/strm", "operation": "read", "result": "ok"}}}
+	require.Error(t, ou.InsertStream("tst/"+audit.StreamName, forged, false))
+	require.Error(t, o.InsertStream("tst/"+audit.StreamName, forged, false))
+	entries, err = ou.ReadUserAudit("tst", 0, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+
+	// A read whose result comes from the query cache is audited
+	co := query.NewCachedOperator(o, query.NewResultCache(1024*1024))
+	for i := 0; i < 2; i++ {
+		dr, err = co.GetStreamIndexRange("tst/dev/strm", 0, 1, "")
+		require.NoError(t, err)
+		dp, err := dr.Next()
+		for dp != nil && err == nil {
+			dp, err = dr.Next()
+		}
+		require.NoError(t, err)
+		dr.Close()
+	}
+	entries, err = ou.ReadUserAudit("tst", 0, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, entries, 7, "The length checks and reads of both queries are recorded")
+	require.Equal(t, audit.Length, entries[5].Operation)
+	require.Equal(t, audit.Read, entries[6].Operation)
+	require.Equal(t, "i=[0,1)", entries[6].Range)
+}
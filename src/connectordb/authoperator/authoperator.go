@@ -1,6 +1,7 @@
 package authoperator
 
 import (
+	"connectordb/audit"
 	"connectordb/authoperator/permissions"
 	"connectordb/operator"
 	"connectordb/pathwrapper"
@@ -15,8 +16,12 @@ type AuthOperator struct {
 	Operator operator.PathOperator
 	pathwrapper.Wrapper
 
-	devicePath string // The string name of this operator
-	deviceID   int64  // The ID of this device
+	devicePath string       // The string name of this operator
+	deviceID   int64        // The ID of this device
+	token      *users.Token // The token used to log in. It is nil if the device logged in with its API key
+	auditor    audit.Sink   // The sink of the audit log of accesses to the data of streams. It is nil if auditing is disabled
+
+	groupRoles *groupRoleCache // The group roles that access checks read
 }
 
 // NewAuthOperator creates a new authentication operator based upon the given DeviceID
@@ -30,18 +35,39 @@ func NewAuthOperator(op operator.PathOperator, deviceID int64) (*AuthOperator, e
 		return nil, err
 	}
 
-	ao := &AuthOperator{op, pathwrapper.Wrapper{}, usr.Name + "/" + dev.Name, deviceID}
+	ao := &AuthOperator{op, pathwrapper.Wrapper{}, usr.Name + "/" + dev.Name, deviceID, nil, nil, newGroupRoleCache()}
 	ao.Wrapper = pathwrapper.Wrap(ao)
 	return ao, nil
 }
 
+// NewTokenAuthOperator creates an authentication operator for the device of the given token,
+// which is limited to the access that the token gives
+func NewTokenAuthOperator(op operator.PathOperator, token *users.Token) (*AuthOperator, error) {
+	ao, err := NewAuthOperator(op, token.DeviceID)
+	if err != nil {
+		return nil, err
+	}
+	ao.token = token
+	return ao, nil
+}
+
 // NewNobody logs in as a "nobody"
 func NewNobody(op operator.PathOperator) *AuthOperator {
-	ao := &AuthOperator{op, pathwrapper.Wrapper{}, "nobody", -2}
+	ao := &AuthOperator{op, pathwrapper.Wrapper{}, "nobody", -2, nil, nil, newGroupRoleCache()}
 	ao.Wrapper = pathwrapper.Wrap(ao)
 	return ao
 }
 
+// WithOperator returns a copy of the operator which is logged in as the same device, but runs its operations
+// through op, such as a transaction of the database
+func (a *AuthOperator) WithOperator(op operator.PathOperator) *AuthOperator {
+	ao := *a
+	ao.Operator = op
+	ao.Wrapper = pathwrapper.Wrap(&ao)
+	ao.groupRoles = newGroupRoleCache()
+	return &ao
+}
+
 // Name is the path to the device underlying the operator
 func (a *AuthOperator) Name() string {
 	return a.devicePath
@@ -98,18 +124,45 @@ func (a *AuthOperator) UserAndDevice() (*users.User, *users.Device, error) {
 
 // getAccessLevels gets the access levels for the current user/device combo
 func (a *AuthOperator) getAccessLevels(userID int64, ispublic, issself bool) (*pconfig.Permissions, *users.User, *users.Device, *pconfig.AccessLevel, *pconfig.AccessLevel, error) {
+	return a.getGroupAccessLevels(userID, ispublic, issself, false)
+}
+
+// getAccountAccessLevels is same as getAccessLevels for a user who is never self, but it leaves out the access that
+// the operator has through a group. It is used for the user's account: the owners and admins of a group manage the
+// group's objects, but they must not be able to take over the user behind the group by changing its password or email,
+// or to delete it other than by deleting the group.
+func (a *AuthOperator) getAccountAccessLevels(userID int64, ispublic bool) (*pconfig.Permissions, *pconfig.AccessLevel, *pconfig.AccessLevel, error) {
+	u, d, err := a.UserAndDevice()
+	if err != nil {
+		return nil, nil, nil, permissions.ErrNoAccess
+	}
+	perm := pconfig.Get()
+
+	up, dp := permissions.GetAccessLevels(perm, u, d, userID, ispublic, false, "")
+	return perm, up, dp, nil
+}
+
+// getGroupAccessLevels is same as getAccessLevels, but if grouppublic is true, the access levels are those of
+// group-public objects, which the members of the owner's groups can access
+func (a *AuthOperator) getGroupAccessLevels(userID int64, ispublic, issself, grouppublic bool) (*pconfig.Permissions, *users.User, *users.Device, *pconfig.AccessLevel, *pconfig.AccessLevel, error) {
 	u, d, err := a.UserAndDevice()
 	if err != nil {
 		return nil, nil, nil, nil, nil, permissions.ErrNoAccess
 	}
 	perm := pconfig.Get()
 
-	up, dp := permissions.GetAccessLevels(perm, u, d, userID, ispublic, issself)
+	up, dp := permissions.GetAccessLevels(perm, u, d, userID, ispublic, issself, a.groupRole(u, userID, grouppublic))
 	return perm, u, d, up, dp, nil
 }
 
 // getDeviceAccessLevels is same as getAccessLevels, but it is given a deviceID
 func (a *AuthOperator) getDeviceAccessLevels(deviceID int64) (*pconfig.Permissions, *users.Device, *users.User, *users.Device, *pconfig.AccessLevel, *pconfig.AccessLevel, error) {
+	return a.getStreamDeviceAccessLevels(deviceID, false)
+}
+
+// getStreamDeviceAccessLevels is same as getDeviceAccessLevels, but it is used for the device's streams, which
+// the members of the owner's groups can access if the stream is group-public
+func (a *AuthOperator) getStreamDeviceAccessLevels(deviceID int64, grouppublic bool) (*pconfig.Permissions, *users.Device, *users.User, *users.Device, *pconfig.AccessLevel, *pconfig.AccessLevel, error) {
 	selfuser, selfdevice, err := a.UserAndDevice()
 	if err != nil {
 		return nil, nil, nil, nil, nil, nil, err
@@ -121,7 +174,7 @@ func (a *AuthOperator) getDeviceAccessLevels(deviceID int64) (*pconfig.Permissio
 	}
 
 	perm := pconfig.Get()
-	up, dp := permissions.GetAccessLevels(perm, selfuser, selfdevice, dev.UserID, dev.Public, selfdevice.DeviceID == dev.DeviceID)
+	up, dp := permissions.GetAccessLevels(perm, selfuser, selfdevice, dev.UserID, dev.Public, selfdevice.DeviceID == dev.DeviceID, a.groupRole(selfuser, dev.UserID, grouppublic))
 
 	return perm, dev, selfuser, selfdevice, up, dp, nil
 }
@@ -113,6 +113,9 @@ func (a *AuthOperator) DeviceMaker() (*users.DeviceMaker, error) {
 
 // CreateDeviceByUserID attempts to create a device for the given user
 func (a *AuthOperator) CreateDeviceByUserID(dm *users.DeviceMaker) error {
+	if err := a.errorIfToken(); err != nil {
+		return err
+	}
 	u, err := a.Operator.ReadUserByID(dm.UserID)
 	if err != nil {
 		return permissions.ErrNoAccess
@@ -140,6 +143,10 @@ func (a *AuthOperator) ReadDeviceByID(deviceID int64) (*users.Device, error) {
 	if err != nil {
 		return nil, err
 	}
+	if a.token != nil {
+		// A token can't be used to get the API key, which has all of the device's rights
+		dev.APIKey = ""
+	}
 
 	return dev, nil
 }
@@ -154,7 +161,11 @@ func (a *AuthOperator) ReadDeviceToMap(devpath string) (map[string]interface{},
 	if err != nil {
 		return nil, err
 	}
-	return permissions.ReadObjectToMap(perm, ua, da, "device", dev)
+	m, err := permissions.ReadObjectToMap(perm, ua, da, "device", dev)
+	if err == nil && a.token != nil {
+		delete(m, "apikey")
+	}
+	return m, err
 }
 
 // ReadDeviceByUserID reads the given device by its name and user ID
@@ -169,7 +180,10 @@ func (a *AuthOperator) ReadDeviceByUserID(userID int64, devicename string) (*use
 
 // UpdateDeviceByID updates the device using its ID
 func (a *AuthOperator) UpdateDeviceByID(deviceID int64, updates map[string]interface{}) error {
-	perm, _, _, _, ua, da, err := a.getDeviceAccessLevels(deviceID)
+	if err := a.errorIfToken(); err != nil {
+		return err
+	}
+	perm, _, _, selfdevice, ua, da, err := a.getDeviceAccessLevels(deviceID)
 	if err != nil {
 		return err
 	}
@@ -178,11 +192,23 @@ func (a *AuthOperator) UpdateDeviceByID(deviceID int64, updates map[string]inter
 	if err != nil {
 		return err
 	}
+
+	// The stream patterns limit the device, so it can't change its own
+	if selfdevice.DeviceID == deviceID {
+		_, allow := updates["allow_streams"]
+		_, deny := updates["deny_streams"]
+		if allow || deny {
+			return permissions.ErrNoAccess
+		}
+	}
 	return a.Operator.UpdateDeviceByID(deviceID, updates)
 }
 
 // DeleteDeviceByID removes a device based upon its ID
 func (a *AuthOperator) DeleteDeviceByID(deviceID int64) error {
+	if err := a.errorIfToken(); err != nil {
+		return err
+	}
 	_, _, _, _, ua, da, err := a.getDeviceAccessLevels(deviceID)
 	if err != nil {
 		return err
@@ -0,0 +1,157 @@
+package authoperator
+
+import (
+	"connectordb/authoperator/permissions"
+	"connectordb/users"
+	"errors"
+
+	pconfig "config/permissions"
+)
+
+// ErrNoShare is returned when the logged in device is not permitted to manage grants
+var ErrNoShare = errors.New("Don't have permissions necessary to share streams")
+
+// errorIfCantShare ensures that the current device can manage the grants given on the user's own user, devices and streams
+func (a *AuthOperator) errorIfCantShare(ownerID int64) error {
+	if err := a.errorIfToken(); err != nil {
+		return err
+	}
+	perm := pconfig.Get()
+	usr, dev, err := a.UserAndDevice()
+	if err != nil {
+		return err
+	}
+	if !permissions.GetUserRole(perm, usr).CanShare || !permissions.GetDeviceRole(perm, dev).CanShare {
+		return ErrNoShare
+	}
+	if usr.UserID != ownerID {
+		return permissions.ErrNoAccess
+	}
+	return nil
+}
+
+// grantOwner returns the ID of the user which owns the grant's target
+func (a *AuthOperator) grantOwner(g *users.Grant) (int64, error) {
+	deviceID := g.DeviceID
+	if g.StreamID > 0 {
+		s, err := a.Operator.ReadStreamByID(g.StreamID)
+		if err != nil {
+			return 0, permissions.ErrNoAccess
+		}
+		deviceID = s.DeviceID
+	}
+	if deviceID > 0 {
+		dev, err := a.Operator.ReadDeviceByID(deviceID)
+		if err != nil {
+			return 0, permissions.ErrNoAccess
+		}
+		return dev.UserID, nil
+	}
+	return g.UserID, nil
+}
+
+// CreateGrantByID gives the grantee access to the grant's target, which must belong to the logged in user
+func (a *AuthOperator) CreateGrantByID(g *users.Grant) error {
+	ownerID, err := a.grantOwner(g)
+	if err != nil {
+		return err
+	}
+	if err = a.errorIfCantShare(ownerID); err != nil {
+		return err
+	}
+	return a.Operator.CreateGrantByID(g)
+}
+
+// ReadGrantByID reads the given grant, if it was given on the logged in user
+func (a *AuthOperator) ReadGrantByID(grantID int64) (*users.Grant, error) {
+	g, err := a.Operator.ReadGrantByID(grantID)
+	if err != nil {
+		return nil, err
+	}
+	if err = a.errorIfCantShare(g.OwnerID); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// ReadAllGrantsByUserID reads all of the grants given on the logged in user
+func (a *AuthOperator) ReadAllGrantsByUserID(userID int64) ([]*users.Grant, error) {
+	if err := a.errorIfCantShare(userID); err != nil {
+		return nil, err
+	}
+	return a.Operator.ReadAllGrantsByUserID(userID)
+}
+
+// ReadStreamGrantsByID reads the grants which give the logged in user or device access to the stream.
+// A device can't read the grants of other users and devices.
+func (a *AuthOperator) ReadStreamGrantsByID(granteeUserID, granteeDeviceID, streamID int64) ([]*users.Grant, error) {
+	u, d, err := a.UserAndDevice()
+	if err != nil {
+		return nil, err
+	}
+	if granteeUserID != u.UserID || granteeDeviceID != d.DeviceID {
+		return nil, permissions.ErrNoAccess
+	}
+	return a.Operator.ReadStreamGrantsByID(granteeUserID, granteeDeviceID, streamID)
+}
+
+// DeleteGrantByID removes the given grant, if it was given on the logged in user
+func (a *AuthOperator) DeleteGrantByID(grantID int64) error {
+	if _, err := a.ReadGrantByID(grantID); err != nil {
+		return err
+	}
+	return a.Operator.DeleteGrantByID(grantID)
+}
+
+// hasGrant returns true if a grant that satisfies allowed gives the logged in device access to the stream.
+// Grants to the device's user only apply if the device can access the data of its own user's streams,
+// in the same way that a device's access levels go up to its user's access levels.
+func (a *AuthOperator) hasGrant(streamID int64, write bool, allowed func(*users.Grant) bool) bool {
+	u, d, err := a.UserAndDevice()
+	if err != nil || d.DeviceID < 0 {
+		// The nobody operator can't be given grants
+		return false
+	}
+
+	perm := pconfig.Get()
+	_, da := permissions.GetAccessLevels(perm, u, d, u.UserID, false, false, "")
+	rw := permissions.GetReadAccess(perm, da)
+	if write {
+		rw = permissions.GetWriteAccess(perm, da)
+	}
+	granteeUserID := u.UserID
+	if !rw.CanAccessStreamData {
+		granteeUserID = 0
+	}
+
+	grants, err := a.Operator.ReadStreamGrantsByID(granteeUserID, d.DeviceID, streamID)
+	if err != nil {
+		return false
+	}
+	for _, g := range grants {
+		if allowed(g) {
+			return true
+		}
+	}
+	return false
+}
+
+// canRead is used with hasGrant to find grants giving read access
+func canRead(g *users.Grant) bool {
+	return g.Read
+}
+
+// canWrite is used with hasGrant to find grants giving write access
+func canWrite(g *users.Grant) bool {
+	return g.Write
+}
+
+// canSubscribe is used with hasGrant to find grants giving subscribe access
+func canSubscribe(g *users.Grant) bool {
+	return g.Subscribe
+}
+
+// isShared is used with hasGrant to find any grant on the stream
+func isShared(g *users.Grant) bool {
+	return true
+}
@@ -0,0 +1,93 @@
+package authoperator_test
+
+import (
+	"connectordb/datastream"
+	"connectordb/messenger"
+	"connectordb/users"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthGrant(t *testing.T) {
+	db.Clear()
+	require.NoError(t, db.CreateUser(&users.UserMaker{User: users.User{Name: "tst", Email: "root@localhost", Password: "mypass", Role: "user", Public: false}}))
+	require.NoError(t, db.CreateDevice("tst/owner", &users.DeviceMaker{Device: users.Device{Role: "user"}}))
+	require.NoError(t, db.CreateStream("tst/owner/strm", &users.StreamMaker{Stream: users.Stream{Schema: `{"type": "integer"}`}}))
+	require.NoError(t, db.InsertStream("tst/owner/strm", datastream.DatapointArray{datastream.Datapoint{Timestamp: 1, Data: 1}}, false))
+
+	require.NoError(t, db.CreateUser(&users.UserMaker{User: users.User{Name: "tst2", Email: "root2@localhost", Password: "mypass", Role: "user", Public: false}}))
+	require.NoError(t, db.CreateDevice("tst2/dev", &users.DeviceMaker{Device: users.Device{Role: "user"}}))
+	require.NoError(t, db.CreateDevice("tst2/none", &users.DeviceMaker{Device: users.Device{Role: "none"}}))
+
+	o, err := db.AsDevice("tst/owner")
+	require.NoError(t, err)
+	o2, err := db.AsDevice("tst2/dev")
+	require.NoError(t, err)
+	onone, err := db.AsDevice("tst2/none")
+	require.NoError(t, err)
+
+	_, err = o2.ReadStream("tst/owner/strm")
+	require.Error(t, err)
+	_, err = o2.GetStreamIndexRange("tst/owner/strm", 0, 0, "")
+	require.Error(t, err)
+
+	// Only the owner can share the stream
+	require.Error(t, o2.CreateGrant("tst2", "tst/owner/strm", &users.Grant{Read: true}))
+	require.Error(t, o.CreateGrant("tst", "tst/owner/strm", &users.Grant{Read: true}))
+	require.NoError(t, o.CreateGrant("tst2", "tst/owner/strm", &users.Grant{Read: true}))
+
+	s, err := o2.ReadStream("tst/owner/strm")
+	require.NoError(t, err)
+	require.Equal(t, "strm", s.Name)
+	dr, err := o2.GetStreamIndexRange("tst/owner/strm", 0, 0, "")
+	require.NoError(t, err)
+	dp, err := dr.Next()
+	require.NoError(t, err)
+	require.NotNil(t, dp)
+	dr.Close()
+
+	// A device that can't access its own user's streams doesn't get the user's grants
+	_, err = onone.GetStreamIndexRange("tst/owner/strm", 0, 0, "")
+	require.Error(t, err)
+
+	// The read grant doesn't permit writing or subscribing
+	require.Error(t, o2.InsertStream("tst/owner/strm", datastream.DatapointArray{datastream.Datapoint{Timestamp: 2, Data: 2}}, false))
+	recvchan := make(chan messenger.Message, 2)
+	_, err = o2.SubscribeStream("tst/owner/strm", recvchan)
+	require.Error(t, err)
+
+	// A grant on the device applies to its streams
+	require.NoError(t, o.CreateGrant("tst2/dev", "tst/owner", &users.Grant{Write: true, Subscribe: true}))
+	require.NoError(t, o2.InsertStream("tst/owner/strm", datastream.DatapointArray{datastream.Datapoint{Timestamp: 2, Data: 2}}, false))
+	sub, err := o2.SubscribeStream("tst/owner/strm", recvchan)
+	require.NoError(t, err)
+	sub.Unsubscribe()
+
+	dr, err = db.GetStreamIndexRange("tst/owner/strm", 1, 2, "")
+	require.NoError(t, err)
+	dp, err = dr.Next()
+	require.NoError(t, err)
+	require.Equal(t, "tst2/dev", dp.Sender)
+	dr.Close()
+
+	// Only the owner can list and revoke the grants
+	_, err = o2.ReadUserGrants("tst")
+	require.Error(t, err)
+	grants, err := o.ReadUserGrants("tst")
+	require.NoError(t, err)
+	require.Len(t, grants, 2)
+	require.Equal(t, "tst2", grants[0].Grantee)
+	require.Equal(t, "tst/owner/strm", grants[0].Target)
+	require.Equal(t, "tst2/dev", grants[1].Grantee)
+	require.Equal(t, "tst/owner", grants[1].Target)
+
+	require.Error(t, o2.DeleteGrantByID(grants[0].GrantID))
+	require.NoError(t, o.DeleteGrantByID(grants[0].GrantID))
+	require.NoError(t, o.DeleteGrantByID(grants[1].GrantID))
+
+	_, err = o2.ReadStream("tst/owner/strm")
+	require.Error(t, err)
+	_, err = o2.GetStreamIndexRange("tst/owner/strm", 0, 0, "")
+	require.Error(t, err)
+}
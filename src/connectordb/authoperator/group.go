@@ -0,0 +1,253 @@
+package authoperator
+
+import (
+	"connectordb/authoperator/permissions"
+	"connectordb/users"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrGroupOwner is returned when a group admin tries to change the group's owners
+	ErrGroupOwner = errors.New("Only the owners of a group can change its owners")
+
+	// ErrNoInvitation is returned when a user tries to join a group without being invited
+	ErrNoInvitation = errors.New("Users can only join the groups that they were invited to")
+)
+
+// GroupRoleCacheTime is how long an operator keeps the group roles that it read. Every access check of another
+// user's objects needs the group role, so that repeated operations, such as inserts, don't each read the memberships.
+var GroupRoleCacheTime = 30 * time.Second
+
+type groupRoleKey struct {
+	ownerID     int64
+	grouppublic bool
+}
+
+type cachedGroupRole struct {
+	role string
+	read time.Time
+}
+
+// groupRoleCache holds the group roles read by an operator
+type groupRoleCache struct {
+	sync.Mutex
+	roles map[groupRoleKey]cachedGroupRole
+}
+
+func newGroupRoleCache() *groupRoleCache {
+	return &groupRoleCache{roles: make(map[groupRoleKey]cachedGroupRole)}
+}
+
+// groupRole returns the role of the user in the group which relates it to the objects owned by ownerID: its role in the
+// group if the owner is a group, or that of a plain member if the objects are group-public and the owner shares a group
+// with the user. It returns an empty string if the user and owner are not related through a group.
+func (a *AuthOperator) groupRole(u *users.User, ownerID int64, grouppublic bool) string {
+	if u.UserID == ownerID || u.UserID < 0 {
+		return ""
+	}
+	k := groupRoleKey{ownerID, grouppublic}
+	a.groupRoles.Lock()
+	r, ok := a.groupRoles.roles[k]
+	a.groupRoles.Unlock()
+	if ok && time.Since(r.read) < GroupRoleCacheTime {
+		return r.role
+	}
+
+	role, err := a.readGroupRole(u.UserID, ownerID, grouppublic)
+	if err != nil {
+		// The role is not kept, so that the next check reads it again
+		return ""
+	}
+	a.groupRoles.Lock()
+	a.groupRoles.roles[k] = cachedGroupRole{role, time.Now()}
+	a.groupRoles.Unlock()
+	return role
+}
+
+// readGroupRole reads the role returned by groupRole from the database
+func (a *AuthOperator) readGroupRole(userID, ownerID int64, grouppublic bool) (string, error) {
+	m, err := a.Operator.ReadGroupMemberByID(ownerID, userID)
+	if err == nil && m.Accepted {
+		return m.Role, nil
+	}
+	if err != nil && err != users.ErrGroupMemberNotFound {
+		return "", err
+	}
+	if !grouppublic {
+		return "", nil
+	}
+	shared, err := a.Operator.SharesGroupByID(userID, ownerID)
+	if err != nil || !shared {
+		return "", err
+	}
+	return users.GroupRoleMember, nil
+}
+
+// clearGroupRoles forgets the group roles read by the operator, once it changed memberships
+func (a *AuthOperator) clearGroupRoles() {
+	a.groupRoles.Lock()
+	a.groupRoles.roles = make(map[groupRoleKey]cachedGroupRole)
+	a.groupRoles.Unlock()
+}
+
+// groupManager returns the logged in user's membership in the group, if the user can manage the group
+func (a *AuthOperator) groupManager(groupID int64) (*users.GroupMember, error) {
+	u, err := a.User()
+	if err != nil {
+		return nil, err
+	}
+	if err = a.errorIfCantShare(u.UserID); err != nil {
+		return nil, err
+	}
+	m, err := a.Operator.ReadGroupMemberByID(groupID, u.UserID)
+	if err != nil || !m.Accepted || !m.CanManage() {
+		return nil, permissions.ErrNoAccess
+	}
+	return m, nil
+}
+
+// errorIfNotMember returns an error if the logged in user is not a member of the group
+func (a *AuthOperator) errorIfNotMember(groupID int64) error {
+	u, err := a.User()
+	if err != nil {
+		return err
+	}
+	if m, err := a.Operator.ReadGroupMemberByID(groupID, u.UserID); err != nil || !m.Accepted {
+		return permissions.ErrNoAccess
+	}
+	return nil
+}
+
+// CreateGroupByID creates a group owned by the logged in user
+func (a *AuthOperator) CreateGroupByID(groupname string, ownerID int64) error {
+	if err := a.errorIfCantShare(ownerID); err != nil {
+		return err
+	}
+	return a.Operator.CreateGroupByID(groupname, ownerID)
+}
+
+// ReadGroupByID reads the group, if the logged in user is one of its members
+func (a *AuthOperator) ReadGroupByID(groupID int64) (*users.Group, error) {
+	if err := a.errorIfNotMember(groupID); err != nil {
+		return nil, err
+	}
+	return a.Operator.ReadGroupByID(groupID)
+}
+
+// ReadGroupMemberByID reads the membership of the user in the group, if the logged in user is one of its members
+func (a *AuthOperator) ReadGroupMemberByID(groupID, userID int64) (*users.GroupMember, error) {
+	if err := a.errorIfNotMember(groupID); err != nil {
+		return nil, err
+	}
+	return a.Operator.ReadGroupMemberByID(groupID, userID)
+}
+
+// ReadAllGroupMembersByID reads the members of the group, if the logged in user is one of them
+func (a *AuthOperator) ReadAllGroupMembersByID(groupID int64) ([]*users.GroupMember, error) {
+	if err := a.errorIfNotMember(groupID); err != nil {
+		return nil, err
+	}
+	return a.Operator.ReadAllGroupMembersByID(groupID)
+}
+
+// ReadAllGroupsByUserID reads the groups of the logged in user, along with the invitations that it did not accept
+func (a *AuthOperator) ReadAllGroupsByUserID(userID int64) ([]*users.GroupMember, error) {
+	u, err := a.User()
+	if err != nil {
+		return nil, err
+	}
+	if u.UserID != userID {
+		return nil, permissions.ErrNoAccess
+	}
+	return a.Operator.ReadAllGroupsByUserID(userID)
+}
+
+// SetGroupMemberByID invites a user to the group or changes its role. Only owners and admins can manage
+// the members of a group, and only owners can change the group's owners. Users join a group by setting
+// their own membership, which accepts the invitation with the role that they were invited with.
+func (a *AuthOperator) SetGroupMemberByID(m *users.GroupMember) error {
+	u, err := a.User()
+	if err != nil {
+		return err
+	}
+	old, err := a.Operator.ReadGroupMemberByID(m.GroupID, m.UserID)
+	if err != nil && err != users.ErrGroupMemberNotFound {
+		return err
+	}
+	if u.UserID == m.UserID && old != nil && !old.Accepted {
+		if err = a.errorIfCantShare(u.UserID); err != nil {
+			return err
+		}
+		defer a.clearGroupRoles()
+		return a.Operator.SetGroupMemberByID(&users.GroupMember{GroupID: m.GroupID, UserID: m.UserID, Role: old.Role, Accepted: true})
+	}
+
+	manager, err := a.groupManager(m.GroupID)
+	if err != nil {
+		if u.UserID == m.UserID && old == nil {
+			return ErrNoInvitation
+		}
+		return err
+	}
+	if manager.Role != users.GroupRoleOwner {
+		if m.Role == users.GroupRoleOwner {
+			return ErrGroupOwner
+		}
+		if old != nil && old.Role == users.GroupRoleOwner {
+			return ErrGroupOwner
+		}
+	}
+	// Managers can only invite users, who need to accept the invitation themselves
+	defer a.clearGroupRoles()
+	return a.Operator.SetGroupMemberByID(&users.GroupMember{GroupID: m.GroupID, UserID: m.UserID, Role: m.Role})
+}
+
+// DeleteGroupMemberByID removes the user from the group. Users can leave their groups, and otherwise
+// only owners and admins can remove members.
+func (a *AuthOperator) DeleteGroupMemberByID(groupID, userID int64) error {
+	u, err := a.User()
+	if err != nil {
+		return err
+	}
+	if u.UserID != userID {
+		manager, err := a.groupManager(groupID)
+		if err != nil {
+			return err
+		}
+		if manager.Role != users.GroupRoleOwner {
+			if old, err := a.Operator.ReadGroupMemberByID(groupID, userID); err == nil && old.Role == users.GroupRoleOwner {
+				return ErrGroupOwner
+			}
+		}
+	} else if err = a.errorIfCantShare(userID); err != nil {
+		return err
+	}
+	defer a.clearGroupRoles()
+	return a.Operator.DeleteGroupMemberByID(groupID, userID)
+}
+
+// SharesGroupByID returns true if the logged in user shares a group with the other user
+func (a *AuthOperator) SharesGroupByID(userID, otherUserID int64) (bool, error) {
+	u, err := a.User()
+	if err != nil {
+		return false, err
+	}
+	if u.UserID != userID {
+		return false, permissions.ErrNoAccess
+	}
+	return a.Operator.SharesGroupByID(userID, otherUserID)
+}
+
+// DeleteGroupByID deletes the group along with its devices and streams. Only the group's owners can delete it.
+func (a *AuthOperator) DeleteGroupByID(groupID int64) error {
+	manager, err := a.groupManager(groupID)
+	if err != nil {
+		return err
+	}
+	if manager.Role != users.GroupRoleOwner {
+		return permissions.ErrNoAccess
+	}
+	return a.Operator.DeleteGroupByID(groupID)
+}
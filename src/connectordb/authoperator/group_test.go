@@ -0,0 +1,163 @@
+package authoperator_test
+
+import (
+	"connectordb/authoperator"
+	"connectordb/datastream"
+	"connectordb/users"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthGroup(t *testing.T) {
+	db.Clear()
+	for _, name := range []string{"tst", "tst2", "tst3"} {
+		require.NoError(t, db.CreateUser(&users.UserMaker{User: users.User{Name: name, Email: name + "@localhost", Password: "mypass", Role: "user", Public: false}}))
+		require.NoError(t, db.CreateDevice(name+"/dev", &users.DeviceMaker{Device: users.Device{Role: "user"}}))
+	}
+	require.NoError(t, db.CreateStream("tst2/dev/private", &users.StreamMaker{Stream: users.Stream{Schema: `{"type": "integer"}`}}))
+	require.NoError(t, db.CreateStream("tst2/dev/shared", &users.StreamMaker{Stream: users.Stream{Schema: `{"type": "integer"}`, GroupPublic: true}}))
+	require.NoError(t, db.InsertStream("tst2/dev/shared", datastream.DatapointArray{datastream.Datapoint{Timestamp: 1, Data: 1}}, false))
+
+	o, err := db.AsDevice("tst/dev")
+	require.NoError(t, err)
+	o2, err := db.AsDevice("tst2/dev")
+	require.NoError(t, err)
+	o3, err := db.AsDevice("tst3/dev")
+	require.NoError(t, err)
+
+	// Users can only create groups that they own
+	require.Error(t, o.CreateGroup("team", "tst2"))
+	require.NoError(t, o.CreateGroup("team", "tst"))
+	members, err := o.ReadGroupMembers("team")
+	require.NoError(t, err)
+	require.Len(t, members, 1)
+	require.Equal(t, "tst", members[0].User)
+	require.Equal(t, users.GroupRoleOwner, members[0].Role)
+
+	// Only the group's managers invite members, and users can't join without an invitation
+	require.Equal(t, authoperator.ErrNoInvitation, o2.SetGroupMember("team", "tst2", users.GroupRoleMember))
+	require.NoError(t, o.SetGroupMember("team", "tst2", users.GroupRoleMember))
+	_, err = o3.ReadGroupMembers("team")
+	require.Error(t, err)
+	groups, err := o2.ReadUserGroups("tst2")
+	require.NoError(t, err)
+	require.Len(t, groups, 1)
+	require.Equal(t, "team", groups[0].Group)
+	require.False(t, groups[0].Accepted)
+
+	// The invitation gives no access until it is accepted
+	_, err = o2.ReadGroupMembers("team")
+	require.Error(t, err)
+	_, err = asDevice(t, "tst/dev").ReadStream("tst2/dev/shared")
+	require.Error(t, err)
+	require.NoError(t, o2.SetGroupMember("team", "tst2", users.GroupRoleAdmin))
+	groups, err = o2.ReadUserGroups("tst2")
+	require.NoError(t, err)
+	require.True(t, groups[0].Accepted)
+	require.Equal(t, users.GroupRoleMember, groups[0].Role, "The invited role is kept")
+	o, o2 = asDevice(t, "tst/dev"), asDevice(t, "tst2/dev")
+
+	// The group owns devices, which its owner manages and its members can read
+	require.NoError(t, o.CreateDevice("team/sensor", &users.DeviceMaker{Device: users.Device{Role: "none"}}))
+	require.NoError(t, o.CreateStream("team/sensor/temp", &users.StreamMaker{Stream: users.Stream{Schema: `{"type": "number"}`}}))
+	require.Error(t, o2.CreateDevice("team/other", &users.DeviceMaker{}))
+	_, err = o2.ReadDevice("team/sensor")
+	require.NoError(t, err)
+	_, err = o2.ReadStream("team/sensor/temp")
+	require.NoError(t, err)
+	_, err = o3.ReadStream("team/sensor/temp")
+	require.Error(t, err)
+	require.Error(t, o2.UpdateStream("team/sensor/temp", map[string]interface{}{"nickname": "hi"}))
+
+	// Members access each other's group-public streams, but not their other streams
+	_, err = o.ReadStream("tst2/dev/shared")
+	require.NoError(t, err)
+	dr, err := o.GetStreamIndexRange("tst2/dev/shared", 0, 0, "")
+	require.NoError(t, err)
+	dp, err := dr.Next()
+	require.NoError(t, err)
+	require.NotNil(t, dp)
+	dr.Close()
+	_, err = o.ReadStream("tst2/dev/private")
+	require.Error(t, err)
+	_, err = o3.ReadStream("tst2/dev/shared")
+	require.Error(t, err)
+
+	ss, err := o.ReadUserStreamsToMap("tst2", false, false, false)
+	require.NoError(t, err)
+	require.Len(t, ss, 1)
+	require.Equal(t, "shared", ss[0]["name"])
+	_, err = o3.ReadUserStreamsToMap("tst2", false, false, false)
+	require.Error(t, err)
+
+	// Admins can't change the owners, and the last owner can't leave
+	require.NoError(t, o.SetGroupMember("team", "tst2", users.GroupRoleAdmin))
+	require.Error(t, o2.SetGroupMember("team", "tst3", users.GroupRoleOwner))
+	require.Error(t, o2.DeleteGroupMember("team", "tst"))
+	require.NoError(t, o2.SetGroupMember("team", "tst3", users.GroupRoleMember))
+	require.Error(t, o.DeleteGroupMember("team", "tst"))
+
+	// The managers change the group's profile, but can't take over or delete the user behind the group
+	team, err := db.ReadUser("team")
+	require.NoError(t, err)
+	for _, m := range []*authoperator.AuthOperator{asDevice(t, "tst/dev"), asDevice(t, "tst2/dev")} {
+		require.NoError(t, m.UpdateUserByID(team.UserID, map[string]interface{}{"nickname": "The Team"}))
+		require.Error(t, m.UpdateUserByID(team.UserID, map[string]interface{}{"password": "newpass"}))
+		require.Error(t, m.UpdateUserByID(team.UserID, map[string]interface{}{"email": "mine@localhost"}))
+		require.Error(t, m.UpdateUserByID(team.UserID, map[string]interface{}{"role": "admin"}))
+		_, err = m.EnrollTOTPByID(team.UserID)
+		require.Error(t, err)
+		require.Error(t, m.DeleteUserByID(team.UserID))
+	}
+	team, err = db.ReadUser("team")
+	require.NoError(t, err)
+	require.Equal(t, "tst+team@localhost", team.Email)
+
+	// Invitations can be declined, and members can leave, after which they lose access
+	require.NoError(t, o3.DeleteGroupMember("team", "tst3"))
+	require.NoError(t, o2.DeleteGroupMember("team", "tst2"))
+	_, err = asDevice(t, "tst/dev").ReadStream("tst2/dev/shared")
+	require.Error(t, err)
+	_, err = o2.ReadStream("team/sensor/temp")
+	require.Error(t, err)
+
+	// Only owners delete the group
+	require.Error(t, o2.DeleteGroup("team"))
+	require.NoError(t, o.DeleteGroup("team"))
+	_, err = db.ReadUser("team")
+	require.Error(t, err)
+}
+
+func asDevice(t *testing.T, devicepath string) *authoperator.AuthOperator {
+	o, err := db.AsDevice(devicepath)
+	require.NoError(t, err)
+	return o
+}
+
+func TestGroupRoleCache(t *testing.T) {
+	db.Clear()
+	for _, name := range []string{"tst", "tst2"} {
+		require.NoError(t, db.CreateUser(&users.UserMaker{User: users.User{Name: name, Email: name + "@localhost", Password: "mypass", Role: "user", Public: false}}))
+		require.NoError(t, db.CreateDevice(name+"/dev", &users.DeviceMaker{Device: users.Device{Role: "user"}}))
+	}
+	require.NoError(t, db.CreateStream("tst2/dev/shared", &users.StreamMaker{Stream: users.Stream{Schema: `{"type": "integer"}`, GroupPublic: true}}))
+	require.NoError(t, db.CreateGroup("team", "tst"))
+	require.NoError(t, db.SetGroupMember("team", "tst2", users.GroupRoleMember))
+	require.NoError(t, asDevice(t, "tst2/dev").SetGroupMember("team", "tst2", users.GroupRoleMember))
+
+	o := asDevice(t, "tst/dev")
+	_, err := o.ReadStream("tst2/dev/shared")
+	require.NoError(t, err)
+
+	// The operator keeps the role that it read for a while, but reads it again once it is old
+	require.NoError(t, db.DeleteGroupMember("team", "tst2"))
+	_, err = o.ReadStream("tst2/dev/shared")
+	require.NoError(t, err)
+
+	cachetime := authoperator.GroupRoleCacheTime
+	defer func() { authoperator.GroupRoleCacheTime = cachetime }()
+	authoperator.GroupRoleCacheTime = 0
+	_, err = o.ReadStream("tst2/dev/shared")
+	require.Error(t, err)
+}
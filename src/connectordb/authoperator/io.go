@@ -1,9 +1,11 @@
 package authoperator
 
 import (
+	"connectordb/audit"
 	"connectordb/authoperator/permissions"
 	"connectordb/datastream"
 	"errors"
+	"fmt"
 
 	pconfig "config/permissions"
 )
@@ -13,15 +15,15 @@ func (a *AuthOperator) getIOPermissions(streamID int64) (*pconfig.Permissions, *
 	if err != nil {
 		return nil, nil, nil, permissions.ErrNoAccess
 	}
-	perm, _, _, _, ua, da, err := a.getDeviceAccessLevels(s.DeviceID)
+	perm, _, _, _, ua, da, err := a.getStreamDeviceAccessLevels(s.DeviceID, s.GroupPublic)
 	if err != nil {
 		return nil, nil, nil, err
 	}
 	return perm, ua, da, nil
 }
 
-// ErrorIfNoIOReadAccess returns the permissions for reading the given stream
-func (a *AuthOperator) ErrorIfNoIOReadAccess(streamID int64, substream string) error {
+// errorIfNoRoleReadAccess returns an error if the access levels of the device's roles don't permit reading the stream
+func (a *AuthOperator) errorIfNoRoleReadAccess(streamID int64, substream string) error {
 	perm, ua, da, err := a.getIOPermissions(streamID)
 	if err != nil {
 		return err
@@ -41,9 +43,29 @@ func (a *AuthOperator) ErrorIfNoIOReadAccess(streamID int64, substream string) e
 	return nil
 }
 
+// ErrorIfNoIOReadAccess returns the permissions for reading the given stream. If the device's roles
+// don't permit reading the stream, it can still be read through a grant.
+func (a *AuthOperator) ErrorIfNoIOReadAccess(streamID int64, substream string) error {
+	if err := a.errorIfAuditStreamDenied(streamID, false); err != nil {
+		return err
+	}
+	if err := a.errorIfTokenDenied(streamID, false); err != nil {
+		return err
+	}
+	if err := a.errorIfStreamPathDenied(streamID); err != nil {
+		return err
+	}
+	err := a.errorIfNoRoleReadAccess(streamID, substream)
+	if err == permissions.ErrNoAccess && a.hasGrant(streamID, false, canRead) {
+		return nil
+	}
+	return err
+}
+
 // LengthStreamByID gets the stream's length
 func (a *AuthOperator) LengthStreamByID(streamID int64, substream string) (int64, error) {
 	err := a.ErrorIfNoIOReadAccess(streamID, substream)
+	a.audit(streamID, substream, audit.Length, "", err)
 	if err != nil {
 		return 0, err
 	}
@@ -53,6 +75,7 @@ func (a *AuthOperator) LengthStreamByID(streamID int64, substream string) (int64
 // TimeToIndexStreamByID gets the time to index. more documentatino in definition of Operator
 func (a *AuthOperator) TimeToIndexStreamByID(streamID int64, substream string, time float64) (int64, error) {
 	err := a.ErrorIfNoIOReadAccess(streamID, substream)
+	a.audit(streamID, substream, audit.TimeToIndex, fmt.Sprintf("t=%v", time), err)
 	if err != nil {
 		return 0, err
 	}
@@ -61,6 +84,15 @@ func (a *AuthOperator) TimeToIndexStreamByID(streamID int64, substream string, t
 
 // InsertStreamByID inserts the given data into the stream
 func (a *AuthOperator) InsertStreamByID(streamID int64, substream string, data datastream.DatapointArray, restamp bool) error {
+	err := a.insertStreamByID(streamID, substream, data, restamp)
+	a.audit(streamID, substream, audit.Insert, fmt.Sprintf("%d datapoints", len(data)), err)
+	return err
+}
+
+func (a *AuthOperator) insertStreamByID(streamID int64, substream string, data datastream.DatapointArray, restamp bool) error {
+	if err := a.errorIfAuditStreamDenied(streamID, true); err != nil {
+		return err
+	}
 	strm, err := a.Operator.ReadStreamByID(streamID)
 	if err != nil {
 		return permissions.ErrNoAccess
@@ -86,18 +118,25 @@ func (a *AuthOperator) InsertStreamByID(streamID int64, substream string, data d
 		}
 	}
 
+	if err = a.errorIfTokenDenied(streamID, true); err != nil {
+		return err
+	}
+	if err = a.errorIfStreamPathDenied(streamID); err != nil {
+		return err
+	}
 	perm, ua, da, err := a.getIOPermissions(streamID)
 	if err != nil {
 		return err
 	}
 
-	// Now: If we want to write to the substream "", we check if can access stream data is true
+	// Now: If we want to write to the substream "", we check if can access stream data is true.
+	// If the roles don't permit the write, it can still be permitted by a grant.
 	if substream == "" {
-		if !permissions.GetWriteAccess(perm, ua).CanAccessStreamData || !permissions.GetWriteAccess(perm, da).CanAccessStreamData {
+		if (!permissions.GetWriteAccess(perm, ua).CanAccessStreamData || !permissions.GetWriteAccess(perm, da).CanAccessStreamData) && !a.hasGrant(streamID, true, canWrite) {
 			return errors.New("Write access to stream data denied.")
 		}
 	} else if substream == "downlink" {
-		if !permissions.GetWriteAccess(perm, ua).CanAccessStreamDownlink || !permissions.GetWriteAccess(perm, da).CanAccessStreamDownlink {
+		if (!permissions.GetWriteAccess(perm, ua).CanAccessStreamDownlink || !permissions.GetWriteAccess(perm, da).CanAccessStreamDownlink) && !a.hasGrant(streamID, true, canWrite) {
 			return errors.New("Write access to stream downlink denied.")
 		}
 	} else {
@@ -110,6 +149,7 @@ func (a *AuthOperator) InsertStreamByID(streamID int64, substream string, data d
 // GetStreamTimeRangeByID is defined in Operator
 func (a *AuthOperator) GetStreamTimeRangeByID(streamID int64, substream string, t1 float64, t2 float64, limit int64, transform string) (datastream.DataRange, error) {
 	err := a.ErrorIfNoIOReadAccess(streamID, substream)
+	a.audit(streamID, substream, audit.Read, audit.TimeRange(t1, t2, limit), err)
 	if err != nil {
 		return nil, err
 	}
@@ -119,6 +159,7 @@ func (a *AuthOperator) GetStreamTimeRangeByID(streamID int64, substream string,
 // GetStreamIndexRangeByID is defined in Operator
 func (a *AuthOperator) GetStreamIndexRangeByID(streamID int64, substream string, i1 int64, i2 int64, transform string) (datastream.DataRange, error) {
 	err := a.ErrorIfNoIOReadAccess(streamID, substream)
+	a.audit(streamID, substream, audit.Read, audit.IndexRange(i1, i2), err)
 	if err != nil {
 		return nil, err
 	}
@@ -128,6 +169,7 @@ func (a *AuthOperator) GetStreamIndexRangeByID(streamID int64, substream string,
 // GetShiftedStreamTimeRangeByID is defined in Operator
 func (a *AuthOperator) GetShiftedStreamTimeRangeByID(streamID int64, substream string, t1 float64, t2 float64, shift, limit int64, transform string) (datastream.DataRange, error) {
 	err := a.ErrorIfNoIOReadAccess(streamID, substream)
+	a.audit(streamID, substream, audit.Read, audit.ShiftedRange(t1, t2, shift, limit), err)
 	if err != nil {
 		return nil, err
 	}
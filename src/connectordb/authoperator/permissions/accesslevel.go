@@ -10,7 +10,10 @@ import (
 // GetAccessLevels returns the two AccessLevel objects relevant to the query. The first is the user's access level, the second is the device's access level.
 // This will never return an error, since ConectorDB has well-defined fallbacks (user-> nobody, and device -> none). If the roles of user/device
 // are not found, it will return the fallbacks, and complain on the error log (error level)
-func GetAccessLevels(perm *pconfig.Permissions, u *users.User, d *users.Device, queryUserID int64, ispublic, isself bool) (usr *pconfig.AccessLevel, dev *pconfig.AccessLevel) {
+// The groupRole is the user's role in the group which relates it to the queried object: the group which owns the object,
+// or a group shared with the object's owner if the object is group-public. It is empty if there is no such group.
+// The owners and admins of a group access the group's objects as their own.
+func GetAccessLevels(perm *pconfig.Permissions, u *users.User, d *users.Device, queryUserID int64, ispublic, isself bool, groupRole string) (usr *pconfig.AccessLevel, dev *pconfig.AccessLevel) {
 	userRole := GetUserRole(perm, u)
 	deviceRole := GetDeviceRole(perm, d)
 
@@ -20,9 +23,12 @@ func GetAccessLevels(perm *pconfig.Permissions, u *users.User, d *users.Device,
 	if isself {
 		userAccess = userRole.SelfAccessLevel
 		deviceAccess = deviceRole.SelfAccessLevel
-	} else if queryUserID == u.UserID {
+	} else if queryUserID == u.UserID || groupRole == users.GroupRoleOwner || groupRole == users.GroupRoleAdmin {
 		userAccess = userRole.UserAccessLevel
 		deviceAccess = deviceRole.UserAccessLevel
+	} else if groupRole != "" {
+		userAccess = userRole.GroupAccessLevel
+		deviceAccess = deviceRole.GroupAccessLevel
 	} else if ispublic {
 		userAccess = userRole.PublicAccessLevel
 		deviceAccess = deviceRole.PublicAccessLevel
@@ -3,6 +3,7 @@ package permissions
 import (
 	pconfig "config/permissions"
 	"connectordb/users"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
 )
@@ -46,3 +47,24 @@ func GetDeviceRole(perm *pconfig.Permissions, d *users.Device) *pconfig.DeviceRo
 	}
 	return p
 }
+
+// minLimit returns the stricter of the two limits, where 0 means unlimited
+func minLimit(a, b int64) int64 {
+	if a == 0 || (b != 0 && b < a) {
+		return b
+	}
+	return a
+}
+
+// GetQueryBudget returns the query budget of the given device. The budget of each
+// device is limited by the budget of its owning user, so the stricter of the two
+// limits is returned for each value.
+func GetQueryBudget(perm *pconfig.Permissions, u *users.User, d *users.Device) (datapoints int64, querytime time.Duration, streams int64) {
+	ur := GetUserRole(perm, u)
+	dr := GetDeviceRole(perm, d)
+
+	datapoints = minLimit(ur.MaxQueryDatapoints, dr.MaxQueryDatapoints)
+	streams = minLimit(ur.MaxQueryStreams, dr.MaxQueryStreams)
+	querytime = time.Duration(minLimit(ur.MaxQueryTime, dr.MaxQueryTime)) * time.Millisecond
+	return
+}
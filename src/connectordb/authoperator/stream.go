@@ -90,7 +90,12 @@ func (a *AuthOperator) ReadUserStreamsToMap(username string, public, downlink, h
 	}
 
 	if !ua.CanListStreams || !da.CanListStreams {
-		return nil, permissions.ErrNoAccess
+		// The members of the user's groups can list its group-public streams
+		_, _, _, ua, da, err = a.getGroupAccessLevels(usr.UserID, usr.Public, false, true)
+		if err != nil || !ua.CanListStreams || !da.CanListStreams {
+			return nil, permissions.ErrNoAccess
+		}
+		public = true
 	}
 
 	// See ReadAllUsers
@@ -148,6 +153,9 @@ func (a *AuthOperator) StreamMaker() (*users.StreamMaker, error) {
 
 // CreateStreamByDeviceID creates the given stream if permitted
 func (a *AuthOperator) CreateStreamByDeviceID(sm *users.StreamMaker) error {
+	if err := a.errorIfToken(); err != nil {
+		return err
+	}
 	_, _, _, _, ua, da, err := a.getDeviceAccessLevels(sm.DeviceID)
 	if err != nil {
 		return err
@@ -166,7 +174,7 @@ func (a *AuthOperator) ReadStreamByID(streamID int64) (*users.Stream, error) {
 	if err != nil {
 		return nil, permissions.ErrNoAccess
 	}
-	perm, _, _, _, ua, da, err := a.getDeviceAccessLevels(s.DeviceID)
+	perm, ua, da, err := a.getStreamAccessLevels(s)
 	if err != nil {
 		return nil, err
 	}
@@ -178,13 +186,27 @@ func (a *AuthOperator) ReadStreamByID(streamID int64) (*users.Stream, error) {
 	return s, nil
 }
 
+// getStreamAccessLevels returns the access levels used to read the stream's metadata. A stream that was shared
+// with the device through a grant can be read with the access levels of a public stream, even if its
+// owner is private.
+func (a *AuthOperator) getStreamAccessLevels(s *users.Stream) (*pconfig.Permissions, *pconfig.AccessLevel, *pconfig.AccessLevel, error) {
+	perm, dev, _, _, ua, da, err := a.getStreamDeviceAccessLevels(s.DeviceID, s.GroupPublic)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if (!permissions.GetReadAccess(perm, ua).CanAccessStream || !permissions.GetReadAccess(perm, da).CanAccessStream) && a.hasGrant(s.StreamID, false, isShared) {
+		perm, _, _, ua, da, err = a.getAccessLevels(dev.UserID, true, false)
+	}
+	return perm, ua, da, err
+}
+
 // ReadStreamToMap reads the given stream into a map, where only the permitted fields are present in the map
 func (a *AuthOperator) ReadStreamToMap(spath string) (map[string]interface{}, error) {
 	s, err := a.Operator.ReadStream(spath)
 	if err != nil {
 		return nil, permissions.ErrNoAccess
 	}
-	perm, _, _, _, ua, da, err := a.getDeviceAccessLevels(s.DeviceID)
+	perm, ua, da, err := a.getStreamAccessLevels(s)
 	if err != nil {
 		return nil, err
 	}
@@ -202,11 +224,17 @@ func (a *AuthOperator) ReadStreamByDeviceID(deviceID int64, streamname string) (
 
 // UpdateStreamByID updates the given stream
 func (a *AuthOperator) UpdateStreamByID(streamID int64, updates map[string]interface{}) error {
+	if err := a.errorIfToken(); err != nil {
+		return err
+	}
+	if err := a.errorIfAuditStreamDenied(streamID, false); err != nil {
+		return err
+	}
 	s, err := a.Operator.ReadStreamByID(streamID)
 	if err != nil {
 		return permissions.ErrNoAccess
 	}
-	perm, _, _, _, ua, da, err := a.getDeviceAccessLevels(s.DeviceID)
+	perm, _, _, _, ua, da, err := a.getStreamDeviceAccessLevels(s.DeviceID, s.GroupPublic)
 	if err != nil {
 		return err
 	}
@@ -219,11 +247,17 @@ func (a *AuthOperator) UpdateStreamByID(streamID int64, updates map[string]inter
 
 // DeleteStreamByID deletes the given stream
 func (a *AuthOperator) DeleteStreamByID(streamID int64, substream string) error {
+	if err := a.errorIfToken(); err != nil {
+		return err
+	}
+	if err := a.errorIfAuditStreamDenied(streamID, false); err != nil {
+		return err
+	}
 	s, err := a.Operator.ReadStreamByID(streamID)
 	if err != nil {
 		return permissions.ErrNoAccess
 	}
-	_, _, _, _, ua, da, err := a.getDeviceAccessLevels(s.DeviceID)
+	_, _, _, _, ua, da, err := a.getStreamDeviceAccessLevels(s.DeviceID, s.GroupPublic)
 	if err != nil {
 		return err
 	}
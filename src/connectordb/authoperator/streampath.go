@@ -0,0 +1,55 @@
+package authoperator
+
+import (
+	"connectordb/authoperator/permissions"
+
+	pconfig "config/permissions"
+)
+
+// streamPath returns the user/device/stream path of the given stream
+func (a *AuthOperator) streamPath(streamID int64) (string, error) {
+	s, err := a.Operator.ReadStreamByID(streamID)
+	if err != nil {
+		return "", permissions.ErrNoAccess
+	}
+	dev, err := a.Operator.ReadDeviceByID(s.DeviceID)
+	if err != nil {
+		return "", permissions.ErrNoAccess
+	}
+	u, err := a.Operator.ReadUserByID(dev.UserID)
+	if err != nil {
+		return "", permissions.ErrNoAccess
+	}
+	return u.Name + "/" + dev.Name + "/" + s.Name, nil
+}
+
+// errorIfStreamPathDenied returns an error if the stream patterns of the device, its role or its user's role
+// don't permit access to the data of the given stream. The patterns restrict the device on top of its access
+// levels, so they also apply to the streams that the device accesses through grants.
+func (a *AuthOperator) errorIfStreamPathDenied(streamID int64) error {
+	u, d, err := a.UserAndDevice()
+	if err != nil {
+		return permissions.ErrNoAccess
+	}
+	perm := pconfig.Get()
+	userRole := permissions.GetUserRole(perm, u)
+	deviceRole := permissions.GetDeviceRole(perm, d)
+
+	if d.AllowStreams == "" && d.DenyStreams == "" && !hasStreamPatterns(&userRole.DeviceRole) && !hasStreamPatterns(deviceRole) {
+		// Don't look up the stream's path if there is nothing to match it against
+		return nil
+	}
+
+	streampath, err := a.streamPath(streamID)
+	if err != nil {
+		return err
+	}
+	if !userRole.CanAccessStream(streampath) || !deviceRole.CanAccessStream(streampath) || !d.CanAccessStream(streampath) {
+		return permissions.ErrNoAccess
+	}
+	return nil
+}
+
+func hasStreamPatterns(r *pconfig.DeviceRole) bool {
+	return len(r.AllowStreams) > 0 || len(r.DenyStreams) > 0
+}
@@ -0,0 +1,67 @@
+package authoperator_test
+
+import (
+	"connectordb/datastream"
+	"connectordb/messenger"
+	"connectordb/users"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthStreamPatterns(t *testing.T) {
+	db.Clear()
+	require.NoError(t, db.CreateUser(&users.UserMaker{User: users.User{Name: "tst", Email: "root@localhost", Password: "mypass", Role: "user", Public: true}}))
+	require.NoError(t, db.CreateDevice("tst/lights", &users.DeviceMaker{}))
+	require.NoError(t, db.CreateDevice("tst/bridge", &users.DeviceMaker{Device: users.Device{Role: "user", AllowStreams: "*/lights/*", DenyStreams: "tst/lights/garage"}}))
+	for _, s := range []string{"tst/lights/kitchen", "tst/lights/garage", "tst/bridge/temp"} {
+		require.NoError(t, db.CreateStream(s, &users.StreamMaker{Stream: users.Stream{Schema: `{"type": "integer"}`}}))
+	}
+
+	// Invalid patterns are not accepted
+	require.Error(t, db.UpdateDevice("tst/lights", map[string]interface{}{"allow_streams": "lights/*"}))
+
+	o, err := db.AsDevice("tst/bridge")
+	require.NoError(t, err)
+
+	data := datastream.DatapointArray{datastream.Datapoint{Timestamp: 1, Data: 1}}
+
+	// The device can only access the data of the streams that its patterns allow
+	require.NoError(t, o.InsertStream("tst/lights/kitchen", data, false))
+	_, err = o.LengthStream("tst/lights/kitchen")
+	require.NoError(t, err)
+
+	require.Error(t, o.InsertStream("tst/lights/garage", data, false))
+	_, err = o.LengthStream("tst/lights/garage")
+	require.Error(t, err)
+	_, err = o.GetStreamIndexRange("tst/lights/garage", 0, 0, "")
+	require.Error(t, err)
+
+	require.Error(t, o.InsertStream("tst/bridge/temp", data, false))
+	_, err = o.LengthStream("tst/bridge/temp")
+	require.Error(t, err)
+	_, err = o.Subscribe("tst/bridge/temp", make(chan messenger.Message))
+	require.Error(t, err)
+
+	// The patterns only limit the data, the streams themselves are still accessible by the access levels
+	_, err = o.ReadStream("tst/bridge/temp")
+	require.NoError(t, err)
+
+	// The user can set the patterns of its devices, but a device can't change its own
+	uo, err := db.AsUser("tst")
+	require.NoError(t, err)
+	require.NoError(t, db.CreateDevice("tst/other", &users.DeviceMaker{Device: users.Device{Role: "user", UserEditable: true}}))
+	require.NoError(t, uo.UpdateDevice("tst/other", map[string]interface{}{"allow_streams": "*/lights/*", "deny_streams": "tst/lights/garage"}))
+	dev, err := db.ReadDevice("tst/other")
+	require.NoError(t, err)
+	require.Equal(t, "*/lights/*", dev.AllowStreams)
+	require.Equal(t, "tst/lights/garage", dev.DenyStreams)
+	require.Error(t, o.UpdateDevice("tst/bridge", map[string]interface{}{"allow_streams": ""}))
+	require.Error(t, o.UpdateDevice("tst/bridge", map[string]interface{}{"deny_streams": ""}))
+	require.NoError(t, o.UpdateDevice("tst/bridge", map[string]interface{}{"nickname": "Bridge"}))
+
+	// Removing the patterns lifts the restrictions
+	require.NoError(t, db.UpdateDevice("tst/bridge", map[string]interface{}{"allow_streams": "", "deny_streams": ""}))
+	require.NoError(t, o.InsertStream("tst/bridge/temp", data, false))
+	require.NoError(t, o.InsertStream("tst/lights/garage", data, false))
+}
@@ -1,6 +1,8 @@
 package authoperator
 
 import (
+	"connectordb/audit"
+	"connectordb/authoperator/permissions"
 	"connectordb/messenger"
 	"errors"
 
@@ -17,11 +19,30 @@ func (a *AuthOperator) SubscribeDeviceByID(deviceID int64, chn chan messenger.Me
 	return nil, errors.New("Subscribing by device is currently not supported for authenticated devices")
 }
 
-// SubscribeStreamByID subscribes to the given stream
+// SubscribeStreamByID subscribes to the given stream. A device which can't read the stream through its roles
+// can subscribe to it if it was given a grant with subscribe access.
 func (a *AuthOperator) SubscribeStreamByID(streamID int64, substream string, chn chan messenger.Message) (*nats.Subscription, error) {
-	err := a.ErrorIfNoIOReadAccess(streamID, substream)
+	err := a.errorIfCantSubscribe(streamID, substream)
+	a.audit(streamID, substream, audit.Subscribe, "", err)
 	if err != nil {
 		return nil, err
 	}
 	return a.Operator.SubscribeStreamByID(streamID, substream, chn)
 }
+
+func (a *AuthOperator) errorIfCantSubscribe(streamID int64, substream string) error {
+	if err := a.errorIfAuditStreamDenied(streamID, false); err != nil {
+		return err
+	}
+	if err := a.errorIfTokenDenied(streamID, false); err != nil {
+		return err
+	}
+	if err := a.errorIfStreamPathDenied(streamID); err != nil {
+		return err
+	}
+	err := a.errorIfNoRoleReadAccess(streamID, substream)
+	if err == permissions.ErrNoAccess && a.hasGrant(streamID, false, canSubscribe) {
+		return nil
+	}
+	return err
+}
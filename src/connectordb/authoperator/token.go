@@ -0,0 +1,104 @@
+package authoperator
+
+import (
+	"connectordb/authoperator/permissions"
+	"connectordb/users"
+	"errors"
+)
+
+// ErrTokenRestricted is returned when a token is used for something other than accessing stream data
+var ErrTokenRestricted = errors.New("Tokens can only be used to access the data of streams")
+
+// Token returns the token that the operator logged in with, or nil if the device logged in with its API key
+func (a *AuthOperator) Token() *users.Token {
+	return a.token
+}
+
+// errorIfToken returns an error if the operator logged in with a token. It is used to prevent
+// tokens from modifying users, devices and streams.
+func (a *AuthOperator) errorIfToken() error {
+	if a.token != nil {
+		return ErrTokenRestricted
+	}
+	return nil
+}
+
+// errorIfTokenDenied returns an error if the operator logged in with a token which does not
+// permit reading (or writing) the data of the given stream
+func (a *AuthOperator) errorIfTokenDenied(streamID int64, write bool) error {
+	if a.token == nil {
+		return nil
+	}
+	if a.token.IsExpired() {
+		return users.ErrTokenExpired
+	}
+
+	streampath := ""
+	if len(a.token.StreamPaths()) > 0 {
+		var err error
+		if streampath, err = a.streamPath(streamID); err != nil {
+			return err
+		}
+	}
+	if !a.token.CanAccess(streampath, write) {
+		return permissions.ErrNoAccess
+	}
+	return nil
+}
+
+// errorIfCantManageTokens ensures that the operator can manage the tokens of the given device.
+// Since a token gives a subset of the rights of the device's API key, the operator needs to be
+// able to change the device's API key.
+func (a *AuthOperator) errorIfCantManageTokens(deviceID int64) error {
+	if err := a.errorIfToken(); err != nil {
+		return err
+	}
+	perm, _, _, _, ua, da, err := a.getDeviceAccessLevels(deviceID)
+	if err != nil {
+		return err
+	}
+	if !permissions.GetWriteAccess(perm, ua).DeviceAPIKey || !permissions.GetWriteAccess(perm, da).DeviceAPIKey {
+		return permissions.ErrNoAccess
+	}
+	return nil
+}
+
+// CreateTokenByID creates a token for the device given by the token's DeviceID
+func (a *AuthOperator) CreateTokenByID(t *users.Token) error {
+	if err := a.errorIfCantManageTokens(t.DeviceID); err != nil {
+		return err
+	}
+	if t.Access == users.TokenRefresh {
+		// Refresh tokens are only given out by the server, through OAuth
+		return permissions.ErrNoAccess
+	}
+	return a.Operator.CreateTokenByID(t)
+}
+
+// ReadTokenByID reads the given token, if the operator can manage the tokens of its device
+func (a *AuthOperator) ReadTokenByID(tokenID int64) (*users.Token, error) {
+	t, err := a.Operator.ReadTokenByID(tokenID)
+	if err != nil {
+		return nil, err
+	}
+	if err = a.errorIfCantManageTokens(t.DeviceID); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// ReadAllTokensByDeviceID reads all of the tokens of the given device
+func (a *AuthOperator) ReadAllTokensByDeviceID(deviceID int64) ([]*users.Token, error) {
+	if err := a.errorIfCantManageTokens(deviceID); err != nil {
+		return nil, err
+	}
+	return a.Operator.ReadAllTokensByDeviceID(deviceID)
+}
+
+// DeleteTokenByID revokes the given token
+func (a *AuthOperator) DeleteTokenByID(tokenID int64) error {
+	if _, err := a.ReadTokenByID(tokenID); err != nil {
+		return err
+	}
+	return a.Operator.DeleteTokenByID(tokenID)
+}
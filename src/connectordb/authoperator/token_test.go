@@ -0,0 +1,70 @@
+package authoperator_test
+
+import (
+	"connectordb/datastream"
+	"connectordb/users"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthToken(t *testing.T) {
+	db.Clear()
+	require.NoError(t, db.CreateUser(&users.UserMaker{User: users.User{Name: "tst", Email: "root@localhost", Password: "mypass", Role: "user", Public: true}}))
+	require.NoError(t, db.CreateDevice("tst/dev", &users.DeviceMaker{}))
+	require.NoError(t, db.CreateStream("tst/dev/s1", &users.StreamMaker{Stream: users.Stream{Schema: `{"type": "integer"}`}}))
+	require.NoError(t, db.CreateStream("tst/dev/s2", &users.StreamMaker{Stream: users.Stream{Schema: `{"type": "integer"}`}}))
+	data := datastream.DatapointArray{datastream.Datapoint{Timestamp: 1, Data: 1}}
+
+	o, err := db.AsDevice("tst/dev")
+	require.NoError(t, err)
+	other, err := db.AsDevice("tst/user")
+	require.NoError(t, err)
+
+	// The device can make tokens for itself, and its user can make tokens for the device
+	wtok := &users.Token{Name: "writer", Access: users.TokenWrite, Streams: "tst/dev/s1"}
+	require.NoError(t, o.CreateToken("tst/dev", wtok))
+	rtok := &users.Token{Name: "reader", Access: users.TokenRead}
+	require.NoError(t, other.CreateToken("tst/dev", rtok))
+	require.Error(t, o.CreateToken("tst/user", &users.Token{Name: "reader", Access: users.TokenRead}))
+
+	w, err := db.TokenLogin(wtok.Key)
+	require.NoError(t, err)
+	require.Equal(t, "tst/dev", w.Name())
+	require.NoError(t, w.InsertStream("tst/dev/s1", data, false))
+	require.Error(t, w.InsertStream("tst/dev/s2", data, false))
+	_, err = w.LengthStream("tst/dev/s1")
+	require.Error(t, err)
+
+	r, err := db.TokenLogin(rtok.Key)
+	require.NoError(t, err)
+	l, err := r.LengthStream("tst/dev/s1")
+	require.NoError(t, err)
+	require.EqualValues(t, 1, l)
+	require.Error(t, r.InsertStream("tst/dev/s2", data, false))
+
+	// Tokens can't change metadata, and can't read the API key
+	require.Error(t, r.CreateStream("tst/dev/s3", &users.StreamMaker{Stream: users.Stream{Schema: `{"type": "integer"}`}}))
+	require.Error(t, r.UpdateDevice("tst/dev", map[string]interface{}{"nickname": "hi"}))
+	require.Error(t, r.DeleteStream("tst/dev/s1"))
+	dev, err := r.ReadDevice("tst/dev")
+	require.NoError(t, err)
+	require.Equal(t, "", dev.APIKey)
+	_, err = r.ReadDeviceTokens("tst/dev")
+	require.Error(t, err)
+
+	tokens, err := o.ReadDeviceTokens("tst/dev")
+	require.NoError(t, err)
+	require.Len(t, tokens, 2)
+
+	// Revoked and expired tokens can't log in
+	require.NoError(t, o.DeleteTokenByID(tokens[0].TokenID))
+	_, err = db.TokenLogin(wtok.Key)
+	require.Error(t, err)
+
+	etok := &users.Token{Name: "expired", Access: users.TokenRead, Expires: float64(time.Now().Unix() - 10)}
+	require.NoError(t, o.CreateToken("tst/dev", etok))
+	_, err = db.TokenLogin(etok.Key)
+	require.Error(t, err)
+}
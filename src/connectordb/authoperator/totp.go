@@ -0,0 +1,48 @@
+package authoperator
+
+import "connectordb/authoperator/permissions"
+
+// errorIfCantManageTOTP ensures that the operator can manage the two-factor authentication of the given user.
+// Two-factor authentication protects logins with the user's password, so the operator needs to be able to
+// change the user's password without the access that it has through a group.
+func (a *AuthOperator) errorIfCantManageTOTP(userID int64) error {
+	if err := a.errorIfToken(); err != nil {
+		return err
+	}
+	usr, err := a.Operator.ReadUserByID(userID)
+	if err != nil {
+		return permissions.ErrNoAccess
+	}
+	perm, ua, da, err := a.getAccountAccessLevels(usr.UserID, usr.Public)
+	if err != nil {
+		return err
+	}
+	if !permissions.GetWriteAccess(perm, ua).UserPassword || !permissions.GetWriteAccess(perm, da).UserPassword {
+		return permissions.ErrNoAccess
+	}
+	return nil
+}
+
+// EnrollTOTPByID starts two-factor enrollment for the given user, returning the new secret
+func (a *AuthOperator) EnrollTOTPByID(userID int64) (string, error) {
+	if err := a.errorIfCantManageTOTP(userID); err != nil {
+		return "", err
+	}
+	return a.Operator.EnrollTOTPByID(userID)
+}
+
+// ConfirmTOTPByID enables two-factor authentication for the given user, returning the recovery codes
+func (a *AuthOperator) ConfirmTOTPByID(userID int64, code string) ([]string, error) {
+	if err := a.errorIfCantManageTOTP(userID); err != nil {
+		return nil, err
+	}
+	return a.Operator.ConfirmTOTPByID(userID, code)
+}
+
+// ResetTOTPByID removes two-factor authentication from the given user
+func (a *AuthOperator) ResetTOTPByID(userID int64) error {
+	if err := a.errorIfCantManageTOTP(userID); err != nil {
+		return err
+	}
+	return a.Operator.ResetTOTPByID(userID)
+}
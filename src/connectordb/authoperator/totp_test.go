@@ -0,0 +1,60 @@
+package authoperator_test
+
+import (
+	"connectordb/users"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthTOTP(t *testing.T) {
+	db.Clear()
+	require.NoError(t, db.CreateUser(&users.UserMaker{User: users.User{Name: "tst", Email: "root@localhost", Password: "mypass", Role: "user", Public: true}}))
+	require.NoError(t, db.CreateUser(&users.UserMaker{User: users.User{Name: "tst2", Email: "root2@localhost", Password: "mypass", Role: "user", Public: true}}))
+	require.NoError(t, db.CreateDevice("tst/dev", &users.DeviceMaker{}))
+
+	o, err := db.UserLogin("tst", "mypass")
+	require.NoError(t, err)
+	dev, err := db.AsDevice("tst/dev")
+	require.NoError(t, err)
+
+	// Only those who can change the user's password can manage two-factor authentication
+	_, err = o.EnrollTOTP("tst2")
+	require.Error(t, err)
+	_, err = dev.EnrollTOTP("tst")
+	require.Error(t, err)
+
+	secret, err := o.EnrollTOTP("tst")
+	require.NoError(t, err)
+
+	// Enrollment is not complete until confirmed
+	_, err = db.UserLogin("tst", "mypass")
+	require.NoError(t, err)
+
+	code, err := users.TOTPCode(secret, time.Now())
+	require.NoError(t, err)
+	codes, err := o.ConfirmTOTP("tst", code)
+	require.NoError(t, err)
+
+	_, err = db.UserLogin("tst", "mypass")
+	require.Equal(t, users.ErrTOTPRequired, err)
+	_, err = db.UserLoginTOTP("tst", "mypass", "000000x")
+	require.Equal(t, users.ErrInvalidTOTP, err)
+	_, err = db.UserLoginTOTP("tst", "mypass", code)
+	require.NoError(t, err)
+	_, err = db.UserLoginTOTP("tst", "mypass", codes[0])
+	require.NoError(t, err)
+	_, err = db.UserLoginTOTP("tst", "mypass", codes[0])
+	require.Error(t, err)
+
+	// Device logins don't need the second factor
+	d, err := dev.Device()
+	require.NoError(t, err)
+	_, err = db.DeviceLogin(d.APIKey)
+	require.NoError(t, err)
+
+	require.NoError(t, db.ResetTOTP("tst"))
+	_, err = db.UserLogin("tst", "mypass")
+	require.NoError(t, err)
+}
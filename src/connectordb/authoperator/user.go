@@ -9,6 +9,9 @@ import (
 	pconfig "config/permissions"
 )
 
+// accountFields are the fields of a user which give control of the account
+var accountFields = []string{"password", "email", "role"}
+
 // CountUsers returns the total number of users of the entire database
 func (a *AuthOperator) CountUsers() (int64, error) {
 	perm := pconfig.Get()
@@ -106,6 +109,9 @@ func (a *AuthOperator) UserMaker() (*users.UserMaker, error) {
 
 // CreateUser creates the given user if the device has user creating permissions
 func (a *AuthOperator) CreateUser(um *users.UserMaker) error {
+	if err := a.errorIfToken(); err != nil {
+		return err
+	}
 	perm, u, _, ua, da, err := a.getAccessLevels(-1, um.Public, false)
 	if err != nil {
 		return err
@@ -173,6 +179,9 @@ func (a *AuthOperator) ReadUserToMap(username string) (map[string]interface{}, e
 // UpdateUserByID updates the user - fails if an attempt is made at updating fields
 // for which the device does not have permission
 func (a *AuthOperator) UpdateUserByID(userID int64, updates map[string]interface{}) error {
+	if err := a.errorIfToken(); err != nil {
+		return err
+	}
 	usr, err := a.Operator.ReadUserByID(userID)
 	if err != nil {
 		return permissions.ErrNoAccess
@@ -185,17 +194,37 @@ func (a *AuthOperator) UpdateUserByID(userID int64, updates map[string]interface
 	if err != nil {
 		return err
 	}
+
+	// The fields which give control of the account are checked without the operator's access through a group
+	account := make(map[string]interface{})
+	for _, field := range accountFields {
+		if v, ok := updates[field]; ok {
+			account[field] = v
+		}
+	}
+	if len(account) > 0 {
+		perm, ua, da, err = a.getAccountAccessLevels(usr.UserID, usr.Public)
+		if err != nil {
+			return err
+		}
+		if err = permissions.CheckIfUpdateFieldsPermitted(perm, ua, da, "user", account); err != nil {
+			return err
+		}
+	}
 	return a.Operator.UpdateUserByID(userID, updates)
 }
 
 // DeleteUserByID removes the given user if the device has the associated permissions
 func (a *AuthOperator) DeleteUserByID(userID int64) error {
+	if err := a.errorIfToken(); err != nil {
+		return err
+	}
 	usr, err := a.Operator.ReadUserByID(userID)
 	if err != nil {
 		return permissions.ErrNoAccess
 	}
-	// A user is never self
-	_, _, _, ua, da, err := a.getAccessLevels(usr.UserID, usr.Public, false)
+	// Groups are deleted with DeleteGroupByID, so their owners and admins can't delete them here
+	_, ua, da, err := a.getAccountAccessLevels(usr.UserID, usr.Public)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,63 @@
+package authoperator
+
+import "connectordb/users"
+
+// Webhooks send the data of the user's streams outside of ConnectorDB, so they are managed with the same
+// permissions as the grants which share the data with other users and devices.
+
+// webhookOwner returns the ID of the user which owns the webhook's device or stream
+func (a *AuthOperator) webhookOwner(w *users.Webhook) (int64, error) {
+	return a.grantOwner(&users.Grant{DeviceID: w.DeviceID, StreamID: w.StreamID})
+}
+
+// CreateWebhookByID adds a webhook on a device or stream, which must belong to the logged in user
+func (a *AuthOperator) CreateWebhookByID(w *users.Webhook) error {
+	ownerID, err := a.webhookOwner(w)
+	if err != nil {
+		return err
+	}
+	if err = a.errorIfCantShare(ownerID); err != nil {
+		return err
+	}
+	return a.Operator.CreateWebhookByID(w)
+}
+
+// ReadWebhookByID reads the given webhook, if it is on a device or stream of the logged in user
+func (a *AuthOperator) ReadWebhookByID(webhookID int64) (*users.Webhook, error) {
+	w, err := a.Operator.ReadWebhookByID(webhookID)
+	if err != nil {
+		return nil, err
+	}
+	if err = a.errorIfCantShare(w.UserID); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// ReadAllWebhooksByUserID reads all of the webhooks of the logged in user
+func (a *AuthOperator) ReadAllWebhooksByUserID(userID int64) ([]*users.Webhook, error) {
+	if err := a.errorIfCantShare(userID); err != nil {
+		return nil, err
+	}
+	return a.Operator.ReadAllWebhooksByUserID(userID)
+}
+
+// ReadStreamWebhooksByID reads the webhooks of the given stream, if it belongs to the logged in user
+func (a *AuthOperator) ReadStreamWebhooksByID(streamID int64) ([]*users.Webhook, error) {
+	ownerID, err := a.webhookOwner(&users.Webhook{StreamID: streamID})
+	if err != nil {
+		return nil, err
+	}
+	if err = a.errorIfCantShare(ownerID); err != nil {
+		return nil, err
+	}
+	return a.Operator.ReadStreamWebhooksByID(streamID)
+}
+
+// DeleteWebhookByID removes the given webhook, if it is on a device or stream of the logged in user
+func (a *AuthOperator) DeleteWebhookByID(webhookID int64) error {
+	if _, err := a.ReadWebhookByID(webhookID); err != nil {
+		return err
+	}
+	return a.Operator.DeleteWebhookByID(webhookID)
+}
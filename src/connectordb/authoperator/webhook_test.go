@@ -0,0 +1,51 @@
+package authoperator_test
+
+import (
+	"connectordb/users"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthWebhook(t *testing.T) {
+	db.Clear()
+	require.NoError(t, db.CreateUser(&users.UserMaker{User: users.User{Name: "tst", Email: "root@localhost", Password: "mypass", Role: "user", Public: true}}))
+	require.NoError(t, db.CreateDevice("tst/owner", &users.DeviceMaker{Device: users.Device{Role: "user"}}))
+	require.NoError(t, db.CreateDevice("tst/none", &users.DeviceMaker{Device: users.Device{Role: "none"}}))
+	require.NoError(t, db.CreateStream("tst/owner/strm", &users.StreamMaker{Stream: users.Stream{Schema: `{"type": "integer"}`}}))
+	require.NoError(t, db.CreateUser(&users.UserMaker{User: users.User{Name: "tst2", Email: "root2@localhost", Password: "mypass", Role: "user", Public: false}}))
+	require.NoError(t, db.CreateDevice("tst2/dev", &users.DeviceMaker{Device: users.Device{Role: "user"}}))
+
+	o, err := db.AsDevice("tst/owner")
+	require.NoError(t, err)
+	onone, err := db.AsDevice("tst/none")
+	require.NoError(t, err)
+	o2, err := db.AsDevice("tst2/dev")
+	require.NoError(t, err)
+
+	// Only the owner's devices which can share its data can add webhooks
+	require.Error(t, o2.CreateWebhook("tst/owner/strm", &users.Webhook{URL: "http://localhost"}))
+	require.Error(t, onone.CreateWebhook("tst/owner/strm", &users.Webhook{URL: "http://localhost"}))
+	require.Error(t, o.CreateWebhook("tst", &users.Webhook{URL: "http://localhost"}))
+	require.Error(t, o.CreateWebhook("tst/owner/strm", &users.Webhook{URL: "http://localhost", Transform: "lol!!"}))
+	require.NoError(t, o.CreateWebhook("tst/owner/strm", &users.Webhook{URL: "http://localhost/strm"}))
+	require.NoError(t, o.CreateWebhook("tst/owner", &users.Webhook{URL: "http://localhost/dev", Transform: "if $ > 5"}))
+
+	webhooks, err := o.ReadUserWebhooks("tst")
+	require.NoError(t, err)
+	require.Len(t, webhooks, 2)
+	require.Equal(t, "tst/owner/strm", webhooks[0].Target)
+	require.Equal(t, "tst/owner", webhooks[1].Target)
+	require.Equal(t, "if $ > 5", webhooks[1].Transform)
+
+	_, err = o2.ReadUserWebhooks("tst")
+	require.Error(t, err)
+	_, err = o2.ReadWebhookByID(webhooks[0].WebhookID)
+	require.Error(t, err)
+	require.Error(t, o2.DeleteWebhookByID(webhooks[0].WebhookID))
+
+	require.NoError(t, o.DeleteWebhookByID(webhooks[0].WebhookID))
+	webhooks, err = o.ReadUserWebhooks("tst")
+	require.NoError(t, err)
+	require.Len(t, webhooks, 1)
+}
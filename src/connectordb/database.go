@@ -3,19 +3,25 @@ package connectordb
 
 import (
 	"config"
+	"connectordb/audit"
 	"connectordb/datastream"
 	"connectordb/datastream/rediscache"
 	"connectordb/messenger"
 	"connectordb/operator"
 	"connectordb/pathwrapper"
+	"connectordb/query"
 	"connectordb/users"
+	"connectordb/webhook"
+	"context"
 	"dbsetup/dbutil"
 	"errors"
+	"sync/atomic"
 	"time"
 	"util"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/jmoiron/sqlx"
+	"github.com/nats-io/nats"
 )
 
 //The ConnectorDB version string
@@ -42,6 +48,22 @@ type Database struct {
 	Messenger  *messenger.Messenger   //messenger is a connection to the messaging client
 
 	Sqldb *sqlx.DB //We only need the sql object here to close it properly, since it is used everywhere.
+
+	QueryCache *query.ResultCache //QueryCache holds the results of recent stream queries. It is nil if disabled.
+
+	Auditor audit.Sink //Auditor records the accesses of logged in devices to the data of streams. It is nil if disabled.
+
+	Webhooks *webhook.Dispatcher //Webhooks delivers inserted data to the webhooks of streams. It is nil unless started.
+
+	querycachechan chan messenger.Message
+	querycachesub  *nats.Subscription
+
+	writerRunning int32  //Whether RunWriter is running in this process, accessed atomically
+	writerErrors  uint32 //The number of times that the writer failed, accessed atomically
+
+	tx *users.Transaction //The transaction of the user database, if the database was returned by Begin
+
+	ctx context.Context //The context of the reads of stream data, if the database was returned by WithContext
 }
 
 // Open ConnectorDB is given an Options object, which holds the information necessary to connect to the database
@@ -86,6 +108,19 @@ func Open(opt *config.Options) (dbp *Database, err error) {
 		return nil, err
 	}
 
+	if opt.QueryCacheSize > 0 {
+		log.Debugf("Starting query result cache")
+		if err = db.startQueryCache(opt.QueryCacheSize); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	if db.Auditor, err = audit.Open(&opt.Audit, &db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
 	// Close the database when the system exits just in case it isn't.
 	util.CloseOnExit(&db)
 
@@ -96,6 +131,17 @@ func Open(opt *config.Options) (dbp *Database, err error) {
 //Close closes all database connections and releases all resources.
 //A word of warning though: If RunWriter() is functional, then RunWriter will crash
 func (db *Database) Close() {
+	if db.querycachesub != nil {
+		db.querycachesub.Unsubscribe()
+		close(db.querycachechan)
+		db.querycachesub = nil
+	}
+	if db.Auditor != nil {
+		db.Auditor.Close()
+	}
+	if db.Webhooks != nil {
+		db.Webhooks.Close()
+	}
 	if db.DataStream != nil {
 		db.DataStream.Close()
 	}
@@ -127,20 +173,48 @@ this database, then Ndb.
 PS: RunWriter will be entirely eliminated fairly soon, since it is the main thing stopping usage of Redis cluster
 */
 func (db *Database) RunWriter() {
+	atomic.StoreInt32(&db.writerRunning, 1)
 	// We try to keey the writer running no matter what happens.
 	for {
 		err := db.DataStream.RunWriter()
+		atomic.AddUint32(&db.writerErrors, 1)
 		//This error display interferes with benchmarks which is annoying.
 		log.Errorf("DBWriter error: %v", err.Error())
 		time.Sleep(time.Second) // Sleep for one second, and see if we can restart the writer.
 	}
 }
 
+// WriterStatus holds the state of the writer which moves data from the cache to the sql database, for monitoring
+type WriterStatus struct {
+	Running   bool      // Whether RunWriter is running in this process. The writer might be run by another process.
+	Errors    uint32    // The number of times that the writer failed, and was restarted
+	LastWrite time.Time // The time at which the writer last wrote a chunk of batches, or started
+	Queue     int64     // The number of batches in the cache which are waiting to be written
+}
+
+// WriterStatus returns the state of the writer. The queue of batches is shared by all processes,
+// while the rest of the status is of the writer in this process.
+func (db *Database) WriterStatus() (*WriterStatus, error) {
+	queue, err := db.DataStream.BatchQueueLength()
+	if err != nil {
+		return nil, err
+	}
+	return &WriterStatus{
+		Running:   atomic.LoadInt32(&db.writerRunning) == 1,
+		Errors:    atomic.LoadUint32(&db.writerErrors),
+		LastWrite: db.DataStream.LastWrite(),
+		Queue:     queue,
+	}, nil
+}
+
 // Clear clears the database (to be used for debugging purposes - NEVER in production)
 // It makes ALL the data go POOF
 func (db *Database) Clear() {
 	db.DataStream.Clear()
 	db.Userdb.Clear()
+	if db.QueryCache != nil {
+		db.QueryCache.Clear()
+	}
 }
 
 // Name is the "Name" of the database. It is needed to conform to the Operator interface
@@ -158,7 +232,41 @@ func (db *Database) Device() (*users.Device, error) {
 	return nil, ErrAdmin
 }
 
+// WithContext returns a copy of the database whose reads of stream data stop once ctx is done, such as when
+// the client of a query disconnects
+func (db *Database) WithContext(ctx context.Context) *Database {
+	ctxdb := *db
+	ctxdb.ctx = ctx
+	ctxdb.Wrapper = pathwrapper.Wrap(&ctxdb)
+	return &ctxdb
+}
+
+// context returns the context of the reads of stream data
+func (db *Database) context() context.Context {
+	if db.ctx == nil {
+		return context.Background()
+	}
+	return db.ctx
+}
+
 // AdminOperator is just the database
 func (db *Database) AdminOperator() operator.PathOperator {
 	return db
 }
+
+// startQueryCache sets up the query result cache, and subscribes to all inserts, so that the cached results
+// of a stream are removed as soon as new data is inserted into it.
+func (db *Database) startQueryCache(size int64) (err error) {
+	db.QueryCache = query.NewResultCache(size)
+	db.querycachechan = make(chan messenger.Message, 100)
+	db.querycachesub, err = db.Messenger.Subscribe(">", db.querycachechan)
+	if err != nil {
+		return err
+	}
+	go func(c *query.ResultCache, chn chan messenger.Message) {
+		for m := range chn {
+			c.InvalidateStream(m.Stream)
+		}
+	}(db.QueryCache, db.querycachechan)
+	return nil
+}
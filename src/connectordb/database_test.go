@@ -8,6 +8,7 @@ import (
 	"config"
 	"log"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -33,3 +34,43 @@ func TestDataBaseBasics(t *testing.T) {
 	require.Error(t, err)
 
 }
+
+func TestReadiness(t *testing.T) {
+	r := Tdb.Readiness()
+	require.True(t, r.Ready, "%+v", r)
+	require.True(t, r.SQL.Healthy)
+	require.True(t, r.Redis.Healthy)
+	require.True(t, r.NATS.Healthy)
+	require.True(t, r.Writer.Healthy)
+
+	// A database which can't reach the sql database is not ready
+	db, err := Open(config.TestConfiguration.Options())
+	require.NoError(t, err)
+	defer db.Close()
+	require.True(t, db.Readiness().Ready)
+	db.Sqldb.Close()
+	r = db.Readiness()
+	require.False(t, r.Ready)
+	require.False(t, r.SQL.Healthy)
+	require.NotEmpty(t, r.SQL.Error)
+	require.True(t, r.Redis.Healthy)
+}
+
+func TestWriterHealth(t *testing.T) {
+	stalled := time.Now().Add(-2 * WriterStallTime)
+
+	h := writerHealth(&WriterStatus{Running: true, Errors: 2, LastWrite: stalled, Queue: 10}, 10)
+	require.False(t, h.Healthy)
+	require.Contains(t, h.Error, "10 batches waiting")
+	require.EqualValues(t, 2, h.Errors)
+	require.InDelta(t, float64(stalled.UnixNano())*1e-9, h.LastWrite, 1e-3)
+
+	// The writer only writes full chunks, is only checked in the process that runs it, and is fine if it wrote recently
+	require.True(t, writerHealth(&WriterStatus{Running: true, LastWrite: stalled, Queue: 9}, 10).Healthy)
+	require.True(t, writerHealth(&WriterStatus{Running: false, LastWrite: stalled, Queue: 10}, 10).Healthy)
+	require.True(t, writerHealth(&WriterStatus{Running: true, LastWrite: time.Now(), Queue: 100}, 10).Healthy)
+
+	h = writerHealth(&WriterStatus{}, 10)
+	require.True(t, h.Healthy)
+	require.Zero(t, h.LastWrite)
+}
@@ -18,6 +18,7 @@ type Cache interface {
 	ReadBatches(batchnumber int) ([]Batch, error)
 	ReadRange(deviceID, streamID int64, substream string, i1, i2 int64) (DatapointArray, int64, int64, error)
 	ClearBatches(b []Batch) error
+	BatchQueueLength() (int64, error)
 	Close() error
 	Clear() error
 }
@@ -59,6 +59,11 @@ func (r *TimeRange) Index() int64 {
 	return r.dr.Index()
 }
 
+//ProfileChildren instruments the underlying ExtendedDataRange
+func (r *TimeRange) ProfileChildren(p *RangeProfile) {
+	r.dr = p.WrapExtended(r.dr, "")
+}
+
 //Close closes the internal ExtendedDataRange
 func (r *TimeRange) Close() {
 	r.dr.Close()
@@ -134,6 +139,11 @@ type NumRange struct {
 	numleft int64 //The number of datapoints left to return
 }
 
+//ProfileChildren instruments the underlying ExtendedDataRange
+func (r *NumRange) ProfileChildren(p *RangeProfile) {
+	r.dr = p.WrapExtended(r.dr, "")
+}
+
 //Close closes the internal ExtendedDataRange
 func (r *NumRange) Close() {
 	r.dr.Close()
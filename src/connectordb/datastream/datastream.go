@@ -5,8 +5,11 @@ Licensed under the MIT license.
 package datastream
 
 import (
+	"context"
 	"errors"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/jmoiron/sqlx"
@@ -24,6 +27,9 @@ type DataStream struct {
 
 	//ChunkSize is the number of batches to write to postgres in one transaction.
 	ChunkSize int
+
+	//The time.Time at which the writer last wrote a chunk
+	lastWrite atomic.Value
 }
 
 //OpenDataStream does just that - it opens the DataStream
@@ -32,7 +38,7 @@ func OpenDataStream(c Cache, sd *sqlx.DB, chunksize int) (ds *DataStream, err er
 	if err != nil {
 		return nil, err
 	}
-	return &DataStream{c, sqls, chunksize}, nil
+	return &DataStream{cache: c, sqls: sqls, ChunkSize: chunksize}, nil
 }
 
 //Close releases all resources held by the DataStream. It does NOT close open ExtendedDataRanges
@@ -93,7 +99,23 @@ func (ds *DataStream) WriteChunk() error {
 	if err = ds.sqls.WriteBatches(b); err != nil {
 		return err
 	}
-	return ds.cache.ClearBatches(b)
+	if err = ds.cache.ClearBatches(b); err != nil {
+		return err
+	}
+	ds.lastWrite.Store(time.Now())
+	return nil
+}
+
+//LastWrite returns the time at which the writer of this process last wrote a chunk of batches to the sql database,
+//or started writing. It is the zero time if the writer isn't run by this process.
+func (ds *DataStream) LastWrite() time.Time {
+	t, _ := ds.lastWrite.Load().(time.Time)
+	return t
+}
+
+//BatchQueueLength returns the number of batches in the cache which are waiting to be written to the sql database
+func (ds *DataStream) BatchQueueLength() (int64, error) {
+	return ds.cache.BatchQueueLength()
 }
 
 //WriteQueue writes the queue of leftover data that might have been half-processed
@@ -133,6 +155,9 @@ func (ds *DataStream) WriteQueue() error {
 func (ds *DataStream) RunWriter() error {
 	log.Debug("Starting Database Writer")
 	err := ds.WriteQueue()
+	if err == nil {
+		ds.lastWrite.Store(time.Now())
+	}
 	log.Debug("Running DBWriter")
 	for err == nil {
 		err = ds.WriteChunk()
@@ -144,6 +169,11 @@ func (ds *DataStream) RunWriter() error {
 //Indices can be python-like, meaning i1 and i2 negative mean "from the end", and i2=0
 //means to the end.
 func (ds *DataStream) IRange(device int64, stream int64, substream string, i1 int64, i2 int64) (dr ExtendedDataRange, err error) {
+	return ds.IRangeContext(context.Background(), device, stream, substream, i1, i2)
+}
+
+//IRangeContext is the same as IRange, but the range stops reading from the sql database once ctx is done
+func (ds *DataStream) IRangeContext(ctx context.Context, device int64, stream int64, substream string, i1 int64, i2 int64) (dr ExtendedDataRange, err error) {
 	dpa, i1, i2, err := ds.cache.ReadRange(device, stream, substream, i1, i2)
 	if err != nil || i1 == i2 {
 		return EmptyRange{}, err
@@ -155,10 +185,11 @@ func (ds *DataStream) IRange(device int64, stream int64, substream string, i1 in
 
 	//At least part of the range was in sql. So query sql with it, and return the StreamRange
 	//object with the correct initialization
-	sqlr, i1, err := ds.sqls.GetByIndex(stream, substream, i1)
+	sqlr, i1, err := ds.sqls.GetByIndexContext(ctx, stream, substream, i1)
 
 	return NewNumRange(&StreamRange{
 		ds:        ds,
+		ctx:       ctx,
 		dr:        sqlr,
 		index:     i1,
 		deviceID:  device,
@@ -169,8 +200,13 @@ func (ds *DataStream) IRange(device int64, stream int64, substream string, i1 in
 
 //TRange returns a ExtendedDataRange of datapoints which are in the given range of timestamp.
 func (ds *DataStream) TRange(device int64, stream int64, substream string, t1, t2 float64) (dr ExtendedDataRange, err error) {
+	return ds.TRangeContext(context.Background(), device, stream, substream, t1, t2)
+}
+
+//TRangeContext is the same as TRange, but the range stops reading from the sql database once ctx is done
+func (ds *DataStream) TRangeContext(ctx context.Context, device int64, stream int64, substream string, t1, t2 float64) (dr ExtendedDataRange, err error) {
 	//TRange works a bit differently from IRange, since time ranges go straight to postgres
-	sqlr, startindex, err := ds.sqls.GetByTime(stream, substream, t1)
+	sqlr, startindex, err := ds.sqls.GetByTimeContext(ctx, stream, substream, t1)
 
 	if err != nil {
 		return EmptyRange{}, err
@@ -178,6 +214,7 @@ func (ds *DataStream) TRange(device int64, stream int64, substream string, t1, t
 
 	return NewTimeRange(&StreamRange{
 		ds:        ds,
+		ctx:       ctx,
 		dr:        sqlr,
 		index:     startindex,
 		deviceID:  device,
@@ -200,8 +237,13 @@ func (ds *DataStream) GetTimeIndex(device int64, stream int64, substream string,
 //TODO: This function can be made much more efficient with a bit of cleverness regarding the underlying
 //	ExtendedDataRanges
 func (ds *DataStream) TimePlusIndexRange(device int64, stream int64, substream string, t1, t2 float64, i int64) (ExtendedDataRange, error) {
+	return ds.TimePlusIndexRangeContext(context.Background(), device, stream, substream, t1, t2, i)
+}
+
+//TimePlusIndexRangeContext is the same as TimePlusIndexRange, but the range stops reading from the sql database once ctx is done
+func (ds *DataStream) TimePlusIndexRangeContext(ctx context.Context, device int64, stream int64, substream string, t1, t2 float64, i int64) (ExtendedDataRange, error) {
 	//First off, we get the TRange
-	dr, err := ds.TRange(device, stream, substream, t1, t2)
+	dr, err := ds.TRangeContext(ctx, device, stream, substream, t1, t2)
 	if err != nil {
 		return nil, err
 	}
@@ -216,7 +258,7 @@ func (ds *DataStream) TimePlusIndexRange(device int64, stream int64, substream s
 			//	in particular the dataset interpolators
 			i = 0
 		}
-		irng, err := ds.IRange(device, stream, substream, i, 0)
+		irng, err := ds.IRangeContext(ctx, device, stream, substream, i, 0)
 		if err != nil {
 			return irng, err
 		}
@@ -75,6 +75,10 @@ func (m *MockCache) ClearBatches(b []Batch) error {
 	args := m.Called(b)
 	return args.Error(0)
 }
+func (m *MockCache) BatchQueueLength() (int64, error) {
+	args := m.Called()
+	return args.Get(0).(int64), args.Error(1)
+}
 func (m *MockCache) Close() error {
 	return nil
 }
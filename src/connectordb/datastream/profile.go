@@ -0,0 +1,146 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package datastream
+
+import (
+	"reflect"
+	"time"
+)
+
+//RangeProfile is a single node in the profile of a range stack. It holds the statistics of one range,
+//and the profiles of all the ranges that it reads from. All times are in seconds.
+type RangeProfile struct {
+	Name       string  `json:"name"`                  //The type of the range
+	Label      string  `json:"label,omitempty"`       //What the range represents, if it is not obvious from its position in the tree
+	Datapoints int64   `json:"datapoints"`            //The number of datapoints returned by the range
+	Bytes      int64   `json:"bytes,omitempty"`       //The number of bytes decoded from the database
+	DecodeTime float64 `json:"decode_time,omitempty"` //The time spent decoding data from the database
+	Time       float64 `json:"time"`                  //The total time spent in the range, including the ranges it reads from
+	SelfTime   float64 `json:"self_time"`             //The time spent in the range itself
+
+	Children []*RangeProfile `json:"children,omitempty"`
+}
+
+//Profilable is implemented by ranges which read from other ranges. ProfileChildren replaces each of the
+//underlying ranges with the result of p.Wrap (or p.WrapExtended), so that every layer of the stack is measured.
+type Profilable interface {
+	ProfileChildren(p *RangeProfile)
+}
+
+//decodeStats is implemented by ranges which decode data from the database
+type decodeStats interface {
+	DecodeStats() (bytes int64, decodetime time.Duration)
+}
+
+//Node adds a child to p for a part of the query which is not a DataRange, such as the interpolator of a dataset
+//element. Its Time and Datapoints are to be measured by the caller, and the ranges that it reads from are to be
+//wrapped by the returned profile.
+func (p *RangeProfile) Node(name, label string) *RangeProfile {
+	c := &RangeProfile{Name: name, Label: label}
+	p.Children = append(p.Children, c)
+	return c
+}
+
+func (p *RangeProfile) child(dr DataRange, label string) *RangeProfile {
+	t := reflect.TypeOf(dr)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	c := p.Node(t.Name(), label)
+
+	if pr, ok := dr.(Profilable); ok {
+		pr.ProfileChildren(c)
+	}
+	return c
+}
+
+//Wrap instruments the given DataRange (and all of the ranges it reads from), adding its profile as a child of p.
+//The returned range must be used in place of dr.
+func (p *RangeProfile) Wrap(dr DataRange, label string) DataRange {
+	if edr, ok := dr.(ExtendedDataRange); ok {
+		return p.WrapExtended(edr, label)
+	}
+	return &profiledRange{dr, p.child(dr, label)}
+}
+
+//WrapExtended is the same as Wrap, but it keeps the ExtendedDataRange interface of the wrapped range
+func (p *RangeProfile) WrapExtended(dr ExtendedDataRange, label string) ExtendedDataRange {
+	return &profiledExtendedRange{profiledRange{dr, p.child(dr, label)}, dr}
+}
+
+//Finish computes the SelfTime of the profile and all of its children. It is to be called once the range was read.
+func (p *RangeProfile) Finish() {
+	p.SelfTime = p.Time - p.DecodeTime
+	for _, c := range p.Children {
+		c.Finish()
+		p.SelfTime -= c.Time
+	}
+	if p.SelfTime < 0 {
+		// The children of ranges can be read during setup, which is not timed by their parent
+		p.SelfTime = 0
+	}
+}
+
+//Profile instruments the entire range stack of the given DataRange. The returned range is to be used in place of dr.
+//Once it is read, calling Finish on the returned RangeProfile gives the time spent in each layer of the stack.
+func Profile(dr DataRange) (DataRange, *RangeProfile) {
+	root := &RangeProfile{}
+	dr = root.Wrap(dr, "")
+	return dr, root.Children[0]
+}
+
+//profiledRange measures the time spent in a DataRange
+type profiledRange struct {
+	dr      DataRange
+	profile *RangeProfile
+}
+
+func (r *profiledRange) stats() {
+	if ds, ok := r.dr.(decodeStats); ok {
+		bytes, decodetime := ds.DecodeStats()
+		r.profile.Bytes = bytes
+		r.profile.DecodeTime = decodetime.Seconds()
+	}
+}
+
+//Close closes the underlying range
+func (r *profiledRange) Close() {
+	r.dr.Close()
+}
+
+//Next returns the next datapoint of the underlying range
+func (r *profiledRange) Next() (*Datapoint, error) {
+	start := time.Now()
+	dp, err := r.dr.Next()
+	r.profile.Time += time.Since(start).Seconds()
+	if dp != nil {
+		r.profile.Datapoints++
+	}
+	r.stats()
+	return dp, err
+}
+
+//profiledExtendedRange measures the time spent in an ExtendedDataRange
+type profiledExtendedRange struct {
+	profiledRange
+	edr ExtendedDataRange
+}
+
+//Index returns the index of the underlying range
+func (r *profiledExtendedRange) Index() int64 {
+	return r.edr.Index()
+}
+
+//NextArray returns the next array of the underlying range
+func (r *profiledExtendedRange) NextArray() (*DatapointArray, error) {
+	start := time.Now()
+	dpa, err := r.edr.NextArray()
+	r.profile.Time += time.Since(start).Seconds()
+	if dpa != nil {
+		r.profile.Datapoints += int64(dpa.Length())
+	}
+	r.stats()
+	return dpa, err
+}
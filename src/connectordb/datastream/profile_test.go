@@ -0,0 +1,36 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package datastream
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfile(t *testing.T) {
+	dr, p := Profile(NewNumRange(NewDatapointArrayRange(dpa7, 0), 3))
+	defer dr.Close()
+
+	_, ok := dr.(ExtendedDataRange)
+	require.True(t, ok, "Profiling must keep the ExtendedDataRange interface")
+
+	for i := 0; i < 3; i++ {
+		d, err := dr.Next()
+		require.NoError(t, err)
+		require.NotNil(t, d)
+	}
+	d, err := dr.Next()
+	require.NoError(t, err)
+	require.Nil(t, d)
+	p.Finish()
+
+	require.Equal(t, "NumRange", p.Name)
+	require.EqualValues(t, 3, p.Datapoints)
+	require.Len(t, p.Children, 1)
+	require.Equal(t, "DatapointArrayRange", p.Children[0].Name)
+	require.EqualValues(t, 3, p.Children[0].Datapoints)
+	require.True(t, p.Time >= p.Children[0].Time)
+}
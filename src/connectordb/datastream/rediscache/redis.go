@@ -277,6 +277,7 @@ var (
 //Quite annoyingly, go-redis does not give an interface using which we can connect to redis. We therefore manually create one
 type redisConnection interface {
 	LRange(key string, start, stop int64) *redis.StringSliceCmd
+	LLen(key string) *redis.IntCmd
 	HGet(key, field string) *redis.StringCmd
 	HKeys(key string) *redis.StringSliceCmd
 	Del(keys ...string) *redis.IntCmd
@@ -492,6 +493,11 @@ func (rc *RedisConnection) GetList(listkey string) ([]string, error) {
 	return rc.Redis.LRange(listkey, 0, -1).Result()
 }
 
+//ListLength returns the number of elements in the given list
+func (rc *RedisConnection) ListLength(listkey string) (int64, error) {
+	return rc.Redis.LLen(listkey).Result()
+}
+
 //DeleteKey removes the given key from the database
 func (rc *RedisConnection) DeleteKey(key string) error {
 	return rc.Redis.Del(key).Err()
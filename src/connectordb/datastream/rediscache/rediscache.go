@@ -98,6 +98,11 @@ func (r RedisCache) ReadBatches(batchnumber int) ([]datastream.Batch, error) {
 	return barray, nil
 }
 
+//BatchQueueLength returns the number of batches waiting in the batch list to be written
+func (r RedisCache) BatchQueueLength() (int64, error) {
+	return r.ListLength("BATCHLIST")
+}
+
 //ReadRange reads the given range from the given stream
 func (r RedisCache) ReadRange(deviceID, streamID int64, substream string, i1, i2 int64) (datastream.DatapointArray, int64, int64, error) {
 	return r.Range(
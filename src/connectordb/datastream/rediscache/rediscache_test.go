@@ -33,9 +33,17 @@ func TestRedisCache(t *testing.T) {
 	require.NoError(t, err)
 	require.Nil(t, b)
 
+	i, err = r.BatchQueueLength()
+	require.NoError(t, err)
+	require.EqualValues(t, 2, i)
+
 	b, err = r.ReadBatches(1)
 	require.NoError(t, err)
 
+	i, err = r.BatchQueueLength()
+	require.NoError(t, err)
+	require.EqualValues(t, 1, i)
+
 	b2, err := r.ReadProcessingQueue()
 	require.NoError(t, err)
 	require.EqualValues(t, b, b2)
@@ -5,6 +5,7 @@ Licensed under the MIT license.
 package datastream
 
 import (
+	"context"
 	"errors"
 	"time"
 
@@ -115,7 +116,11 @@ func (s *SqlStore) Clear() error {
 //In effect, if the datapoints in a key were all in one huge array, returns array.length
 //(not including the datapoints which are not yet committed to the SqlStore)
 func (s *SqlStore) GetEndIndex(streamID int64, substream string) (ei int64, err error) {
-	rows, err := s.endindex.Query(streamID, substream)
+	return s.getEndIndex(context.Background(), streamID, substream)
+}
+
+func (s *SqlStore) getEndIndex(ctx context.Context, streamID int64, substream string) (ei int64, err error) {
+	rows, err := s.endindex.QueryContext(ctx, streamID, substream)
 	if err != nil {
 		return 0, err
 	}
@@ -203,13 +208,18 @@ func (s *SqlStore) DeleteSubstream(streamID int64, substream string) error {
 
 //GetByTime returns a ExtendedDataRange of datapoints starting at the starttime
 func (s *SqlStore) GetByTime(streamID int64, substream string, starttime float64) (dr ExtendedDataRange, startindex int64, err error) {
-	rows, err := s.timequery.Query(streamID, substream, starttime)
+	return s.GetByTimeContext(context.Background(), streamID, substream, starttime)
+}
+
+//GetByTimeContext is the same as GetByTime, but the query stops reading from the database once ctx is done
+func (s *SqlStore) GetByTimeContext(ctx context.Context, streamID int64, substream string, starttime float64) (dr ExtendedDataRange, startindex int64, err error) {
+	rows, err := s.timequery.QueryContext(ctx, streamID, substream, starttime)
 	if err != nil {
 		return nil, 0, err
 	}
 
 	if !rows.Next() { //Check if there is any data to read
-		startindex, err = s.GetEndIndex(streamID, substream)
+		startindex, err = s.getEndIndex(ctx, streamID, substream)
 		if rows.Err() != nil {
 			err = rows.Err()
 		}
@@ -225,11 +235,13 @@ func (s *SqlStore) GetByTime(streamID int64, substream string, starttime float64
 		return EmptyRange{}, endindex, err
 	}
 
+	decodestart := time.Now()
 	da, err := DecodeDatapointArray(data, version)
 	if err != nil {
 		rows.Close()
 		return EmptyRange{}, endindex, err
 	}
+	decodetime := time.Since(decodestart)
 	tmp := da.TStart(starttime)
 	da = &tmp
 	if da == nil || int64(da.Length()) > endindex {
@@ -237,18 +249,23 @@ func (s *SqlStore) GetByTime(streamID int64, substream string, starttime float64
 		return EmptyRange{}, endindex, ErrorDatabaseCorrupted
 	}
 	curindex := endindex - int64(da.Length())
-	return &SqlRange{rows, da, curindex}, curindex, nil
+	return &SqlRange{r: rows, da: da, index: curindex, bytes: int64(len(data)), decodetime: decodetime}, curindex, nil
 }
 
 //GetByIndex returns a ExtendedDataRange of datapoints starting at the nearest dataindex to the given startindex
 func (s *SqlStore) GetByIndex(streamID int64, substream string, startindex int64) (dr ExtendedDataRange, dataindex int64, err error) {
-	rows, err := s.indexquery.Query(streamID, substream, startindex)
+	return s.GetByIndexContext(context.Background(), streamID, substream, startindex)
+}
+
+//GetByIndexContext is the same as GetByIndex, but the query stops reading from the database once ctx is done
+func (s *SqlStore) GetByIndexContext(ctx context.Context, streamID int64, substream string, startindex int64) (dr ExtendedDataRange, dataindex int64, err error) {
+	rows, err := s.indexquery.QueryContext(ctx, streamID, substream, startindex)
 	if err != nil {
 		return nil, 0, err
 	}
 
 	if !rows.Next() { //Check if there is any data to read
-		startindex, err = s.GetEndIndex(streamID, substream)
+		startindex, err = s.getEndIndex(ctx, streamID, substream)
 		if rows.Err() != nil {
 			err = rows.Err()
 		}
@@ -264,11 +281,13 @@ func (s *SqlStore) GetByIndex(streamID int64, substream string, startindex int64
 		return EmptyRange{}, endindex, err
 	}
 
+	decodestart := time.Now()
 	da, err := DecodeDatapointArray(data, version)
 	if err != nil {
 		rows.Close()
 		return EmptyRange{}, endindex, err
 	}
+	decodetime := time.Since(decodestart)
 
 	if da == nil || int64(da.Length()) > endindex {
 		rows.Close()
@@ -283,5 +302,5 @@ func (s *SqlStore) GetByIndex(streamID int64, substream string, startindex int64
 		da = da.IRange(da.Length()-int(fromend), da.Length())
 	}
 	curindex := endindex - int64(da.Length())
-	return &SqlRange{rows, da, curindex}, curindex, nil
+	return &SqlRange{r: rows, da: da, index: curindex, bytes: int64(len(data)), decodetime: decodetime}, curindex, nil
 }
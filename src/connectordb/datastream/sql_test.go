@@ -5,6 +5,7 @@ Licensed under the MIT license.
 package datastream
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -285,3 +286,20 @@ func BenchmarkSql1000Read(b *testing.B) {
 		r.Close()
 	}
 }
+
+func TestGetContext(t *testing.T) {
+	sdb.Clear()
+	require.NoError(t, sdb.Append(1, "", dpa6))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	dr, _, err := sdb.GetByIndexContext(ctx, 1, "", 0)
+	require.NoError(t, err)
+	dr.Close()
+
+	// The queries of a canceled context don't read from the database
+	cancel()
+	_, _, err = sdb.GetByIndexContext(ctx, 1, "", 0)
+	require.Equal(t, context.Canceled, err)
+	_, _, err = sdb.GetByTimeContext(ctx, 1, "", 0)
+	require.Equal(t, context.Canceled, err)
+}
@@ -4,13 +4,24 @@ Licensed under the MIT license.
 **/
 package datastream
 
-import "database/sql"
+import (
+	"database/sql"
+	"time"
+)
 
 //SqlRange is a range object that conforms to the range interface
 type SqlRange struct {
 	r     *sql.Rows
 	da    *DatapointArray
 	index int64
+
+	bytes      int64         //The number of bytes decoded so far
+	decodetime time.Duration //The time spent decoding
+}
+
+//DecodeStats returns the number of bytes decoded from the database, and the time it took to decode them
+func (s *SqlRange) DecodeStats() (int64, time.Duration) {
+	return s.bytes, s.decodetime
 }
 
 //Close clears all resources used by the sqlRange
@@ -55,7 +66,11 @@ func (s *SqlRange) NextArray() (da *DatapointArray, err error) {
 		s.Close()
 		return nil, err
 	}
-	if s.da, err = DecodeDatapointArray(data, version); err != nil {
+	decodestart := time.Now()
+	s.da, err = DecodeDatapointArray(data, version)
+	s.decodetime += time.Since(decodestart)
+	s.bytes += int64(len(data))
+	if err != nil {
 		s.Close()
 		return nil, err
 	}
@@ -4,15 +4,28 @@ Licensed under the MIT license.
 **/
 package datastream
 
+import "context"
+
 //StreamRange is a ExtendedDataRange that combines the redis and sql data into one coherent stream
 type StreamRange struct {
-	ds *DataStream
-	dr ExtendedDataRange
+	ds  *DataStream
+	dr  ExtendedDataRange
+	ctx context.Context //The context of the sql queries, which are run again once the range runs out of data
 
 	index     int64
 	deviceID  int64
 	streamID  int64
 	substream string
+
+	profile *RangeProfile //If the range is being profiled, the ranges read later on are added to the profile
+}
+
+//ProfileChildren instruments the underlying range, as well as any ranges that will be read once it runs out of data
+func (d *StreamRange) ProfileChildren(p *RangeProfile) {
+	d.profile = p
+	if d.dr != nil {
+		d.dr = p.WrapExtended(d.dr, "")
+	}
 }
 
 //Close the StreamRange
@@ -40,7 +53,10 @@ func (d *StreamRange) getNextExtendedDataRange() (err error) {
 	// At this point, d.dr is already assumed to be closed, but just to make sure,
 	// we close it again
 	d.Close()
-	d.dr, err = d.ds.IRange(d.deviceID, d.streamID, d.substream, d.index, 0)
+	d.dr, err = d.ds.IRangeContext(d.ctx, d.deviceID, d.streamID, d.substream, d.index, 0)
+	if err == nil && d.profile != nil {
+		d.dr = d.profile.WrapExtended(d.dr, "")
+	}
 	return err
 }
 
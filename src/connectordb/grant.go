@@ -0,0 +1,68 @@
+package connectordb
+
+import (
+	"connectordb/users"
+	"errors"
+)
+
+// CreateGrantByID gives the grantee access to the grant's target, both of which are given by their IDs.
+// The owner of the grant is set to the user that owns the target.
+func (db *Database) CreateGrantByID(g *users.Grant) error {
+	switch {
+	case g.StreamID > 0:
+		s, err := db.ReadStreamByID(g.StreamID)
+		if err != nil {
+			return err
+		}
+		dev, err := db.ReadDeviceByID(s.DeviceID)
+		if err != nil {
+			return err
+		}
+		g.OwnerID = dev.UserID
+	case g.DeviceID > 0:
+		dev, err := db.ReadDeviceByID(g.DeviceID)
+		if err != nil {
+			return err
+		}
+		g.OwnerID = dev.UserID
+	case g.UserID > 0:
+		g.OwnerID = g.UserID
+	default:
+		return users.ErrInvalidGrant
+	}
+
+	if g.GranteeUserID == g.OwnerID {
+		return errors.New("Can't give a grant to the user which owns its target")
+	}
+	if g.GranteeDeviceID > 0 {
+		dev, err := db.ReadDeviceByID(g.GranteeDeviceID)
+		if err != nil {
+			return err
+		}
+		if dev.UserID == g.OwnerID {
+			return errors.New("Can't give a grant to a device of the user which owns its target")
+		}
+	}
+
+	return db.Userdb.CreateGrant(g)
+}
+
+// ReadGrantByID reads the given grant
+func (db *Database) ReadGrantByID(grantID int64) (*users.Grant, error) {
+	return db.Userdb.ReadGrantByID(grantID)
+}
+
+// ReadAllGrantsByUserID reads all of the grants given on the user and its devices and streams
+func (db *Database) ReadAllGrantsByUserID(userID int64) ([]*users.Grant, error) {
+	return db.Userdb.ReadGrantsByOwner(userID)
+}
+
+// ReadStreamGrantsByID reads the unexpired grants which give the given user or device access to the stream
+func (db *Database) ReadStreamGrantsByID(granteeUserID, granteeDeviceID, streamID int64) ([]*users.Grant, error) {
+	return db.Userdb.ReadStreamGrants(granteeUserID, granteeDeviceID, streamID)
+}
+
+// DeleteGrantByID removes the given grant
+func (db *Database) DeleteGrantByID(grantID int64) error {
+	return db.Userdb.DeleteGrant(grantID)
+}
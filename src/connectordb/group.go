@@ -0,0 +1,159 @@
+package connectordb
+
+import (
+	pconfig "config/permissions"
+	"connectordb/users"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nu7hatch/gouuid"
+)
+
+// ErrLastOwner is returned when removing the last owner of a group
+var ErrLastOwner = errors.New("A group must have at least one owner")
+
+// CreateGroupByID creates a group with the given name, owned by the given user. The group is backed by a
+// new user, which gets the role that the owner's role gives to users that join, and an address at
+// the owner's email, so that the group's emails reach its owner.
+func (db *Database) CreateGroupByID(groupname string, ownerID int64) error {
+	owner, err := db.ReadUserByID(ownerID)
+	if err != nil {
+		return err
+	}
+	if _, err = db.Userdb.ReadGroupByID(ownerID); err == nil {
+		return errors.New("A group can't be a member of a group")
+	}
+
+	perm := pconfig.Get()
+	ownerRole, ok := perm.UserRoles[owner.Role]
+	if !ok {
+		return fmt.Errorf("The given role '%s' does not exist", owner.Role)
+	}
+	r, ok := perm.UserRoles[ownerRole.JoinRole]
+	if !ok {
+		return fmt.Errorf("The given role '%s' does not exist", ownerRole.JoinRole)
+	}
+
+	i := strings.LastIndex(owner.Email, "@")
+	if i < 0 {
+		return users.ErrInvalidEmail
+	}
+	password, err := uuid.NewV4()
+	if err != nil {
+		return err
+	}
+
+	um := &users.UserMaker{}
+	um.Name = groupname
+	um.Email = owner.Email[:i] + "+" + groupname + owner.Email[i:]
+	um.Password = password.String()
+	um.Role = ownerRole.JoinRole
+	um.Public = !r.CanBePrivate
+	um.Description = r.CreateUserDefaults.Description
+	um.Icon = r.CreateUserDefaults.Icon
+	if err = db.CreateUser(um); err != nil {
+		return err
+	}
+
+	u, err := db.ReadUser(groupname)
+	if err != nil {
+		return err
+	}
+	if err = db.Userdb.CreateGroup(u.UserID, ownerID); err != nil {
+		db.DeleteUserByID(u.UserID)
+		return err
+	}
+	return nil
+}
+
+// ReadGroupByID reads the group backed by the given user
+func (db *Database) ReadGroupByID(groupID int64) (*users.Group, error) {
+	return db.Userdb.ReadGroupByID(groupID)
+}
+
+// ReadGroupMemberByID reads the membership of the user in the group
+func (db *Database) ReadGroupMemberByID(groupID, userID int64) (*users.GroupMember, error) {
+	return db.Userdb.ReadGroupMember(groupID, userID)
+}
+
+// ReadAllGroupMembersByID reads all of the members of the group
+func (db *Database) ReadAllGroupMembersByID(groupID int64) ([]*users.GroupMember, error) {
+	if _, err := db.Userdb.ReadGroupByID(groupID); err != nil {
+		return nil, err
+	}
+	return db.Userdb.ReadGroupMembers(groupID)
+}
+
+// ReadAllGroupsByUserID reads the user's memberships in all of its groups
+func (db *Database) ReadAllGroupsByUserID(userID int64) ([]*users.GroupMember, error) {
+	return db.Userdb.ReadGroupsByMember(userID)
+}
+
+// SetGroupMemberByID adds the user to the group with the given role, or changes the role of an existing member.
+// Groups can't be members of groups, and the last owner of a group can't be given another role.
+func (db *Database) SetGroupMemberByID(m *users.GroupMember) error {
+	if _, err := db.Userdb.ReadGroupByID(m.GroupID); err != nil {
+		return err
+	}
+	if _, err := db.Userdb.ReadGroupByID(m.UserID); err == nil {
+		return errors.New("A group can't be a member of a group")
+	}
+	if m.Role != users.GroupRoleOwner {
+		if err := db.errorIfLastOwner(m.GroupID, m.UserID); err != nil {
+			return err
+		}
+	}
+	return db.Userdb.SetGroupMember(m)
+}
+
+// DeleteGroupMemberByID removes the user from the group, unless it is the group's last owner
+func (db *Database) DeleteGroupMemberByID(groupID, userID int64) error {
+	if err := db.errorIfLastOwner(groupID, userID); err != nil {
+		return err
+	}
+	return db.Userdb.DeleteGroupMember(groupID, userID)
+}
+
+// errorIfLastOwner returns ErrLastOwner if the user is the only owner of the group. Invited owners
+// who did not accept yet don't count.
+func (db *Database) errorIfLastOwner(groupID, userID int64) error {
+	members, err := db.Userdb.ReadGroupMembers(groupID)
+	if err != nil {
+		return err
+	}
+	owners := 0
+	isowner := false
+	for _, m := range members {
+		if m.Role == users.GroupRoleOwner && m.Accepted {
+			owners++
+			isowner = isowner || m.UserID == userID
+		}
+	}
+	if isowner && owners == 1 {
+		return ErrLastOwner
+	}
+	return nil
+}
+
+// SharesGroupByID returns true if the two users are members of the same group
+func (db *Database) SharesGroupByID(userID, otherUserID int64) (bool, error) {
+	return db.Userdb.SharesGroup(userID, otherUserID)
+}
+
+// DeleteGroupByID deletes the group, along with the user backing it and the group's devices and streams
+func (db *Database) DeleteGroupByID(groupID int64) error {
+	if _, err := db.Userdb.ReadGroupByID(groupID); err != nil {
+		return err
+	}
+	members, err := db.Userdb.ReadGroupMembers(groupID)
+	if err != nil {
+		return err
+	}
+	for _, m := range members {
+		if err = db.Userdb.DeleteGroupMember(groupID, m.UserID); err != nil {
+			return err
+		}
+	}
+	return db.DeleteUserByID(groupID)
+}
@@ -0,0 +1,103 @@
+package connectordb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// WriterStallTime is how long the writer may go without writing while a chunk of batches is waiting,
+// before it is considered to be stuck
+var WriterStallTime = 5 * time.Minute
+
+// ReadinessTimeout is how long the readiness check waits for the sql database and NATS to respond
+var ReadinessTimeout = 2 * time.Second
+
+// ErrNATSDisconnected is given by the readiness check when a connection to NATS is down
+var ErrNATSDisconnected = errors.New("The connection to NATS is down")
+
+// ComponentHealth is the result of checking one of the services that ConnectorDB depends on
+type ComponentHealth struct {
+	Healthy bool    `json:"healthy"`
+	Error   string  `json:"error,omitempty"`
+	Latency float64 `json:"latency"` // The number of seconds that the check took
+}
+
+func checkComponent(start time.Time, err error) ComponentHealth {
+	c := ComponentHealth{Healthy: err == nil, Latency: time.Since(start).Seconds()}
+	if err != nil {
+		c.Error = err.Error()
+	}
+	return c
+}
+
+// WriterHealth is the state of the writer which moves data from the cache to the sql database
+type WriterHealth struct {
+	ComponentHealth
+	Running   bool    `json:"running"`    // Whether the writer is run by this process
+	Errors    uint32  `json:"errors"`     // The number of times that the writer failed
+	LastWrite float64 `json:"last_write"` // The unix time at which the writer last wrote, or 0 if it is not run by this process
+	Backlog   int64   `json:"backlog"`    // The number of batches waiting to be written
+}
+
+// Readiness holds the state of each of the services, and whether all of them are healthy
+type Readiness struct {
+	Ready  bool            `json:"ready"`
+	SQL    ComponentHealth `json:"sql"`
+	Redis  ComponentHealth `json:"redis"`
+	NATS   ComponentHealth `json:"nats"`
+	Writer WriterHealth    `json:"writer"`
+}
+
+// Readiness checks the connections to the sql database, redis and NATS, and whether the writer is keeping up.
+// The writer is only checked if it is run by this process.
+func (db *Database) Readiness() *Readiness {
+	var r Readiness
+
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), ReadinessTimeout)
+	r.SQL = checkComponent(start, db.Sqldb.PingContext(ctx))
+	cancel()
+
+	start = time.Now()
+	var err error
+	if !db.Messenger.SendConn.IsConnected() || !db.Messenger.RecvConn.IsConnected() {
+		err = ErrNATSDisconnected
+	} else {
+		err = db.Messenger.SendConn.FlushTimeout(ReadinessTimeout)
+	}
+	r.NATS = checkComponent(start, err)
+
+	// Reading the length of the batch queue is a round trip to redis, so it doubles as the check of the cache
+	start = time.Now()
+	w, err := db.WriterStatus()
+	r.Redis = checkComponent(start, err)
+	if err != nil {
+		r.Writer.Error = "The batch queue could not be read"
+	} else {
+		r.Writer = writerHealth(w, db.DataStream.ChunkSize)
+	}
+
+	r.Ready = r.SQL.Healthy && r.Redis.Healthy && r.NATS.Healthy && r.Writer.Healthy
+	return &r
+}
+
+// writerHealth returns the health of the writer with the given status. The writer waits for a whole chunk of batches,
+// so it is only stuck if a chunk has been waiting for too long.
+func writerHealth(w *WriterStatus, chunkSize int) WriterHealth {
+	h := WriterHealth{
+		ComponentHealth: ComponentHealth{Healthy: true},
+		Running:         w.Running,
+		Errors:          w.Errors,
+		Backlog:         w.Queue,
+	}
+	if !w.LastWrite.IsZero() {
+		h.LastWrite = float64(w.LastWrite.UnixNano()) * 1e-9
+	}
+	if stalled := time.Since(w.LastWrite); w.Running && w.Queue >= int64(chunkSize) && stalled > WriterStallTime {
+		h.Healthy = false
+		h.Error = fmt.Sprintf("The writer has not written for %d seconds, with %d batches waiting", int64(stalled.Seconds()), w.Queue)
+	}
+	return h
+}
@@ -92,7 +92,7 @@ func (db *Database) GetStreamTimeRangeByID(streamID int64, substream string, t1
 		return nil, err
 	}
 
-	dr, err := db.DataStream.TRange(strm.DeviceID, strm.StreamID, substream, t1, t2)
+	dr, err := db.DataStream.TRangeContext(db.context(), strm.DeviceID, strm.StreamID, substream, t1, t2)
 
 	//Add a transform to the resulting data range if one is wanted
 	if transform != "" {
@@ -118,7 +118,7 @@ func (db *Database) GetShiftedStreamTimeRangeByID(streamID int64, substream stri
 		return nil, err
 	}
 
-	dr, err := db.DataStream.TimePlusIndexRange(strm.DeviceID, strm.StreamID, substream, t1, t2, shift)
+	dr, err := db.DataStream.TimePlusIndexRangeContext(db.context(), strm.DeviceID, strm.StreamID, substream, t1, t2, shift)
 	if err != nil {
 		return nil, err
 	}
@@ -146,7 +146,7 @@ func (db *Database) GetStreamIndexRangeByID(streamID int64, substream string, i1
 		return nil, err
 	}
 
-	dr, err := db.DataStream.IRange(strm.DeviceID, strm.StreamID, substream, i1, i2)
+	dr, err := db.DataStream.IRangeContext(db.context(), strm.DeviceID, strm.StreamID, substream, i1, i2)
 	if err != nil {
 		return nil, err
 	}
@@ -11,7 +11,12 @@ func (db *Database) DeviceAuthOperator(dev *users.Device) (*authoperator.AuthOpe
 	if err != nil {
 		return nil, err
 	}
-	return authoperator.NewAuthOperator(o, dev.DeviceID)
+	ao, err := authoperator.NewAuthOperator(o, dev.DeviceID)
+	if err != nil {
+		return nil, err
+	}
+	ao.SetAuditor(db.Auditor)
+	return ao, nil
 }
 
 // AsUser returns the AuthOperator for the given user
@@ -29,12 +34,58 @@ func (db *Database) AsDevice(devicepath string) (*authoperator.AuthOperator, err
 	return db.DeviceAuthOperator(dev)
 }
 
-// UserLogin attempts to log in using a username and password
+// TokenLogin logs in as the device of the given token. The operator is limited to the token's access.
+func (db *Database) TokenLogin(key string) (*authoperator.AuthOperator, error) {
+	token, err := db.Userdb.ReadTokenByKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if token.IsExpired() {
+		return nil, users.ErrTokenExpired
+	}
+	if token.Access == users.TokenRefresh {
+		return nil, users.ErrRefreshToken
+	}
+	dev, err := db.Userdb.ReadDeviceByID(token.DeviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	o, err := AddMetaLog(dev.UserID, db)
+	if err != nil {
+		return nil, err
+	}
+	ao, err := authoperator.NewTokenAuthOperator(o, token)
+	if err != nil {
+		return nil, err
+	}
+	ao.SetAuditor(db.Auditor)
+	return ao, nil
+}
+
+// UserLogin attempts to log in using a username and password. Users who enabled two-factor
+// authentication can't log in without a code, so they need to use UserLoginTOTP.
 func (db *Database) UserLogin(username, password string) (*authoperator.AuthOperator, error) {
-	_, dev, err := db.Userdb.Login(username, password)
+	return db.UserLoginTOTP(username, password, "")
+}
+
+// UserLoginTOTP attempts to log in using a username, password and two-factor authentication code.
+// The code can also be one of the user's recovery codes. It is ignored if the user did not enable two-factor authentication.
+func (db *Database) UserLoginTOTP(username, password, code string) (*authoperator.AuthOperator, error) {
+	u, dev, err := db.Userdb.Login(username, password)
+	if err != nil {
+		return nil, err
+	}
+	recovered, err := u.CheckSecondFactor(code)
 	if err != nil {
 		return nil, err
 	}
+	if recovered {
+		// The recovery code can't be used again
+		if err = db.Userdb.UpdateUser(u); err != nil {
+			return nil, err
+		}
+	}
 
 	return db.DeviceAuthOperator(dev)
 }
@@ -51,5 +102,7 @@ func (db *Database) DeviceLogin(apikey string) (*authoperator.AuthOperator, erro
 
 // Nobody returns the operator of a "nobody" - it will behave as someone who has "nobody" permissions
 func (db *Database) Nobody() *authoperator.AuthOperator {
-	return authoperator.NewNobody(db)
+	ao := authoperator.NewNobody(db)
+	ao.SetAuditor(db.Auditor)
+	return ao
 }
@@ -0,0 +1,42 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package connectordb
+
+import (
+	"connectordb/messenger"
+
+	"github.com/nats-io/nats"
+)
+
+// LoginUnlockRouting is the messenger routing on which login unlocks are sent to all servers. Usernames
+// start with a letter, so it can't be mistaken for the data of a user.
+const LoginUnlockRouting = "_login/unlock"
+
+// UnlockLogin removes the login lockout of the given username or ip address on all servers that are
+// connected to the database. The lockouts are held by each server, so the shell can't remove them directly.
+func (db *Database) UnlockLogin(target string) error {
+	err := db.Messenger.Publish(LoginUnlockRouting, messenger.Message{Stream: target})
+	db.Messenger.Flush()
+	return err
+}
+
+// SubscribeLoginUnlock subscribes to login unlocks. The target of the unlock is the message's Stream.
+func (db *Database) SubscribeLoginUnlock(chn chan messenger.Message) (*nats.Subscription, error) {
+	return db.Messenger.Subscribe(LoginUnlockRouting, chn)
+}
+
+// LogLoginEvent writes the given event of a user's logins (such as a lockout) to the user's meta/log stream
+func (db *Database) LogLoginEvent(username string, cmd string) error {
+	u, err := db.ReadUser(username)
+	if err != nil {
+		return err
+	}
+	ml, err := AddMetaLog(u.UserID, db)
+	if err != nil {
+		return err
+	}
+	ml.writeLog(cmd, u.Name)
+	return nil
+}
@@ -2,11 +2,14 @@ package connectordb
 
 import (
 	"connectordb/datastream"
+	"connectordb/messenger"
 	"connectordb/operator"
 	"connectordb/pathwrapper"
 	"connectordb/users"
 	"strings"
 
+	"github.com/nats-io/nats"
+
 	log "github.com/Sirupsen/logrus"
 )
 
@@ -41,6 +44,12 @@ func AddMetaLog(userID int64, o operator.Operator) (MetaLog, error) {
 	return ml, err
 }
 
+// SubscribeMetaLog subscribes to the meta log of the given user, which gets a datapoint whenever the user or one
+// of its devices or streams is created, updated or deleted. The meta log doesn't need to exist yet.
+func (db *Database) SubscribeMetaLog(username string, chn chan messenger.Message) (*nats.Subscription, error) {
+	return db.Messenger.Subscribe(username+"/meta/log", chn)
+}
+
 func (m MetaLog) checkcreate(path string) error {
 	_, err := m.AdminOperator().ReadStream(path)
 	if err != nil {
@@ -83,6 +83,45 @@ type Operator interface {
 	**/
 	GetShiftedStreamTimeRangeByID(streamID int64, substream string, t1 float64, t2 float64, shift, limit int64, transform string) (datastream.DataRange, error)
 
+	// Grants give specific users and devices access to a user, device or stream which they could not otherwise access.
+	// A grant on a user or device applies to all of its streams.
+	CreateGrantByID(g *users.Grant) error
+	ReadGrantByID(grantID int64) (*users.Grant, error)
+	ReadAllGrantsByUserID(userID int64) ([]*users.Grant, error)
+	ReadStreamGrantsByID(granteeUserID, granteeDeviceID, streamID int64) ([]*users.Grant, error)
+	DeleteGrantByID(grantID int64) error
+
+	// Tokens are named keys which log in as their device, but can only access the data of the given streams.
+	CreateTokenByID(t *users.Token) error
+	ReadTokenByID(tokenID int64) (*users.Token, error)
+	ReadAllTokensByDeviceID(deviceID int64) ([]*users.Token, error)
+	DeleteTokenByID(tokenID int64) error
+
+	// Two-factor authentication of users. Enrollment returns the new secret, and confirmation with a code
+	// from the secret enables two-factor authentication, returning the recovery codes.
+	EnrollTOTPByID(userID int64) (string, error)
+	ConfirmTOTPByID(userID int64, code string) ([]string, error)
+	ResetTOTPByID(userID int64) error
+
+	// Groups are teams of users, each backed by a user which owns the group's devices. The members of a group access its
+	// devices and streams, and each other's group-public streams, with the access given by their role in the group.
+	CreateGroupByID(groupname string, ownerID int64) error
+	ReadGroupByID(groupID int64) (*users.Group, error)
+	ReadGroupMemberByID(groupID, userID int64) (*users.GroupMember, error)
+	ReadAllGroupMembersByID(groupID int64) ([]*users.GroupMember, error)
+	ReadAllGroupsByUserID(userID int64) ([]*users.GroupMember, error)
+	SetGroupMemberByID(m *users.GroupMember) error
+	DeleteGroupMemberByID(groupID, userID int64) error
+	SharesGroupByID(userID, otherUserID int64) (bool, error)
+	DeleteGroupByID(groupID int64) error
+
+	// Webhooks post the data inserted into a stream, or into any of the streams of a device, to an external url.
+	CreateWebhookByID(w *users.Webhook) error
+	ReadWebhookByID(webhookID int64) (*users.Webhook, error)
+	ReadAllWebhooksByUserID(userID int64) ([]*users.Webhook, error)
+	ReadStreamWebhooksByID(streamID int64) ([]*users.Webhook, error)
+	DeleteWebhookByID(webhookID int64) error
+
 	SubscribeUserByID(userID int64, chn chan messenger.Message) (*nats.Subscription, error)
 	SubscribeDeviceByID(deviceID int64, chn chan messenger.Message) (*nats.Subscription, error)
 	SubscribeStreamByID(streamID int64, substream string, chn chan messenger.Message) (*nats.Subscription, error)
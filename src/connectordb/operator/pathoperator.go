@@ -39,6 +39,26 @@ type PathOperator interface {
 	InsertStream(streampath string, data datastream.DatapointArray, restamp bool) error
 	LengthStream(streampath string) (int64, error)
 
+	CreateGrant(granteepath, targetpath string, g *users.Grant) error
+	ReadUserGrants(username string) ([]*users.Grant, error)
+
+	CreateToken(devpath string, t *users.Token) error
+	ReadDeviceTokens(devpath string) ([]*users.Token, error)
+
+	EnrollTOTP(username string) (string, error)
+	ConfirmTOTP(username, code string) ([]string, error)
+	ResetTOTP(username string) error
+
+	CreateGroup(groupname, ownername string) error
+	ReadGroupMembers(groupname string) ([]*users.GroupMember, error)
+	ReadUserGroups(username string) ([]*users.GroupMember, error)
+	SetGroupMember(groupname, username, role string) error
+	DeleteGroupMember(groupname, username string) error
+	DeleteGroup(groupname string) error
+
+	CreateWebhook(targetpath string, w *users.Webhook) error
+	ReadUserWebhooks(username string) ([]*users.Webhook, error)
+
 	Subscribe(path string, chn chan messenger.Message) (*nats.Subscription, error)
 	SubscribeDevice(devpath string, chn chan messenger.Message) (*nats.Subscription, error)
 	SubscribeStream(streampath string, chn chan messenger.Message) (*nats.Subscription, error)
@@ -0,0 +1,120 @@
+package pathwrapper
+
+import (
+	"connectordb/users"
+	"errors"
+	"strings"
+)
+
+// CreateGrant gives the user or device at granteepath access to the user, device or stream at targetpath
+func (w Wrapper) CreateGrant(granteepath, targetpath string, g *users.Grant) error {
+	g.GranteeUserID, g.GranteeDeviceID = 0, 0
+	switch strings.Count(granteepath, "/") {
+	case 0:
+		u, err := w.AdminOperator().ReadUser(granteepath)
+		if err != nil {
+			return err
+		}
+		g.GranteeUserID = u.UserID
+	case 1:
+		dev, err := w.AdminOperator().ReadDevice(granteepath)
+		if err != nil {
+			return err
+		}
+		g.GranteeDeviceID = dev.DeviceID
+	default:
+		return errors.New("Grants can only be given to users and devices")
+	}
+
+	g.UserID, g.DeviceID, g.StreamID = 0, 0, 0
+	switch strings.Count(targetpath, "/") {
+	case 0:
+		u, err := w.AdminOperator().ReadUser(targetpath)
+		if err != nil {
+			return err
+		}
+		g.UserID = u.UserID
+	case 1:
+		dev, err := w.AdminOperator().ReadDevice(targetpath)
+		if err != nil {
+			return err
+		}
+		g.DeviceID = dev.DeviceID
+	case 2:
+		s, err := w.AdminOperator().ReadStream(targetpath)
+		if err != nil {
+			return err
+		}
+		g.StreamID = s.StreamID
+	default:
+		return errors.New("Grants can only be given on users, devices and streams")
+	}
+
+	return w.CreateGrantByID(g)
+}
+
+// ReadUserGrants reads all of the grants given on the user and its devices and streams,
+// with the paths of their grantees and targets filled in
+func (w Wrapper) ReadUserGrants(username string) ([]*users.Grant, error) {
+	u, err := w.AdminOperator().ReadUser(username)
+	if err != nil {
+		return nil, err
+	}
+	grants, err := w.ReadAllGrantsByUserID(u.UserID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range grants {
+		if err = w.fillGrantPaths(grants[i]); err != nil {
+			return nil, err
+		}
+	}
+	return grants, nil
+}
+
+// userPath returns the name of the user with the given ID
+func (w Wrapper) userPath(userID int64) (string, error) {
+	u, err := w.AdminOperator().ReadUserByID(userID)
+	if err != nil {
+		return "", err
+	}
+	return u.Name, nil
+}
+
+// devicePath returns the path of the device with the given ID
+func (w Wrapper) devicePath(deviceID int64) (string, error) {
+	dev, err := w.AdminOperator().ReadDeviceByID(deviceID)
+	if err != nil {
+		return "", err
+	}
+	upath, err := w.userPath(dev.UserID)
+	return upath + "/" + dev.Name, err
+}
+
+// fillGrantPaths sets the Grantee and Target paths of the grant from its IDs
+func (w Wrapper) fillGrantPaths(g *users.Grant) (err error) {
+	if g.GranteeUserID > 0 {
+		g.Grantee, err = w.userPath(g.GranteeUserID)
+	} else {
+		g.Grantee, err = w.devicePath(g.GranteeDeviceID)
+	}
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case g.StreamID > 0:
+		s, err := w.AdminOperator().ReadStreamByID(g.StreamID)
+		if err != nil {
+			return err
+		}
+		dpath, err := w.devicePath(s.DeviceID)
+		g.Target = dpath + "/" + s.Name
+		return err
+	case g.DeviceID > 0:
+		g.Target, err = w.devicePath(g.DeviceID)
+	default:
+		g.Target, err = w.userPath(g.UserID)
+	}
+	return err
+}
@@ -0,0 +1,65 @@
+package pathwrapper
+
+import "connectordb/users"
+
+// CreateGroup creates a group with the given name, owned by the given user
+func (w Wrapper) CreateGroup(groupname, ownername string) error {
+	u, err := w.AdminOperator().ReadUser(ownername)
+	if err != nil {
+		return err
+	}
+	return w.CreateGroupByID(groupname, u.UserID)
+}
+
+// ReadGroupMembers reads all of the members of the group
+func (w Wrapper) ReadGroupMembers(groupname string) ([]*users.GroupMember, error) {
+	g, err := w.AdminOperator().ReadUser(groupname)
+	if err != nil {
+		return nil, err
+	}
+	return w.ReadAllGroupMembersByID(g.UserID)
+}
+
+// ReadUserGroups reads the user's memberships in all of its groups
+func (w Wrapper) ReadUserGroups(username string) ([]*users.GroupMember, error) {
+	u, err := w.AdminOperator().ReadUser(username)
+	if err != nil {
+		return nil, err
+	}
+	return w.ReadAllGroupsByUserID(u.UserID)
+}
+
+// SetGroupMember adds the user to the group with the given role, or changes the role of an existing member
+func (w Wrapper) SetGroupMember(groupname, username, role string) error {
+	g, err := w.AdminOperator().ReadUser(groupname)
+	if err != nil {
+		return err
+	}
+	u, err := w.AdminOperator().ReadUser(username)
+	if err != nil {
+		return err
+	}
+	return w.SetGroupMemberByID(&users.GroupMember{GroupID: g.UserID, UserID: u.UserID, Role: role})
+}
+
+// DeleteGroupMember removes the user from the group
+func (w Wrapper) DeleteGroupMember(groupname, username string) error {
+	g, err := w.AdminOperator().ReadUser(groupname)
+	if err != nil {
+		return err
+	}
+	u, err := w.AdminOperator().ReadUser(username)
+	if err != nil {
+		return err
+	}
+	return w.DeleteGroupMemberByID(g.UserID, u.UserID)
+}
+
+// DeleteGroup deletes the group, along with its devices and streams
+func (w Wrapper) DeleteGroup(groupname string) error {
+	g, err := w.AdminOperator().ReadUser(groupname)
+	if err != nil {
+		return err
+	}
+	return w.DeleteGroupByID(g.UserID)
+}
@@ -0,0 +1,22 @@
+package pathwrapper
+
+import "connectordb/users"
+
+// CreateToken creates a token which logs in as the given device
+func (w Wrapper) CreateToken(devpath string, t *users.Token) error {
+	dev, err := w.AdminOperator().ReadDevice(devpath)
+	if err != nil {
+		return err
+	}
+	t.DeviceID = dev.DeviceID
+	return w.CreateTokenByID(t)
+}
+
+// ReadDeviceTokens reads all of the tokens of the given device
+func (w Wrapper) ReadDeviceTokens(devpath string) ([]*users.Token, error) {
+	dev, err := w.AdminOperator().ReadDevice(devpath)
+	if err != nil {
+		return nil, err
+	}
+	return w.ReadAllTokensByDeviceID(dev.DeviceID)
+}
@@ -0,0 +1,28 @@
+package pathwrapper
+
+// EnrollTOTP starts two-factor enrollment for the given user, returning the new secret
+func (w Wrapper) EnrollTOTP(username string) (string, error) {
+	u, err := w.AdminOperator().ReadUser(username)
+	if err != nil {
+		return "", err
+	}
+	return w.EnrollTOTPByID(u.UserID)
+}
+
+// ConfirmTOTP enables two-factor authentication for the given user, returning the recovery codes
+func (w Wrapper) ConfirmTOTP(username, code string) ([]string, error) {
+	u, err := w.AdminOperator().ReadUser(username)
+	if err != nil {
+		return nil, err
+	}
+	return w.ConfirmTOTPByID(u.UserID, code)
+}
+
+// ResetTOTP removes two-factor authentication from the given user
+func (w Wrapper) ResetTOTP(username string) error {
+	u, err := w.AdminOperator().ReadUser(username)
+	if err != nil {
+		return err
+	}
+	return w.ResetTOTPByID(u.UserID)
+}
@@ -0,0 +1,63 @@
+package pathwrapper
+
+import (
+	"connectordb/users"
+	"errors"
+	"strings"
+)
+
+// CreateWebhook adds a webhook which posts the data inserted into the device or stream at targetpath
+func (w Wrapper) CreateWebhook(targetpath string, wh *users.Webhook) error {
+	wh.DeviceID, wh.StreamID = 0, 0
+	switch strings.Count(targetpath, "/") {
+	case 1:
+		dev, err := w.AdminOperator().ReadDevice(targetpath)
+		if err != nil {
+			return err
+		}
+		wh.DeviceID = dev.DeviceID
+	case 2:
+		s, err := w.AdminOperator().ReadStream(targetpath)
+		if err != nil {
+			return err
+		}
+		wh.StreamID = s.StreamID
+	default:
+		return errors.New("Webhooks can only be added on devices and streams")
+	}
+
+	return w.CreateWebhookByID(wh)
+}
+
+// ReadUserWebhooks reads all of the webhooks on the user's devices and streams, with the paths of their targets filled in
+func (w Wrapper) ReadUserWebhooks(username string) ([]*users.Webhook, error) {
+	u, err := w.AdminOperator().ReadUser(username)
+	if err != nil {
+		return nil, err
+	}
+	webhooks, err := w.ReadAllWebhooksByUserID(u.UserID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range webhooks {
+		if err = w.fillWebhookTarget(webhooks[i]); err != nil {
+			return nil, err
+		}
+	}
+	return webhooks, nil
+}
+
+// fillWebhookTarget sets the Target path of the webhook from its IDs
+func (w Wrapper) fillWebhookTarget(wh *users.Webhook) (err error) {
+	if wh.StreamID > 0 {
+		s, err := w.AdminOperator().ReadStreamByID(wh.StreamID)
+		if err != nil {
+			return err
+		}
+		dpath, err := w.devicePath(s.DeviceID)
+		wh.Target = dpath + "/" + s.Name
+		return err
+	}
+	wh.Target, err = w.devicePath(wh.DeviceID)
+	return err
+}
@@ -0,0 +1,243 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package query
+
+import (
+	"connectordb/datastream"
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	//ErrQueryCanceled is returned when the query's context is canceled (such as when the client disconnects)
+	ErrQueryCanceled = errors.New("The query was canceled")
+	//ErrQueryTime is returned when a query runs for longer than its budget allows
+	ErrQueryTime = errors.New("The query took too long to run")
+)
+
+//Budget holds the resource limits of a single query, along with the resources the query used so far.
+//A limit of 0 means unlimited. A Budget is shared by all of the ranges opened during a query, so
+//a dataset or merge is limited as a whole, rather than per stream.
+type Budget struct {
+	MaxDatapoints int64     //The maximum number of datapoints that can be read from the database
+	MaxStreams    int64     //The maximum number of stream ranges that can be opened
+	Deadline      time.Time //The time after which the query is aborted. Zero if there is no deadline.
+
+	ctx        context.Context
+	datapoints int64
+	streams    int64
+}
+
+//NewBudget creates a Budget for a query that starts now. The query is aborted as soon as ctx is done,
+//or once any of the limits are exceeded.
+func NewBudget(ctx context.Context, maxDatapoints int64, maxTime time.Duration, maxStreams int64) *Budget {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	b := &Budget{
+		MaxDatapoints: maxDatapoints,
+		MaxStreams:    maxStreams,
+		ctx:           ctx,
+	}
+	if maxTime > 0 {
+		b.Deadline = time.Now().Add(maxTime)
+	}
+	return b
+}
+
+//Datapoints returns the number of datapoints that were read so far
+func (b *Budget) Datapoints() int64 {
+	return atomic.LoadInt64(&b.datapoints)
+}
+
+//Streams returns the number of stream ranges that were opened so far
+func (b *Budget) Streams() int64 {
+	return atomic.LoadInt64(&b.streams)
+}
+
+//Check returns an error if the query was canceled or ran out of time
+func (b *Budget) Check() error {
+	if err := b.ctx.Err(); err != nil {
+		if err == context.DeadlineExceeded {
+			return ErrQueryTime
+		}
+		return ErrQueryCanceled
+	}
+	if !b.Deadline.IsZero() && time.Now().After(b.Deadline) {
+		return ErrQueryTime
+	}
+	return nil
+}
+
+//AddStream registers a new stream range with the budget
+func (b *Budget) AddStream() error {
+	if err := b.Check(); err != nil {
+		return err
+	}
+	s := atomic.AddInt64(&b.streams, 1)
+	if b.MaxStreams > 0 && s > b.MaxStreams {
+		return fmt.Errorf("Queries are limited to %d streams", b.MaxStreams)
+	}
+	return nil
+}
+
+//AddDatapoints registers the given number of datapoints as read
+func (b *Budget) AddDatapoints(num int64) error {
+	if err := b.Check(); err != nil {
+		return err
+	}
+	d := atomic.AddInt64(&b.datapoints, num)
+	if b.MaxDatapoints > 0 && d > b.MaxDatapoints {
+		return fmt.Errorf("Queries are limited to reading %d datapoints", b.MaxDatapoints)
+	}
+	return nil
+}
+
+//BudgetRange is an ExtendedDataRange which charges all datapoints read from the underlying range to a Budget.
+//Once the budget is exceeded or the query is canceled, it returns an error instead of reading more data.
+type BudgetRange struct {
+	Data   datastream.ExtendedDataRange
+	Budget *Budget
+}
+
+//Index returns the index of the underlying range
+func (r *BudgetRange) Index() int64 {
+	return r.Data.Index()
+}
+
+//ProfileChildren instruments the underlying range
+func (r *BudgetRange) ProfileChildren(p *datastream.RangeProfile) {
+	r.Data = p.WrapExtended(r.Data, "")
+}
+
+//Close closes the underlying range
+func (r *BudgetRange) Close() {
+	r.Data.Close()
+}
+
+//Next returns the next datapoint of the underlying range
+func (r *BudgetRange) Next() (*datastream.Datapoint, error) {
+	if err := r.Budget.Check(); err != nil {
+		return nil, err
+	}
+	dp, err := r.Data.Next()
+	if err != nil {
+		//A read which was stopped by the query's context fails with the reason that the query was stopped
+		if berr := r.Budget.Check(); berr != nil {
+			return nil, berr
+		}
+		return nil, err
+	}
+	if dp == nil {
+		return nil, nil
+	}
+	return dp, r.Budget.AddDatapoints(1)
+}
+
+//NextArray returns the next batch of datapoints from the underlying range
+func (r *BudgetRange) NextArray() (*datastream.DatapointArray, error) {
+	if err := r.Budget.Check(); err != nil {
+		return nil, err
+	}
+	dpa, err := r.Data.NextArray()
+	if err != nil {
+		if berr := r.Budget.Check(); berr != nil {
+			return nil, berr
+		}
+		return nil, err
+	}
+	if dpa == nil {
+		return nil, nil
+	}
+	return dpa, r.Budget.AddDatapoints(int64(dpa.Length()))
+}
+
+//BudgetOperator wraps an Operator so that all of the ranges it returns are charged to a Budget. The datapoints are counted
+//as they are read from the database, before transforms are run, so that an expensive filter can't get around the budget.
+type BudgetOperator struct {
+	Operator
+	Budget *Budget
+
+	//Cancel is called by Close, to stop the context which the operator's queries run with. It can be nil.
+	Cancel context.CancelFunc
+}
+
+//NewBudgetOperator returns an Operator which runs queries on o, limited by the given budget
+func NewBudgetOperator(o Operator, b *Budget) *BudgetOperator {
+	return &BudgetOperator{o, b}
+}
+
+//Close releases the context of the operator's queries. It is called once the ranges of the queries are closed.
+func (o *BudgetOperator) Close() {
+	if o.Cancel != nil {
+		o.Cancel()
+	}
+}
+
+//wrap sets up the budget for a raw range from the underlying operator, and then adds the transform and limit
+func (o *BudgetOperator) wrap(dr datastream.DataRange, transform string, limit int64) (datastream.DataRange, error) {
+	edr, ok := dr.(datastream.ExtendedDataRange)
+	if !ok {
+		dr.Close()
+		return nil, errors.New("The underlying range does not support query budgets")
+	}
+	return transformRange(&BudgetRange{edr, o.Budget}, transform, limit)
+}
+
+//transformRange adds the given transform and limit to a raw range. It is used by operators which need to
+//act on the data read from the database before the stream's transform is run.
+func transformRange(edr datastream.ExtendedDataRange, transform string, limit int64) (datastream.DataRange, error) {
+	if transform != "" {
+		tr, err := NewExtendedTransformRange(edr, transform)
+		if err != nil {
+			edr.Close()
+			return nil, err
+		}
+		edr = tr
+	}
+	if limit > 0 {
+		edr = datastream.NewNumRange(edr, limit)
+	}
+	return edr, nil
+}
+
+//GetStreamIndexRange gets an index range of the stream, charged to the budget
+func (o *BudgetOperator) GetStreamIndexRange(streampath string, i1 int64, i2 int64, transform string) (datastream.DataRange, error) {
+	if err := o.Budget.AddStream(); err != nil {
+		return nil, err
+	}
+	dr, err := o.Operator.GetStreamIndexRange(streampath, i1, i2, "")
+	if err != nil {
+		return nil, err
+	}
+	return o.wrap(dr, transform, 0)
+}
+
+//GetStreamTimeRange gets a time range of the stream, charged to the budget
+func (o *BudgetOperator) GetStreamTimeRange(streampath string, t1 float64, t2 float64, limit int64, transform string) (datastream.DataRange, error) {
+	if err := o.Budget.AddStream(); err != nil {
+		return nil, err
+	}
+	dr, err := o.Operator.GetStreamTimeRange(streampath, t1, t2, 0, "")
+	if err != nil {
+		return nil, err
+	}
+	return o.wrap(dr, transform, limit)
+}
+
+//GetShiftedStreamTimeRange gets a shifted time range of the stream, charged to the budget
+func (o *BudgetOperator) GetShiftedStreamTimeRange(streampath string, t1 float64, t2 float64, ishift, limit int64, transform string) (datastream.DataRange, error) {
+	if err := o.Budget.AddStream(); err != nil {
+		return nil, err
+	}
+	dr, err := o.Operator.GetShiftedStreamTimeRange(streampath, t1, t2, ishift, 0, "")
+	if err != nil {
+		return nil, err
+	}
+	return o.wrap(dr, transform, limit)
+}
@@ -0,0 +1,105 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package query
+
+import (
+	"connectordb/datastream"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBudgetOperator(t *testing.T) {
+	dpa := datastream.DatapointArray{
+		datastream.Datapoint{Timestamp: 1, Data: 1},
+		datastream.Datapoint{Timestamp: 2, Data: 2},
+		datastream.Datapoint{Timestamp: 3, Data: 3},
+		datastream.Datapoint{Timestamp: 4, Data: 4},
+	}
+	mq := NewMockOperator(map[string]datastream.DatapointArray{"u/d/s1": dpa, "u/d/s2": dpa})
+
+	// An unlimited budget reads everything
+	b := NewBudget(context.Background(), 0, 0, 0)
+	mr, err := Merge(NewBudgetOperator(mq, b), []*StreamQuery{
+		&StreamQuery{Stream: "u/d/s1"},
+		&StreamQuery{Stream: "u/d/s2"},
+	})
+	require.NoError(t, err)
+	for dp, err := mr.Next(); dp != nil; dp, err = mr.Next() {
+		require.NoError(t, err)
+	}
+	require.EqualValues(t, 8, b.Datapoints())
+	require.EqualValues(t, 2, b.Streams())
+
+	// Too many streams
+	b = NewBudget(context.Background(), 0, 0, 1)
+	_, err = Merge(NewBudgetOperator(mq, b), []*StreamQuery{
+		&StreamQuery{Stream: "u/d/s1"},
+		&StreamQuery{Stream: "u/d/s2"},
+	})
+	require.Error(t, err)
+
+	// Datapoints are counted before the transform, so filters still use up the budget
+	b = NewBudget(context.Background(), 3, 0, 0)
+	s := StreamQuery{Stream: "u/d/s1", Transform: "if $ > 10"}
+	dr, err := s.Run(NewBudgetOperator(mq, b))
+	require.NoError(t, err)
+	_, err = dr.Next()
+	require.Error(t, err)
+
+	// The transform and limit are still applied
+	b = NewBudget(context.Background(), 0, 0, 0)
+	s = StreamQuery{Stream: "u/d/s1", T1: 0.5, Limit: 1, Transform: "$ + 1"}
+	dr, err = s.Run(NewBudgetOperator(mq, b))
+	require.NoError(t, err)
+	dp, err := dr.Next()
+	require.NoError(t, err)
+	require.EqualValues(t, 2, dp.Data)
+	dp, err = dr.Next()
+	require.NoError(t, err)
+	require.Nil(t, dp)
+}
+
+func TestBudgetCancel(t *testing.T) {
+	dpa := datastream.DatapointArray{
+		datastream.Datapoint{Timestamp: 1, Data: 1},
+		datastream.Datapoint{Timestamp: 2, Data: 2},
+	}
+	mq := NewMockOperator(map[string]datastream.DatapointArray{"u/d/s": dpa})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	b := NewBudget(ctx, 0, 0, 0)
+	s := StreamQuery{Stream: "u/d/s"}
+	dr, err := s.Run(NewBudgetOperator(mq, b))
+	require.NoError(t, err)
+	_, err = dr.Next()
+	require.NoError(t, err)
+
+	cancel()
+	_, err = dr.Next()
+	require.Equal(t, ErrQueryCanceled, err)
+
+	// A query which ran out of time stops too
+	b = NewBudget(context.Background(), 0, time.Nanosecond, 0)
+	time.Sleep(time.Millisecond)
+	_, err = s.Run(NewBudgetOperator(mq, b))
+	require.Equal(t, ErrQueryTime, err)
+
+	// The context's deadline counts as running out of time, and closing the operator cancels the context
+	ctx, cancel = context.WithTimeout(context.Background(), time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	_, err = s.Run(NewBudgetOperator(mq, NewBudget(ctx, 0, 0, 0)))
+	require.Equal(t, ErrQueryTime, err)
+	cancel()
+
+	ctx, cancel = context.WithCancel(context.Background())
+	bo := NewBudgetOperator(mq, NewBudget(ctx, 0, 0, 0))
+	bo.Cancel = cancel
+	bo.Close()
+	_, err = s.Run(bo)
+	require.Equal(t, ErrQueryCanceled, err)
+}
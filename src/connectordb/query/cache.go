@@ -0,0 +1,331 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package query
+
+import (
+	"connectordb/audit"
+	"connectordb/datastream"
+	"container/list"
+	"fmt"
+	"sync"
+)
+
+//ResultCache holds the results of recent stream queries in memory, so that dashboards which poll the same ranges
+//don't need to read and decode the same chunks from the database each time. The cached results are keyed on the
+//stream's length, so an insert makes all of the stream's old results unreachable. The entries are also removed
+//explicitly by calling InvalidateStream on each insert, which frees their memory right away.
+//The least recently used results are removed once the cache grows larger than MaxSize bytes.
+type ResultCache struct {
+	sync.Mutex
+
+	MaxSize int64 //The maximum estimated memory use of the cache in bytes
+
+	size     int64
+	lru      *list.List
+	entries  map[string]*list.Element
+	bystream map[string]map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key    string
+	stream string
+	index  int64
+	data   datastream.DatapointArray
+	size   int64
+}
+
+//NewResultCache creates a ResultCache which uses up to maxsize bytes
+func NewResultCache(maxsize int64) *ResultCache {
+	return &ResultCache{
+		MaxSize:  maxsize,
+		lru:      list.New(),
+		entries:  make(map[string]*list.Element),
+		bystream: make(map[string]map[string]*list.Element),
+	}
+}
+
+//Size returns the estimated memory use of the cache in bytes
+func (c *ResultCache) Size() int64 {
+	c.Lock()
+	defer c.Unlock()
+	return c.size
+}
+
+//Len returns the number of cached results
+func (c *ResultCache) Len() int {
+	c.Lock()
+	defer c.Unlock()
+	return len(c.entries)
+}
+
+//Clear removes all cached results
+func (c *ResultCache) Clear() {
+	c.Lock()
+	defer c.Unlock()
+	c.size = 0
+	c.lru.Init()
+	c.entries = make(map[string]*list.Element)
+	c.bystream = make(map[string]map[string]*list.Element)
+}
+
+//InvalidateStream removes all cached results of the given stream path (including the substream, if any)
+func (c *ResultCache) InvalidateStream(streampath string) {
+	c.Lock()
+	defer c.Unlock()
+	for _, e := range c.bystream[streampath] {
+		c.remove(e)
+	}
+}
+
+//remove deletes the given element. The cache must be locked.
+func (c *ResultCache) remove(e *list.Element) {
+	ce := c.lru.Remove(e).(*cacheEntry)
+	c.size -= ce.size
+	delete(c.entries, ce.key)
+	delete(c.bystream[ce.stream], ce.key)
+	if len(c.bystream[ce.stream]) == 0 {
+		delete(c.bystream, ce.stream)
+	}
+}
+
+func (c *ResultCache) get(key string) *cacheEntry {
+	c.Lock()
+	defer c.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	c.lru.MoveToFront(e)
+	return e.Value.(*cacheEntry)
+}
+
+func (c *ResultCache) put(ce *cacheEntry) {
+	c.Lock()
+	defer c.Unlock()
+	if e, ok := c.entries[ce.key]; ok {
+		c.remove(e)
+	}
+	if ce.size > c.MaxSize {
+		return
+	}
+	e := c.lru.PushFront(ce)
+	c.entries[ce.key] = e
+	if _, ok := c.bystream[ce.stream]; !ok {
+		c.bystream[ce.stream] = make(map[string]*list.Element)
+	}
+	c.bystream[ce.stream][ce.key] = e
+	c.size += ce.size
+
+	for c.size > c.MaxSize {
+		c.remove(c.lru.Back())
+	}
+}
+
+//dataSize gives a rough estimate of the memory used by a datapoint's data
+func dataSize(v interface{}) int64 {
+	switch d := v.(type) {
+	case string:
+		return 16 + int64(len(d))
+	case []interface{}:
+		s := int64(24)
+		for i := range d {
+			s += dataSize(d[i])
+		}
+		return s
+	case map[string]interface{}:
+		s := int64(48)
+		for k, v := range d {
+			s += 16 + int64(len(k)) + dataSize(v)
+		}
+		return s
+	}
+	return 16
+}
+
+//datapointSize gives a rough estimate of the memory used by a datapoint
+func datapointSize(dp *datastream.Datapoint) int64 {
+	return 40 + int64(len(dp.Sender)) + dataSize(dp.Data)
+}
+
+//CachedRange is an ExtendedDataRange which reads a cached result
+type CachedRange struct {
+	data  datastream.DatapointArray
+	i     int
+	index int64
+}
+
+//Index returns the index of the next datapoint
+func (r *CachedRange) Index() int64 {
+	return r.index + int64(r.i)
+}
+
+//Close does nothing, since the cached data is shared
+func (r *CachedRange) Close() {}
+
+//Next returns a copy of the next cached datapoint
+func (r *CachedRange) Next() (*datastream.Datapoint, error) {
+	if r.i >= len(r.data) {
+		return nil, nil
+	}
+	dp := r.data[r.i]
+	r.i++
+	return &dp, nil
+}
+
+//NextArray returns a copy of the remaining cached datapoints
+func (r *CachedRange) NextArray() (*datastream.DatapointArray, error) {
+	if r.i >= len(r.data) {
+		return nil, nil
+	}
+	dpa := make(datastream.DatapointArray, len(r.data)-r.i)
+	copy(dpa, r.data[r.i:])
+	r.i = len(r.data)
+	return &dpa, nil
+}
+
+//cacheRecorder passes through the data of an ExtendedDataRange, and saves it in the cache once the range was read to the end
+type cacheRecorder struct {
+	Data  datastream.ExtendedDataRange
+	cache *ResultCache
+	entry *cacheEntry // nil once recording stopped
+}
+
+//ProfileChildren instruments the underlying range
+func (r *cacheRecorder) ProfileChildren(p *datastream.RangeProfile) {
+	r.Data = p.WrapExtended(r.Data, "")
+}
+
+func (r *cacheRecorder) record(dpa datastream.DatapointArray, err error) {
+	if r.entry == nil {
+		return
+	}
+	if err != nil {
+		r.entry = nil
+		return
+	}
+	if dpa == nil {
+		// The range was read to the end - save the result
+		r.cache.put(r.entry)
+		r.entry = nil
+		return
+	}
+	for i := range dpa {
+		r.entry.size += datapointSize(&dpa[i])
+	}
+	if r.entry.size > r.cache.MaxSize/4 {
+		// Results that would take up a large part of the cache are not worth keeping
+		r.entry = nil
+		return
+	}
+	r.entry.data = append(r.entry.data, dpa...)
+}
+
+//Index returns the index of the underlying range
+func (r *cacheRecorder) Index() int64 {
+	return r.Data.Index()
+}
+
+//Close closes the underlying range. If the range was not read to the end, nothing is cached.
+func (r *cacheRecorder) Close() {
+	r.entry = nil
+	r.Data.Close()
+}
+
+//Next returns the next datapoint of the underlying range
+func (r *cacheRecorder) Next() (*datastream.Datapoint, error) {
+	dp, err := r.Data.Next()
+	if dp == nil {
+		r.record(nil, err)
+	} else {
+		r.record(datastream.DatapointArray{*dp}, err)
+	}
+	return dp, err
+}
+
+//NextArray returns the next array of the underlying range
+func (r *cacheRecorder) NextArray() (*datastream.DatapointArray, error) {
+	dpa, err := r.Data.NextArray()
+	if dpa == nil {
+		r.record(nil, err)
+	} else {
+		r.record(*dpa, err)
+	}
+	return dpa, err
+}
+
+//LengthOperator is an Operator which can also return the length of streams, which is needed for caching results
+type LengthOperator interface {
+	Operator
+	LengthStream(streampath string) (int64, error)
+}
+
+//Auditor is an Operator which records the reads of stream data in an audit log. The results which come from the
+//cache are never read from the operator, so their reads are recorded with AuditStreamRead.
+type Auditor interface {
+	AuditStreamRead(streampath, datarange string)
+}
+
+//CachedOperator wraps an Operator so that the results of its queries are read from a ResultCache when possible.
+//The stream's length is read through the underlying operator for each query, so permissions are checked even
+//when the result comes from the cache.
+type CachedOperator struct {
+	LengthOperator
+	Cache *ResultCache
+}
+
+//NewCachedOperator returns an Operator which caches the results of queries run on o in the given cache
+func NewCachedOperator(o LengthOperator, c *ResultCache) *CachedOperator {
+	return &CachedOperator{o, c}
+}
+
+//get returns the result of the query from the cache, or runs it. The datarange describes the read in the audit log.
+func (o *CachedOperator) get(streampath, query, datarange string, run func() (datastream.DataRange, error)) (datastream.DataRange, error) {
+	length, err := o.LengthStream(streampath)
+	if err != nil {
+		return nil, err
+	}
+	key := fmt.Sprintf("%s:%d:%s", streampath, length, query)
+	if ce := o.Cache.get(key); ce != nil {
+		if a, ok := o.LengthOperator.(Auditor); ok {
+			a.AuditStreamRead(streampath, datarange)
+		}
+		return &CachedRange{data: ce.data, index: ce.index}, nil
+	}
+
+	dr, err := run()
+	if err != nil {
+		return nil, err
+	}
+	edr, ok := dr.(datastream.ExtendedDataRange)
+	if !ok {
+		return dr, nil
+	}
+	return &cacheRecorder{
+		Data:  edr,
+		cache: o.Cache,
+		entry: &cacheEntry{key: key, stream: streampath, index: edr.Index()},
+	}, nil
+}
+
+//GetStreamIndexRange gets an index range of the stream, using the cache when possible
+func (o *CachedOperator) GetStreamIndexRange(streampath string, i1 int64, i2 int64, transform string) (datastream.DataRange, error) {
+	return o.get(streampath, fmt.Sprintf("i:%d:%d:%s", i1, i2, transform), audit.IndexRange(i1, i2), func() (datastream.DataRange, error) {
+		return o.LengthOperator.GetStreamIndexRange(streampath, i1, i2, transform)
+	})
+}
+
+//GetStreamTimeRange gets a time range of the stream, using the cache when possible
+func (o *CachedOperator) GetStreamTimeRange(streampath string, t1 float64, t2 float64, limit int64, transform string) (datastream.DataRange, error) {
+	return o.get(streampath, fmt.Sprintf("t:%v:%v:%d:%s", t1, t2, limit, transform), audit.TimeRange(t1, t2, limit), func() (datastream.DataRange, error) {
+		return o.LengthOperator.GetStreamTimeRange(streampath, t1, t2, limit, transform)
+	})
+}
+
+//GetShiftedStreamTimeRange gets a shifted time range of the stream, using the cache when possible
+func (o *CachedOperator) GetShiftedStreamTimeRange(streampath string, t1 float64, t2 float64, ishift, limit int64, transform string) (datastream.DataRange, error) {
+	return o.get(streampath, fmt.Sprintf("s:%v:%v:%d:%d:%s", t1, t2, ishift, limit, transform), audit.ShiftedRange(t1, t2, ishift, limit), func() (datastream.DataRange, error) {
+		return o.LengthOperator.GetShiftedStreamTimeRange(streampath, t1, t2, ishift, limit, transform)
+	})
+}
@@ -0,0 +1,110 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package query
+
+import (
+	"connectordb/datastream"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+//countingOperator counts the number of ranges that were read from the underlying MockOperator
+type countingOperator struct {
+	*MockOperator
+	reads int
+}
+
+func (c *countingOperator) LengthStream(streampath string) (int64, error) {
+	return int64(len(c.Data[streampath])), nil
+}
+
+func (c *countingOperator) GetStreamIndexRange(streampath string, i1 int64, i2 int64, transform string) (datastream.DataRange, error) {
+	c.reads++
+	return c.MockOperator.GetStreamIndexRange(streampath, i1, i2, transform)
+}
+
+//auditingOperator records the reads that the CachedOperator gives it to audit
+type auditingOperator struct {
+	countingOperator
+	audited []string
+}
+
+func (a *auditingOperator) AuditStreamRead(streampath, datarange string) {
+	a.audited = append(a.audited, streampath+" "+datarange)
+}
+
+func TestResultCache(t *testing.T) {
+	dpa := datastream.DatapointArray{
+		datastream.Datapoint{Timestamp: 1, Data: 1},
+		datastream.Datapoint{Timestamp: 2, Data: "hi"},
+		datastream.Datapoint{Timestamp: 3, Data: map[string]interface{}{"a": 1}},
+	}
+	co := &countingOperator{MockOperator: NewMockOperator(map[string]datastream.DatapointArray{"u/d/s": dpa})}
+	cache := NewResultCache(1024 * 1024)
+	o := NewCachedOperator(co, cache)
+	s := StreamQuery{Stream: "u/d/s"}
+
+	dr, err := s.Run(o)
+	require.NoError(t, err)
+	CompareRange(t, dr, dpa)
+	require.Equal(t, 1, co.reads)
+	require.Equal(t, 1, cache.Len())
+
+	// The second query is read from the cache
+	dr, err = s.Run(o)
+	require.NoError(t, err)
+	CompareRange(t, dr, dpa)
+	require.Equal(t, 1, co.reads)
+
+	// A range that was not read to the end is not cached
+	s.I1 = 1
+	dr, err = s.Run(o)
+	require.NoError(t, err)
+	dr.Close()
+	require.Equal(t, 1, cache.Len())
+
+	// Inserting data changes the length of the stream, so the old result is no longer used
+	s.I1 = 0
+	dpa = append(dpa, datastream.Datapoint{Timestamp: 4, Data: 4})
+	co.Data["u/d/s"] = dpa
+	dr, err = s.Run(o)
+	require.NoError(t, err)
+	CompareRange(t, dr, dpa)
+	require.Equal(t, 3, co.reads)
+	require.Equal(t, 2, cache.Len())
+
+	cache.InvalidateStream("u/d/s")
+	require.Equal(t, 0, cache.Len())
+	require.EqualValues(t, 0, cache.Size())
+
+	// Results larger than the cache are not kept
+	cache.MaxSize = 100
+	dr, err = s.Run(o)
+	require.NoError(t, err)
+	CompareRange(t, dr, dpa)
+	require.Equal(t, 0, cache.Len())
+}
+
+func TestCachedOperatorAudit(t *testing.T) {
+	dpa := datastream.DatapointArray{datastream.Datapoint{Timestamp: 1, Data: 1}}
+	ao := &auditingOperator{countingOperator: countingOperator{MockOperator: NewMockOperator(map[string]datastream.DatapointArray{"u/d/s": dpa})}}
+	o := NewCachedOperator(ao, NewResultCache(1024*1024))
+	s := StreamQuery{Stream: "u/d/s", I2: 1}
+
+	// The operator audits the reads that reach it by itself
+	dr, err := s.Run(o)
+	require.NoError(t, err)
+	CompareRange(t, dr, dpa)
+	require.Equal(t, 1, ao.reads)
+	require.Empty(t, ao.audited)
+
+	// A result from the cache is audited just like a read of the stream
+	dr, err = s.Run(o)
+	require.NoError(t, err)
+	CompareRange(t, dr, dpa)
+	require.Equal(t, 1, ao.reads)
+	require.Equal(t, []string{"u/d/s i=[0,1)"}, ao.audited)
+}
@@ -8,6 +8,7 @@ import (
 	"connectordb/datastream"
 	"errors"
 	"fmt"
+	"sort"
 
 	"github.com/connectordb/pipescript"
 	"github.com/connectordb/pipescript/interpolator"
@@ -51,6 +52,9 @@ func (dqe *DatasetQueryElement) Get(o Operator, tstart float64) (dre *DatasetRan
 		}
 
 		dr, err = dqe.StreamQuery.Run(o)
+		if err == nil {
+			dr = prefetch(dr)
+		}
 
 	} else {
 		//The dataset is a merge
@@ -79,7 +83,8 @@ func (dqe *DatasetQueryElement) Get(o Operator, tstart float64) (dre *DatasetRan
 	}
 
 	//The element's datarange is ready - set up the interpolator
-	intpltr, err := interpolator.Parse(dqe.Interpolator, &DatapointIterator{dr})
+	input := &DatapointIterator{dr}
+	intpltr, err := interpolator.Parse(dqe.Interpolator, input)
 	if err != nil {
 		dr.Close()
 		return nil, err
@@ -89,6 +94,7 @@ func (dqe *DatasetQueryElement) Get(o Operator, tstart float64) (dre *DatasetRan
 		Interpolator: intpltr,
 		Range:        dr,
 		AllowNil:     dqe.AllowNil,
+		input:        input,
 	}, nil
 }
 
@@ -106,19 +112,32 @@ func (d *DatasetQuery) GetDatasetElements(o Operator, tstart float64) (map[strin
 	if len(d.Dataset) == 0 {
 		return nil, errors.New("The dataset query must have a dataset!")
 	}
-	res := make(map[string]*DatasetRangeElement)
 
+	// The elements are opened in parallel. Their keys are sorted, so that the returned error is deterministic
+	keys := make([]string, 0, len(d.Dataset))
 	for key := range d.Dataset {
-		de, err := d.Dataset[key].Get(o, tstart)
-		if err != nil {
-			for k := range res {
-				res[k].Close()
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	elements := make([]*DatasetRangeElement, len(keys))
+	err := runParallel(len(keys), func(i int) (err error) {
+		elements[i], err = d.Dataset[keys[i]].Get(o, tstart)
+		return err
+	})
+	if err != nil {
+		for i := range elements {
+			if elements[i] != nil {
+				elements[i].Close()
 			}
-			return nil, err
 		}
-		res[key] = de
+		return nil, err
 	}
 
+	res := make(map[string]*DatasetRangeElement)
+	for i := range keys {
+		res[keys[i]] = elements[i]
+	}
 	return res, nil
 }
 
@@ -136,10 +155,43 @@ func (d *DatasetQuery) GetXRange(o Operator) (dr datastream.DataRange, err error
 	return Merge(o, d.Merge)
 }
 
+//clone returns a deep copy of the query. Running a DatasetQuery sets the ranges of its elements, so a query which
+//is run more than once needs to be copied first.
+func (d *DatasetQuery) clone() *DatasetQuery {
+	c := *d
+	c.Merge = cloneStreamQueries(d.Merge)
+	c.Dataset = make(map[string]*DatasetQueryElement)
+	for key, e := range d.Dataset {
+		ce := *e
+		ce.Merge = cloneStreamQueries(e.Merge)
+		c.Dataset[key] = &ce
+	}
+	return &c
+}
+
+func cloneStreamQueries(sq []*StreamQuery) []*StreamQuery {
+	if sq == nil {
+		return nil
+	}
+	res := make([]*StreamQuery, len(sq))
+	for i := range sq {
+		s := *sq[i]
+		res[i] = &s
+	}
+	return res
+}
+
 //Run executes the query to get the dataset
 func (d DatasetQuery) Run(o Operator) (dr datastream.DataRange, err error) {
+	return d.run(o, d.T1, nil)
+}
+
+//run executes the dataset query, with the dataset's elements starting at tstart. If xr is not nil, it is used as the
+//range of a Ydataset's X values instead of running the dataset's stream or merge query.
+func (d *DatasetQuery) run(o Operator, tstart float64, xr datastream.DataRange) (dr datastream.DataRange, err error) {
 	var posttransform *pipescript.Script
 	var iiter pipescript.DatapointIterator
+	var xiter *DatapointIterator
 	if d.PostTransform != "" {
 		posttransform, err = pipescript.Parse(d.PostTransform)
 		if err != nil {
@@ -148,13 +200,13 @@ func (d DatasetQuery) Run(o Operator) (dr datastream.DataRange, err error) {
 	}
 
 	// Get the dataset elements, and prepare the interpolator map
-	dsetrange, err := d.GetDatasetElements(o, d.T1)
+	dsetrange, err := d.GetDatasetElements(o, tstart)
 	if err != nil {
 		return nil, err
 	}
 	dsetipltr := make(map[string]interpolator.InterpolatorInstance)
 	for key := range dsetrange {
-		dsetipltr[key] = dsetrange[key].Interpolator
+		dsetipltr[key] = dsetrange[key]
 	}
 
 	//first find out if we are doing a Tdataset or a ydataset
@@ -174,12 +226,17 @@ func (d DatasetQuery) Run(o Operator) (dr datastream.DataRange, err error) {
 			return nil, errors.New("Dataset must be either time or stream based. Not both.")
 		}
 
-		dr, err = d.GetXRange(o)
-		if err != nil {
-			return nil, err
+		if xr != nil {
+			dr = xr
+		} else {
+			dr, err = d.GetXRange(o)
+			if err != nil {
+				return nil, err
+			}
 		}
 
-		iiter, err = interpolator.GetXDataset(&DatapointIterator{dr}, "x", dsetipltr)
+		xiter = &DatapointIterator{dr}
+		iiter, err = interpolator.GetXDataset(xiter, "x", dsetipltr)
 	}
 	if err != nil {
 		return nil, err
@@ -191,6 +248,10 @@ func (d DatasetQuery) Run(o Operator) (dr datastream.DataRange, err error) {
 		posttransform.SetInput(dnc)
 		iiter = posttransform
 	}
-	return &DatasetRange{dnc, iiter}, nil
+	dset := &DatasetRange{Dnc: dnc, Iter: iiter, x: xiter}
+	if bo, ok := o.(*BudgetOperator); ok {
+		dset.Budget = bo.Budget
+	}
+	return dset, nil
 
 }
@@ -7,6 +7,7 @@ package query
 import (
 	"connectordb/datastream"
 	"errors"
+	"time"
 
 	"github.com/connectordb/pipescript"
 	"github.com/connectordb/pipescript/interpolator"
@@ -17,6 +18,24 @@ type DatasetRangeElement struct {
 	Interpolator interpolator.InterpolatorInstance
 	Range        datastream.DataRange
 	AllowNil     bool
+
+	input   *DatapointIterator       // The interpolator's input, which reads from Range
+	profile *datastream.RangeProfile // The profile of the interpolator, if the dataset is profiled
+}
+
+//Interpolate returns the element's datapoint at the given timestamp. The element is given to the dataset in place of
+//its interpolator, so that the time spent interpolating is measured when the dataset is profiled.
+func (dre *DatasetRangeElement) Interpolate(ts float64) (*pipescript.Datapoint, error) {
+	if dre.profile == nil {
+		return dre.Interpolator.Interpolate(ts)
+	}
+	start := time.Now()
+	dp, err := dre.Interpolator.Interpolate(ts)
+	dre.profile.Time += time.Since(start).Seconds()
+	if dp != nil {
+		dre.profile.Datapoints++
+	}
+	return dp, err
 }
 
 //Close closes the internal database connections
@@ -64,17 +83,42 @@ func (dr *DatasetNullChecker) Next() (*pipescript.Datapoint, error) {
 // The DatasetRange is split into a DatasetNullChecker, which checks the dataset keys for null,
 // and the Iter resulting from adding the DatasetNullChecker. The reason the component had to be split
 // into two parts is because the posttransform can only be applied AFTER the null checker, so Iter might
-// actually be the post-transform
+// actually be the post-transform. If Budget is set, it is checked for each datapoint, so that generating
+// a large interpolated dataset can be canceled even when no more data is read from the underlying streams.
 type DatasetRange struct {
-	Dnc  *DatasetNullChecker
-	Iter pipescript.DatapointIterator
+	Dnc    *DatasetNullChecker
+	Iter   pipescript.DatapointIterator
+	Budget *Budget
+
+	x *DatapointIterator // The range of the stream that the dataset is based on, if it is not a Tdataset
+}
+
+//ProfileChildren instruments the interpolators of the dataset's elements along with the DataRanges that they read,
+//as well as the range the dataset is based on
+func (dc *DatasetRange) ProfileChildren(p *datastream.RangeProfile) {
+	if dc.x != nil {
+		dc.x.Range = p.Wrap(dc.x.Range, "x")
+	}
+	for key, dre := range dc.Dnc.Data {
+		dre.profile = p.Node("Interpolator", key)
+		dre.Range = dre.profile.Wrap(dre.Range, "")
+		dre.input.Range = dre.Range
+	}
 }
 
 func (dc *DatasetRange) Close() {
 	dc.Dnc.Close()
+	if dc.x != nil {
+		dc.x.Range.Close()
+	}
 }
 
 func (dc *DatasetRange) Next() (*datastream.Datapoint, error) {
+	if dc.Budget != nil {
+		if err := dc.Budget.Check(); err != nil {
+			return nil, err
+		}
+	}
 	dp, err := dc.Iter.Next()
 	if err != nil || dp == nil {
 		return nil, err
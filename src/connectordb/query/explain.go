@@ -0,0 +1,46 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package query
+
+import (
+	"connectordb/datastream"
+	"time"
+)
+
+//Explanation holds the profile of a query, showing where the time was spent when running it. All times are in seconds.
+type Explanation struct {
+	SetupTime  float64                  `json:"setup_time"`      //The time it took to set up the range stack (including the initial database queries)
+	Time       float64                  `json:"time"`            //The total time it took to run the query, including setup
+	Datapoints int64                    `json:"datapoints"`      //The number of datapoints returned by the query
+	Error      string                   `json:"error,omitempty"` //The error that stopped the query, if any
+	Range      *datastream.RangeProfile `json:"range"`           //The profile of the range stack
+}
+
+//Explain reads the entire DataRange, and returns the profile of its range stack instead of the data.
+//setup is the time that it took to create the DataRange. The DataRange is closed before the profile is finished,
+//so that any ranges still reading ahead in the background are stopped.
+func Explain(dr datastream.DataRange, setup time.Duration) *Explanation {
+	start := time.Now()
+	dr, profile := datastream.Profile(dr)
+
+	e := &Explanation{Range: profile}
+	for {
+		dp, err := dr.Next()
+		if err != nil {
+			e.Error = err.Error()
+			break
+		}
+		if dp == nil {
+			break
+		}
+		e.Datapoints++
+	}
+	dr.Close()
+	profile.Finish()
+
+	e.SetupTime = setup.Seconds()
+	e.Time = e.SetupTime + time.Since(start).Seconds()
+	return e
+}
@@ -0,0 +1,83 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package query
+
+import (
+	"connectordb/datastream"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplain(t *testing.T) {
+	dpa := datastream.DatapointArray{
+		datastream.Datapoint{Timestamp: 1, Data: 1},
+		datastream.Datapoint{Timestamp: 2, Data: 2},
+		datastream.Datapoint{Timestamp: 3, Data: 3},
+	}
+	mq := NewMockOperator(map[string]datastream.DatapointArray{"u/d/s1": dpa, "u/d/s2": dpa})
+
+	dr, err := Merge(mq, []*StreamQuery{
+		&StreamQuery{Stream: "u/d/s1"},
+		&StreamQuery{Stream: "u/d/s2"},
+	})
+	require.NoError(t, err)
+
+	e := Explain(dr, 0)
+	require.Empty(t, e.Error)
+	require.EqualValues(t, 6, e.Datapoints)
+	require.Equal(t, "MergeRange", e.Range.Name)
+	require.EqualValues(t, 6, e.Range.Datapoints)
+	require.Len(t, e.Range.Children, 2)
+	require.Equal(t, "0", e.Range.Children[0].Label)
+	require.EqualValues(t, 3, e.Range.Children[0].Datapoints)
+	require.EqualValues(t, 3, e.Range.Children[1].Datapoints)
+
+	// The transform and budget layers show up in the tree
+	s := StreamQuery{Stream: "u/d/s1", Transform: "if $ > 1"}
+	sr, err := s.Run(NewBudgetOperator(mq, NewBudget(context.Background(), 0, 0, 0)))
+	require.NoError(t, err)
+
+	e = Explain(sr, 0)
+	require.Empty(t, e.Error)
+	require.EqualValues(t, 2, e.Datapoints)
+	require.Equal(t, "ExtendedTransformRange", e.Range.Name)
+	require.Equal(t, "BudgetRange", e.Range.Children[0].Name)
+	require.EqualValues(t, 3, e.Range.Children[0].Datapoints)
+}
+
+func TestExplainDataset(t *testing.T) {
+	dpa := datastream.DatapointArray{
+		datastream.Datapoint{Timestamp: 1, Data: 1},
+		datastream.Datapoint{Timestamp: 2, Data: 2},
+		datastream.Datapoint{Timestamp: 3, Data: 3},
+	}
+	mq := NewMockOperator(map[string]datastream.DatapointArray{"u/d/x": dpa, "u/d/y": dpa})
+
+	dr, err := DatasetQuery{
+		StreamQuery: StreamQuery{Stream: "u/d/x"},
+		Dataset: map[string]*DatasetQueryElement{
+			"y": &DatasetQueryElement{StreamQuery: StreamQuery{Stream: "u/d/y"}, Interpolator: "closest"},
+		},
+	}.Run(mq)
+	require.NoError(t, err)
+
+	// The interpolator is a node between the dataset and the range that it reads
+	e := Explain(dr, 0)
+	require.Empty(t, e.Error)
+	require.EqualValues(t, 3, e.Datapoints)
+	require.Equal(t, "DatasetRange", e.Range.Name)
+	require.Len(t, e.Range.Children, 2)
+	require.Equal(t, "x", e.Range.Children[0].Label)
+	require.EqualValues(t, 3, e.Range.Children[0].Datapoints)
+
+	ip := e.Range.Children[1]
+	require.Equal(t, "Interpolator", ip.Name)
+	require.Equal(t, "y", ip.Label)
+	require.EqualValues(t, 3, ip.Datapoints)
+	require.Len(t, ip.Children, 1)
+	require.True(t, ip.Time >= ip.Children[0].Time)
+}
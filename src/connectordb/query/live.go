@@ -0,0 +1,355 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package query
+
+import (
+	"connectordb/datastream"
+	"errors"
+	"math"
+	"sort"
+)
+
+//LiveQuery is a merge or dataset query which keeps returning new results as data is inserted into its streams.
+//Start returns the result of the query up to now, and each call to Update returns the results that were added since.
+//
+//Merges and stream based datasets keep track of the number of datapoints read from each of their streams, so that
+//no datapoint is returned twice, even if it was inserted while the query was running. The transforms of the streams
+//are run separately on each batch of new datapoints. Time based datasets only return rows up to the timestamp of the
+//latest datapoint of their least recently updated element, since the rows after that could still change.
+type LiveQuery struct {
+	Merge   []*StreamQuery `json:"merge,omitempty"`   //The streams to merge
+	Dataset *DatasetQuery  `json:"dataset,omitempty"` //The dataset to generate
+
+	lengths map[string]int64 //The number of datapoints of each stream that were already read
+	tlast   float64          //The timestamp of the last row of a time based dataset that was returned
+}
+
+//isTDataset returns true if the query is a time based dataset
+func (q *LiveQuery) isTDataset() bool {
+	return q.Dataset != nil && !q.Dataset.IsValid() && len(q.Dataset.Merge) == 0
+}
+
+//indexed returns the stream queries whose new datapoints are read by index
+func (q *LiveQuery) indexed() []*StreamQuery {
+	if q.Dataset == nil {
+		return q.Merge
+	}
+	if q.Dataset.IsValid() {
+		return []*StreamQuery{&q.Dataset.StreamQuery}
+	}
+	return q.Dataset.Merge
+}
+
+//Sources returns the streams whose inserts can add new results to the query. These are the streams that are merged,
+//the X stream(s) of a Ydataset, and all of the element streams of a Tdataset.
+func (q *LiveQuery) Sources() []string {
+	m := make(map[string]bool)
+	for _, s := range q.indexed() {
+		m[s.Stream] = true
+	}
+	if q.isTDataset() {
+		for _, e := range q.Dataset.Dataset {
+			if e.Stream != "" {
+				m[e.Stream] = true
+			}
+			for _, s := range e.Merge {
+				m[s.Stream] = true
+			}
+		}
+	}
+
+	res := make([]string, 0, len(m))
+	for s := range m {
+		res = append(res, s)
+	}
+	sort.Strings(res)
+	return res
+}
+
+//Start runs the query on the data that is currently in the database. The lengths of streams are read through lo,
+//and the data through o, which allows o to be limited by a query budget.
+func (q *LiveQuery) Start(o Operator, lo LengthOperator) (datastream.DataRange, error) {
+	if (len(q.Merge) > 0) == (q.Dataset != nil) {
+		return nil, errors.New("A live query must be either a merge or a dataset")
+	}
+
+	q.lengths = make(map[string]int64)
+	for _, s := range q.indexed() {
+		l, err := lo.LengthStream(s.Stream)
+		if err != nil {
+			return nil, err
+		}
+		q.lengths[s.Stream] = l
+	}
+
+	// The streams are cut off at the lengths that were just read, so that datapoints inserted
+	// while the query runs are returned by the next call to Update
+	co := &cappedOperator{o, q.lengths}
+	if q.Dataset == nil {
+		mr, err := Merge(co, q.Merge)
+		if err != nil {
+			return nil, err
+		}
+		return mr, nil
+	}
+	if q.isTDataset() {
+		q.tlast = q.Dataset.T2
+	}
+	return q.Dataset.clone().Run(co)
+}
+
+//Update returns the results that were added to the query since the previous call to Start or Update
+func (q *LiveQuery) Update(o Operator, lo LengthOperator) (datastream.DataRange, error) {
+	if q.lengths == nil {
+		return nil, errors.New("The live query was not started")
+	}
+	if q.isTDataset() {
+		return q.updateTDataset(o)
+	}
+
+	dr, lengths, err := q.newData(o, lo)
+	if err != nil {
+		return nil, err
+	}
+	commit := func() { q.lengths = lengths }
+	if q.Dataset == nil {
+		return &liveRange{dr, commit}, nil
+	}
+
+	// The new datapoints are the X values of the dataset's new rows. They are read into memory,
+	// so that the dataset's elements can start at the first new timestamp.
+	var dpa datastream.DatapointArray
+	dp, err := dr.Next()
+	for ; dp != nil && err == nil; dp, err = dr.Next() {
+		dpa = append(dpa, *dp)
+	}
+	dr.Close()
+	if err != nil {
+		return nil, err
+	}
+	if len(dpa) == 0 {
+		commit()
+		return datastream.EmptyRange{}, nil
+	}
+	dr, err = q.Dataset.clone().run(o, dpa[0].Timestamp, datastream.NewDatapointArrayRange(dpa, 0))
+	if err != nil {
+		return nil, err
+	}
+	return &liveRange{dr, commit}, nil
+}
+
+//newData returns the merged datapoints that were inserted into the indexed streams since they were last read,
+//along with the lengths of the streams that they were read up to
+func (q *LiveQuery) newData(o Operator, lo LengthOperator) (datastream.DataRange, map[string]int64, error) {
+	sq := q.indexed()
+	lengths := make(map[string]int64)
+	for _, s := range sq {
+		l, err := lo.LengthStream(s.Stream)
+		if err != nil {
+			return nil, nil, err
+		}
+		lengths[s.Stream] = l
+	}
+
+	dr, err := openRanges(len(sq), func(i int) (datastream.DataRange, error) {
+		s := sq[i]
+		if lengths[s.Stream] <= q.lengths[s.Stream] {
+			return datastream.EmptyRange{}, nil
+		}
+		return o.GetStreamIndexRange(s.Stream, q.lengths[s.Stream], lengths[s.Stream], s.Transform)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	mr, err := NewMergeRange(dr)
+	if err != nil {
+		return nil, nil, err
+	}
+	return mr, lengths, nil
+}
+
+//updateTDataset returns the new rows of a time based dataset. The rows stay on the time grid of the original query.
+func (q *LiveQuery) updateTDataset(o Operator) (datastream.DataRange, error) {
+	d := q.Dataset
+
+	tmax := math.Inf(1)
+	for _, s := range q.Sources() {
+		dr, err := o.GetStreamIndexRange(s, -1, 0, "")
+		if err != nil {
+			return nil, err
+		}
+		dp, err := dr.Next()
+		dr.Close()
+		if err != nil {
+			return nil, err
+		}
+		if dp == nil {
+			// The stream has no data yet, so none of the rows are ready
+			return datastream.EmptyRange{}, nil
+		}
+		tmax = math.Min(tmax, dp.Timestamp)
+	}
+
+	t1 := d.T1 + math.Floor((q.tlast-d.T1)/d.Dt)*d.Dt
+	t2 := d.T1 + math.Floor((tmax-d.T1)/d.Dt)*d.Dt
+	if t2 <= q.tlast+d.Dt/2 {
+		return datastream.EmptyRange{}, nil
+	}
+
+	dq := d.clone()
+	dq.T1 = t1
+	dq.T2 = t2
+	dr, err := dq.Run(o)
+	if err != nil {
+		return nil, err
+	}
+
+	// Half a time step is used as margin, so that floating point error doesn't return the last row twice
+	return &liveRange{&afterRange{dr, q.tlast + d.Dt/2}, func() { q.tlast = t2 }}, nil
+}
+
+//liveRange holds the new results of a live query. The query only moves past the results once all of them were read
+//without error, so that the next update returns them again if reading them failed.
+type liveRange struct {
+	Data   datastream.DataRange
+	commit func()
+}
+
+//ProfileChildren instruments the underlying range
+func (r *liveRange) ProfileChildren(p *datastream.RangeProfile) {
+	r.Data = p.Wrap(r.Data, "")
+}
+
+//Close closes the underlying range
+func (r *liveRange) Close() {
+	r.Data.Close()
+}
+
+//Next returns the next datapoint of the underlying range, moving the query past the results once they were all read
+func (r *liveRange) Next() (*datastream.Datapoint, error) {
+	dp, err := r.Data.Next()
+	if dp == nil && err == nil && r.commit != nil {
+		r.commit()
+		r.commit = nil
+	}
+	return dp, err
+}
+
+//afterRange returns only the datapoints of the underlying range which have a timestamp after the given time
+type afterRange struct {
+	Data datastream.DataRange
+	t    float64
+}
+
+//ProfileChildren instruments the underlying range
+func (r *afterRange) ProfileChildren(p *datastream.RangeProfile) {
+	r.Data = p.Wrap(r.Data, "")
+}
+
+//Close closes the underlying range
+func (r *afterRange) Close() {
+	r.Data.Close()
+}
+
+//Next returns the next datapoint after the range's time
+func (r *afterRange) Next() (*datastream.Datapoint, error) {
+	dp, err := r.Data.Next()
+	for dp != nil && err == nil && dp.Timestamp <= r.t {
+		dp, err = r.Data.Next()
+	}
+	return dp, err
+}
+
+//cappedOperator reads the given streams only up to their index in caps
+type cappedOperator struct {
+	Operator
+	caps map[string]int64
+}
+
+func (o *cappedOperator) wrap(streampath string, dr datastream.DataRange, transform string, limit int64) (datastream.DataRange, error) {
+	edr, ok := dr.(datastream.ExtendedDataRange)
+	if !ok {
+		dr.Close()
+		return nil, errors.New("The underlying range does not support live queries")
+	}
+	if i, ok := o.caps[streampath]; ok {
+		edr = &cappedRange{edr, i}
+	}
+	return transformRange(edr, transform, limit)
+}
+
+//GetStreamIndexRange gets an index range of the stream, up to its cap
+func (o *cappedOperator) GetStreamIndexRange(streampath string, i1 int64, i2 int64, transform string) (datastream.DataRange, error) {
+	dr, err := o.Operator.GetStreamIndexRange(streampath, i1, i2, "")
+	if err != nil {
+		return nil, err
+	}
+	return o.wrap(streampath, dr, transform, 0)
+}
+
+//GetStreamTimeRange gets a time range of the stream, up to its cap
+func (o *cappedOperator) GetStreamTimeRange(streampath string, t1 float64, t2 float64, limit int64, transform string) (datastream.DataRange, error) {
+	dr, err := o.Operator.GetStreamTimeRange(streampath, t1, t2, 0, "")
+	if err != nil {
+		return nil, err
+	}
+	return o.wrap(streampath, dr, transform, limit)
+}
+
+//GetShiftedStreamTimeRange gets a shifted time range of the stream, up to its cap
+func (o *cappedOperator) GetShiftedStreamTimeRange(streampath string, t1 float64, t2 float64, ishift, limit int64, transform string) (datastream.DataRange, error) {
+	dr, err := o.Operator.GetShiftedStreamTimeRange(streampath, t1, t2, ishift, 0, "")
+	if err != nil {
+		return nil, err
+	}
+	return o.wrap(streampath, dr, transform, limit)
+}
+
+//cappedRange stops reading the underlying range at the given index
+type cappedRange struct {
+	Data datastream.ExtendedDataRange
+	cap  int64
+}
+
+//Index returns the index of the underlying range
+func (r *cappedRange) Index() int64 {
+	return r.Data.Index()
+}
+
+//ProfileChildren instruments the underlying range
+func (r *cappedRange) ProfileChildren(p *datastream.RangeProfile) {
+	r.Data = p.WrapExtended(r.Data, "")
+}
+
+//Close closes the underlying range
+func (r *cappedRange) Close() {
+	r.Data.Close()
+}
+
+//Next returns the next datapoint, if it is before the cap
+func (r *cappedRange) Next() (*datastream.Datapoint, error) {
+	if r.Data.Index() >= r.cap {
+		return nil, nil
+	}
+	return r.Data.Next()
+}
+
+//NextArray returns the next array of datapoints, cut off at the cap
+func (r *cappedRange) NextArray() (*datastream.DatapointArray, error) {
+	i := r.Data.Index()
+	if i >= r.cap {
+		return nil, nil
+	}
+	dpa, err := r.Data.NextArray()
+	if err != nil || dpa == nil {
+		return dpa, err
+	}
+	if i+int64(len(*dpa)) > r.cap {
+		d := (*dpa)[:r.cap-i]
+		dpa = &d
+	}
+	return dpa, nil
+}
@@ -0,0 +1,229 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package query
+
+import (
+	"connectordb/datastream"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+//indexOperator is a MockOperator which supports index ranges and stream lengths, which are needed for live queries
+type indexOperator struct {
+	*MockOperator
+}
+
+func (m *indexOperator) LengthStream(streampath string) (int64, error) {
+	return int64(len(m.Data[streampath])), nil
+}
+
+func (m *indexOperator) GetStreamIndexRange(streampath string, i1 int64, i2 int64, transform string) (datastream.DataRange, error) {
+	dpa, ok := m.Data[streampath]
+	if !ok {
+		return nil, errors.New("Could not find stream " + streampath)
+	}
+	n := int64(len(dpa))
+	if i1 < 0 {
+		i1 += n
+	}
+	if i2 <= 0 {
+		i2 += n
+	}
+	return datastream.NewDatapointArrayRange(dpa[i1:i2], i1), nil
+}
+
+func readAll(t *testing.T, dr datastream.DataRange) datastream.DatapointArray {
+	var dpa datastream.DatapointArray
+	dp, err := dr.Next()
+	for ; dp != nil; dp, err = dr.Next() {
+		dpa = append(dpa, *dp)
+	}
+	require.NoError(t, err)
+	dr.Close()
+	return dpa
+}
+
+func TestLiveMerge(t *testing.T) {
+	o := &indexOperator{NewMockOperator(map[string]datastream.DatapointArray{
+		"u/d/a": datastream.DatapointArray{
+			datastream.Datapoint{Timestamp: 1, Data: 1},
+			datastream.Datapoint{Timestamp: 3, Data: 3},
+		},
+		"u/d/b": datastream.DatapointArray{
+			datastream.Datapoint{Timestamp: 2, Data: 2},
+		},
+	})}
+
+	q := &LiveQuery{}
+	_, err := q.Start(o, o)
+	require.Error(t, err)
+
+	q = &LiveQuery{Merge: []*StreamQuery{&StreamQuery{Stream: "u/d/a"}, &StreamQuery{Stream: "u/d/b"}}}
+	require.Equal(t, []string{"u/d/a", "u/d/b"}, q.Sources())
+	_, err = q.Update(o, o)
+	require.Error(t, err)
+
+	dr, err := q.Start(o, o)
+	require.NoError(t, err)
+	CompareRange(t, dr, datastream.DatapointArray{
+		datastream.Datapoint{Timestamp: 1, Data: 1},
+		datastream.Datapoint{Timestamp: 2, Data: 2},
+		datastream.Datapoint{Timestamp: 3, Data: 3},
+	})
+
+	o.Data["u/d/a"] = append(o.Data["u/d/a"], datastream.Datapoint{Timestamp: 5, Data: 5})
+	o.Data["u/d/b"] = append(o.Data["u/d/b"], datastream.Datapoint{Timestamp: 4, Data: 4})
+	dr, err = q.Update(o, o)
+	require.NoError(t, err)
+	CompareRange(t, dr, datastream.DatapointArray{
+		datastream.Datapoint{Timestamp: 4, Data: 4},
+		datastream.Datapoint{Timestamp: 5, Data: 5},
+	})
+
+	// Nothing was inserted
+	dr, err = q.Update(o, o)
+	require.NoError(t, err)
+	CompareRange(t, dr, datastream.DatapointArray{})
+}
+
+//failingOperator is an indexOperator whose ranges fail to read while fail is set
+type failingOperator struct {
+	*indexOperator
+	fail bool
+}
+
+func (m *failingOperator) GetStreamIndexRange(streampath string, i1 int64, i2 int64, transform string) (datastream.DataRange, error) {
+	dr, err := m.indexOperator.GetStreamIndexRange(streampath, i1, i2, transform)
+	if err != nil || !m.fail {
+		return dr, err
+	}
+	dr.Close()
+	return &failingRange{}, nil
+}
+
+type failingRange struct{}
+
+func (r *failingRange) Close() {}
+
+func (r *failingRange) Next() (*datastream.Datapoint, error) {
+	return nil, errors.New("The read failed")
+}
+
+func TestLiveUpdateFailure(t *testing.T) {
+	o := &failingOperator{indexOperator: &indexOperator{NewMockOperator(map[string]datastream.DatapointArray{
+		"u/d/a": datastream.DatapointArray{
+			datastream.Datapoint{Timestamp: 1, Data: 1},
+		},
+	})}}
+	q := &LiveQuery{Merge: []*StreamQuery{&StreamQuery{Stream: "u/d/a"}}}
+	dr, err := q.Start(o, o)
+	require.NoError(t, err)
+	require.Len(t, readAll(t, dr), 1)
+
+	// The datapoints which failed to be read are returned by the next update
+	o.Data["u/d/a"] = append(o.Data["u/d/a"], datastream.Datapoint{Timestamp: 2, Data: 2})
+	o.fail = true
+	dr, err = q.Update(o, o)
+	if err == nil {
+		_, err = dr.Next()
+		dr.Close()
+	}
+	require.Error(t, err)
+
+	o.fail = false
+	dr, err = q.Update(o, o)
+	require.NoError(t, err)
+	CompareRange(t, dr, datastream.DatapointArray{
+		datastream.Datapoint{Timestamp: 2, Data: 2},
+	})
+
+	// Results which were read are not returned again
+	dr, err = q.Update(o, o)
+	require.NoError(t, err)
+	CompareRange(t, dr, datastream.DatapointArray{})
+}
+
+func TestCappedOperator(t *testing.T) {
+	dpa := datastream.DatapointArray{
+		datastream.Datapoint{Timestamp: 1, Data: 1},
+		datastream.Datapoint{Timestamp: 2, Data: 2},
+		datastream.Datapoint{Timestamp: 3, Data: 3},
+	}
+	o := &indexOperator{NewMockOperator(map[string]datastream.DatapointArray{"u/d/a": dpa, "u/d/b": dpa})}
+	co := &cappedOperator{o, map[string]int64{"u/d/a": 2}}
+
+	dr, err := co.GetStreamIndexRange("u/d/a", 0, 0, "")
+	require.NoError(t, err)
+	CompareRange(t, dr, dpa[:2])
+
+	dr, err = co.GetStreamIndexRange("u/d/a", 0, 0, "")
+	require.NoError(t, err)
+	a, err := dr.(datastream.ExtendedDataRange).NextArray()
+	require.NoError(t, err)
+	require.Len(t, *a, 2)
+
+	dr, err = co.GetStreamIndexRange("u/d/b", 0, 0, "")
+	require.NoError(t, err)
+	CompareRange(t, dr, dpa)
+}
+
+func TestLiveDataset(t *testing.T) {
+	o := &indexOperator{NewMockOperator(map[string]datastream.DatapointArray{
+		"u/d/x": datastream.DatapointArray{
+			datastream.Datapoint{Timestamp: 1, Data: 1},
+			datastream.Datapoint{Timestamp: 2, Data: 2},
+		},
+		"u/d/y": datastream.DatapointArray{
+			datastream.Datapoint{Timestamp: 1, Data: 1},
+			datastream.Datapoint{Timestamp: 2, Data: 2},
+			datastream.Datapoint{Timestamp: 3, Data: 3},
+		},
+	})}
+
+	// A stream based dataset returns a row for each new X datapoint
+	q := &LiveQuery{Dataset: &DatasetQuery{
+		StreamQuery: StreamQuery{Stream: "u/d/x"},
+		Dataset: map[string]*DatasetQueryElement{
+			"y": &DatasetQueryElement{StreamQuery: StreamQuery{Stream: "u/d/y"}, Interpolator: "closest"},
+		},
+	}}
+	require.Equal(t, []string{"u/d/x"}, q.Sources())
+	dr, err := q.Start(o, o)
+	require.NoError(t, err)
+	require.Len(t, readAll(t, dr), 2)
+
+	o.Data["u/d/x"] = append(o.Data["u/d/x"], datastream.Datapoint{Timestamp: 3, Data: 3})
+	dr, err = q.Update(o, o)
+	require.NoError(t, err)
+	dpa := readAll(t, dr)
+	require.Len(t, dpa, 1)
+	require.EqualValues(t, 3, dpa[0].Timestamp)
+
+	// A time based dataset returns the rows up to the latest datapoint of its elements
+	q = &LiveQuery{Dataset: &DatasetQuery{
+		StreamQuery: StreamQuery{T1: 1, T2: 3},
+		Dt:          1,
+		Dataset: map[string]*DatasetQueryElement{
+			"y": &DatasetQueryElement{StreamQuery: StreamQuery{Stream: "u/d/y"}, Interpolator: "closest"},
+		},
+	}}
+	require.Equal(t, []string{"u/d/y"}, q.Sources())
+	dr, err = q.Start(o, o)
+	require.NoError(t, err)
+	readAll(t, dr)
+
+	dr, err = q.Update(o, o)
+	require.NoError(t, err)
+	require.Len(t, readAll(t, dr), 0)
+
+	o.Data["u/d/y"] = append(o.Data["u/d/y"], datastream.Datapoint{Timestamp: 4.5, Data: 4})
+	dr, err = q.Update(o, o)
+	require.NoError(t, err)
+	dpa = readAll(t, dr)
+	require.Len(t, dpa, 1)
+	require.EqualValues(t, 4, dpa[0].Timestamp)
+}
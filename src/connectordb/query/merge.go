@@ -8,6 +8,7 @@ import (
 	"connectordb/datastream"
 	"errors"
 	"fmt"
+	"strconv"
 
 	"github.com/connectordb/pipescript"
 	"github.com/connectordb/pipescript/interpolator"
@@ -19,9 +20,18 @@ var MaxMergeNumber = 10
 //MergeRange is a DataRange that merges several DataRanges together. It is used to implement the Merge command
 type MergeRange struct {
 	datarange []datastream.DataRange
+	inputs    []*DatapointIterator
 	iterator  pipescript.DatapointIterator
 }
 
+//ProfileChildren instruments each of the merged DataRanges
+func (mr *MergeRange) ProfileChildren(p *datastream.RangeProfile) {
+	for i := range mr.datarange {
+		mr.datarange[i] = p.Wrap(mr.datarange[i], strconv.Itoa(i))
+		mr.inputs[i].Range = mr.datarange[i]
+	}
+}
+
 //Close closes the merge
 func (mr *MergeRange) Close() {
 	for i := range mr.datarange {
@@ -40,10 +50,12 @@ func (mr *MergeRange) Next() (*datastream.Datapoint, error) {
 
 //NewMergeRange generates a MergeRange given an array of DataRanges
 func NewMergeRange(dr []datastream.DataRange) (*MergeRange, error) {
+	inputs := make([]*DatapointIterator, len(dr))
 	iarray := make([]pipescript.DatapointIterator, len(dr))
 
 	for i := range dr {
-		iarray[i] = &DatapointIterator{dr[i]}
+		inputs[i] = &DatapointIterator{dr[i]}
+		iarray[i] = inputs[i]
 	}
 	mrg, err := interpolator.Merge(iarray)
 	if err != nil {
@@ -52,7 +64,7 @@ func NewMergeRange(dr []datastream.DataRange) (*MergeRange, error) {
 		}
 	}
 
-	return &MergeRange{dr, mrg}, err
+	return &MergeRange{dr, inputs, mrg}, err
 }
 
 //Merge returns a MergeRange which merges the given streams into one large stream
@@ -61,17 +73,17 @@ func Merge(qo Operator, sq []*StreamQuery) (*MergeRange, error) {
 		return nil, errors.New(fmt.Sprintf("Merging more than %d streams is disabled.", MaxMergeNumber))
 	}
 
-	dr := make([]datastream.DataRange, 0, len(sq))
-	for i := range sq {
+	//The streams are opened in parallel, and each one is read ahead in the background,
+	//so that the merge doesn't wait on each stream's database queries one after another
+	dr, err := openRanges(len(sq), func(i int) (datastream.DataRange, error) {
 		d, err := sq[i].Run(qo)
 		if err != nil {
-			//We've got an error - close all the ranges
-			for j := range dr {
-				dr[j].Close()
-			}
 			return nil, err
 		}
-		dr = append(dr, d)
+		return prefetch(d), nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return NewMergeRange(dr)
@@ -0,0 +1,153 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package query
+
+import (
+	"config"
+	"connectordb/datastream"
+	"sync"
+)
+
+//runParallel calls f for each i in [0,n), using up to config's QueryWorkers goroutines at once. This allows the latency
+//of the database queries made when opening the ranges of a merge or dataset to be paid in parallel. It returns the
+//error with the lowest index, so that the result does not depend on the order in which the goroutines ran.
+func runParallel(n int, f func(i int) error) error {
+	errs := make([]error, n)
+
+	workers := config.Get().QueryWorkers
+	if workers <= 1 || n <= 1 {
+		for i := 0; i < n; i++ {
+			if errs[i] = f(i); errs[i] != nil {
+				return errs[i]
+			}
+		}
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, workers)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			errs[i] = f(i)
+			<-sem
+			wg.Done()
+		}(i)
+	}
+	wg.Wait()
+
+	for i := range errs {
+		if errs[i] != nil {
+			return errs[i]
+		}
+	}
+	return nil
+}
+
+//openRanges opens n DataRanges in parallel, returning them in the order of their indices.
+//If any of the ranges fails to open, all of the others are closed.
+func openRanges(n int, open func(i int) (datastream.DataRange, error)) ([]datastream.DataRange, error) {
+	dr := make([]datastream.DataRange, n)
+	err := runParallel(n, func(i int) (err error) {
+		dr[i], err = open(i)
+		return err
+	})
+	if err != nil {
+		for i := range dr {
+			if dr[i] != nil {
+				dr[i].Close()
+			}
+		}
+		return nil, err
+	}
+	return dr, nil
+}
+
+//prefetched is a single result of reading a range in the background
+type prefetched struct {
+	dp  *datastream.Datapoint
+	err error
+}
+
+//PrefetchRange reads ahead from a DataRange in a background goroutine, buffering up to a fixed number of datapoints.
+//This allows the streams of a merge or dataset to be read from the database in parallel, while the datapoints of each
+//stream are still returned in their original order. The background goroutine is only started on the first call to Next,
+//so the range can still be profiled after it is created.
+type PrefetchRange struct {
+	Data datastream.DataRange
+
+	size    int
+	c       chan prefetched
+	stop    chan struct{}
+	done    chan struct{}
+	started bool
+	once    sync.Once
+}
+
+//NewPrefetchRange creates a PrefetchRange which buffers up to size datapoints of dr
+func NewPrefetchRange(dr datastream.DataRange, size int) *PrefetchRange {
+	return &PrefetchRange{
+		Data: dr,
+		size: size,
+		c:    make(chan prefetched, size),
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+}
+
+//prefetch wraps the given range in a PrefetchRange if reading ahead is enabled in the configuration
+func prefetch(dr datastream.DataRange) datastream.DataRange {
+	size := config.Get().QueryPrefetch
+	if size <= 0 {
+		return dr
+	}
+	return NewPrefetchRange(dr, size)
+}
+
+func (r *PrefetchRange) run() {
+	defer close(r.done)
+	defer close(r.c)
+	for {
+		dp, err := r.Data.Next()
+		select {
+		case r.c <- prefetched{dp, err}:
+		case <-r.stop:
+			return
+		}
+		if dp == nil || err != nil {
+			return
+		}
+	}
+}
+
+//ProfileChildren instruments the underlying range. It must be called before the first call to Next.
+func (r *PrefetchRange) ProfileChildren(p *datastream.RangeProfile) {
+	r.Data = p.Wrap(r.Data, "")
+}
+
+//Next returns the next datapoint of the underlying range
+func (r *PrefetchRange) Next() (*datastream.Datapoint, error) {
+	if !r.started {
+		r.started = true
+		go r.run()
+	}
+	p, ok := <-r.c
+	if !ok {
+		return nil, nil
+	}
+	return p.dp, p.err
+}
+
+//Close stops reading ahead, and closes the underlying range
+func (r *PrefetchRange) Close() {
+	r.once.Do(func() {
+		close(r.stop)
+		if r.started {
+			<-r.done
+		}
+		r.Data.Close()
+	})
+}
@@ -0,0 +1,84 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package query
+
+import (
+	"connectordb/datastream"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrefetchRange(t *testing.T) {
+	dpa := datastream.DatapointArray{
+		datastream.Datapoint{Timestamp: 1, Data: 1},
+		datastream.Datapoint{Timestamp: 2, Data: 2},
+		datastream.Datapoint{Timestamp: 3, Data: 3},
+		datastream.Datapoint{Timestamp: 4, Data: 4},
+	}
+
+	CompareRange(t, NewPrefetchRange(datastream.NewDatapointArrayRange(dpa, 0), 2), dpa)
+
+	// Closing in the middle of the range stops the background reads
+	pr := NewPrefetchRange(datastream.NewDatapointArrayRange(dpa, 0), 1)
+	dp, err := pr.Next()
+	require.NoError(t, err)
+	require.Equal(t, dpa[0].String(), dp.String())
+	pr.Close()
+	pr.Close()
+
+	// Closing before reading works too
+	NewPrefetchRange(datastream.NewDatapointArrayRange(dpa, 0), 1).Close()
+}
+
+func TestOpenRanges(t *testing.T) {
+	dpa := datastream.DatapointArray{
+		datastream.Datapoint{Timestamp: 1, Data: 1},
+	}
+
+	dr, err := openRanges(10, func(i int) (datastream.DataRange, error) {
+		return datastream.NewDatapointArrayRange(datastream.DatapointArray{datastream.Datapoint{Timestamp: float64(i)}}, 0), nil
+	})
+	require.NoError(t, err)
+	require.Len(t, dr, 10)
+	for i := range dr {
+		CompareRange(t, dr[i], datastream.DatapointArray{datastream.Datapoint{Timestamp: float64(i)}})
+	}
+
+	// The error with the lowest index is returned
+	_, err = openRanges(10, func(i int) (datastream.DataRange, error) {
+		if i == 3 || i == 7 {
+			return nil, fmt.Errorf("%d", i)
+		}
+		return datastream.NewDatapointArrayRange(dpa, 0), nil
+	})
+	require.EqualError(t, err, "3")
+}
+
+func TestParallelMerge(t *testing.T) {
+	dpa := datastream.DatapointArray{
+		datastream.Datapoint{Timestamp: 1, Data: 1},
+		datastream.Datapoint{Timestamp: 3, Data: 3},
+	}
+	dpb := datastream.DatapointArray{
+		datastream.Datapoint{Timestamp: 2, Data: 2},
+		datastream.Datapoint{Timestamp: 4, Data: 4},
+	}
+	mq := NewMockOperator(map[string]datastream.DatapointArray{"u/d/s1": dpa, "u/d/s2": dpb})
+
+	mr, err := Merge(mq, []*StreamQuery{
+		&StreamQuery{Stream: "u/d/s1"},
+		&StreamQuery{Stream: "u/d/s2"},
+	})
+	require.NoError(t, err)
+	CompareRange(t, mr, datastream.DatapointArray{dpa[0], dpb[0], dpa[1], dpb[1]})
+
+	_, err = Merge(mq, []*StreamQuery{
+		&StreamQuery{Stream: "u/d/s1"},
+		&StreamQuery{Stream: "u/d/notastream"},
+	})
+	require.Error(t, err)
+}
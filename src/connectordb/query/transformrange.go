@@ -44,6 +44,8 @@ func TransformArray(t *pipescript.Script, dpa *datastream.DatapointArray) (*data
 type ExtendedTransformRange struct {
 	Data      datastream.ExtendedDataRange
 	Transform *pipescript.Script
+
+	input *DatapointIterator // The transform's input, which reads from Data
 }
 
 //Index returns the index of the next datapoint in the underlying ExtendedDataRange - it does not guarantee that the datapoint won't be filtered by the
@@ -52,6 +54,12 @@ func (t *ExtendedTransformRange) Index() int64 {
 	return t.Data.Index()
 }
 
+//ProfileChildren instruments the underlying ExtendedDataRange
+func (t *ExtendedTransformRange) ProfileChildren(p *datastream.RangeProfile) {
+	t.Data = p.WrapExtended(t.Data, "")
+	t.input.Range = t.Data
+}
+
 //Close closes the underlying ExtendedDataRange
 func (t *ExtendedTransformRange) Close() {
 	t.Data.Close()
@@ -96,11 +104,13 @@ func NewExtendedTransformRange(dr datastream.ExtendedDataRange, transformpipelin
 	if err != nil {
 		return nil, err
 	}
-	t.SetInput(&DatapointIterator{dr})
+	input := &DatapointIterator{dr}
+	t.SetInput(input)
 
 	return &ExtendedTransformRange{
 		Data:      dr,
 		Transform: t,
+		input:     input,
 	}, nil
 }
 
@@ -108,6 +118,14 @@ func NewExtendedTransformRange(dr datastream.ExtendedDataRange, transformpipelin
 type TransformRange struct {
 	Data      datastream.DataRange
 	Transform *pipescript.Script
+
+	input *DatapointIterator // The transform's input, which reads from Data
+}
+
+//ProfileChildren instruments the underlying DataRange
+func (t *TransformRange) ProfileChildren(p *datastream.RangeProfile) {
+	t.Data = p.Wrap(t.Data, "")
+	t.input.Range = t.Data
 }
 
 //Close closes the underlying ExtendedDataRange
@@ -134,10 +152,12 @@ func NewTransformRange(dr datastream.ExtendedDataRange, transformpipeline string
 	if err != nil {
 		return nil, err
 	}
-	t.SetInput(&DatapointIterator{dr})
+	input := &DatapointIterator{dr}
+	t.SetInput(input)
 
 	return &TransformRange{
 		Data:      dr,
 		Transform: t,
+		input:     input,
 	}, nil
 }
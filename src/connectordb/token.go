@@ -0,0 +1,23 @@
+package connectordb
+
+import "connectordb/users"
+
+// CreateTokenByID creates a token for the device given by the token's DeviceID
+func (db *Database) CreateTokenByID(t *users.Token) error {
+	return db.Userdb.CreateToken(t)
+}
+
+// ReadTokenByID reads the given token
+func (db *Database) ReadTokenByID(tokenID int64) (*users.Token, error) {
+	return db.Userdb.ReadTokenByID(tokenID)
+}
+
+// ReadAllTokensByDeviceID reads all of the tokens of the given device
+func (db *Database) ReadAllTokensByDeviceID(deviceID int64) ([]*users.Token, error) {
+	return db.Userdb.ReadTokensByDevice(deviceID)
+}
+
+// DeleteTokenByID revokes the given token
+func (db *Database) DeleteTokenByID(tokenID int64) error {
+	return db.Userdb.DeleteToken(tokenID)
+}
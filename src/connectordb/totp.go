@@ -0,0 +1,37 @@
+package connectordb
+
+// EnrollTOTPByID starts two-factor enrollment for the given user, returning the new secret
+func (db *Database) EnrollTOTPByID(userID int64) (string, error) {
+	u, err := db.Userdb.ReadUserById(userID)
+	if err != nil {
+		return "", err
+	}
+	secret, err := u.EnrollTOTP()
+	if err != nil {
+		return "", err
+	}
+	return secret, db.Userdb.UpdateUser(u)
+}
+
+// ConfirmTOTPByID enables two-factor authentication for the given user if the code is valid, returning the recovery codes
+func (db *Database) ConfirmTOTPByID(userID int64, code string) ([]string, error) {
+	u, err := db.Userdb.ReadUserById(userID)
+	if err != nil {
+		return nil, err
+	}
+	codes, err := u.ConfirmTOTP(code)
+	if err != nil {
+		return nil, err
+	}
+	return codes, db.Userdb.UpdateUser(u)
+}
+
+// ResetTOTPByID removes two-factor authentication from the given user
+func (db *Database) ResetTOTPByID(userID int64) error {
+	u, err := db.Userdb.ReadUserById(userID)
+	if err != nil {
+		return err
+	}
+	u.ResetTOTP()
+	return db.Userdb.UpdateUser(u)
+}
@@ -0,0 +1,42 @@
+package connectordb
+
+import (
+	"connectordb/pathwrapper"
+	"connectordb/users"
+	"errors"
+)
+
+// ErrNotTransaction is returned when committing or rolling back a database which was not returned by Begin
+var ErrNotTransaction = errors.New("The database is not a transaction")
+
+// Begin starts a transaction of the changes to users, devices and streams. The returned database makes its changes
+// within the transaction, so that they are only seen by the rest of ConnectorDB once Commit is called, and are undone
+// by Rollback. Only the sql database is part of the transaction: the data of streams, and the messages sent about
+// the changes, are not undone.
+func (db *Database) Begin() (*Database, error) {
+	tx, err := users.Begin(db.Userdb)
+	if err != nil {
+		return nil, err
+	}
+	txdb := *db
+	txdb.Userdb = tx
+	txdb.tx = tx
+	txdb.Wrapper = pathwrapper.Wrap(&txdb)
+	return &txdb, nil
+}
+
+// Commit makes the changes of a transaction started with Begin
+func (db *Database) Commit() error {
+	if db.tx == nil {
+		return ErrNotTransaction
+	}
+	return db.tx.Commit()
+}
+
+// Rollback undoes the changes of a transaction started with Begin
+func (db *Database) Rollback() error {
+	if db.tx == nil {
+		return ErrNotTransaction
+	}
+	return db.tx.Rollback()
+}
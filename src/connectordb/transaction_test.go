@@ -0,0 +1,37 @@
+package connectordb
+
+import (
+	"connectordb/users"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransaction(t *testing.T) {
+	Tdb.Clear()
+
+	require.Equal(t, ErrNotTransaction, Tdb.Commit())
+	require.Equal(t, ErrNotTransaction, Tdb.Rollback())
+
+	tx, err := Tdb.Begin()
+	require.NoError(t, err)
+	require.NoError(t, tx.CreateUser(&users.UserMaker{User: users.User{Name: "myuser", Email: "email@email", Password: "test", Role: "user", Public: true}}))
+	require.NoError(t, tx.CreateDevice("myuser/mydevice", &users.DeviceMaker{}))
+
+	//The changes are seen within the transaction, but not outside of it
+	_, err = tx.ReadDevice("myuser/mydevice")
+	require.NoError(t, err)
+	_, err = Tdb.ReadUser("myuser")
+	require.Error(t, err)
+
+	require.NoError(t, tx.Rollback())
+	_, err = Tdb.ReadUser("myuser")
+	require.Error(t, err)
+
+	tx, err = Tdb.Begin()
+	require.NoError(t, err)
+	require.NoError(t, tx.CreateUser(&users.UserMaker{User: users.User{Name: "myuser", Email: "email@email", Password: "test", Role: "user", Public: true}}))
+	require.NoError(t, tx.Commit())
+	_, err = Tdb.ReadUser("myuser")
+	require.NoError(t, err)
+}
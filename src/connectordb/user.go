@@ -71,6 +71,7 @@ func (db *Database) UpdateUserByID(userID int64, update map[string]interface{})
 	}
 
 	oldname := u.Name
+	oldemail := u.Email
 	_, haspassword := update["password"]
 
 	err = WriteObjectFromMap(u, update)
@@ -101,6 +102,10 @@ func (db *Database) UpdateUserByID(userID int64, update map[string]interface{})
 	if haspassword {
 		u.SetNewPassword(u.Password)
 	}
+	if u.Email != oldemail {
+		// The new address was not verified
+		u.EmailVerified = false
+	}
 
 	return db.Userdb.UpdateUser(u)
 }
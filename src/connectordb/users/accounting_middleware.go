@@ -123,3 +123,128 @@ func (userdb *AccountingMiddleware) UpdateUser(user *User) error {
 	atomic.AddUint64(&userdb.databaseCalls, 1)
 	return userdb.UserDatabase.UpdateUser(user)
 }
+
+func (userdb *AccountingMiddleware) CreateGrant(g *Grant) error {
+	atomic.AddUint64(&userdb.databaseCalls, 1)
+	return userdb.UserDatabase.CreateGrant(g)
+}
+
+func (userdb *AccountingMiddleware) ReadGrantByID(GrantID int64) (*Grant, error) {
+	atomic.AddUint64(&userdb.databaseCalls, 1)
+	return userdb.UserDatabase.ReadGrantByID(GrantID)
+}
+
+func (userdb *AccountingMiddleware) ReadGrantsByOwner(UserID int64) ([]*Grant, error) {
+	atomic.AddUint64(&userdb.databaseCalls, 1)
+	return userdb.UserDatabase.ReadGrantsByOwner(UserID)
+}
+
+func (userdb *AccountingMiddleware) ReadStreamGrants(GranteeUserID, GranteeDeviceID, StreamID int64) ([]*Grant, error) {
+	atomic.AddUint64(&userdb.databaseCalls, 1)
+	return userdb.UserDatabase.ReadStreamGrants(GranteeUserID, GranteeDeviceID, StreamID)
+}
+
+func (userdb *AccountingMiddleware) DeleteGrant(GrantID int64) error {
+	atomic.AddUint64(&userdb.databaseCalls, 1)
+	return userdb.UserDatabase.DeleteGrant(GrantID)
+}
+
+func (userdb *AccountingMiddleware) CreateToken(t *Token) error {
+	atomic.AddUint64(&userdb.databaseCalls, 1)
+	return userdb.UserDatabase.CreateToken(t)
+}
+
+func (userdb *AccountingMiddleware) ReadTokenByID(TokenID int64) (*Token, error) {
+	atomic.AddUint64(&userdb.databaseCalls, 1)
+	return userdb.UserDatabase.ReadTokenByID(TokenID)
+}
+
+func (userdb *AccountingMiddleware) ReadTokenByKey(Key string) (*Token, error) {
+	atomic.AddUint64(&userdb.databaseCalls, 1)
+	return userdb.UserDatabase.ReadTokenByKey(Key)
+}
+
+func (userdb *AccountingMiddleware) ReadTokensByDevice(DeviceID int64) ([]*Token, error) {
+	atomic.AddUint64(&userdb.databaseCalls, 1)
+	return userdb.UserDatabase.ReadTokensByDevice(DeviceID)
+}
+
+func (userdb *AccountingMiddleware) DeleteToken(TokenID int64) error {
+	atomic.AddUint64(&userdb.databaseCalls, 1)
+	return userdb.UserDatabase.DeleteToken(TokenID)
+}
+
+func (userdb *AccountingMiddleware) CreateIdentity(i *Identity) error {
+	atomic.AddUint64(&userdb.databaseCalls, 1)
+	return userdb.UserDatabase.CreateIdentity(i)
+}
+
+func (userdb *AccountingMiddleware) ReadIdentity(Issuer, Subject string) (*Identity, error) {
+	atomic.AddUint64(&userdb.databaseCalls, 1)
+	return userdb.UserDatabase.ReadIdentity(Issuer, Subject)
+}
+
+func (userdb *AccountingMiddleware) CreateGroup(GroupID, OwnerID int64) error {
+	atomic.AddUint64(&userdb.databaseCalls, 1)
+	return userdb.UserDatabase.CreateGroup(GroupID, OwnerID)
+}
+
+func (userdb *AccountingMiddleware) ReadGroupByID(GroupID int64) (*Group, error) {
+	atomic.AddUint64(&userdb.databaseCalls, 1)
+	return userdb.UserDatabase.ReadGroupByID(GroupID)
+}
+
+func (userdb *AccountingMiddleware) ReadGroupMember(GroupID, UserID int64) (*GroupMember, error) {
+	atomic.AddUint64(&userdb.databaseCalls, 1)
+	return userdb.UserDatabase.ReadGroupMember(GroupID, UserID)
+}
+
+func (userdb *AccountingMiddleware) ReadGroupMembers(GroupID int64) ([]*GroupMember, error) {
+	atomic.AddUint64(&userdb.databaseCalls, 1)
+	return userdb.UserDatabase.ReadGroupMembers(GroupID)
+}
+
+func (userdb *AccountingMiddleware) ReadGroupsByMember(UserID int64) ([]*GroupMember, error) {
+	atomic.AddUint64(&userdb.databaseCalls, 1)
+	return userdb.UserDatabase.ReadGroupsByMember(UserID)
+}
+
+func (userdb *AccountingMiddleware) SetGroupMember(m *GroupMember) error {
+	atomic.AddUint64(&userdb.databaseCalls, 1)
+	return userdb.UserDatabase.SetGroupMember(m)
+}
+
+func (userdb *AccountingMiddleware) DeleteGroupMember(GroupID, UserID int64) error {
+	atomic.AddUint64(&userdb.databaseCalls, 1)
+	return userdb.UserDatabase.DeleteGroupMember(GroupID, UserID)
+}
+
+func (userdb *AccountingMiddleware) SharesGroup(UserID, OtherUserID int64) (bool, error) {
+	atomic.AddUint64(&userdb.databaseCalls, 1)
+	return userdb.UserDatabase.SharesGroup(UserID, OtherUserID)
+}
+
+func (userdb *AccountingMiddleware) CreateWebhook(w *Webhook) error {
+	atomic.AddUint64(&userdb.databaseCalls, 1)
+	return userdb.UserDatabase.CreateWebhook(w)
+}
+
+func (userdb *AccountingMiddleware) ReadWebhookByID(WebhookID int64) (*Webhook, error) {
+	atomic.AddUint64(&userdb.databaseCalls, 1)
+	return userdb.UserDatabase.ReadWebhookByID(WebhookID)
+}
+
+func (userdb *AccountingMiddleware) ReadWebhooksByUser(UserID int64) ([]*Webhook, error) {
+	atomic.AddUint64(&userdb.databaseCalls, 1)
+	return userdb.UserDatabase.ReadWebhooksByUser(UserID)
+}
+
+func (userdb *AccountingMiddleware) ReadStreamWebhooks(StreamID int64) ([]*Webhook, error) {
+	atomic.AddUint64(&userdb.databaseCalls, 1)
+	return userdb.UserDatabase.ReadStreamWebhooks(StreamID)
+}
+
+func (userdb *AccountingMiddleware) DeleteWebhook(WebhookID int64) error {
+	atomic.AddUint64(&userdb.databaseCalls, 1)
+	return userdb.UserDatabase.DeleteWebhook(WebhookID)
+}
@@ -59,10 +59,15 @@ func NewCacheMiddleware(parent UserDatabase, userCacheSize, deviceCacheSize, str
 
 // Clear removes all data from the cache
 func (userdb *CacheMiddleware) Clear() {
+	userdb.purge()
+	userdb.UserDatabase.Clear()
+}
+
+// purge removes everything from the cache, without clearing the database
+func (userdb *CacheMiddleware) purge() {
 	userdb.userCache.Purge()
 	userdb.deviceCache.Purge()
 	userdb.streamCache.Purge()
-	userdb.UserDatabase.Clear()
 }
 
 // Removes a particular user and its dependents from the cache
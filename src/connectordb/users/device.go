@@ -8,6 +8,7 @@ device or service that can read a user's data and write to its streams.
 package users
 
 import (
+	pconfig "config/permissions"
 	"database/sql"
 	"errors"
 	"strings"
@@ -32,6 +33,10 @@ type Device struct {
 
 	IsVisible    bool `json:"visible" permissions:"visible"`
 	UserEditable bool `json:"user_editable" permissions:"user_editable"`
+
+	// Comma separated stream patterns which limit the streams that the device can access, on top of its role's patterns
+	AllowStreams string `json:"allow_streams" permissions:"allow_streams"`
+	DenyStreams  string `json:"deny_streams" permissions:"deny_streams"`
 }
 
 // DeviceMaker is the structure used to create a device
@@ -74,11 +79,44 @@ func (d *Device) ValidityCheck() error {
 	if !IsValidName(d.Name) {
 		return InvalidNameError
 	}
+	if err := pconfig.ValidateStreamPatterns(d.AllowStreamPatterns()); err != nil {
+		return err
+	}
+	if err := pconfig.ValidateStreamPatterns(d.DenyStreamPatterns()); err != nil {
+		return err
+	}
 
 	return validateIcon(d.Icon)
 
 }
 
+// AllowStreamPatterns returns the patterns of the streams that the device is limited to
+func (d *Device) AllowStreamPatterns() []string {
+	return splitList(d.AllowStreams)
+}
+
+// DenyStreamPatterns returns the patterns of the streams that the device can't access
+func (d *Device) DenyStreamPatterns() []string {
+	return splitList(d.DenyStreams)
+}
+
+// CanAccessStream returns true if the device's stream patterns permit access to the stream at the given path
+func (d *Device) CanAccessStream(streampath string) bool {
+	return pconfig.MatchStreamPath(d.AllowStreamPatterns(), d.DenyStreamPatterns(), streampath)
+}
+
+// splitList splits a comma separated list, returning nil if the list is empty
+func splitList(list string) []string {
+	if list == "" {
+		return nil
+	}
+	items := strings.Split(list, ",")
+	for i := range items {
+		items[i] = strings.TrimSpace(items[i])
+	}
+	return items
+}
+
 // CreateDevice adds a device to the system given its owner and name.
 // returns the last inserted id. It is assumed that the DeviceMaker was already validated.
 // This means that DeviceMaker.Validate() had already been called, and returned nil
@@ -110,10 +148,13 @@ func (userdb *SqlUserDatabase) CreateDevice(d *DeviceMaker) error {
 			enabled,
 			role,
 			isvisible,
-			usereditable
+			usereditable,
+			allowstreams,
+			denystreams
 		)
-			VALUES (?,?,?,?,?,?,?,?,?,?,?)`, d.Name, d.APIKey, d.UserID, d.Public,
-		d.Description, d.Icon, d.Nickname, d.Enabled, d.Role, d.IsVisible, d.UserEditable)
+			VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?)`, d.Name, d.APIKey, d.UserID, d.Public,
+		d.Description, d.Icon, d.Nickname, d.Enabled, d.Role, d.IsVisible, d.UserEditable,
+		d.AllowStreams, d.DenyStreams)
 
 	if err != nil && strings.HasPrefix(err.Error(), "pq: duplicate key value violates unique constraint ") {
 		return errors.New("Device with this name already exists")
@@ -217,6 +258,8 @@ func (userdb *SqlUserDatabase) UpdateDevice(device *Device) error {
 		role = ?,
 		isvisible = ?,
 		usereditable = ?,
+		allowstreams = ?,
+		denystreams = ?,
 		public = ? WHERE deviceid = ?;`,
 		device.Name,
 		device.Nickname,
@@ -228,6 +271,8 @@ func (userdb *SqlUserDatabase) UpdateDevice(device *Device) error {
 		device.Role,
 		device.IsVisible,
 		device.UserEditable,
+		device.AllowStreams,
+		device.DenyStreams,
 		device.Public,
 		device.DeviceID)
 
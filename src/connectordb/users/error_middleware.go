@@ -107,6 +107,106 @@ func (userdb *ErrorUserdb) UpdateUser(user *User) error {
 	return ErrorUserdbError
 }
 
+func (userdb *ErrorUserdb) CreateGrant(g *Grant) error {
+	return ErrorUserdbError
+}
+
+func (userdb *ErrorUserdb) ReadGrantByID(GrantID int64) (*Grant, error) {
+	return nil, ErrorUserdbError
+}
+
+func (userdb *ErrorUserdb) ReadGrantsByOwner(UserID int64) ([]*Grant, error) {
+	return nil, ErrorUserdbError
+}
+
+func (userdb *ErrorUserdb) ReadStreamGrants(GranteeUserID, GranteeDeviceID, StreamID int64) ([]*Grant, error) {
+	return nil, ErrorUserdbError
+}
+
+func (userdb *ErrorUserdb) DeleteGrant(GrantID int64) error {
+	return ErrorUserdbError
+}
+
+func (userdb *ErrorUserdb) CreateToken(t *Token) error {
+	return ErrorUserdbError
+}
+
+func (userdb *ErrorUserdb) ReadTokenByID(TokenID int64) (*Token, error) {
+	return nil, ErrorUserdbError
+}
+
+func (userdb *ErrorUserdb) ReadTokenByKey(Key string) (*Token, error) {
+	return nil, ErrorUserdbError
+}
+
+func (userdb *ErrorUserdb) ReadTokensByDevice(DeviceID int64) ([]*Token, error) {
+	return nil, ErrorUserdbError
+}
+
+func (userdb *ErrorUserdb) DeleteToken(TokenID int64) error {
+	return ErrorUserdbError
+}
+
+func (userdb *ErrorUserdb) CreateIdentity(i *Identity) error {
+	return ErrorUserdbError
+}
+
+func (userdb *ErrorUserdb) ReadIdentity(Issuer, Subject string) (*Identity, error) {
+	return nil, ErrorUserdbError
+}
+
+func (userdb *ErrorUserdb) CreateGroup(GroupID, OwnerID int64) error {
+	return ErrorUserdbError
+}
+
+func (userdb *ErrorUserdb) ReadGroupByID(GroupID int64) (*Group, error) {
+	return nil, ErrorUserdbError
+}
+
+func (userdb *ErrorUserdb) ReadGroupMember(GroupID, UserID int64) (*GroupMember, error) {
+	return nil, ErrorUserdbError
+}
+
+func (userdb *ErrorUserdb) ReadGroupMembers(GroupID int64) ([]*GroupMember, error) {
+	return nil, ErrorUserdbError
+}
+
+func (userdb *ErrorUserdb) ReadGroupsByMember(UserID int64) ([]*GroupMember, error) {
+	return nil, ErrorUserdbError
+}
+
+func (userdb *ErrorUserdb) SetGroupMember(m *GroupMember) error {
+	return ErrorUserdbError
+}
+
+func (userdb *ErrorUserdb) DeleteGroupMember(GroupID, UserID int64) error {
+	return ErrorUserdbError
+}
+
+func (userdb *ErrorUserdb) SharesGroup(UserID, OtherUserID int64) (bool, error) {
+	return false, ErrorUserdbError
+}
+
+func (userdb *ErrorUserdb) CreateWebhook(w *Webhook) error {
+	return ErrorUserdbError
+}
+
+func (userdb *ErrorUserdb) ReadWebhookByID(WebhookID int64) (*Webhook, error) {
+	return nil, ErrorUserdbError
+}
+
+func (userdb *ErrorUserdb) ReadWebhooksByUser(UserID int64) ([]*Webhook, error) {
+	return nil, ErrorUserdbError
+}
+
+func (userdb *ErrorUserdb) ReadStreamWebhooks(StreamID int64) ([]*Webhook, error) {
+	return nil, ErrorUserdbError
+}
+
+func (userdb *ErrorUserdb) DeleteWebhook(WebhookID int64) error {
+	return ErrorUserdbError
+}
+
 func (userdb *ErrorUserdb) CountUsers() (int64, error) {
 	return 1, ErrorUserdbError
 }
@@ -0,0 +1,151 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package users
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var (
+	ErrGrantNotFound = errors.New("The requested grant was not found.")
+	ErrInvalidGrant  = errors.New("A grant must have exactly one grantee, exactly one target, and give at least one of read, write or subscribe access")
+)
+
+// Grant gives a specific user or device access to a user, device or stream which it could not otherwise access.
+// Exactly one of the grantee IDs and exactly one of the target IDs is set. A grant on a user or device applies
+// to all of its streams.
+type Grant struct {
+	GrantID int64 `json:"id" db:"grantid"`
+	OwnerID int64 `json:"-" db:"ownerid"` // The user which owns the target of the grant
+
+	GranteeUserID   int64 `json:"-" db:"grantee_userid"`
+	GranteeDeviceID int64 `json:"-" db:"grantee_deviceid"`
+
+	UserID   int64 `json:"-" db:"userid"`
+	DeviceID int64 `json:"-" db:"deviceid"`
+	StreamID int64 `json:"-" db:"streamid"`
+
+	Read      bool    `json:"read" db:"can_read"`
+	Write     bool    `json:"write" db:"can_write"`
+	Subscribe bool    `json:"subscribe" db:"can_subscribe"`
+	Expires   float64 `json:"expires,omitempty" db:"expires"` // The unix time at which the grant expires. 0 means never
+
+	// The paths of the grantee and target are not stored in the database, but are filled in
+	// when the grant is returned through the API
+	Grantee string `json:"grantee" db:"-"`
+	Target  string `json:"target" db:"-"`
+}
+
+// Validate ensures that the grant has a single grantee and target, and gives some form of access
+func (g *Grant) Validate() error {
+	grantees := 0
+	for _, id := range []int64{g.GranteeUserID, g.GranteeDeviceID} {
+		if id > 0 {
+			grantees++
+		}
+	}
+	targets := 0
+	for _, id := range []int64{g.UserID, g.DeviceID, g.StreamID} {
+		if id > 0 {
+			targets++
+		}
+	}
+	if grantees != 1 || targets != 1 || g.OwnerID <= 0 || !(g.Read || g.Write || g.Subscribe) || g.Expires < 0 {
+		return ErrInvalidGrant
+	}
+	return nil
+}
+
+// IsExpired returns true if the grant is no longer valid
+func (g *Grant) IsExpired() bool {
+	return g.Expires > 0 && g.Expires <= float64(time.Now().Unix())
+}
+
+// nullID stores unset IDs as NULL, so that the foreign keys of the grants table are satisfied
+func nullID(id int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: id, Valid: id > 0}
+}
+
+// The nullable ID columns are read as 0 when unset
+const grantColumns = `grantid, ownerid,
+	COALESCE(grantee_userid, 0) AS grantee_userid, COALESCE(grantee_deviceid, 0) AS grantee_deviceid,
+	COALESCE(userid, 0) AS userid, COALESCE(deviceid, 0) AS deviceid, COALESCE(streamid, 0) AS streamid,
+	can_read, can_write, can_subscribe, expires`
+
+// CreateGrant adds the given grant to the database
+func (userdb *SqlUserDatabase) CreateGrant(g *Grant) error {
+	if err := g.Validate(); err != nil {
+		return err
+	}
+
+	_, err := userdb.Exec(`INSERT INTO grants
+		(	ownerid,
+			grantee_userid,
+			grantee_deviceid,
+			userid,
+			deviceid,
+			streamid,
+			can_read,
+			can_write,
+			can_subscribe,
+			expires) VALUES (?,?,?,?,?,?,?,?,?,?);`, g.OwnerID, nullID(g.GranteeUserID), nullID(g.GranteeDeviceID),
+		nullID(g.UserID), nullID(g.DeviceID), nullID(g.StreamID), g.Read, g.Write, g.Subscribe, g.Expires)
+	return err
+}
+
+// ReadGrantByID reads the grant with the given ID
+func (userdb *SqlUserDatabase) ReadGrantByID(GrantID int64) (*Grant, error) {
+	var grant Grant
+
+	err := userdb.Get(&grant, "SELECT "+grantColumns+" FROM grants WHERE grantid = ? LIMIT 1;", GrantID)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrGrantNotFound
+	}
+
+	return &grant, err
+}
+
+// ReadGrantsByOwner reads all of the grants that were given on the user's own user, devices and streams,
+// including the expired ones
+func (userdb *SqlUserDatabase) ReadGrantsByOwner(UserID int64) ([]*Grant, error) {
+	var grants []*Grant
+
+	err := userdb.Select(&grants, "SELECT "+grantColumns+" FROM grants WHERE ownerid = ? ORDER BY grantid ASC;", UserID)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrGrantNotFound
+	}
+
+	return grants, err
+}
+
+// ReadStreamGrants reads all of the unexpired grants that give the given user or device access to the given stream,
+// either directly, or through the stream's device or user
+func (userdb *SqlUserDatabase) ReadStreamGrants(GranteeUserID, GranteeDeviceID, StreamID int64) ([]*Grant, error) {
+	var grants []*Grant
+
+	err := userdb.Select(&grants, `SELECT `+grantColumns+` FROM grants
+		WHERE (grantee_userid = ? OR grantee_deviceid = ?)
+		AND (expires = 0 OR expires > ?)
+		AND (streamid = ?
+			OR deviceid = (SELECT deviceid FROM streams WHERE streamid = ?)
+			OR userid = (SELECT d.userid FROM streams s INNER JOIN devices d ON s.deviceid = d.deviceid WHERE s.streamid = ?));`,
+		GranteeUserID, GranteeDeviceID, float64(time.Now().Unix()), StreamID, StreamID, StreamID)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	return grants, err
+}
+
+// DeleteGrant removes the given grant
+func (userdb *SqlUserDatabase) DeleteGrant(GrantID int64) error {
+	result, err := userdb.Exec(`DELETE FROM grants WHERE grantid = ?;`, GrantID)
+	return getDeleteError(result, err)
+}
@@ -0,0 +1,71 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package users
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGrant(t *testing.T) {
+	for _, testdb := range testdatabases {
+		u, d, s, err := CreateUDS(testdb)
+		require.NoError(t, err)
+		u2, d2, _, err := CreateUDS(testdb)
+		require.NoError(t, err)
+
+		// Invalid grants
+		require.Error(t, testdb.CreateGrant(&Grant{OwnerID: u.UserID, GranteeUserID: u2.UserID, StreamID: s.StreamID}))
+		require.Error(t, testdb.CreateGrant(&Grant{OwnerID: u.UserID, GranteeUserID: u2.UserID, GranteeDeviceID: d2.DeviceID, StreamID: s.StreamID, Read: true}))
+		require.Error(t, testdb.CreateGrant(&Grant{OwnerID: u.UserID, GranteeUserID: u2.UserID, DeviceID: d.DeviceID, StreamID: s.StreamID, Read: true}))
+
+		grants, err := testdb.ReadStreamGrants(u2.UserID, d2.DeviceID, s.StreamID)
+		require.NoError(t, err)
+		require.Len(t, grants, 0)
+
+		// A grant to the user on the stream
+		require.NoError(t, testdb.CreateGrant(&Grant{OwnerID: u.UserID, GranteeUserID: u2.UserID, StreamID: s.StreamID, Read: true}))
+		grants, err = testdb.ReadStreamGrants(u2.UserID, d2.DeviceID, s.StreamID)
+		require.NoError(t, err)
+		require.Len(t, grants, 1)
+		require.True(t, grants[0].Read)
+		require.False(t, grants[0].Write)
+		require.Equal(t, s.StreamID, grants[0].StreamID)
+		require.EqualValues(t, 0, grants[0].DeviceID)
+
+		// A grant to the device on the stream's device
+		require.NoError(t, testdb.CreateGrant(&Grant{OwnerID: u.UserID, GranteeDeviceID: d2.DeviceID, DeviceID: d.DeviceID, Write: true}))
+		grants, err = testdb.ReadStreamGrants(u2.UserID, d2.DeviceID, s.StreamID)
+		require.NoError(t, err)
+		require.Len(t, grants, 2)
+
+		// A grant on the stream's user, which expired
+		require.NoError(t, testdb.CreateGrant(&Grant{OwnerID: u.UserID, GranteeUserID: u2.UserID, UserID: u.UserID, Subscribe: true, Expires: float64(time.Now().Unix() - 10)}))
+		grants, err = testdb.ReadStreamGrants(u2.UserID, d2.DeviceID, s.StreamID)
+		require.NoError(t, err)
+		require.Len(t, grants, 2)
+
+		grants, err = testdb.ReadGrantsByOwner(u.UserID)
+		require.NoError(t, err)
+		require.Len(t, grants, 3)
+		require.True(t, grants[2].IsExpired())
+
+		g, err := testdb.ReadGrantByID(grants[0].GrantID)
+		require.NoError(t, err)
+		require.Equal(t, u2.UserID, g.GranteeUserID)
+
+		require.NoError(t, testdb.DeleteGrant(g.GrantID))
+		require.Error(t, testdb.DeleteGrant(g.GrantID))
+		_, err = testdb.ReadGrantByID(g.GrantID)
+		require.Equal(t, ErrGrantNotFound, err)
+
+		// The other user's grants only apply to the other user
+		grants, err = testdb.ReadStreamGrants(u.UserID, d.DeviceID, s.StreamID)
+		require.NoError(t, err)
+		require.Len(t, grants, 0)
+	}
+}
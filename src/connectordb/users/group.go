@@ -0,0 +1,182 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+
+This file contains the functions for groups. A group is a team of users which share devices and streams.
+Each group is backed by a user of its own, which owns the group's devices, so that they are accessed through
+the same paths as the devices of any other user. The members of the group are given access to the group's
+devices, and to each other's group-public streams. Users are invited to groups by the group's managers, and
+only become members once they accept the invitation.
+**/
+package users
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+)
+
+// The roles that a member can have in a group
+const (
+	GroupRoleOwner  = "owner"  // Owners manage the group and its members, and can delete the group
+	GroupRoleAdmin  = "admin"  // Admins manage the group's devices and its members, other than the owners
+	GroupRoleMember = "member" // Members get the group access level to the group's devices and streams
+)
+
+var (
+	ErrGroupNotFound       = errors.New("The requested group was not found.")
+	ErrGroupMemberNotFound = errors.New("The user is not a member of the group.")
+	ErrInvalidGroupMember  = errors.New("A group member must have a role of 'owner', 'admin' or 'member'")
+)
+
+// Group is a team of users. The group's ID is the ID of the user which backs it.
+type Group struct {
+	GroupID int64  `json:"-" db:"groupid"`
+	Name    string `json:"name" db:"name"`
+}
+
+// GroupMember is the membership of a user in a group. Until the user accepts it, the membership is an invitation,
+// which gives no access.
+type GroupMember struct {
+	GroupID  int64  `json:"-" db:"groupid"`
+	UserID   int64  `json:"-" db:"userid"`
+	Role     string `json:"role" db:"role"`
+	Accepted bool   `json:"accepted" db:"accepted"`
+
+	// The names of the group and user, which are read along with the membership
+	Group string `json:"group" db:"groupname"`
+	User  string `json:"user" db:"username"`
+}
+
+// Validate ensures that the membership has a valid role
+func (m *GroupMember) Validate() error {
+	if m.GroupID <= 0 || m.UserID <= 0 {
+		return ErrInvalidGroupMember
+	}
+	switch m.Role {
+	case GroupRoleOwner, GroupRoleAdmin, GroupRoleMember:
+		return nil
+	}
+	return ErrInvalidGroupMember
+}
+
+// CanManage returns true if the member can manage the group's members and devices
+func (m *GroupMember) CanManage() bool {
+	return m.Role == GroupRoleOwner || m.Role == GroupRoleAdmin
+}
+
+// The membership is read along with the names of its group and user
+const groupMemberQuery = `SELECT m.groupid, m.userid, m.role, m.accepted, g.name AS groupname, u.name AS username FROM groupmembers m
+	INNER JOIN users g ON m.groupid = g.userid
+	INNER JOIN users u ON m.userid = u.userid`
+
+// CreateGroup makes the given user into a group, with the given user as its owner
+func (userdb *SqlUserDatabase) CreateGroup(GroupID, OwnerID int64) error {
+	if GroupID == OwnerID {
+		return errors.New("A group can't be a member of itself")
+	}
+	if _, err := userdb.Exec(`INSERT INTO usergroups (groupid) VALUES (?);`, GroupID); err != nil {
+		if strings.HasPrefix(err.Error(), "pq: duplicate key value violates unique constraint ") {
+			return errors.New("The user is already a group")
+		}
+		return err
+	}
+	return userdb.SetGroupMember(&GroupMember{GroupID: GroupID, UserID: OwnerID, Role: GroupRoleOwner, Accepted: true})
+}
+
+// ReadGroupByID reads the group backed by the given user
+func (userdb *SqlUserDatabase) ReadGroupByID(GroupID int64) (*Group, error) {
+	var group Group
+
+	err := userdb.Get(&group, `SELECT g.groupid, u.name FROM usergroups g INNER JOIN users u ON g.groupid = u.userid
+		WHERE g.groupid = ? LIMIT 1;`, GroupID)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrGroupNotFound
+	}
+
+	return &group, err
+}
+
+// ReadGroupMember reads the membership of the given user in the given group
+func (userdb *SqlUserDatabase) ReadGroupMember(GroupID, UserID int64) (*GroupMember, error) {
+	var member GroupMember
+
+	err := userdb.Get(&member, groupMemberQuery+" WHERE m.groupid = ? AND m.userid = ? LIMIT 1;", GroupID, UserID)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrGroupMemberNotFound
+	}
+
+	return &member, err
+}
+
+// ReadGroupMembers reads all of the members of the group
+func (userdb *SqlUserDatabase) ReadGroupMembers(GroupID int64) ([]*GroupMember, error) {
+	var members []*GroupMember
+
+	err := userdb.Select(&members, groupMemberQuery+" WHERE m.groupid = ? ORDER BY u.name ASC;", GroupID)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrGroupNotFound
+	}
+
+	return members, err
+}
+
+// ReadGroupsByMember reads the memberships of the user in all of its groups
+func (userdb *SqlUserDatabase) ReadGroupsByMember(UserID int64) ([]*GroupMember, error) {
+	var members []*GroupMember
+
+	err := userdb.Select(&members, groupMemberQuery+" WHERE m.userid = ? ORDER BY g.name ASC;", UserID)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	return members, err
+}
+
+// SetGroupMember adds the user to the group, or changes its role if it is already a member. A membership which
+// was accepted stays accepted, so that changing the role of a member doesn't turn it back into an invitation.
+func (userdb *SqlUserDatabase) SetGroupMember(m *GroupMember) error {
+	if err := m.Validate(); err != nil {
+		return err
+	}
+	if m.GroupID == m.UserID {
+		return errors.New("A group can't be a member of itself")
+	}
+
+	result, err := userdb.Exec(`UPDATE groupmembers SET role = ?, accepted = (accepted OR ?) WHERE groupid = ? AND userid = ?;`,
+		m.Role, m.Accepted, m.GroupID, m.UserID)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err != nil || rows > 0 {
+		return err
+	}
+
+	_, err = userdb.Exec(`INSERT INTO groupmembers
+		(	groupid,
+			userid,
+			role,
+			accepted) VALUES (?,?,?,?);`, m.GroupID, m.UserID, m.Role, m.Accepted)
+	return err
+}
+
+// DeleteGroupMember removes the user from the group
+func (userdb *SqlUserDatabase) DeleteGroupMember(GroupID, UserID int64) error {
+	result, err := userdb.Exec(`DELETE FROM groupmembers WHERE groupid = ? AND userid = ?;`, GroupID, UserID)
+	return getDeleteError(result, err)
+}
+
+// SharesGroup returns true if the two users are members of the same group. Invitations which were not accepted
+// don't count.
+func (userdb *SqlUserDatabase) SharesGroup(UserID, OtherUserID int64) (bool, error) {
+	var count int64
+
+	err := userdb.Get(&count, `SELECT COUNT(*) FROM groupmembers m INNER JOIN groupmembers o ON m.groupid = o.groupid
+		WHERE m.userid = ? AND o.userid = ? AND m.accepted = ? AND o.accepted = ?;`, UserID, OtherUserID, true, true)
+
+	return count > 0, err
+}
@@ -0,0 +1,86 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package users
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroup(t *testing.T) {
+	for _, testdb := range testdatabases {
+		grp, err := CreateTestUser(testdb)
+		require.NoError(t, err)
+		owner, err := CreateTestUser(testdb)
+		require.NoError(t, err)
+		member, d, _, err := CreateUDS(testdb)
+		require.NoError(t, err)
+		other, err := CreateTestUser(testdb)
+		require.NoError(t, err)
+
+		_, err = testdb.ReadGroupByID(grp.UserID)
+		require.Equal(t, ErrGroupNotFound, err)
+		require.NoError(t, testdb.CreateGroup(grp.UserID, owner.UserID))
+		g, err := testdb.ReadGroupByID(grp.UserID)
+		require.NoError(t, err)
+		require.Equal(t, grp.Name, g.Name)
+
+		m, err := testdb.ReadGroupMember(grp.UserID, owner.UserID)
+		require.NoError(t, err)
+		require.Equal(t, GroupRoleOwner, m.Role)
+		require.Equal(t, grp.Name, m.Group)
+		require.Equal(t, owner.Name, m.User)
+		require.True(t, m.Accepted)
+
+		require.Error(t, testdb.SetGroupMember(&GroupMember{GroupID: grp.UserID, UserID: member.UserID, Role: "lol"}))
+		require.NoError(t, testdb.SetGroupMember(&GroupMember{GroupID: grp.UserID, UserID: member.UserID, Role: GroupRoleAdmin}))
+		require.NoError(t, testdb.SetGroupMember(&GroupMember{GroupID: grp.UserID, UserID: member.UserID, Role: GroupRoleMember}))
+
+		members, err := testdb.ReadGroupMembers(grp.UserID)
+		require.NoError(t, err)
+		require.Len(t, members, 2)
+
+		groups, err := testdb.ReadGroupsByMember(member.UserID)
+		require.NoError(t, err)
+		require.Len(t, groups, 1)
+		require.Equal(t, GroupRoleMember, groups[0].Role)
+		require.False(t, groups[0].Accepted)
+
+		// The member only shares the group once it accepts the invitation
+		shared, err := testdb.SharesGroup(owner.UserID, member.UserID)
+		require.NoError(t, err)
+		require.False(t, shared)
+		require.NoError(t, testdb.SetGroupMember(&GroupMember{GroupID: grp.UserID, UserID: member.UserID, Role: GroupRoleMember, Accepted: true}))
+		shared, err = testdb.SharesGroup(owner.UserID, member.UserID)
+		require.NoError(t, err)
+		require.True(t, shared)
+
+		// Changing the role keeps the membership accepted
+		require.NoError(t, testdb.SetGroupMember(&GroupMember{GroupID: grp.UserID, UserID: member.UserID, Role: GroupRoleAdmin}))
+		m, err = testdb.ReadGroupMember(grp.UserID, member.UserID)
+		require.NoError(t, err)
+		require.True(t, m.Accepted)
+		shared, err = testdb.SharesGroup(owner.UserID, other.UserID)
+		require.NoError(t, err)
+		require.False(t, shared)
+
+		// Group-public streams are returned along with the streams of public devices
+		require.NoError(t, testdb.CreateStream(&StreamMaker{Stream: Stream{Name: "shared", Schema: `{"type":"number"}`, DeviceID: d.DeviceID, GroupPublic: true}}))
+		streams, err := testdb.ReadStreamsByUser(member.UserID, true, false, false)
+		require.NoError(t, err)
+		require.Len(t, streams, 1)
+		require.Equal(t, "shared", streams[0].Name)
+		require.True(t, streams[0].GroupPublic)
+
+		require.NoError(t, testdb.DeleteGroupMember(grp.UserID, member.UserID))
+		require.Equal(t, ErrNothingToDelete, testdb.DeleteGroupMember(grp.UserID, member.UserID))
+		_, err = testdb.ReadGroupMember(grp.UserID, member.UserID)
+		require.Equal(t, ErrGroupMemberNotFound, err)
+		shared, err = testdb.SharesGroup(owner.UserID, member.UserID)
+		require.NoError(t, err)
+		require.False(t, shared)
+	}
+}
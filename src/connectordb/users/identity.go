@@ -0,0 +1,58 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+
+This file contains the functions for external identities. An identity links a user to their
+account at an OpenID Connect identity provider, so that they can log in through the provider.
+**/
+package users
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+)
+
+var (
+	ErrIdentityNotFound = errors.New("The identity is not linked to any user.")
+	ErrInvalidIdentity  = errors.New("An identity must have an issuer and a subject")
+)
+
+// Identity is the account of a user at an external identity provider. The account is identified
+// by the provider's issuer URL, and the subject that the provider gives the account.
+type Identity struct {
+	IdentityID int64  `json:"id" db:"identityid"`
+	UserID     int64  `json:"-" db:"userid"`
+	Issuer     string `json:"issuer" db:"issuer"`
+	Subject    string `json:"subject" db:"subject"`
+}
+
+// CreateIdentity links the given identity to its user
+func (userdb *SqlUserDatabase) CreateIdentity(i *Identity) error {
+	if i.UserID <= 0 || i.Issuer == "" || i.Subject == "" {
+		return ErrInvalidIdentity
+	}
+
+	_, err := userdb.Exec(`INSERT INTO identities
+		(	userid,
+			issuer,
+			subject) VALUES (?,?,?);`, i.UserID, i.Issuer, i.Subject)
+
+	if err != nil && strings.HasPrefix(err.Error(), "pq: duplicate key value violates unique constraint ") {
+		return errors.New("This identity is already linked to a user")
+	}
+	return err
+}
+
+// ReadIdentity reads the identity with the given issuer and subject
+func (userdb *SqlUserDatabase) ReadIdentity(Issuer, Subject string) (*Identity, error) {
+	var identity Identity
+
+	err := userdb.Get(&identity, "SELECT * FROM identities WHERE issuer = ? AND subject = ? LIMIT 1;", Issuer, Subject)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrIdentityNotFound
+	}
+
+	return &identity, err
+}
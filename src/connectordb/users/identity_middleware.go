@@ -98,3 +98,103 @@ func (userdb *IdentityMiddleware) UpdateStream(stream *Stream) error {
 func (userdb *IdentityMiddleware) UpdateUser(user *User) error {
 	return userdb.UserDatabase.UpdateUser(user)
 }
+
+func (userdb *IdentityMiddleware) CreateGrant(g *Grant) error {
+	return userdb.UserDatabase.CreateGrant(g)
+}
+
+func (userdb *IdentityMiddleware) ReadGrantByID(GrantID int64) (*Grant, error) {
+	return userdb.UserDatabase.ReadGrantByID(GrantID)
+}
+
+func (userdb *IdentityMiddleware) ReadGrantsByOwner(UserID int64) ([]*Grant, error) {
+	return userdb.UserDatabase.ReadGrantsByOwner(UserID)
+}
+
+func (userdb *IdentityMiddleware) ReadStreamGrants(GranteeUserID, GranteeDeviceID, StreamID int64) ([]*Grant, error) {
+	return userdb.UserDatabase.ReadStreamGrants(GranteeUserID, GranteeDeviceID, StreamID)
+}
+
+func (userdb *IdentityMiddleware) DeleteGrant(GrantID int64) error {
+	return userdb.UserDatabase.DeleteGrant(GrantID)
+}
+
+func (userdb *IdentityMiddleware) CreateToken(t *Token) error {
+	return userdb.UserDatabase.CreateToken(t)
+}
+
+func (userdb *IdentityMiddleware) ReadTokenByID(TokenID int64) (*Token, error) {
+	return userdb.UserDatabase.ReadTokenByID(TokenID)
+}
+
+func (userdb *IdentityMiddleware) ReadTokenByKey(Key string) (*Token, error) {
+	return userdb.UserDatabase.ReadTokenByKey(Key)
+}
+
+func (userdb *IdentityMiddleware) ReadTokensByDevice(DeviceID int64) ([]*Token, error) {
+	return userdb.UserDatabase.ReadTokensByDevice(DeviceID)
+}
+
+func (userdb *IdentityMiddleware) DeleteToken(TokenID int64) error {
+	return userdb.UserDatabase.DeleteToken(TokenID)
+}
+
+func (userdb *IdentityMiddleware) CreateIdentity(i *Identity) error {
+	return userdb.UserDatabase.CreateIdentity(i)
+}
+
+func (userdb *IdentityMiddleware) ReadIdentity(Issuer, Subject string) (*Identity, error) {
+	return userdb.UserDatabase.ReadIdentity(Issuer, Subject)
+}
+
+func (userdb *IdentityMiddleware) CreateGroup(GroupID, OwnerID int64) error {
+	return userdb.UserDatabase.CreateGroup(GroupID, OwnerID)
+}
+
+func (userdb *IdentityMiddleware) ReadGroupByID(GroupID int64) (*Group, error) {
+	return userdb.UserDatabase.ReadGroupByID(GroupID)
+}
+
+func (userdb *IdentityMiddleware) ReadGroupMember(GroupID, UserID int64) (*GroupMember, error) {
+	return userdb.UserDatabase.ReadGroupMember(GroupID, UserID)
+}
+
+func (userdb *IdentityMiddleware) ReadGroupMembers(GroupID int64) ([]*GroupMember, error) {
+	return userdb.UserDatabase.ReadGroupMembers(GroupID)
+}
+
+func (userdb *IdentityMiddleware) ReadGroupsByMember(UserID int64) ([]*GroupMember, error) {
+	return userdb.UserDatabase.ReadGroupsByMember(UserID)
+}
+
+func (userdb *IdentityMiddleware) SetGroupMember(m *GroupMember) error {
+	return userdb.UserDatabase.SetGroupMember(m)
+}
+
+func (userdb *IdentityMiddleware) DeleteGroupMember(GroupID, UserID int64) error {
+	return userdb.UserDatabase.DeleteGroupMember(GroupID, UserID)
+}
+
+func (userdb *IdentityMiddleware) SharesGroup(UserID, OtherUserID int64) (bool, error) {
+	return userdb.UserDatabase.SharesGroup(UserID, OtherUserID)
+}
+
+func (userdb *IdentityMiddleware) CreateWebhook(w *Webhook) error {
+	return userdb.UserDatabase.CreateWebhook(w)
+}
+
+func (userdb *IdentityMiddleware) ReadWebhookByID(WebhookID int64) (*Webhook, error) {
+	return userdb.UserDatabase.ReadWebhookByID(WebhookID)
+}
+
+func (userdb *IdentityMiddleware) ReadWebhooksByUser(UserID int64) ([]*Webhook, error) {
+	return userdb.UserDatabase.ReadWebhooksByUser(UserID)
+}
+
+func (userdb *IdentityMiddleware) ReadStreamWebhooks(StreamID int64) ([]*Webhook, error) {
+	return userdb.UserDatabase.ReadStreamWebhooks(StreamID)
+}
+
+func (userdb *IdentityMiddleware) DeleteWebhook(WebhookID int64) error {
+	return userdb.UserDatabase.DeleteWebhook(WebhookID)
+}
@@ -0,0 +1,29 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package users
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdentity(t *testing.T) {
+	for _, testdb := range testdatabases {
+		u, _, _, err := CreateUDS(testdb)
+		require.NoError(t, err)
+
+		require.Error(t, testdb.CreateIdentity(&Identity{UserID: u.UserID, Issuer: "https://id.example.com"}))
+		require.NoError(t, testdb.CreateIdentity(&Identity{UserID: u.UserID, Issuer: "https://id.example.com", Subject: "1234"}))
+		require.Error(t, testdb.CreateIdentity(&Identity{UserID: u.UserID, Issuer: "https://id.example.com", Subject: "1234"}))
+
+		i, err := testdb.ReadIdentity("https://id.example.com", "1234")
+		require.NoError(t, err)
+		require.Equal(t, u.UserID, i.UserID)
+
+		_, err = testdb.ReadIdentity("https://other.example.com", "1234")
+		require.Equal(t, ErrIdentityNotFound, err)
+	}
+}
@@ -107,6 +107,106 @@ func (userdb *KnownUserdb) UpdateUser(user *User) error {
 	return nil
 }
 
+func (userdb *KnownUserdb) CreateGrant(g *Grant) error {
+	return nil
+}
+
+func (userdb *KnownUserdb) ReadGrantByID(GrantID int64) (*Grant, error) {
+	return &Grant{GrantID: GrantID}, nil
+}
+
+func (userdb *KnownUserdb) ReadGrantsByOwner(UserID int64) ([]*Grant, error) {
+	return []*Grant{}, nil
+}
+
+func (userdb *KnownUserdb) ReadStreamGrants(GranteeUserID, GranteeDeviceID, StreamID int64) ([]*Grant, error) {
+	return []*Grant{}, nil
+}
+
+func (userdb *KnownUserdb) DeleteGrant(GrantID int64) error {
+	return nil
+}
+
+func (userdb *KnownUserdb) CreateToken(t *Token) error {
+	return nil
+}
+
+func (userdb *KnownUserdb) ReadTokenByID(TokenID int64) (*Token, error) {
+	return &Token{TokenID: TokenID, Access: TokenReadWrite}, nil
+}
+
+func (userdb *KnownUserdb) ReadTokenByKey(Key string) (*Token, error) {
+	return &Token{Key: Key, Access: TokenReadWrite}, nil
+}
+
+func (userdb *KnownUserdb) ReadTokensByDevice(DeviceID int64) ([]*Token, error) {
+	return []*Token{}, nil
+}
+
+func (userdb *KnownUserdb) DeleteToken(TokenID int64) error {
+	return nil
+}
+
+func (userdb *KnownUserdb) CreateIdentity(i *Identity) error {
+	return nil
+}
+
+func (userdb *KnownUserdb) ReadIdentity(Issuer, Subject string) (*Identity, error) {
+	return &Identity{UserID: 1, Issuer: Issuer, Subject: Subject}, nil
+}
+
+func (userdb *KnownUserdb) CreateGroup(GroupID, OwnerID int64) error {
+	return nil
+}
+
+func (userdb *KnownUserdb) ReadGroupByID(GroupID int64) (*Group, error) {
+	return &Group{GroupID: GroupID}, nil
+}
+
+func (userdb *KnownUserdb) ReadGroupMember(GroupID, UserID int64) (*GroupMember, error) {
+	return &GroupMember{GroupID: GroupID, UserID: UserID, Role: GroupRoleMember}, nil
+}
+
+func (userdb *KnownUserdb) ReadGroupMembers(GroupID int64) ([]*GroupMember, error) {
+	return []*GroupMember{}, nil
+}
+
+func (userdb *KnownUserdb) ReadGroupsByMember(UserID int64) ([]*GroupMember, error) {
+	return []*GroupMember{}, nil
+}
+
+func (userdb *KnownUserdb) SetGroupMember(m *GroupMember) error {
+	return nil
+}
+
+func (userdb *KnownUserdb) DeleteGroupMember(GroupID, UserID int64) error {
+	return nil
+}
+
+func (userdb *KnownUserdb) SharesGroup(UserID, OtherUserID int64) (bool, error) {
+	return false, nil
+}
+
+func (userdb *KnownUserdb) CreateWebhook(w *Webhook) error {
+	return nil
+}
+
+func (userdb *KnownUserdb) ReadWebhookByID(WebhookID int64) (*Webhook, error) {
+	return &Webhook{WebhookID: WebhookID}, nil
+}
+
+func (userdb *KnownUserdb) ReadWebhooksByUser(UserID int64) ([]*Webhook, error) {
+	return []*Webhook{}, nil
+}
+
+func (userdb *KnownUserdb) ReadStreamWebhooks(StreamID int64) ([]*Webhook, error) {
+	return []*Webhook{}, nil
+}
+
+func (userdb *KnownUserdb) DeleteWebhook(WebhookID int64) error {
+	return nil
+}
+
 func (userdb *KnownUserdb) CountUsers() (int64, error) {
 	return 1, nil
 }
@@ -34,6 +34,7 @@ type Stream struct {
 	DeviceID    int64  `json:"-" permissions:"-"`
 	Ephemeral   bool   `json:"ephemeral" permissions:"ephemeral"`
 	Downlink    bool   `json:"downlink" permissions:"downlink"`
+	GroupPublic bool   `json:"group_public" permissions:"group_public"` // Whether the members of the owner's groups can access the stream
 }
 
 // The struct passed in to create a stream
@@ -160,8 +161,9 @@ func (userdb *SqlUserDatabase) CreateStream(s *StreamMaker) error {
 			icon,
 			nickname,
 			ephemeral,
-			downlink) VALUES (?,?,?,?,?,?,?,?,?);`, s.Name, minSchema, s.DeviceID,
-		s.Description, s.Datatype, s.Icon, s.Nickname, s.Ephemeral, s.Downlink)
+			downlink,
+			grouppublic) VALUES (?,?,?,?,?,?,?,?,?,?);`, s.Name, minSchema, s.DeviceID,
+		s.Description, s.Datatype, s.Icon, s.Nickname, s.Ephemeral, s.Downlink, s.GroupPublic)
 
 	if err != nil && strings.HasPrefix(err.Error(), "pq: duplicate key value violates unique constraint ") {
 		return errors.New("Stream with this name already exists")
@@ -231,7 +233,8 @@ func (userdb *SqlUserDatabase) UpdateStream(stream *Stream) error {
 		datatype= ?,
 		deviceid = ?,
 		ephemeral = ?,
-		downlink = ?
+		downlink = ?,
+		grouppublic = ?
 		WHERE streamid= ?;`,
 		stream.Name,
 		stream.Nickname,
@@ -242,6 +245,7 @@ func (userdb *SqlUserDatabase) UpdateStream(stream *Stream) error {
 		stream.DeviceID,
 		stream.Ephemeral,
 		stream.Downlink,
+		stream.GroupPublic,
 		stream.StreamID)
 
 	return err
@@ -261,7 +265,7 @@ type DevStream struct {
 
 // ReadStreamsByUser returns a user's streams along with parent device name.
 // If downlink is true, returns only streams that are downlinks
-// If public is true, only returns streams of public devices.
+// If public is true, only returns streams of public devices, and the streams which are public within the user's groups.
 // If hidehidden is true, only returns the streams that belong to visible devices
 func (userdb *SqlUserDatabase) ReadStreamsByUser(UserID int64, public, downlink, hidehidden bool) ([]*DevStream, error) {
 	var streams []*DevStream
@@ -280,8 +284,8 @@ func (userdb *SqlUserDatabase) ReadStreamsByUser(UserID int64, public, downlink,
 	}
 
 	if public {
-		query += " AND d.public = ?"
-		params = append(params, true)
+		query += " AND (d.public = ? OR s.grouppublic = ?)"
+		params = append(params, true, true)
 	}
 
 	if hidehidden {
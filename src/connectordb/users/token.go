@@ -0,0 +1,164 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+
+This file contains the functions for "tokens". A token is a named, revocable key which a device
+gives to a client in place of its API key. The token carries only a subset of the device's rights.
+**/
+package users
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/nu7hatch/gouuid"
+)
+
+// The access that a token can give to the data of streams
+const (
+	TokenRead      = "read"
+	TokenWrite     = "write"
+	TokenReadWrite = "readwrite"
+
+	// A refresh token can't log in, and gives no access. It is only exchanged for access tokens by the
+	// OAuth token endpoint, so that an app's long-lived credential can be revoked like any other token.
+	TokenRefresh = "refresh"
+)
+
+var (
+	ErrTokenNotFound = errors.New("The requested token was not found.")
+	ErrTokenExpired  = errors.New("The token has expired.")
+	ErrInvalidToken  = errors.New("A token must have a name and an access of 'read', 'write', 'readwrite' or 'refresh'")
+	ErrRefreshToken  = errors.New("Refresh tokens can't be used to log in")
+)
+
+// Token is a key which logs in as its device, but only gives read and/or write access to the data
+// of the given streams. Tokens can't modify users, devices or streams.
+type Token struct {
+	TokenID  int64  `json:"id" db:"tokenid"`
+	DeviceID int64  `json:"-" db:"deviceid"` // The device which the token logs in as
+	Name     string `json:"name" db:"name"`
+	Key      string `json:"token" db:"token"` // A uuid used as the token
+
+	Streams string  `json:"streams" db:"streams"`           // A comma separated list of the stream paths that the token can access. Empty means all streams.
+	Access  string  `json:"access" db:"access"`             // One of read, write or readwrite
+	Expires float64 `json:"expires,omitempty" db:"expires"` // The unix time at which the token expires. 0 means never
+}
+
+// Validate ensures that the token has a name and a valid access
+func (t *Token) Validate() error {
+	if t.DeviceID <= 0 || !IsValidName(t.Name) || t.Expires < 0 {
+		return ErrInvalidToken
+	}
+	switch t.Access {
+	case TokenRead, TokenWrite, TokenReadWrite, TokenRefresh:
+		return nil
+	}
+	return ErrInvalidToken
+}
+
+// IsExpired returns true if the token can no longer be used
+func (t *Token) IsExpired() bool {
+	return t.Expires > 0 && t.Expires <= float64(time.Now().Unix())
+}
+
+// StreamPaths returns the paths of the streams that the token is limited to
+func (t *Token) StreamPaths() []string {
+	return splitList(t.Streams)
+}
+
+// CanAccess returns true if the token permits reading (or writing) the data of the stream at the given path
+func (t *Token) CanAccess(streampath string, write bool) bool {
+	if t.IsExpired() || t.Access == TokenRefresh {
+		return false
+	}
+	if write && t.Access == TokenRead || !write && t.Access == TokenWrite {
+		return false
+	}
+	paths := t.StreamPaths()
+	if len(paths) == 0 {
+		return true
+	}
+	for _, p := range paths {
+		if p == streampath {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateToken adds the given token to the database, generating its key if it was not given
+func (userdb *SqlUserDatabase) CreateToken(t *Token) error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+	if t.Key == "" {
+		key, _ := uuid.NewV4()
+		t.Key = key.String()
+	}
+
+	_, err := userdb.Exec(`INSERT INTO tokens
+		(	deviceid,
+			name,
+			token,
+			streams,
+			access,
+			expires) VALUES (?,?,?,?,?,?);`, t.DeviceID, t.Name, t.Key, t.Streams, t.Access, t.Expires)
+
+	if err != nil && strings.HasPrefix(err.Error(), "pq: duplicate key value violates unique constraint ") {
+		return errors.New("Token with this name already exists")
+	}
+	return err
+}
+
+// ReadTokenByID reads the token with the given ID
+func (userdb *SqlUserDatabase) ReadTokenByID(TokenID int64) (*Token, error) {
+	var token Token
+
+	err := userdb.Get(&token, "SELECT * FROM tokens WHERE tokenid = ? LIMIT 1;", TokenID)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrTokenNotFound
+	}
+
+	return &token, err
+}
+
+// ReadTokenByKey reads the token with the given key. Expired tokens are returned, so that
+// the caller can decide what to do with them.
+func (userdb *SqlUserDatabase) ReadTokenByKey(Key string) (*Token, error) {
+	var token Token
+
+	if Key == "" {
+		return nil, errors.New("Must have non-empty token")
+	}
+
+	err := userdb.Get(&token, "SELECT * FROM tokens WHERE token = ? LIMIT 1;", Key)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrTokenNotFound
+	}
+
+	return &token, err
+}
+
+// ReadTokensByDevice reads all of the tokens of the given device
+func (userdb *SqlUserDatabase) ReadTokensByDevice(DeviceID int64) ([]*Token, error) {
+	var tokens []*Token
+
+	err := userdb.Select(&tokens, "SELECT * FROM tokens WHERE deviceid = ? ORDER BY tokenid ASC;", DeviceID)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrTokenNotFound
+	}
+
+	return tokens, err
+}
+
+// DeleteToken revokes the given token
+func (userdb *SqlUserDatabase) DeleteToken(TokenID int64) error {
+	result, err := userdb.Exec(`DELETE FROM tokens WHERE tokenid = ?;`, TokenID)
+	return getDeleteError(result, err)
+}
@@ -0,0 +1,62 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package users
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenAccess(t *testing.T) {
+	tok := &Token{Access: TokenRead, Streams: "u/d/s1, u/d/s2"}
+	require.Equal(t, []string{"u/d/s1", "u/d/s2"}, tok.StreamPaths())
+	require.True(t, tok.CanAccess("u/d/s2", false))
+	require.False(t, tok.CanAccess("u/d/s2", true))
+	require.False(t, tok.CanAccess("u/d/s3", false))
+
+	tok = &Token{Access: TokenWrite}
+	require.True(t, tok.CanAccess("u/d/s3", true))
+	require.False(t, tok.CanAccess("u/d/s3", false))
+
+	tok.Expires = float64(time.Now().Unix() - 10)
+	require.False(t, tok.CanAccess("u/d/s3", true))
+}
+
+func TestToken(t *testing.T) {
+	for _, testdb := range testdatabases {
+		_, d, _, err := CreateUDS(testdb)
+		require.NoError(t, err)
+
+		require.Error(t, testdb.CreateToken(&Token{DeviceID: d.DeviceID, Name: "tok", Access: "everything"}))
+		require.Error(t, testdb.CreateToken(&Token{DeviceID: d.DeviceID, Access: TokenRead}))
+
+		tok := &Token{DeviceID: d.DeviceID, Name: "tok", Access: TokenRead}
+		require.NoError(t, testdb.CreateToken(tok))
+		require.NotEqual(t, "", tok.Key)
+		require.Error(t, testdb.CreateToken(&Token{DeviceID: d.DeviceID, Name: "tok", Access: TokenRead}))
+		require.NoError(t, testdb.CreateToken(&Token{DeviceID: d.DeviceID, Name: "tok2", Access: TokenWrite, Streams: "a/b/c"}))
+
+		tokens, err := testdb.ReadTokensByDevice(d.DeviceID)
+		require.NoError(t, err)
+		require.Len(t, tokens, 2)
+		require.Equal(t, "a/b/c", tokens[1].Streams)
+
+		tk, err := testdb.ReadTokenByKey(tok.Key)
+		require.NoError(t, err)
+		require.Equal(t, "tok", tk.Name)
+		require.Equal(t, d.DeviceID, tk.DeviceID)
+
+		tk, err = testdb.ReadTokenByID(tk.TokenID)
+		require.NoError(t, err)
+		require.Equal(t, tok.Key, tk.Key)
+
+		require.NoError(t, testdb.DeleteToken(tk.TokenID))
+		require.Error(t, testdb.DeleteToken(tk.TokenID))
+		_, err = testdb.ReadTokenByKey(tok.Key)
+		require.Equal(t, ErrTokenNotFound, err)
+	}
+}
@@ -0,0 +1,188 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+
+This file contains two-factor authentication for users, using time-based one time passwords (RFC 6238).
+A user enrolls by adding the secret to an authenticator app, and confirming with a code from the app.
+On confirmation, the user is given recovery codes, each of which can be used once instead of a code.
+**/
+package users
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// The number of seconds that each code is valid, and the number of digits in a code
+	totpPeriod = 30
+	totpDigits = 6
+
+	// The number of recovery codes that a user gets when enrolling
+	recoveryCodeCount = 10
+)
+
+var (
+	ErrTOTPRequired    = errors.New("A two-factor authentication code is required to log in as this user")
+	ErrInvalidTOTP     = errors.New("The two-factor authentication code is invalid")
+	ErrTOTPNotEnrolled = errors.New("Two-factor authentication enrollment was not started")
+	ErrTOTPEnabled     = errors.New("Two-factor authentication is already enabled")
+
+	totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+)
+
+// TOTPCode returns the code of the given base32 secret at the given time
+func TOTPCode(secret string, t time.Time) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", err
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(t.Unix()/totpPeriod))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation, as described in RFC 4226
+	offset := sum[len(sum)-1] & 0xf
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%uint32(math.Pow10(totpDigits))), nil
+}
+
+// TOTPURI returns the otpauth:// uri of the secret, which authenticator apps read from a QR code
+func TOTPURI(issuer, account, secret string) string {
+	v := url.Values{"secret": {secret}, "issuer": {issuer}}
+	return "otpauth://totp/" + url.PathEscape(issuer+":"+account) + "?" + v.Encode()
+}
+
+// randomBase32 returns the given number of random bytes, encoded in base32
+func randomBase32(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(b), nil
+}
+
+// hashRecoveryCode returns the hash of a recovery code, which is what is stored in the database.
+// Recovery codes are long random strings, so a plain hash is sufficient.
+func hashRecoveryCode(code string) string {
+	h := sha256.Sum256([]byte(strings.ToLower(strings.Replace(code, "-", "", -1))))
+	return hex.EncodeToString(h[:])
+}
+
+// ValidateTOTP returns whether the code is valid for the user's secret. The codes of the neighboring
+// periods are also accepted, to allow for clock drift.
+func (u *User) ValidateTOTP(code string) bool {
+	if u.TOTPSecret == "" || len(code) != totpDigits {
+		return false
+	}
+	now := time.Now()
+	for i := -1; i <= 1; i++ {
+		c, err := TOTPCode(u.TOTPSecret, now.Add(time.Duration(i*totpPeriod)*time.Second))
+		if err == nil && subtle.ConstantTimeCompare([]byte(c), []byte(code)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// UseRecoveryCode checks if the given code is one of the user's unused recovery codes. If it is, the code
+// is removed, and the user needs to be saved.
+func (u *User) UseRecoveryCode(code string) bool {
+	if u.RecoveryCodes == "" {
+		return false
+	}
+	h := hashRecoveryCode(code)
+	codes := strings.Split(u.RecoveryCodes, ",")
+	for i := range codes {
+		if subtle.ConstantTimeCompare([]byte(codes[i]), []byte(h)) == 1 {
+			u.RecoveryCodes = strings.Join(append(codes[:i], codes[i+1:]...), ",")
+			return true
+		}
+	}
+	return false
+}
+
+// CheckSecondFactor checks the second factor of a login, which is either a code or a recovery code.
+// Users who did not enable two-factor authentication don't need a code. If a recovery code was used,
+// true is returned, and the user needs to be saved.
+func (u *User) CheckSecondFactor(code string) (bool, error) {
+	if !u.TOTPEnabled {
+		return false, nil
+	}
+	if code == "" {
+		return false, ErrTOTPRequired
+	}
+	if u.ValidateTOTP(code) {
+		return false, nil
+	}
+	if u.UseRecoveryCode(code) {
+		return true, nil
+	}
+	return false, ErrInvalidTOTP
+}
+
+// EnrollTOTP starts two-factor enrollment, generating a new secret. Two-factor authentication is
+// only enabled once the user confirms that they set up the secret with ConfirmTOTP.
+func (u *User) EnrollTOTP() (string, error) {
+	if u.TOTPEnabled {
+		return "", ErrTOTPEnabled
+	}
+	secret, err := randomBase32(20)
+	if err != nil {
+		return "", err
+	}
+	u.TOTPSecret = secret
+	u.RecoveryCodes = ""
+	return secret, nil
+}
+
+// ConfirmTOTP enables two-factor authentication if the code is valid for the enrolled secret.
+// The recovery codes are returned. They are only stored as hashes, so they can't be read again.
+func (u *User) ConfirmTOTP(code string) ([]string, error) {
+	if u.TOTPEnabled {
+		return nil, ErrTOTPEnabled
+	}
+	if u.TOTPSecret == "" {
+		return nil, ErrTOTPNotEnrolled
+	}
+	if !u.ValidateTOTP(code) {
+		return nil, ErrInvalidTOTP
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		c, err := randomBase32(10)
+		if err != nil {
+			return nil, err
+		}
+		c = strings.ToLower(c)
+		codes[i] = c[:8] + "-" + c[8:]
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+	u.TOTPEnabled = true
+	u.RecoveryCodes = strings.Join(hashes, ",")
+	return codes, nil
+}
+
+// ResetTOTP removes the user's two-factor authentication
+func (u *User) ResetTOTP() {
+	u.TOTPSecret = ""
+	u.TOTPEnabled = false
+	u.RecoveryCodes = ""
+}
@@ -0,0 +1,67 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package users
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTOTPCode(t *testing.T) {
+	// The test vectors of RFC 6238, truncated to 6 digits
+	secret := totpEncoding.EncodeToString([]byte("12345678901234567890"))
+	c, err := TOTPCode(secret, time.Unix(59, 0))
+	require.NoError(t, err)
+	require.Equal(t, "287082", c)
+	c, err = TOTPCode(secret, time.Unix(1111111109, 0))
+	require.NoError(t, err)
+	require.Equal(t, "081804", c)
+}
+
+func TestTOTPEnrollment(t *testing.T) {
+	u := &User{}
+	recovered, err := u.CheckSecondFactor("")
+	require.NoError(t, err)
+	require.False(t, recovered)
+
+	_, err = u.ConfirmTOTP("123456")
+	require.Equal(t, ErrTOTPNotEnrolled, err)
+
+	secret, err := u.EnrollTOTP()
+	require.NoError(t, err)
+	require.False(t, u.TOTPEnabled)
+	_, err = u.ConfirmTOTP("abcdef")
+	require.Equal(t, ErrInvalidTOTP, err)
+
+	code, err := TOTPCode(secret, time.Now())
+	require.NoError(t, err)
+	codes, err := u.ConfirmTOTP(code)
+	require.NoError(t, err)
+	require.Len(t, codes, recoveryCodeCount)
+	require.True(t, u.TOTPEnabled)
+	_, err = u.EnrollTOTP()
+	require.Equal(t, ErrTOTPEnabled, err)
+
+	_, err = u.CheckSecondFactor("")
+	require.Equal(t, ErrTOTPRequired, err)
+	_, err = u.CheckSecondFactor("abcdef")
+	require.Equal(t, ErrInvalidTOTP, err)
+	recovered, err = u.CheckSecondFactor(code)
+	require.NoError(t, err)
+	require.False(t, recovered)
+
+	// Each recovery code works only once
+	recovered, err = u.CheckSecondFactor(codes[3])
+	require.NoError(t, err)
+	require.True(t, recovered)
+	_, err = u.CheckSecondFactor(codes[3])
+	require.Equal(t, ErrInvalidTOTP, err)
+
+	u.ResetTOTP()
+	_, err = u.CheckSecondFactor("")
+	require.NoError(t, err)
+}
@@ -0,0 +1,56 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package users
+
+import "errors"
+
+// ErrNoTransactions is returned when beginning a transaction on a user database which can't run one
+var ErrNoTransactions = errors.New("The user database does not support transactions")
+
+// Transaction is a user database which makes its changes within an sql transaction. The changes are
+// only seen by the rest of the database once Commit is called, and are undone by Rollback.
+type Transaction struct {
+	*SqlUserDatabase
+
+	cache *CacheMiddleware // The cache of the database that the transaction was started from, if any
+}
+
+// Begin starts a transaction of the changes to the user database. The transaction reads straight from
+// the sql database, so that it sees its own changes rather than what is in the cache.
+func Begin(userdb UserDatabase) (*Transaction, error) {
+	var cache *CacheMiddleware
+	if c, ok := userdb.(*CacheMiddleware); ok {
+		cache = c
+		userdb = c.UserDatabase
+	}
+	sqldb, ok := userdb.(*SqlUserDatabase)
+	if !ok {
+		return nil, ErrNoTransactions
+	}
+
+	tx, err := sqldb.DB.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	t := &Transaction{SqlUserDatabase: &SqlUserDatabase{dbtype: sqldb.dbtype}, cache: cache}
+	t.InitSqlxMixin(sqldb.DB)
+	t.Tx = tx
+	return t, nil
+}
+
+// Commit makes the changes of the transaction. The cache held the users, devices and streams
+// from before the transaction, so it is purged.
+func (t *Transaction) Commit() error {
+	err := t.Tx.Commit()
+	if t.cache != nil {
+		t.cache.purge()
+	}
+	return err
+}
+
+// Rollback undoes all of the changes made in the transaction
+func (t *Transaction) Rollback() error {
+	return t.Tx.Rollback()
+}
@@ -41,6 +41,14 @@ type User struct {
 	PasswordSalt       string `json:"password_salt" permissions:"-"`   // The password salt to be attached to the end of the password
 	PasswordHashScheme string `json:"password_scheme" permissions:"-"` // A string representing the hashing scheme used
 
+	// Two-factor authentication (see totp.go). The secret and the hashes of the recovery codes are never returned.
+	TOTPSecret    string `json:"-" permissions:"-"`
+	TOTPEnabled   bool   `json:"totp_enabled" permissions:"-"`
+	RecoveryCodes string `json:"-" permissions:"-"`
+
+	// Whether the user showed that they own their email address, by following a link sent to it.
+	// It is reset whenever the email address changes.
+	EmailVerified bool `json:"email_verified" permissions:"-"`
 }
 
 // UserMaker is the structure used to create users
@@ -237,32 +245,31 @@ func (userdb *SqlUserDatabase) CreateUser(um *UserMaker) error {
 		// and the meta device. In other databases this is done automatically through triggers (see dbsetup/dbutil/setup.go)
 		var uid int64
 
-		uidr := userdb.DB.QueryRow("SELECT userid FROM users WHERE name=?;", um.Name)
-		err = uidr.Scan(&uid)
+		err = userdb.Get(&uid, "SELECT userid FROM users WHERE name=?;", um.Name)
 		if err != nil {
 			userdb.Exec("DELETE FROM users WHERE name=?;", um.Name)
 			return err
 		}
 
-		tx, err := userdb.DB.Beginx()
-		if err != nil {
-			return err
+		// If the user database is already in a transaction, the devices are inserted within it
+		tx := userdb.Tx
+		if tx == nil {
+			if tx, err = userdb.DB.Beginx(); err != nil {
+				return err
+			}
 		}
 
 		_, err = tx.Exec("INSERT INTO devices (name,userid,apikey, role, description, icon) VALUES ('user',?,?,'user','Holds manually inserted data for the user','material:person');", uid, salt)
-		if err != nil {
-			tx.Rollback()
-			userdb.Exec("DELETE FROM users WHERE name=?;", um.Name)
-			return err
+		if err == nil {
+			_, err = tx.Exec("INSERT INTO devices (name, userid, apikey, description, usereditable, isvisible, icon) VALUES ('meta', ?, '','The meta device holds automatically generated streams', 0, 0,'material:bug_report');", uid)
 		}
-		_, err = tx.Exec("INSERT INTO devices (name, userid, apikey, description, usereditable, isvisible, icon) VALUES ('meta', ?, '','The meta device holds automatically generated streams', 0, 0,'material:bug_report');", uid)
-		if err != nil {
-			tx.Rollback()
-			userdb.Exec("DELETE FROM users WHERE name=?;", um.Name)
-			return err
+		if userdb.Tx == nil {
+			if err != nil {
+				tx.Rollback()
+			} else {
+				err = tx.Commit()
+			}
 		}
-
-		err = tx.Commit()
 		if err != nil {
 			userdb.Exec("DELETE FROM users WHERE name=?;", um.Name)
 			return err
@@ -416,7 +423,11 @@ func (userdb *SqlUserDatabase) UpdateUser(user *User) error {
 					description=?,
 					icon=?,
 					public=?,
-					role=?
+					role=?,
+					totpsecret=?,
+					totpenabled=?,
+					recoverycodes=?,
+					emailverified=?
 					WHERE userid = ?`,
 		user.Name,
 		user.Nickname,
@@ -428,6 +439,10 @@ func (userdb *SqlUserDatabase) UpdateUser(user *User) error {
 		user.Icon,
 		user.Public,
 		user.Role,
+		user.TOTPSecret,
+		user.TOTPEnabled,
+		user.RecoveryCodes,
+		user.EmailVerified,
 		user.UserID)
 
 	return err
@@ -48,6 +48,12 @@ func (db *SqlUserDatabase) Clear() {
 	db.Exec("DELETE FROM Users;")
 	db.Exec("DELETE FROM Devices;")
 	db.Exec("DELETE FROM Streams;")
+	db.Exec("DELETE FROM Grants;")
+	db.Exec("DELETE FROM Tokens;")
+	db.Exec("DELETE FROM Identities;")
+	db.Exec("DELETE FROM GroupMembers;")
+	db.Exec("DELETE FROM UserGroups;")
+	db.Exec("DELETE FROM Webhooks;")
 }
 
 func NewUserDatabase(sqldb *sqlx.DB, cache bool, cache_timeout int64, usersize int64, devsize int64, streamsize int64) UserDatabase {
@@ -36,6 +36,41 @@ type UserDatabase interface {
 	UpdateStream(stream *Stream) error
 	UpdateUser(user *User) error
 
+	// Grants give specific users and devices access to things they could not otherwise access
+	CreateGrant(g *Grant) error
+	ReadGrantByID(GrantID int64) (*Grant, error)
+	ReadGrantsByOwner(UserID int64) ([]*Grant, error)
+	ReadStreamGrants(GranteeUserID, GranteeDeviceID, StreamID int64) ([]*Grant, error)
+	DeleteGrant(GrantID int64) error
+
+	// Tokens are restricted keys which log in as their device
+	CreateToken(t *Token) error
+	ReadTokenByID(TokenID int64) (*Token, error)
+	ReadTokenByKey(Key string) (*Token, error)
+	ReadTokensByDevice(DeviceID int64) ([]*Token, error)
+	DeleteToken(TokenID int64) error
+
+	// Identities link users to their accounts at external identity providers
+	CreateIdentity(i *Identity) error
+	ReadIdentity(Issuer, Subject string) (*Identity, error)
+
+	// Groups are teams of users, backed by a user which owns the group's devices
+	CreateGroup(GroupID, OwnerID int64) error
+	ReadGroupByID(GroupID int64) (*Group, error)
+	ReadGroupMember(GroupID, UserID int64) (*GroupMember, error)
+	ReadGroupMembers(GroupID int64) ([]*GroupMember, error)
+	ReadGroupsByMember(UserID int64) ([]*GroupMember, error)
+	SetGroupMember(m *GroupMember) error
+	DeleteGroupMember(GroupID, UserID int64) error
+	SharesGroup(UserID, OtherUserID int64) (bool, error)
+
+	// Webhooks post the data inserted into streams to external urls
+	CreateWebhook(w *Webhook) error
+	ReadWebhookByID(WebhookID int64) (*Webhook, error)
+	ReadWebhooksByUser(UserID int64) ([]*Webhook, error)
+	ReadStreamWebhooks(StreamID int64) ([]*Webhook, error)
+	DeleteWebhook(WebhookID int64) error
+
 	// Returns the total number of users in the database
 	CountUsers() (int64, error)
 	CountDevices() (int64, error)
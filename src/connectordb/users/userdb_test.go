@@ -10,6 +10,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 //
@@ -64,3 +65,35 @@ func TestGetDeleteError(t *testing.T) {
 
 	assert.Equal(t, nil, getDeleteError(fakeResult, nil))
 }
+
+func TestTransaction(t *testing.T) {
+	for i, testdb := range testdatabases {
+		u, err := CreateTestUser(testdb)
+		require.NoError(t, err, testdatabasesNames[i])
+
+		tx, err := Begin(testdb)
+		require.NoError(t, err)
+		u.Description = "changed"
+		require.NoError(t, tx.UpdateUser(u))
+		name := GetNextName()
+		require.NoError(t, tx.CreateUser(&UserMaker{User: User{Name: name, Email: GetNextEmail(), Password: testPassword, Role: "test"}}))
+
+		//The transaction sees its own changes
+		_, err = tx.ReadUserByName(name)
+		require.NoError(t, err)
+		require.NoError(t, tx.Rollback())
+
+		_, err = testdb.ReadUserByName(name)
+		require.Error(t, err)
+		u2, err := testdb.ReadUserById(u.UserID)
+		require.NoError(t, err)
+		require.Equal(t, "", u2.Description)
+
+		tx, err = Begin(testdb)
+		require.NoError(t, err)
+		require.NoError(t, tx.CreateUser(&UserMaker{User: User{Name: name, Email: GetNextEmail(), Password: testPassword, Role: "test"}}))
+		require.NoError(t, tx.Commit())
+		_, err = testdb.ReadUserByName(name)
+		require.NoError(t, err)
+	}
+}
@@ -0,0 +1,120 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package users
+
+import (
+	"database/sql"
+	"errors"
+	"net/url"
+
+	"github.com/nu7hatch/gouuid"
+)
+
+var (
+	ErrWebhookNotFound = errors.New("The requested webhook was not found.")
+	ErrInvalidWebhook  = errors.New("A webhook must be on exactly one device or stream, and have an http or https url")
+)
+
+// Webhook posts the data inserted into a stream, or into any of the streams of a device, to the given url.
+// Exactly one of DeviceID and StreamID is set.
+type Webhook struct {
+	WebhookID int64 `json:"id" db:"webhookid"`
+	UserID    int64 `json:"-" db:"userid"` // The user which owns the device or stream
+
+	DeviceID int64 `json:"-" db:"deviceid"`
+	StreamID int64 `json:"-" db:"streamid"`
+
+	URL       string `json:"url" db:"url"`
+	Secret    string `json:"secret" db:"secret"`       // The key of the HMAC signature of the posted data
+	Transform string `json:"transform" db:"transform"` // An optional pipescript transform which filters the posted data
+
+	// The path of the device or stream is not stored in the database, but is filled in
+	// when the webhook is returned through the API
+	Target string `json:"target" db:"-"`
+}
+
+// Validate ensures that the webhook has a single target and a valid url
+func (w *Webhook) Validate() error {
+	if (w.DeviceID > 0) == (w.StreamID > 0) || w.UserID <= 0 {
+		return ErrInvalidWebhook
+	}
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrInvalidWebhook
+	}
+	return nil
+}
+
+// The nullable ID columns are read as 0 when unset
+const webhookColumns = `webhookid, userid,
+	COALESCE(deviceid, 0) AS deviceid, COALESCE(streamid, 0) AS streamid,
+	url, secret, transform`
+
+// CreateWebhook adds the given webhook to the database, generating its secret if it was not given
+func (userdb *SqlUserDatabase) CreateWebhook(w *Webhook) error {
+	if err := w.Validate(); err != nil {
+		return err
+	}
+	if w.Secret == "" {
+		secret, _ := uuid.NewV4()
+		w.Secret = secret.String()
+	}
+
+	_, err := userdb.Exec(`INSERT INTO webhooks
+		(	userid,
+			deviceid,
+			streamid,
+			url,
+			secret,
+			transform) VALUES (?,?,?,?,?,?);`, w.UserID, nullID(w.DeviceID), nullID(w.StreamID), w.URL, w.Secret, w.Transform)
+	return err
+}
+
+// ReadWebhookByID reads the webhook with the given ID
+func (userdb *SqlUserDatabase) ReadWebhookByID(WebhookID int64) (*Webhook, error) {
+	var webhook Webhook
+
+	err := userdb.Get(&webhook, "SELECT "+webhookColumns+" FROM webhooks WHERE webhookid = ? LIMIT 1;", WebhookID)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrWebhookNotFound
+	}
+
+	return &webhook, err
+}
+
+// ReadWebhooksByUser reads all of the webhooks on the user's devices and streams
+func (userdb *SqlUserDatabase) ReadWebhooksByUser(UserID int64) ([]*Webhook, error) {
+	var webhooks []*Webhook
+
+	err := userdb.Select(&webhooks, "SELECT "+webhookColumns+" FROM webhooks WHERE userid = ? ORDER BY webhookid ASC;", UserID)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrWebhookNotFound
+	}
+
+	return webhooks, err
+}
+
+// ReadStreamWebhooks reads the webhooks which are given on the stream, either directly or through its device
+func (userdb *SqlUserDatabase) ReadStreamWebhooks(StreamID int64) ([]*Webhook, error) {
+	var webhooks []*Webhook
+
+	err := userdb.Select(&webhooks, `SELECT `+webhookColumns+` FROM webhooks
+		WHERE streamid = ? OR deviceid = (SELECT deviceid FROM streams WHERE streamid = ?)
+		ORDER BY webhookid ASC;`, StreamID, StreamID)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	return webhooks, err
+}
+
+// DeleteWebhook removes the given webhook
+func (userdb *SqlUserDatabase) DeleteWebhook(WebhookID int64) error {
+	result, err := userdb.Exec(`DELETE FROM webhooks WHERE webhookid = ?;`, WebhookID)
+	return getDeleteError(result, err)
+}
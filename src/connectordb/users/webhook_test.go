@@ -0,0 +1,50 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package users
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhook(t *testing.T) {
+	for _, testdb := range testdatabases {
+		u, d, s, err := CreateUDS(testdb)
+		require.NoError(t, err)
+
+		require.Error(t, testdb.CreateWebhook(&Webhook{UserID: u.UserID, StreamID: s.StreamID, URL: "ftp://localhost"}))
+		require.Error(t, testdb.CreateWebhook(&Webhook{UserID: u.UserID, URL: "http://localhost"}))
+		require.Error(t, testdb.CreateWebhook(&Webhook{UserID: u.UserID, DeviceID: d.DeviceID, StreamID: s.StreamID, URL: "http://localhost"}))
+
+		require.NoError(t, testdb.CreateWebhook(&Webhook{UserID: u.UserID, StreamID: s.StreamID, URL: "http://localhost/stream"}))
+		require.NoError(t, testdb.CreateWebhook(&Webhook{UserID: u.UserID, DeviceID: d.DeviceID, URL: "http://localhost/device", Secret: "mysecret"}))
+
+		webhooks, err := testdb.ReadWebhooksByUser(u.UserID)
+		require.NoError(t, err)
+		require.Len(t, webhooks, 2)
+		require.Equal(t, s.StreamID, webhooks[0].StreamID)
+		require.Equal(t, int64(0), webhooks[0].DeviceID)
+		require.NotEmpty(t, webhooks[0].Secret)
+		require.Equal(t, "mysecret", webhooks[1].Secret)
+
+		// The webhooks of the stream include the webhooks of its device
+		webhooks, err = testdb.ReadStreamWebhooks(s.StreamID)
+		require.NoError(t, err)
+		require.Len(t, webhooks, 2)
+
+		w, err := testdb.ReadWebhookByID(webhooks[1].WebhookID)
+		require.NoError(t, err)
+		require.Equal(t, "http://localhost/device", w.URL)
+
+		require.NoError(t, testdb.DeleteWebhook(w.WebhookID))
+		require.Equal(t, ErrNothingToDelete, testdb.DeleteWebhook(w.WebhookID))
+		_, err = testdb.ReadWebhookByID(w.WebhookID)
+		require.Equal(t, ErrWebhookNotFound, err)
+		webhooks, err = testdb.ReadStreamWebhooks(s.StreamID)
+		require.NoError(t, err)
+		require.Len(t, webhooks, 1)
+	}
+}
@@ -0,0 +1,60 @@
+package connectordb
+
+import (
+	"config"
+	"connectordb/users"
+	"connectordb/webhook"
+
+	"github.com/connectordb/pipescript"
+)
+
+// StartWebhooks starts delivering the inserted data to the webhooks of the streams. It is only called by the server,
+// so that the other tools which open the database don't deliver the webhooks a second time.
+func (db *Database) StartWebhooks(c *config.Webhooks) (err error) {
+	db.Webhooks, err = webhook.Start(c, db, db.Messenger)
+	return err
+}
+
+// CreateWebhookByID adds a webhook on the device or stream given by its ID.
+// The owner of the webhook is set to the user that owns the device or stream.
+func (db *Database) CreateWebhookByID(w *users.Webhook) error {
+	if w.Transform != "" {
+		if _, err := pipescript.Parse(w.Transform); err != nil {
+			return err
+		}
+	}
+	deviceID := w.DeviceID
+	if w.StreamID > 0 {
+		s, err := db.ReadStreamByID(w.StreamID)
+		if err != nil {
+			return err
+		}
+		deviceID = s.DeviceID
+	}
+	dev, err := db.ReadDeviceByID(deviceID)
+	if err != nil {
+		return err
+	}
+	w.UserID = dev.UserID
+	return db.Userdb.CreateWebhook(w)
+}
+
+// ReadWebhookByID reads the given webhook
+func (db *Database) ReadWebhookByID(webhookID int64) (*users.Webhook, error) {
+	return db.Userdb.ReadWebhookByID(webhookID)
+}
+
+// ReadAllWebhooksByUserID reads all of the webhooks on the user's devices and streams
+func (db *Database) ReadAllWebhooksByUserID(userID int64) ([]*users.Webhook, error) {
+	return db.Userdb.ReadWebhooksByUser(userID)
+}
+
+// ReadStreamWebhooksByID reads the webhooks which post the data inserted into the stream
+func (db *Database) ReadStreamWebhooksByID(streamID int64) ([]*users.Webhook, error) {
+	return db.Userdb.ReadStreamWebhooks(streamID)
+}
+
+// DeleteWebhookByID removes the given webhook
+func (db *Database) DeleteWebhookByID(webhookID int64) error {
+	return db.Userdb.DeleteWebhook(webhookID)
+}
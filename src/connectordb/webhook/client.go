@@ -0,0 +1,166 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+
+Package webhook delivers the data inserted into streams to the webhooks that users registered on them.
+**/
+package webhook
+
+import (
+	"bytes"
+	"connectordb/datastream"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"time"
+)
+
+// SignatureHeader holds the hex encoded HMAC-SHA256 of the posted body, keyed with the webhook's secret,
+// so that the receiver can check that the data comes from ConnectorDB
+const SignatureHeader = "X-ConnectorDB-Signature"
+
+// ErrForbiddenAddress is returned when the url of a webhook is on a loopback, link-local or private address
+var ErrForbiddenAddress = errors.New("Webhooks can't be delivered to loopback, link-local or private addresses")
+
+// privateNetworks are the addresses which webhooks are not delivered to, since they would let users reach the
+// services of the server's own network, such as the database or the metadata service of a cloud provider
+var privateNetworks = parseNetworks("0.0.0.0/8", "10.0.0.0/8", "100.64.0.0/10", "127.0.0.0/8", "169.254.0.0/16",
+	"172.16.0.0/12", "192.0.0.0/24", "192.168.0.0/16", "198.18.0.0/15", "224.0.0.0/4", "240.0.0.0/4",
+	"::/128", "::1/128", "fc00::/7", "fe80::/10", "ff00::/8")
+
+func parseNetworks(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, len(cidrs))
+	for i, c := range cidrs {
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			panic(err)
+		}
+		nets[i] = n
+	}
+	return nets
+}
+
+// IsPrivate returns whether the address is a loopback, link-local, private or otherwise non-public address
+func IsPrivate(ip net.IP) bool {
+	for _, n := range privateNetworks {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewHTTPClient returns the http client which posts to webhooks. Unless allowPrivate is set, it refuses to connect
+// to private addresses. The address is checked as the connection is made, so a hostname which resolves to another
+// address than when the webhook was created, such as through DNS rebinding, can't get around the check.
+// Proxies from the environment are not used, since the proxy would make the connection in place of the client.
+func NewHTTPClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second}
+	dial := dialer.DialContext
+	if !allowPrivate {
+		dial = publicDialer(dialer)
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         dial,
+			TLSHandshakeTimeout: timeout,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
+}
+
+// publicDialer returns a dial function which only connects to public addresses. The host is resolved here,
+// and the connection is made to the address that was checked, rather than resolving the host again.
+func publicDialer(dialer *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		err = ErrForbiddenAddress
+		for _, a := range addrs {
+			if IsPrivate(a.IP) {
+				continue
+			}
+			var conn net.Conn
+			if conn, err = dialer.DialContext(ctx, network, net.JoinHostPort(a.IP.String(), port)); err == nil {
+				return conn, nil
+			}
+		}
+		return nil, err
+	}
+}
+
+// Payload is the json body posted to a webhook
+type Payload struct {
+	Webhook int64                     `json:"webhook"` // The id of the webhook
+	Stream  string                    `json:"stream"`  // The path of the stream which was inserted into
+	Data    datastream.DatapointArray `json:"data"`    // The inserted datapoints, after the webhook's transform
+}
+
+// Sign returns the signature of the body, in the format of the SignatureHeader
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Client posts payloads to webhooks. A failed post is retried up to Retries times, waiting Backoff
+// before the first retry, and doubling the wait with each following retry.
+type Client struct {
+	HTTP    *http.Client
+	Retries int
+	Backoff time.Duration
+
+	// Closing Done stops the retries of the posts that are waiting for their next try
+	Done <-chan struct{}
+}
+
+// Post sends the signed body to the url, retrying until it succeeds or runs out of retries
+func (c *Client) Post(url, secret string, body []byte) (err error) {
+	wait := c.Backoff
+	for i := 0; ; i++ {
+		if err = c.post(url, secret, body); err == nil || i >= c.Retries {
+			return err
+		}
+		select {
+		case <-time.After(wait):
+		case <-c.Done:
+			return err
+		}
+		wait *= 2
+	}
+}
+
+func (c *Client) post(url, secret string, body []byte) error {
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, Sign(secret, body))
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	// The body is read so that the connection can be reused
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("The webhook responded with %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package webhook
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient(t *testing.T) {
+	var calls int32
+	var failures int32
+	var signature string
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if atomic.AddInt32(&failures, -1) >= 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		signature = r.Header.Get(SignatureHeader)
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	c := &Client{HTTP: &http.Client{}, Retries: 2, Backoff: time.Millisecond}
+	payload := []byte(`{"webhook":1}`)
+
+	// The post succeeds once the server stops failing
+	failures = 2
+	require.NoError(t, c.Post(server.URL, "mysecret", payload))
+	require.Equal(t, int32(3), calls)
+	require.Equal(t, payload, body)
+	require.Equal(t, Sign("mysecret", payload), signature)
+	require.NotEqual(t, Sign("othersecret", payload), signature)
+
+	// The post fails once it runs out of retries
+	calls, failures = 0, 3
+	require.Error(t, c.Post(server.URL, "mysecret", payload))
+	require.Equal(t, int32(3), calls)
+
+	// Closing Done stops the retries
+	done := make(chan struct{})
+	close(done)
+	c = &Client{HTTP: &http.Client{}, Retries: 5, Backoff: time.Hour, Done: done}
+	calls, failures = 0, 10
+	require.Error(t, c.Post(server.URL, "mysecret", payload))
+	require.Equal(t, int32(1), calls)
+}
+
+func TestIsPrivate(t *testing.T) {
+	for _, addr := range []string{"127.0.0.1", "127.1.2.3", "10.0.0.1", "172.16.5.4", "172.31.255.255", "192.168.1.1",
+		"169.254.169.254", "100.64.0.1", "0.0.0.0", "224.0.0.1", "::1", "::", "fe80::1", "fd00::1", "::ffff:127.0.0.1", "::ffff:10.0.0.1"} {
+		require.True(t, IsPrivate(net.ParseIP(addr)), addr)
+	}
+	for _, addr := range []string{"8.8.8.8", "172.32.0.1", "192.169.0.1", "100.128.0.1", "2001:4860:4860::8888", "::ffff:8.8.8.8"} {
+		require.False(t, IsPrivate(net.ParseIP(addr)), addr)
+	}
+}
+
+func TestPrivateAddress(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+	}))
+	defer server.Close()
+	_, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	require.NoError(t, err)
+
+	// The address is checked when connecting, whether the url holds an address or a hostname
+	c := &Client{HTTP: NewHTTPClient(time.Second, false)}
+	err = c.Post(server.URL, "mysecret", []byte("{}"))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), ErrForbiddenAddress.Error())
+	err = c.Post("http://localhost:"+port, "mysecret", []byte("{}"))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), ErrForbiddenAddress.Error())
+	require.Equal(t, int32(0), calls)
+
+	c = &Client{HTTP: NewHTTPClient(time.Second, true)}
+	require.NoError(t, c.Post(server.URL, "mysecret", []byte("{}")))
+	require.Equal(t, int32(1), calls)
+}
@@ -0,0 +1,299 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package webhook
+
+import (
+	"config"
+	"connectordb/datastream"
+	"connectordb/messenger"
+	"connectordb/operator"
+	"connectordb/query"
+	"connectordb/users"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/connectordb/pipescript"
+	"github.com/nats-io/nats"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// DeadLetterStream is the path of the stream within the user which holds the deliveries that failed
+const DeadLetterStream = "meta/webhooks"
+
+// ErrQueueFull is written to the dead letter stream when a webhook falls too far behind
+var ErrQueueFull = errors.New("Too many deliveries are queued up for the webhook")
+
+const (
+	// The webhooks of each stream are cached for this long, so that inserts don't each query the database.
+	// Created and deleted webhooks take effect once the cache expires.
+	webhookCacheTime = 10 * time.Second
+
+	// A webhook which has nothing to deliver for this long has its worker stopped. The state of its transform is reset.
+	workerIdleTime = 10 * time.Minute
+)
+
+type cachedWebhooks struct {
+	webhooks []*users.Webhook
+	expires  time.Time
+}
+
+// workerKey identifies the worker of a webhook and one of its streams. The webhook of a device gets a worker
+// for each stream that is inserted into, so that the state of its transform isn't mixed between streams.
+type workerKey struct {
+	webhookID int64
+	stream    string
+}
+
+// worker delivers the data inserted into a single stream to a webhook in the order it was inserted
+type worker struct {
+	key     workerKey
+	webhook *users.Webhook
+	tf      *pipescript.Script // Each worker gets its own transform, since transforms keep state between datapoints
+	c       chan messenger.Message
+}
+
+// failure is a delivery which failed, and is waiting to be written to the dead letter stream
+type failure struct {
+	webhook *users.Webhook
+	stream  string
+	data    datastream.DatapointArray
+	err     error
+}
+
+// Dispatcher subscribes to all inserts, and delivers the inserted data to the webhooks of the streams
+type Dispatcher struct {
+	sync.Mutex
+
+	o         operator.PathOperator
+	client    *Client
+	queueSize int
+
+	c           chan messenger.Message
+	sub         *nats.Subscription
+	cache       map[int64]cachedWebhooks
+	workers     map[workerKey]*worker
+	deadLetters chan failure
+	done        chan struct{}
+}
+
+// Start subscribes to the inserts of all streams, and starts delivering them to their webhooks.
+// The webhooks are read through the given administrative operator. It returns nil if webhooks are disabled.
+func Start(c *config.Webhooks, o operator.PathOperator, m *messenger.Messenger) (*Dispatcher, error) {
+	if !c.Enabled {
+		return nil, nil
+	}
+	done := make(chan struct{})
+	d := &Dispatcher{
+		o: o,
+		client: &Client{
+			HTTP:    NewHTTPClient(time.Duration(c.Timeout)*time.Second, c.AllowPrivate),
+			Retries: c.Retries,
+			Backoff: time.Duration(c.Backoff) * time.Second,
+			Done:    done,
+		},
+		queueSize:   c.QueueSize,
+		c:           make(chan messenger.Message, 100),
+		cache:       make(map[int64]cachedWebhooks),
+		workers:     make(map[workerKey]*worker),
+		deadLetters: make(chan failure, c.QueueSize),
+		done:        done,
+	}
+	var err error
+	if d.sub, err = m.Subscribe(">", d.c); err != nil {
+		return nil, err
+	}
+	go d.run()
+	go d.runDeadLetters()
+	return d, nil
+}
+
+func (d *Dispatcher) run() {
+	for msg := range d.c {
+		if err := d.dispatch(msg); err != nil {
+			log.WithField("stream", msg.Stream).Warn("Could not dispatch webhooks: ", err)
+		}
+	}
+}
+
+// webhooks returns the webhooks of the given stream, using the cached webhooks if they are recent
+func (d *Dispatcher) webhooks(streamID int64) ([]*users.Webhook, error) {
+	if c, ok := d.cache[streamID]; ok && time.Now().Before(c.expires) {
+		return c.webhooks, nil
+	}
+	webhooks, err := d.o.ReadStreamWebhooksByID(streamID)
+	if err != nil {
+		return nil, err
+	}
+	d.cache[streamID] = cachedWebhooks{webhooks, time.Now().Add(webhookCacheTime)}
+	return webhooks, nil
+}
+
+// dispatch queues up the message for each of the webhooks of its stream
+func (d *Dispatcher) dispatch(msg messenger.Message) error {
+	parts := strings.Split(msg.Stream, "/")
+	if len(parts) != 3 || len(msg.Data) == 0 {
+		// Only the data inserted into the streams themselves is delivered, not their downlinks
+		return nil
+	}
+	if parts[1]+"/"+parts[2] == DeadLetterStream {
+		// A webhook on the dead letter stream must not be able to fill it with its own failures
+		return nil
+	}
+	s, err := d.o.ReadStream(msg.Stream)
+	if err != nil {
+		return err
+	}
+	webhooks, err := d.webhooks(s.StreamID)
+	if err != nil {
+		return err
+	}
+
+	d.Lock()
+	defer d.Unlock()
+	for _, wh := range webhooks {
+		key := workerKey{wh.WebhookID, msg.Stream}
+		w, ok := d.workers[key]
+		if !ok {
+			if w, err = d.startWorker(key, wh); err != nil {
+				d.queueDeadLetter(wh, msg.Stream, msg.Data, err)
+				continue
+			}
+		}
+		select {
+		case w.c <- msg:
+		default:
+			d.queueDeadLetter(wh, msg.Stream, msg.Data, ErrQueueFull)
+		}
+	}
+	return nil
+}
+
+// startWorker starts delivering the data of the key's stream to the given webhook. The dispatcher must be locked.
+func (d *Dispatcher) startWorker(key workerKey, wh *users.Webhook) (*worker, error) {
+	w := &worker{key: key, webhook: wh, c: make(chan messenger.Message, d.queueSize)}
+	if wh.Transform != "" {
+		var err error
+		if w.tf, err = pipescript.Parse(wh.Transform); err != nil {
+			return nil, err
+		}
+	}
+	d.workers[key] = w
+	go d.runWorker(w)
+	return w, nil
+}
+
+func (d *Dispatcher) runWorker(w *worker) {
+	for {
+		select {
+		case msg := <-w.c:
+			d.deliver(w, msg)
+		case <-time.After(workerIdleTime):
+			d.Lock()
+			if len(w.c) == 0 {
+				delete(d.workers, w.key)
+				d.Unlock()
+				return
+			}
+			d.Unlock()
+		case <-d.done:
+			return
+		}
+	}
+}
+
+// deliver posts the message's data to the webhook, writing it to the dead letter stream if it fails
+func (d *Dispatcher) deliver(w *worker, msg messenger.Message) {
+	data := msg.Data
+	if w.tf != nil {
+		result, err := query.TransformArray(w.tf, &data)
+		if err != nil {
+			d.deadLetter(w.webhook, msg.Stream, msg.Data, err)
+			return
+		}
+		if result.Length() == 0 {
+			return
+		}
+		data = *result
+	}
+	body, err := json.Marshal(Payload{w.webhook.WebhookID, msg.Stream, data})
+	if err == nil {
+		err = d.client.Post(w.webhook.URL, w.webhook.Secret, body)
+	}
+	select {
+	case <-d.done:
+		// The retries were stopped because the dispatcher is closing
+		return
+	default:
+	}
+	if err != nil {
+		d.deadLetter(w.webhook, msg.Stream, data, err)
+	}
+}
+
+// queueDeadLetter queues up the failed delivery to be written to the dead letter stream. The dispatcher doesn't
+// wait for the database, so when too many failures are waiting to be written, the failure is only logged.
+func (d *Dispatcher) queueDeadLetter(wh *users.Webhook, stream string, data datastream.DatapointArray, err error) {
+	select {
+	case d.deadLetters <- failure{wh, stream, data, err}:
+	default:
+		log.WithFields(log.Fields{"webhook": wh.WebhookID, "stream": stream}).Error("Dropped a failed webhook delivery, since too many are waiting to be written: ", err)
+	}
+}
+
+// runDeadLetters writes the queued up failures to the dead letter streams one at a time
+func (d *Dispatcher) runDeadLetters() {
+	for {
+		select {
+		case f := <-d.deadLetters:
+			d.deadLetter(f.webhook, f.stream, f.data, f.err)
+		case <-d.done:
+			return
+		}
+	}
+}
+
+// deadLetter writes the failed delivery to the dead letter stream of the user which owns the stream,
+// creating the stream if it does not exist yet
+func (d *Dispatcher) deadLetter(wh *users.Webhook, stream string, data datastream.DatapointArray, failure error) {
+	logger := log.WithFields(log.Fields{"webhook": wh.WebhookID, "stream": stream})
+	logger.Warn("Webhook delivery failed: ", failure)
+
+	streampath := strings.Split(stream, "/")[0] + "/" + DeadLetterStream
+	dpa := datastream.DatapointArray{datastream.Datapoint{
+		Timestamp: float64(time.Now().UnixNano()) * 1e-9,
+		Data: map[string]interface{}{
+			"webhook": wh.WebhookID,
+			"url":     wh.URL,
+			"stream":  stream,
+			"data":    data,
+			"error":   failure.Error(),
+		},
+	}}
+	if err := d.o.InsertStream(streampath, dpa, true); err == nil {
+		return
+	}
+	err := d.o.CreateStream(streampath, &users.StreamMaker{Stream: users.Stream{
+		Description: "The data which could not be delivered to the webhooks of this user's streams",
+		Schema:      `{"type": "object", "properties": {"webhook": {"type": "integer"},"stream": {"type": "string"},"error": {"type": "string"}},"required": ["webhook","stream","error"]}`,
+		Icon:        "material:error",
+	}})
+	if err == nil {
+		err = d.o.InsertStream(streampath, dpa, true)
+	}
+	if err != nil {
+		logger.Error("Could not write the failed delivery: ", err)
+	}
+}
+
+// Close stops delivering the webhooks. The deliveries which are still queued up are dropped.
+func (d *Dispatcher) Close() {
+	d.sub.Unsubscribe()
+	close(d.done)
+	close(d.c)
+}
@@ -0,0 +1,47 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package webhook
+
+import (
+	"connectordb/datastream"
+	"connectordb/users"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueueDeadLetter(t *testing.T) {
+	d := &Dispatcher{deadLetters: make(chan failure, 2)}
+	wh := &users.Webhook{WebhookID: 1}
+	dpa := datastream.DatapointArray{datastream.Datapoint{Timestamp: 1, Data: 1}}
+
+	// Failures beyond the size of the queue are dropped rather than waited on
+	for i := 0; i < 5; i++ {
+		d.queueDeadLetter(wh, "u/d/s", dpa, ErrQueueFull)
+	}
+	require.Len(t, d.deadLetters, 2)
+	f := <-d.deadLetters
+	require.Equal(t, "u/d/s", f.stream)
+	require.Equal(t, ErrQueueFull, f.err)
+}
+
+func TestStartWorker(t *testing.T) {
+	d := &Dispatcher{queueSize: 10, workers: make(map[workerKey]*worker), done: make(chan struct{})}
+	defer close(d.done)
+	wh := &users.Webhook{WebhookID: 1, Transform: "$ > 1"}
+
+	// Each stream of a device's webhook gets its own worker, with its own transform
+	w1, err := d.startWorker(workerKey{1, "u/d/s1"}, wh)
+	require.NoError(t, err)
+	w2, err := d.startWorker(workerKey{1, "u/d/s2"}, wh)
+	require.NoError(t, err)
+	require.Len(t, d.workers, 2)
+	require.True(t, w1.tf != w2.tf)
+	require.Equal(t, w2, d.workers[workerKey{1, "u/d/s2"}])
+
+	_, err = d.startWorker(workerKey{2, "u/d/s1"}, &users.Webhook{WebhookID: 2, Transform: "$ >"})
+	require.Error(t, err)
+	require.Len(t, d.workers, 2)
+}
@@ -0,0 +1,76 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package connectordb
+
+import (
+	"config"
+	"connectordb/datastream"
+	"connectordb/users"
+	"connectordb/webhook"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhooks(t *testing.T) {
+	Tdb.Clear()
+	db := Tdb
+
+	require.NoError(t, db.CreateUser(&users.UserMaker{User: users.User{Name: "tst", Email: "email@email", Password: "mypass", Role: "user", Public: true}}))
+	require.NoError(t, db.CreateDevice("tst/tst", &users.DeviceMaker{}))
+	require.NoError(t, db.CreateStream("tst/tst/tst", &users.StreamMaker{Stream: users.Stream{Schema: `{"type":"integer"}`}}))
+
+	posts := make(chan webhook.Payload, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.Header.Get(webhook.SignatureHeader) != webhook.Sign("mysecret", body) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var p webhook.Payload
+		json.Unmarshal(body, &p)
+		posts <- p
+	}))
+	defer server.Close()
+
+	require.NoError(t, db.StartWebhooks(&config.Webhooks{Enabled: true, Retries: 1, Timeout: 1, QueueSize: 10}))
+	defer func() {
+		db.Webhooks.Close()
+		db.Webhooks = nil
+	}()
+
+	// The transform filters the posted data, and the webhook with the wrong secret fails
+	require.NoError(t, db.CreateWebhook("tst/tst/tst", &users.Webhook{URL: server.URL, Secret: "mysecret", Transform: "if $ > 5"}))
+	require.NoError(t, db.CreateWebhook("tst/tst", &users.Webhook{URL: server.URL, Secret: "wrongsecret"}))
+	db.Messenger.Flush()
+
+	require.NoError(t, db.InsertStream("tst/tst/tst", datastream.DatapointArray{
+		datastream.Datapoint{Timestamp: 1, Data: 1},
+		datastream.Datapoint{Timestamp: 2, Data: 10},
+	}, false))
+
+	select {
+	case p := <-posts:
+		require.Equal(t, "tst/tst/tst", p.Stream)
+		require.Len(t, p.Data, 1)
+		require.Equal(t, float64(10), p.Data[0].Data)
+	case <-time.After(5 * time.Second):
+		t.Fatal("The webhook was not posted")
+	}
+
+	// The failed delivery ends up in the dead letter stream
+	for i := 0; i < 50; i++ {
+		if l, err := db.LengthStream("tst/" + webhook.DeadLetterStream); err == nil && l > 0 {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatal("The failed delivery was not written to the dead letter stream")
+}
@@ -1,8 +1,6 @@
 package dbutil
 
 import (
-	"errors"
-
 	log "github.com/Sirupsen/logrus"
 	"github.com/jmoiron/sqlx"
 )
@@ -25,8 +23,12 @@ func OpenDatabase(dbtype, uri string) (*sqlx.DB, error) {
 	if err != nil {
 		return nil, err
 	}
-	if version != "20160820" {
-		return nil, errors.New("The existing database is incompatible with this version of ConnectorDB")
+	if version != DBVersion {
+		// Databases created by older versions of ConnectorDB are upgraded to the current schema
+		if err = UpgradeDatabase(db, dbtype, version); err != nil {
+			db.Close()
+			return nil, err
+		}
 	}
 	return db, nil
 }
@@ -0,0 +1,86 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package dbutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+// The parts of the schema at version 20160820 which the upgrade changes
+const schema20160820 = `
+CREATE TABLE connectordbmeta (
+  key VARCHAR UNIQUE NOT NULL,
+  value VARCHAR NOT NULL);
+
+CREATE TABLE users (
+	userid INTEGER PRIMARY KEY AUTOINCREMENT,
+	name VARCHAR UNIQUE NOT NULL,
+	email VARCHAR UNIQUE NOT NULL,
+	role VARCHAR NOT NULL,
+	password VARCHAR NOT NULL,
+	passwordsalt VARCHAR NOT NULL,
+	passwordhashscheme VARCHAR NOT NULL);
+
+CREATE TABLE devices (
+	deviceid INTEGER PRIMARY KEY AUTOINCREMENT,
+	name VARCHAR NOT NULL,
+	userid INTEGER,
+	apikey VARCHAR NOT NULL,
+	FOREIGN KEY(userid) REFERENCES users(userid) ON DELETE CASCADE);
+
+CREATE TABLE streams (
+	streamid INTEGER PRIMARY KEY AUTOINCREMENT,
+	name VARCHAR NOT NULL,
+	schema VARCHAR NOT NULL,
+	deviceid INTEGER,
+	FOREIGN KEY(deviceid) REFERENCES devices(deviceid) ON DELETE CASCADE);
+
+INSERT INTO connectordbmeta VALUES ('DBVersion', '20160820');
+INSERT INTO users (name, email, role, password, passwordsalt, passwordhashscheme) VALUES ('tst', 'root@localhost', 'user', 'p', 's', 'SHA512');
+INSERT INTO devices (name, userid, apikey) VALUES ('user', 1, 'key');
+`
+
+func TestOpenUpgrade(t *testing.T) {
+	dir, err := ioutil.TempDir("", "connectordb_dbutil")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	uri := filepath.Join(dir, "db.sqlite3")
+
+	db, err := sqlx.Open("sqlite3", uri)
+	require.NoError(t, err)
+	_, err = db.Exec(schema20160820)
+	require.NoError(t, err)
+	db.Close()
+
+	db, err = OpenDatabase("sqlite3", uri)
+	require.NoError(t, err)
+	defer db.Close()
+
+	var version string
+	require.NoError(t, db.Get(&version, "SELECT value FROM connectordbmeta WHERE key='DBVersion';"))
+	require.Equal(t, DBVersion, version)
+
+	// The existing rows get the defaults of the new columns, and the new tables exist
+	var enabled bool
+	require.NoError(t, db.Get(&enabled, "SELECT totpenabled FROM users WHERE name='tst';"))
+	require.False(t, enabled)
+	var allow string
+	require.NoError(t, db.Get(&allow, "SELECT allowstreams FROM devices WHERE name='user';"))
+	require.Equal(t, "", allow)
+	_, err = db.Exec("INSERT INTO identities (userid, issuer, subject) VALUES (1, 'issuer', 'subject');")
+	require.NoError(t, err)
+
+	// A database at an unknown version is refused
+	_, err = db.Exec("UPDATE connectordbmeta SET value='20150101' WHERE key='DBVersion';")
+	require.NoError(t, err)
+	_, err = OpenDatabase("sqlite3", uri)
+	require.Error(t, err)
+}
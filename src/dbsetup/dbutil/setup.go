@@ -2,6 +2,7 @@ package dbutil
 
 import (
 	"bytes"
+	"fmt"
 	"html/template"
 	"os"
 
@@ -12,6 +13,10 @@ import (
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// DBVersion is the version of the schema created by SetupDatabase. It changes whenever the schema changes,
+// and databases at an older version are upgraded when they are opened.
+const DBVersion = "20161101"
+
 // This is the relevant schema used in ConnectorDB.
 const dbSchema = `
 
@@ -32,7 +37,13 @@ CREATE TABLE users (
 
 	password VARCHAR NOT NULL,
 	passwordsalt VARCHAR NOT NULL,
-	passwordhashscheme VARCHAR NOT NULL);
+	passwordhashscheme VARCHAR NOT NULL,
+
+	totpsecret VARCHAR DEFAULT '',
+	totpenabled BOOLEAN DEFAULT FALSE,
+	recoverycodes VARCHAR DEFAULT '',
+
+	emailverified BOOLEAN DEFAULT FALSE);
 
 CREATE UNIQUE INDEX UserNameIndex ON users (name);
 
@@ -54,6 +65,9 @@ CREATE TABLE devices (
 
 	isvisible BOOLEAN DEFAULT TRUE,
 	usereditable BOOLEAN DEFAULT TRUE,
+
+	allowstreams VARCHAR NOT NULL DEFAULT '',
+	denystreams VARCHAR NOT NULL DEFAULT '',
 	UNIQUE(userid, name),
 	FOREIGN KEY(userid) REFERENCES users(userid) ON DELETE CASCADE);
 
@@ -73,6 +87,7 @@ CREATE TABLE streams (
 	deviceid INTEGER,
 	ephemeral BOOLEAN DEFAULT FALSE,
 	downlink BOOLEAN DEFAULT FALSE,
+	grouppublic BOOLEAN DEFAULT FALSE,
 	UNIQUE(name, deviceid),
 	FOREIGN KEY(deviceid) REFERENCES devices(deviceid) ON DELETE CASCADE);
 
@@ -81,6 +96,7 @@ CREATE INDEX StreamNameIndex ON streams (name);
 CREATE INDEX StreamDeviceIndex ON streams (deviceid);
 
 
+` + tablesSince20160820 + `
 CREATE TABLE datastream (
 	streamid BIGINT NOT NULL,
 	substream VARCHAR,
@@ -94,9 +110,125 @@ CREATE TABLE datastream (
 
 CREATE INDEX datastreamtime ON datastream (streamID,substream,endtime ASC);
 
-INSERT INTO connectordbmeta VALUES ('DBVersion', '20160820');
+INSERT INTO connectordbmeta VALUES ('DBVersion', '{{.version}}');
+`
+
+// tablesSince20160820 are the tables which were added to the schema after version 20160820
+const tablesSince20160820 = `
+CREATE TABLE grants (
+	grantid {{.pkey_exp}},
+	ownerid INTEGER NOT NULL,
+
+	grantee_userid INTEGER,
+	grantee_deviceid INTEGER,
+
+	userid INTEGER,
+	deviceid INTEGER,
+	streamid INTEGER,
+
+	can_read BOOLEAN DEFAULT FALSE,
+	can_write BOOLEAN DEFAULT FALSE,
+	can_subscribe BOOLEAN DEFAULT FALSE,
+	expires DOUBLE PRECISION DEFAULT 0,
+
+	FOREIGN KEY(ownerid) REFERENCES users(userid) ON DELETE CASCADE,
+	FOREIGN KEY(grantee_userid) REFERENCES users(userid) ON DELETE CASCADE,
+	FOREIGN KEY(grantee_deviceid) REFERENCES devices(deviceid) ON DELETE CASCADE,
+	FOREIGN KEY(userid) REFERENCES users(userid) ON DELETE CASCADE,
+	FOREIGN KEY(deviceid) REFERENCES devices(deviceid) ON DELETE CASCADE,
+	FOREIGN KEY(streamid) REFERENCES streams(streamid) ON DELETE CASCADE);
+
+CREATE INDEX GrantOwnerIndex ON grants (ownerid);
+CREATE INDEX GrantUserGranteeIndex ON grants (grantee_userid);
+CREATE INDEX GrantDeviceGranteeIndex ON grants (grantee_deviceid);
+
+
+CREATE TABLE tokens (
+	tokenid {{.pkey_exp}},
+	deviceid INTEGER NOT NULL,
+	name VARCHAR NOT NULL,
+	token VARCHAR NOT NULL,
+
+	streams VARCHAR DEFAULT '',
+	access VARCHAR NOT NULL,
+	expires DOUBLE PRECISION DEFAULT 0,
+
+	UNIQUE(deviceid, name),
+	FOREIGN KEY(deviceid) REFERENCES devices(deviceid) ON DELETE CASCADE);
+
+CREATE UNIQUE INDEX TokenIndex ON tokens (token);
+CREATE INDEX TokenDeviceIndex ON tokens (deviceid);
+
+
+CREATE TABLE identities (
+	identityid {{.pkey_exp}},
+	userid INTEGER NOT NULL,
+	issuer VARCHAR NOT NULL,
+	subject VARCHAR NOT NULL,
+
+	UNIQUE(issuer, subject),
+	FOREIGN KEY(userid) REFERENCES users(userid) ON DELETE CASCADE);
+
+CREATE INDEX IdentityUserIndex ON identities (userid);
+
+
+CREATE TABLE usergroups (
+	groupid INTEGER PRIMARY KEY,
+	FOREIGN KEY(groupid) REFERENCES users(userid) ON DELETE CASCADE);
+
+CREATE TABLE groupmembers (
+	groupid INTEGER NOT NULL,
+	userid INTEGER NOT NULL,
+	role VARCHAR NOT NULL,
+	accepted BOOLEAN DEFAULT FALSE,
+
+	UNIQUE(groupid, userid),
+	FOREIGN KEY(groupid) REFERENCES usergroups(groupid) ON DELETE CASCADE,
+	FOREIGN KEY(userid) REFERENCES users(userid) ON DELETE CASCADE);
+
+CREATE INDEX GroupMemberUserIndex ON groupmembers (userid);
+
+
+CREATE TABLE webhooks (
+	webhookid {{.pkey_exp}},
+	userid INTEGER NOT NULL,
+
+	deviceid INTEGER,
+	streamid INTEGER,
+
+	url VARCHAR NOT NULL,
+	secret VARCHAR NOT NULL,
+	transform VARCHAR DEFAULT '',
+
+	FOREIGN KEY(userid) REFERENCES users(userid) ON DELETE CASCADE,
+	FOREIGN KEY(deviceid) REFERENCES devices(deviceid) ON DELETE CASCADE,
+	FOREIGN KEY(streamid) REFERENCES streams(streamid) ON DELETE CASCADE);
+
+CREATE INDEX WebhookUserIndex ON webhooks (userid);
+CREATE INDEX WebhookDeviceIndex ON webhooks (deviceid);
+CREATE INDEX WebhookStreamIndex ON webhooks (streamid);
 `
 
+// upgrade20160820 upgrades a database at version 20160820 to the current schema
+const upgrade20160820 = `
+ALTER TABLE users ADD COLUMN totpsecret VARCHAR DEFAULT '';
+ALTER TABLE users ADD COLUMN totpenabled BOOLEAN DEFAULT FALSE;
+ALTER TABLE users ADD COLUMN recoverycodes VARCHAR DEFAULT '';
+ALTER TABLE users ADD COLUMN emailverified BOOLEAN DEFAULT FALSE;
+
+ALTER TABLE devices ADD COLUMN allowstreams VARCHAR NOT NULL DEFAULT '';
+ALTER TABLE devices ADD COLUMN denystreams VARCHAR NOT NULL DEFAULT '';
+
+ALTER TABLE streams ADD COLUMN grouppublic BOOLEAN DEFAULT FALSE;
+` + tablesSince20160820 + `
+UPDATE connectordbmeta SET value='{{.version}}' WHERE key='DBVersion';
+`
+
+// dbUpgrades holds the schema upgrades of older versions of the database, by the version that they upgrade
+var dbUpgrades = map[string]string{
+	"20160820": upgrade20160820,
+}
+
 // postgresFunctions allow certain things to happen automatically in postgres,
 // which is safer than doing them manually (as is done in sqlite)
 const postgresFunctions = `
@@ -126,13 +258,18 @@ SET search_path = public;
 `
 
 func getSchemaString(dbtype string) (string, error) {
-	templateParams := make(map[string]string)
+	return executeSchema(dbtype, dbSchema)
+}
+
+// executeSchema fills in the parts of the given schema which differ between database types
+func executeSchema(dbtype, schema string) (string, error) {
+	templateParams := map[string]string{"version": DBVersion}
 	if dbtype == "postgres" {
 		templateParams["pkey_exp"] = "SERIAL PRIMARY KEY"
 	} else {
 		templateParams["pkey_exp"] = "INTEGER PRIMARY KEY AUTOINCREMENT"
 	}
-	schemaTemplate, err := template.New("dbschema").Parse(dbSchema)
+	schemaTemplate, err := template.New("dbschema").Parse(schema)
 	if err != nil {
 		return "", err
 	}
@@ -170,6 +307,29 @@ func SetupDatabase(dbtype, uri string) error {
 	return err
 }
 
+// UpgradeDatabase upgrades the schema of a database at the given older version to the current version.
+// The upgrade is run in a transaction, so that a failed upgrade leaves the database as it was.
+func UpgradeDatabase(db *sqlx.DB, dbtype, version string) error {
+	upgrade, ok := dbUpgrades[version]
+	if !ok {
+		return fmt.Errorf("The existing database (version %s) is incompatible with this version of ConnectorDB", version)
+	}
+	log.Warnf("Upgrading %s database from version %s to %s", dbtype, version, DBVersion)
+	upgradeString, err := executeSchema(dbtype, upgrade)
+	if err != nil {
+		return err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	if _, err = tx.Exec(upgradeString); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
 // ClearDatabase removes all data from the database
 func ClearDatabase(dbtype, uri string) error {
 	log.Warnf("Clearing %s database at %s", dbtype, uri)
@@ -15,6 +15,10 @@ type SqlxMixin struct {
 	DB                    *sqlx.DB
 	sqlxPreparedStmtCache map[string]*sqlx.Stmt
 	lock                  sync.RWMutex
+
+	// If Tx is set, the queries are run within the transaction rather than on DB.
+	// They are not prepared, since statements prepared on a transaction are closed along with it.
+	Tx *sqlx.Tx
 }
 
 // Initializes a sqlx mixin
@@ -56,6 +60,9 @@ Gets a single item from the DB, remember to add LIMIT 1 if the DB doesn't know
 about the query being for a unique item.
 **/
 func (db *SqlxMixin) Get(dest interface{}, query string, args ...interface{}) error {
+	if db.Tx != nil {
+		return db.Tx.Get(dest, db.Tx.Rebind(query), args...)
+	}
 	prep, err := db.GetOrPrepare(query)
 
 	if err != nil {
@@ -71,6 +78,9 @@ procedures executes them, and does conversion to the given query style for the
 given database.
 **/
 func (db *SqlxMixin) Select(dest interface{}, query string, args ...interface{}) error {
+	if db.Tx != nil {
+		return db.Tx.Select(dest, db.Tx.Rebind(query), args...)
+	}
 	prep, err := db.GetOrPrepare(query)
 
 	if err != nil {
@@ -86,6 +96,9 @@ procedures executes them, and does conversion to the given query style for the
 given database.
 **/
 func (db *SqlxMixin) Exec(query string, args ...interface{}) (sql.Result, error) {
+	if db.Tx != nil {
+		return db.Tx.Exec(db.Tx.Rebind(query), args...)
+	}
 	prep, err := db.GetOrPrepare(query)
 
 	if err != nil {
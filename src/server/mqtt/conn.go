@@ -0,0 +1,290 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package mqtt
+
+import (
+	"bufio"
+	"config"
+	"connectordb"
+	"connectordb/authoperator"
+	"connectordb/messenger"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"server/webcore"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nats-io/nats"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// The time to wait on a write to a client
+const writeWait = 10 * time.Second
+
+// ErrWildcard is the reason that a subscription with an unsupported wildcard is refused
+var ErrWildcard = errors.New("Subscriptions can only use # at the end of a user or device, and can't use +")
+
+// Conn is a single MQTT client. Each client is a device, which logs in with its API key.
+type Conn struct {
+	sync.Mutex // Guards the writes to the connection
+
+	conn   net.Conn
+	r      *bufio.Reader
+	c      *config.MQTT
+	db     *connectordb.Database
+	o      *authoperator.AuthOperator
+	logger *log.Entry
+
+	keepalive time.Duration
+
+	// The subscriptions by their topic filter, and the channel to which their messages are sent
+	subscriptions map[string]*nats.Subscription
+	messages      chan messenger.Message
+
+	// The ids of QoS 2 publishes which were inserted, but not released yet. A publish which
+	// is sent again before it is released is not inserted twice.
+	received map[uint16]bool
+
+	done chan struct{}
+}
+
+// NewConn sets up the client of the given connection. The client logs in when it is run.
+func NewConn(conn net.Conn, c *config.MQTT, db *connectordb.Database) *Conn {
+	return &Conn{
+		conn:          conn,
+		r:             bufio.NewReader(conn),
+		c:             c,
+		db:            db,
+		logger:        log.WithField("mqtt", conn.RemoteAddr().String()),
+		keepalive:     time.Duration(c.DefaultKeepAlive) * time.Second,
+		subscriptions: make(map[string]*nats.Subscription),
+		messages:      make(chan messenger.Message, config.Get().Websocket.MessageBuffer),
+		received:      make(map[uint16]bool),
+		done:          make(chan struct{}),
+	}
+}
+
+// read reads the next packet. A client which sends nothing for one and a half keepalive periods is gone.
+func (c *Conn) read() (*packet, error) {
+	c.conn.SetReadDeadline(time.Now().Add(c.keepalive * 3 / 2))
+	return readPacket(c.r, c.c.MaxPacketBytes)
+}
+
+func (c *Conn) write(p *packet) error {
+	c.Lock()
+	defer c.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	return writePacket(c.conn, p)
+}
+
+// login reads the CONNECT of the client, and logs in with the API key given as its password.
+// Clients that can't set a password can give the API key as the username instead.
+func (c *Conn) login() error {
+	p, err := c.read()
+	if err != nil {
+		return err
+	}
+	if p.Type != packetConnect {
+		return ErrMalformedPacket
+	}
+	connect, err := decodeConnect(p)
+	if err != nil {
+		return err
+	}
+	if (connect.Protocol != "MQTT" && connect.Protocol != "MQIsdp") || (connect.Level != 3 && connect.Level != 4) {
+		c.write(encodeConnack(connackBadProtocolVersion))
+		return errors.New("Unsupported MQTT protocol version")
+	}
+
+	apikey := connect.Password
+	if apikey == "" {
+		apikey = connect.Username
+	}
+	if apikey == "" {
+		c.write(encodeConnack(connackNotAuthorized))
+		return webcore.ErrNoAuthentication
+	}
+	c.o, err = webcore.DeviceKeyLogin(c.db, c.conn.RemoteAddr().String(), apikey)
+	if err != nil {
+		c.write(encodeConnack(connackBadCredentials))
+		return err
+	}
+
+	if connect.KeepAlive > 0 {
+		c.keepalive = time.Duration(connect.KeepAlive) * time.Second
+	}
+	c.logger = c.logger.WithFields(log.Fields{"dev": c.o.Name(), "client": connect.ClientID})
+	return c.write(encodeConnack(connackAccepted))
+}
+
+// Run logs in the client, and then handles its packets until it disconnects
+func (c *Conn) Run() {
+	defer c.Close()
+	if err := c.login(); err != nil {
+		c.logger.Warnln("MQTT login failed: ", err)
+		return
+	}
+	c.logger.Debugln("MQTT client connected")
+	go c.runWriter()
+
+	for {
+		p, err := c.read()
+		if err != nil {
+			if err != io.EOF {
+				c.logger.Debugln(err)
+			}
+			return
+		}
+		switch p.Type {
+		case packetPublish:
+			err = c.publish(p)
+		case packetPubrel:
+			var id uint16
+			if id, err = decodePacketID(p); err == nil {
+				delete(c.received, id)
+				err = c.write(encodeAck(packetPubcomp, id))
+			}
+		case packetSubscribe:
+			err = c.subscribe(p)
+		case packetUnsubscribe:
+			err = c.unsubscribe(p)
+		case packetPingreq:
+			err = c.write(&packet{Type: packetPingresp})
+		case packetPuback, packetPubrec, packetPubcomp:
+			// Messages are only sent to the client with QoS 0, so there is nothing to acknowledge
+		case packetDisconnect:
+			c.logger.Debugln("MQTT client disconnected")
+			return
+		default:
+			err = ErrMalformedPacket
+		}
+		if err != nil {
+			c.logger.Warnln(err)
+			return
+		}
+	}
+}
+
+// publish inserts the data of a PUBLISH into the stream given by its topic. MQTT 3.1.1 has no way of refusing a
+// publish, so failed inserts are only logged, the same way that brokers drop messages which clients can't publish.
+func (c *Conn) publish(p *packet) error {
+	pub, err := decodePublish(p)
+	if err != nil {
+		return err
+	}
+	if pub.QoS < 2 || !c.received[pub.PacketID] {
+		logger := c.logger.WithFields(log.Fields{"cmd": "insert", "arg": pub.Topic})
+		dpa := parsePayload(pub.Payload, float64(time.Now().UnixNano())*1e-9)
+		logger.Debugln("-> insert ", len(dpa), "dp")
+		if err = c.o.InsertStream(pub.Topic, dpa, true); err != nil {
+			logger.Warnln(err)
+		} else {
+			atomic.AddUint32(&webcore.StatsInserts, uint32(len(dpa)))
+		}
+	}
+	switch pub.QoS {
+	case 1:
+		return c.write(encodeAck(packetPuback, pub.PacketID))
+	case 2:
+		c.received[pub.PacketID] = true
+		return c.write(encodeAck(packetPubrec, pub.PacketID))
+	}
+	return nil
+}
+
+// topicPath returns the path to subscribe to for the topic filter. A stream is subscribed to by its path,
+// with /downlink for its downlink, and all the streams of a user or device by their path followed by /#.
+func topicPath(topic string) (string, error) {
+	path := topic
+	if strings.HasSuffix(topic, "/#") {
+		path = strings.TrimSuffix(topic, "/#")
+		if strings.Count(path, "/") > 1 {
+			return "", ErrWildcard
+		}
+	}
+	if path == "" || strings.ContainsAny(path, "#+") {
+		return "", ErrWildcard
+	}
+	return path, nil
+}
+
+// subscribe subscribes to the topics of a SUBSCRIBE. The data of the subscriptions is sent with QoS 0.
+func (c *Conn) subscribe(p *packet) error {
+	id, topics, err := decodeTopics(p)
+	if err != nil {
+		return err
+	}
+	codes := make([]byte, len(topics))
+	for i, topic := range topics {
+		logger := c.logger.WithFields(log.Fields{"cmd": "subscribe", "arg": topic})
+		if _, ok := c.subscriptions[topic]; ok {
+			continue
+		}
+		var s *nats.Subscription
+		path, err := topicPath(topic)
+		if err == nil {
+			s, err = c.o.Subscribe(path, c.messages)
+		}
+		if err != nil {
+			logger.Warnln(err)
+			codes[i] = subackFailure
+			continue
+		}
+		logger.Debugln("Initializing subscription")
+		c.subscriptions[topic] = s
+	}
+	return c.write(encodeSuback(id, codes))
+}
+
+func (c *Conn) unsubscribe(p *packet) error {
+	id, topics, err := decodeTopics(p)
+	if err != nil {
+		return err
+	}
+	for _, topic := range topics {
+		if s, ok := c.subscriptions[topic]; ok {
+			c.logger.WithFields(log.Fields{"cmd": "unsubscribe", "arg": topic}).Debugln("stop subscription")
+			s.Unsubscribe()
+			delete(c.subscriptions, topic)
+		}
+	}
+	return c.write(encodeAck(packetUnsuback, id))
+}
+
+// runWriter sends the messages of the subscriptions to the client, with the message's stream as the topic,
+// and its datapoints as the payload
+func (c *Conn) runWriter() {
+	for {
+		select {
+		case msg := <-c.messages:
+			payload, err := json.Marshal(msg.Data)
+			if err == nil {
+				c.logger.WithField("arg", msg.Stream).Debugln("<- send")
+				err = c.write(encodePublish(&publishPacket{Topic: msg.Stream, Payload: payload}))
+			}
+			if err != nil {
+				c.logger.Warnln(err)
+				c.conn.Close()
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// Close unsubscribes from all topics and closes the connection
+func (c *Conn) Close() {
+	for _, s := range c.subscriptions {
+		s.Unsubscribe()
+	}
+	close(c.done)
+	c.conn.Close()
+}
@@ -0,0 +1,156 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package mqtt
+
+import (
+	"bufio"
+	"config"
+	"connectordb"
+	"connectordb/datastream"
+	"connectordb/users"
+	"encoding/json"
+	"log"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+var tdb *connectordb.Database
+
+func init() {
+	db, err := connectordb.Open(config.TestConfiguration.Options())
+	if err != nil {
+		log.Fatal(err)
+	}
+	tdb = db
+	go db.RunWriter()
+}
+
+// readNext reads the next packet sent to the client, ensuring that it has the given type
+func readNext(t *testing.T, r *bufio.Reader, packetType byte) *packet {
+	packets := make(chan *packet, 1)
+	go func() {
+		p, err := readPacket(r, 1024*1024)
+		if err != nil {
+			p = nil
+		}
+		packets <- p
+	}()
+	select {
+	case p := <-packets:
+		require.NotNil(t, p, "The connection was closed")
+		require.Equal(t, packetType, p.Type)
+		return p
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "No packet was sent")
+	}
+	return nil
+}
+
+// connect runs a client on one end of a pipe, and sends the CONNECT with the api key from the other end.
+// It returns the end of the pipe which acts as the client, and the return code of the CONNACK.
+func connect(t *testing.T, apikey string) (net.Conn, *bufio.Reader, byte) {
+	client, server := net.Pipe()
+	go NewConn(server, &config.MQTT{MaxPacketBytes: 1024 * 1024, DefaultKeepAlive: 60}, tdb).Run()
+
+	e := &encoder{}
+	e.string("MQTT")
+	e.byte(4)
+	e.byte(0x40 | 0x02) // Password and clean session
+	e.uint16(60)
+	e.string("testclient")
+	e.string(apikey)
+	require.NoError(t, writePacket(client, &packet{Type: packetConnect, Body: e.b}))
+
+	r := bufio.NewReader(client)
+	p := readNext(t, r, packetConnack)
+	require.Len(t, p.Body, 2)
+	return client, r, p.Body[1]
+}
+
+// publish sends a PUBLISH with the given QoS and packet id
+func publish(t *testing.T, client net.Conn, topic string, qos byte, id uint16, payload string) {
+	require.NoError(t, writePacket(client, encodePublish(&publishPacket{Topic: topic, QoS: qos, PacketID: id, Payload: []byte(payload)})))
+}
+
+// requireAck reads the next packet, ensuring that it acknowledges the given packet id
+func requireAck(t *testing.T, r *bufio.Reader, packetType byte, id uint16) {
+	ackid, err := decodePacketID(readNext(t, r, packetType))
+	require.NoError(t, err)
+	require.Equal(t, id, ackid)
+}
+
+func requireLength(t *testing.T, stream string, length int64) {
+	l, err := tdb.LengthStream(stream)
+	require.NoError(t, err)
+	require.Equal(t, length, l)
+}
+
+func TestConn(t *testing.T) {
+	tdb.Clear()
+	require.NoError(t, tdb.CreateUser(&users.UserMaker{User: users.User{Name: "mqttuser", Email: "mqtt@localhost", Password: "mypass", Role: "user", Public: true}}))
+	require.NoError(t, tdb.CreateDevice("mqttuser/mqttdevice", &users.DeviceMaker{}))
+	require.NoError(t, tdb.CreateStream("mqttuser/mqttdevice/s1", &users.StreamMaker{Stream: users.Stream{Schema: `{"type":"number"}`, Downlink: true}}))
+	dev, err := tdb.ReadDevice("mqttuser/mqttdevice")
+	require.NoError(t, err)
+
+	client, r, code := connect(t, dev.APIKey)
+	defer client.Close()
+	require.Equal(t, byte(connackAccepted), code)
+
+	// Publishes are inserted into the stream of their topic
+	publish(t, client, "mqttuser/mqttdevice/s1", 1, 1, "5")
+	requireAck(t, r, packetPuback, 1)
+	requireLength(t, "mqttuser/mqttdevice/s1", 1)
+
+	// A QoS 2 publish which is sent again before it is released is only inserted once
+	publish(t, client, "mqttuser/mqttdevice/s1", 2, 9, "6")
+	requireAck(t, r, packetPubrec, 9)
+	publish(t, client, "mqttuser/mqttdevice/s1", 2, 9, "6")
+	requireAck(t, r, packetPubrec, 9)
+	requireLength(t, "mqttuser/mqttdevice/s1", 2)
+	require.NoError(t, writePacket(client, encodeAck(packetPubrel, 9)))
+	requireAck(t, r, packetPubcomp, 9)
+	publish(t, client, "mqttuser/mqttdevice/s1", 2, 9, "7")
+	requireAck(t, r, packetPubrec, 9)
+	requireLength(t, "mqttuser/mqttdevice/s1", 3)
+
+	// Subscriptions with unsupported wildcards are refused
+	e := &encoder{}
+	e.uint16(3)
+	e.string("mqttuser/mqttdevice/s1/downlink")
+	e.byte(0)
+	e.string("mqttuser/+/s1")
+	e.byte(0)
+	require.NoError(t, writePacket(client, &packet{Type: packetSubscribe, Flags: 0x02, Body: e.b}))
+	p := readNext(t, r, packetSuback)
+	require.Equal(t, []byte{0, 3, 0, subackFailure}, p.Body)
+
+	// The inserts into the downlink are sent to the client
+	require.NoError(t, tdb.InsertStream("mqttuser/mqttdevice/s1/downlink", datastream.DatapointArray{{Timestamp: 1, Data: 8}}, false))
+	pub, err := decodePublish(readNext(t, r, packetPublish))
+	require.NoError(t, err)
+	require.Equal(t, "mqttuser/mqttdevice/s1/downlink", pub.Topic)
+	require.Equal(t, byte(0), pub.QoS)
+	var dpa datastream.DatapointArray
+	require.NoError(t, json.Unmarshal(pub.Payload, &dpa))
+	require.Equal(t, datastream.DatapointArray{{Timestamp: 1, Data: 8.0}}, dpa)
+
+	require.NoError(t, writePacket(client, &packet{Type: packetPingreq}))
+	readNext(t, r, packetPingresp)
+}
+
+func TestConnLogin(t *testing.T) {
+	tdb.Clear()
+
+	// A client with a wrong api key is refused, and disconnected
+	client, r, code := connect(t, "notanapikey")
+	defer client.Close()
+	require.Equal(t, byte(connackBadCredentials), code)
+	_, err := readPacket(r, 1024*1024)
+	require.Error(t, err)
+}
@@ -0,0 +1,286 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// The MQTT control packet types
+const (
+	packetConnect     = 1
+	packetConnack     = 2
+	packetPublish     = 3
+	packetPuback      = 4
+	packetPubrec      = 5
+	packetPubrel      = 6
+	packetPubcomp     = 7
+	packetSubscribe   = 8
+	packetSuback      = 9
+	packetUnsubscribe = 10
+	packetUnsuback    = 11
+	packetPingreq     = 12
+	packetPingresp    = 13
+	packetDisconnect  = 14
+)
+
+// The return codes of a CONNACK
+const (
+	connackAccepted           = 0
+	connackBadProtocolVersion = 1
+	connackBadCredentials     = 4
+	connackNotAuthorized      = 5
+)
+
+// subackFailure is the return code of a SUBACK for a subscription that was refused
+const subackFailure = 0x80
+
+var (
+	// ErrPacketTooLarge is returned when a packet is larger than the configured limit
+	ErrPacketTooLarge = errors.New("The MQTT packet is too large")
+	// ErrMalformedPacket is returned when a packet can't be decoded
+	ErrMalformedPacket = errors.New("Malformed MQTT packet")
+)
+
+// packet is an MQTT control packet. The flags are the lower 4 bits of the fixed header, and the
+// body holds the variable header and payload.
+type packet struct {
+	Type  byte
+	Flags byte
+	Body  []byte
+}
+
+// readPacket reads the next packet, refusing packets with a body larger than max bytes
+func readPacket(r *bufio.Reader, max int64) (*packet, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	// The remaining length is encoded in up to 4 bytes, 7 bits at a time
+	var length int64
+	for i := uint(0); ; i++ {
+		if i >= 4 {
+			return nil, ErrMalformedPacket
+		}
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		length |= int64(b&0x7f) << (7 * i)
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	if length > max {
+		return nil, ErrPacketTooLarge
+	}
+	p := &packet{Type: header >> 4, Flags: header & 0x0f, Body: make([]byte, length)}
+	if _, err = io.ReadFull(r, p.Body); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// writePacket writes the packet with its fixed header
+func writePacket(w io.Writer, p *packet) error {
+	b := make([]byte, 1, 5+len(p.Body))
+	b[0] = p.Type<<4 | p.Flags
+	length := len(p.Body)
+	for {
+		digit := byte(length & 0x7f)
+		length >>= 7
+		if length > 0 {
+			digit |= 0x80
+		}
+		b = append(b, digit)
+		if length == 0 {
+			break
+		}
+	}
+	_, err := w.Write(append(b, p.Body...))
+	return err
+}
+
+// decoder reads the fields of a packet's body. The first error is kept, and makes all following reads return zero values.
+type decoder struct {
+	b   []byte
+	err error
+}
+
+func (d *decoder) byte() byte {
+	if d.err != nil || len(d.b) < 1 {
+		d.err = ErrMalformedPacket
+		return 0
+	}
+	v := d.b[0]
+	d.b = d.b[1:]
+	return v
+}
+
+func (d *decoder) uint16() uint16 {
+	if d.err != nil || len(d.b) < 2 {
+		d.err = ErrMalformedPacket
+		return 0
+	}
+	v := binary.BigEndian.Uint16(d.b)
+	d.b = d.b[2:]
+	return v
+}
+
+// bytes reads a length-prefixed byte array
+func (d *decoder) bytes() []byte {
+	n := int(d.uint16())
+	if d.err != nil || len(d.b) < n {
+		d.err = ErrMalformedPacket
+		return nil
+	}
+	v := d.b[:n]
+	d.b = d.b[n:]
+	return v
+}
+
+// string reads a length-prefixed UTF-8 string
+func (d *decoder) string() string {
+	return string(d.bytes())
+}
+
+// empty returns whether the whole body was read
+func (d *decoder) empty() bool {
+	return len(d.b) == 0
+}
+
+// encoder builds the body of a packet
+type encoder struct {
+	b []byte
+}
+
+func (e *encoder) byte(v byte) {
+	e.b = append(e.b, v)
+}
+
+func (e *encoder) uint16(v uint16) {
+	e.b = append(e.b, byte(v>>8), byte(v))
+}
+
+func (e *encoder) string(v string) {
+	e.uint16(uint16(len(v)))
+	e.b = append(e.b, v...)
+}
+
+// connectPacket holds the fields of a CONNECT that are used. Wills are read, but not supported.
+type connectPacket struct {
+	Protocol  string
+	Level     byte
+	KeepAlive uint16
+	ClientID  string
+	Username  string
+	Password  string
+}
+
+func decodeConnect(p *packet) (*connectPacket, error) {
+	d := &decoder{b: p.Body}
+	c := &connectPacket{}
+	c.Protocol = d.string()
+	c.Level = d.byte()
+	flags := d.byte()
+	c.KeepAlive = d.uint16()
+	c.ClientID = d.string()
+	if flags&0x04 != 0 {
+		// The will topic and message
+		d.string()
+		d.bytes()
+	}
+	if flags&0x80 != 0 {
+		c.Username = d.string()
+	}
+	if flags&0x40 != 0 {
+		c.Password = string(d.bytes())
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	return c, nil
+}
+
+// publishPacket is an application message
+type publishPacket struct {
+	Topic    string
+	QoS      byte
+	PacketID uint16
+	Payload  []byte
+}
+
+func decodePublish(p *packet) (*publishPacket, error) {
+	d := &decoder{b: p.Body}
+	pub := &publishPacket{QoS: (p.Flags >> 1) & 0x03}
+	pub.Topic = d.string()
+	if pub.QoS > 0 {
+		pub.PacketID = d.uint16()
+	}
+	if d.err != nil || pub.QoS > 2 {
+		return nil, ErrMalformedPacket
+	}
+	pub.Payload = d.b
+	return pub, nil
+}
+
+func encodePublish(pub *publishPacket) *packet {
+	e := &encoder{}
+	e.string(pub.Topic)
+	if pub.QoS > 0 {
+		e.uint16(pub.PacketID)
+	}
+	e.b = append(e.b, pub.Payload...)
+	return &packet{Type: packetPublish, Flags: pub.QoS << 1, Body: e.b}
+}
+
+// decodeTopics reads the packet id and topic filters of a SUBSCRIBE or UNSUBSCRIBE.
+// A SUBSCRIBE gives a requested QoS after each topic, which is skipped.
+func decodeTopics(p *packet) (id uint16, topics []string, err error) {
+	d := &decoder{b: p.Body}
+	id = d.uint16()
+	for d.err == nil && !d.empty() {
+		topics = append(topics, d.string())
+		if p.Type == packetSubscribe {
+			d.byte()
+		}
+	}
+	if d.err != nil || len(topics) == 0 {
+		return 0, nil, ErrMalformedPacket
+	}
+	return id, topics, nil
+}
+
+// decodePacketID reads the packet id of a PUBREL
+func decodePacketID(p *packet) (uint16, error) {
+	d := &decoder{b: p.Body}
+	id := d.uint16()
+	return id, d.err
+}
+
+// encodeAck returns a packet which only holds the packet id that it acknowledges
+func encodeAck(packetType byte, id uint16) *packet {
+	e := &encoder{}
+	e.uint16(id)
+	flags := byte(0)
+	if packetType == packetPubrel {
+		flags = 0x02
+	}
+	return &packet{Type: packetType, Flags: flags, Body: e.b}
+}
+
+func encodeConnack(code byte) *packet {
+	return &packet{Type: packetConnack, Body: []byte{0, code}}
+}
+
+func encodeSuback(id uint16, codes []byte) *packet {
+	e := &encoder{}
+	e.uint16(id)
+	e.b = append(e.b, codes...)
+	return &packet{Type: packetSuback, Body: e.b}
+}
@@ -0,0 +1,91 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package mqtt
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPacketEncoding(t *testing.T) {
+	var buf bytes.Buffer
+	body := bytes.Repeat([]byte{1}, 200) // Needs two bytes of remaining length
+	require.NoError(t, writePacket(&buf, &packet{Type: packetPublish, Flags: 2, Body: body}))
+	require.NoError(t, writePacket(&buf, &packet{Type: packetPingreq}))
+	require.Equal(t, []byte{0x32, 0xc8, 0x01}, buf.Bytes()[:3])
+
+	r := bufio.NewReader(bytes.NewReader(buf.Bytes()))
+	p, err := readPacket(r, 1000)
+	require.NoError(t, err)
+	require.Equal(t, &packet{Type: packetPublish, Flags: 2, Body: body}, p)
+	p, err = readPacket(r, 1000)
+	require.NoError(t, err)
+	require.Equal(t, byte(packetPingreq), p.Type)
+	require.Empty(t, p.Body)
+
+	_, err = readPacket(bufio.NewReader(bytes.NewReader(buf.Bytes())), 100)
+	require.Equal(t, ErrPacketTooLarge, err)
+	_, err = readPacket(bufio.NewReader(bytes.NewReader([]byte{0x30, 0xff, 0xff, 0xff, 0xff, 0x01})), 1000)
+	require.Equal(t, ErrMalformedPacket, err)
+}
+
+func TestConnectDecoding(t *testing.T) {
+	e := &encoder{}
+	e.string("MQTT")
+	e.byte(4)
+	e.byte(0x80 | 0x40 | 0x04 | 0x02) // Username, password, will and clean session
+	e.uint16(60)
+	e.string("myclient")
+	e.string("will/topic")
+	e.string("will message")
+	e.string("myuser")
+	e.string("myapikey")
+
+	c, err := decodeConnect(&packet{Type: packetConnect, Body: e.b})
+	require.NoError(t, err)
+	require.Equal(t, &connectPacket{"MQTT", 4, 60, "myclient", "myuser", "myapikey"}, c)
+
+	_, err = decodeConnect(&packet{Type: packetConnect, Body: e.b[:20]})
+	require.Equal(t, ErrMalformedPacket, err)
+}
+
+func TestPublishEncoding(t *testing.T) {
+	pub := &publishPacket{Topic: "u/d/s", QoS: 1, PacketID: 7, Payload: []byte("hi")}
+	p := encodePublish(pub)
+	require.Equal(t, byte(2), p.Flags)
+	pub2, err := decodePublish(p)
+	require.NoError(t, err)
+	require.Equal(t, pub, pub2)
+
+	pub = &publishPacket{Topic: "u/d/s/downlink", Payload: []byte("[]")}
+	pub2, err = decodePublish(encodePublish(pub))
+	require.NoError(t, err)
+	require.Equal(t, pub, pub2)
+
+	_, err = decodePublish(&packet{Type: packetPublish, Flags: 6, Body: p.Body})
+	require.Equal(t, ErrMalformedPacket, err, "QoS 3 is invalid")
+}
+
+func TestTopicDecoding(t *testing.T) {
+	e := &encoder{}
+	e.uint16(3)
+	e.string("u/d/s")
+	e.byte(1)
+	e.string("u/#")
+	e.byte(0)
+	id, topics, err := decodeTopics(&packet{Type: packetSubscribe, Body: e.b})
+	require.NoError(t, err)
+	require.Equal(t, uint16(3), id)
+	require.Equal(t, []string{"u/d/s", "u/#"}, topics)
+
+	_, _, err = decodeTopics(&packet{Type: packetUnsubscribe, Body: []byte{0, 3}})
+	require.Equal(t, ErrMalformedPacket, err)
+
+	require.Equal(t, []byte{0, 3, 0, 0x80}, encodeSuback(3, []byte{0, subackFailure}).Body)
+	require.Equal(t, &packet{Type: packetPubrel, Flags: 2, Body: []byte{0, 3}}, encodeAck(packetPubrel, 3))
+}
@@ -0,0 +1,56 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package mqtt
+
+import (
+	"connectordb/datastream"
+	"encoding/json"
+)
+
+// parsePayload returns the datapoints held in the payload of a PUBLISH. The payload is either a json array
+// of datapoints, a single json datapoint, or the value of a datapoint, which is inserted at the given time.
+// A value which is not valid json is inserted as a string, so that the simplest devices can publish plain text.
+func parsePayload(payload []byte, now float64) datastream.DatapointArray {
+	var dpa datastream.DatapointArray
+	if err := json.Unmarshal(payload, &dpa); err == nil && len(dpa) > 0 && isDatapointArray(payload) {
+		return dpa
+	}
+	var dp datastream.Datapoint
+	if isDatapoint(payload) && json.Unmarshal(payload, &dp) == nil {
+		return datastream.DatapointArray{dp}
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(payload, &v); err != nil {
+		v = string(payload)
+	}
+	return datastream.DatapointArray{datastream.Datapoint{Timestamp: now, Data: v}}
+}
+
+// isDatapoint returns whether the json is an object with a timestamp and data, and nothing else.
+// Other objects are the values of datapoints.
+func isDatapoint(b []byte) bool {
+	var obj map[string]json.RawMessage
+	if json.Unmarshal(b, &obj) != nil {
+		return false
+	}
+	_, hasT := obj["t"]
+	_, hasD := obj["d"]
+	return hasT && hasD && len(obj) == 2
+}
+
+// isDatapointArray returns whether the json is an array in which every element is a datapoint
+func isDatapointArray(b []byte) bool {
+	var arr []json.RawMessage
+	if json.Unmarshal(b, &arr) != nil {
+		return false
+	}
+	for _, v := range arr {
+		if !isDatapoint(v) {
+			return false
+		}
+	}
+	return true
+}
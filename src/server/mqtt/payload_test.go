@@ -0,0 +1,28 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package mqtt
+
+import (
+	"connectordb/datastream"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePayload(t *testing.T) {
+	dp := func(t float64, d interface{}) datastream.DatapointArray {
+		return datastream.DatapointArray{datastream.Datapoint{Timestamp: t, Data: d}}
+	}
+
+	require.Equal(t, append(dp(1, 2.0), dp(3, "hi")...), parsePayload([]byte(`[{"t":1,"d":2},{"t":3,"d":"hi"}]`), 10))
+	require.Equal(t, dp(1, true), parsePayload([]byte(`{"t":1,"d":true}`), 10))
+
+	// Anything that isn't a datapoint is the value of a datapoint
+	require.Equal(t, dp(10, 21.5), parsePayload([]byte(`21.5`), 10))
+	require.Equal(t, dp(10, "on"), parsePayload([]byte(`on`), 10))
+	require.Equal(t, dp(10, map[string]interface{}{"d": 1.0}), parsePayload([]byte(`{"d":1}`), 10))
+	require.Equal(t, dp(10, []interface{}{1.0, 2.0}), parsePayload([]byte(`[1,2]`), 10))
+	require.Equal(t, dp(10, []interface{}{}), parsePayload([]byte(`[]`), 10))
+}
@@ -0,0 +1,83 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+
+Package mqtt is a minimal MQTT 3.1.1 listener, which lets devices that speak MQTT insert into their streams,
+and subscribe to streams and their downlinks. It is not a general purpose broker: topics are stream paths,
+messages are only delivered with QoS 0, and retained messages, wills and persistent sessions are not supported.
+**/
+package mqtt
+
+import (
+	"config"
+	"connectordb"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Server accepts the connections of MQTT clients
+type Server struct {
+	listener net.Listener
+	c        *config.MQTT
+	db       *connectordb.Database
+
+	// The connections that are currently open, so that they can be closed with the server
+	lock  sync.Mutex
+	conns map[*Conn]bool
+}
+
+// Listen listens on the configured address. The TLS config is used if the configuration enables TLS.
+func Listen(c *config.MQTT, tlsConfig *tls.Config, db *connectordb.Database) (*Server, error) {
+	address := fmt.Sprintf("%s:%d", c.Hostname, c.Port)
+	var listener net.Listener
+	var err error
+	if c.TLS {
+		listener, err = tls.Listen("tcp", address, tlsConfig)
+	} else {
+		listener, err = net.Listen("tcp", address)
+	}
+	if err != nil {
+		return nil, err
+	}
+	tlsString := ""
+	if c.TLS {
+		tlsString = " TLS"
+	}
+	log.Infof("Running MQTT listener at %s%s", address, tlsString)
+	return &Server{listener: listener, c: c, db: db, conns: make(map[*Conn]bool)}, nil
+}
+
+// Serve runs the clients that connect, until the server is closed
+func (s *Server) Serve() error {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return err
+		}
+		c := NewConn(conn, s.c, s.db)
+		s.lock.Lock()
+		s.conns[c] = true
+		s.lock.Unlock()
+		go func() {
+			c.Run()
+			s.lock.Lock()
+			delete(s.conns, c)
+			s.lock.Unlock()
+		}()
+	}
+}
+
+// Close stops accepting clients, and disconnects the clients that are connected
+func (s *Server) Close() error {
+	err := s.listener.Close()
+	s.lock.Lock()
+	for c := range s.conns {
+		c.conn.Close()
+	}
+	s.lock.Unlock()
+	return err
+}
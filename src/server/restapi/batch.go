@@ -0,0 +1,252 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package restapi
+
+import (
+	"bytes"
+	"connectordb"
+	"connectordb/authoperator"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"server/restapi/restcore"
+	"server/webcore"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+//OperationLimit is the largest number of operations that a single batch can hold
+var OperationLimit = 1000
+
+var (
+	//ErrNoOperations is returned when a batch holds no operations
+	ErrNoOperations = errors.New("The batch holds no operations")
+
+	//ErrBatchTransaction is returned when the database can't run the operations of a batch in a transaction
+	ErrBatchTransaction = errors.New("The database does not support all-or-nothing batches")
+
+	//ErrNoResponse is the error of an operation which wrote no response, such as when its handler panicked
+	ErrNoResponse = errors.New("The operation did not respond")
+
+	//ErrNotRun is the error of the operations of an all-or-nothing batch which come after an operation that failed
+	ErrNotRun = errors.New("Not run, since an earlier operation of the batch failed")
+)
+
+//BatchOperation is a single request run by a batch
+type BatchOperation struct {
+	Method string          `json:"method"` // The http method of the request. It is GET if not given.
+	Path   string          `json:"path"`   // The path of the request within the REST API, along with its query, such as crud/myuser/mydevice
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+//Batch is the body of a batch request
+type Batch struct {
+	//Atomic runs the operations all-or-nothing: the batch stops at the first operation which fails, and the
+	//changes made to users, devices and streams by the earlier operations are rolled back
+	Atomic     bool             `json:"atomic"`
+	Operations []BatchOperation `json:"operations"`
+}
+
+//BatchResult is the response to one of the operations of a batch
+type BatchResult struct {
+	Status int             `json:"status"`
+	Body   json.RawMessage `json:"body,omitempty"` // The json of the response. Responses which are not json are given as a string.
+}
+
+//BatchResponse holds the results of the operations of a batch, in the same order as the operations
+type BatchResponse struct {
+	Committed bool          `json:"committed"` // Whether the changes were kept. It is only false if an atomic batch was rolled back.
+	Results   []BatchResult `json:"results"`
+}
+
+//batchRecorder holds the response written by an operation of a batch
+type batchRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (r *batchRecorder) Header() http.Header {
+	return r.header
+}
+
+func (r *batchRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+}
+
+func (r *batchRecorder) Write(b []byte) (int, error) {
+	r.WriteHeader(http.StatusOK)
+	return r.body.Write(b)
+}
+
+//result returns the result of the operation from what it wrote
+func (r *batchRecorder) result() BatchResult {
+	if r.status == 0 {
+		return errorResult(http.StatusInternalServerError, ErrNoResponse)
+	}
+	res := BatchResult{Status: r.status}
+	if r.body.Len() == 0 {
+		return res
+	}
+	if strings.HasPrefix(r.header.Get("Content-Type"), "application/json") {
+		res.Body = r.body.Bytes()
+	} else {
+		res.Body, _ = json.Marshal(r.body.String())
+	}
+	return res
+}
+
+//errorResult returns the result of an operation which failed before it could be run
+func errorResult(status int, err error) BatchResult {
+	res, _ := json.Marshal(restcore.ErrorResponse{Code: status, Message: err.Error()})
+	return BatchResult{Status: status, Body: res}
+}
+
+//batchPath returns the path of the operation's request within the REST API, which is mounted at base.
+//Only the crud part of the API can be used from a batch.
+func batchPath(base string, op BatchOperation) (*url.URL, error) {
+	u, err := url.Parse(op.Path)
+	if err != nil {
+		return nil, err
+	}
+	p := path.Clean("/" + u.Path)
+	if p != "/crud" && !strings.HasPrefix(p, "/crud/") {
+		return nil, fmt.Errorf("Batches can only run crud operations, not '%s'", op.Path)
+	}
+	if strings.HasSuffix(u.Path, "/") {
+		//Cleaning removes the trailing slash, which is part of some routes, such as the list of users
+		p += "/"
+	}
+	return &url.URL{Path: base + p, RawQuery: u.RawQuery}, nil
+}
+
+//undoableDeletes are the queries of the deletes which only remove rows of the user database. Other deletes, such as
+//of users, groups, devices and streams, also delete the data of streams.
+var undoableDeletes = map[string]bool{
+	"grants":   true,
+	"webhooks": true,
+	"tokens":   true,
+	"members":  true,
+	"totp":     true,
+}
+
+//undoable returns whether the changes made by the operation are undone by rolling back the transaction of the user
+//database. Inserting data, and deleting users, devices and streams along with their data, can't be undone.
+func undoable(method string, u *url.URL) bool {
+	switch method {
+	case "GET", "HEAD":
+		return true
+	case "DELETE":
+		return undoableDeletes[u.Query().Get("q")]
+	}
+	return !strings.HasSuffix(u.Path, "/data")
+}
+
+//RunBatch returns the handler of batch requests, which runs each operation of the batch through the api router
+//as the device that sent the batch
+func RunBatch(api *mux.Router) webcore.APIHandler {
+	return func(o *authoperator.AuthOperator, writer http.ResponseWriter, request *http.Request, logger *log.Entry) (int, string) {
+		var b Batch
+		if err := restcore.UnmarshalRequest(request, &b); err != nil {
+			return restcore.WriteError(writer, logger, http.StatusBadRequest, err, false)
+		}
+		if len(b.Operations) == 0 {
+			return restcore.WriteError(writer, logger, http.StatusBadRequest, ErrNoOperations, false)
+		}
+		if len(b.Operations) > OperationLimit {
+			return restcore.WriteError(writer, logger, http.StatusBadRequest, fmt.Errorf("A batch can hold at most %d operations", OperationLimit), false)
+		}
+
+		//The API is mounted where the batch is, so "/api/v1/batch" runs operations on "/api/v1/crud"
+		base := strings.TrimSuffix(path.Dir(request.URL.Path), "/")
+
+		urls := make([]*url.URL, len(b.Operations))
+		for i, op := range b.Operations {
+			op.Method = strings.ToUpper(op.Method)
+			if op.Method == "" {
+				op.Method = "GET"
+			}
+			b.Operations[i].Method = op.Method
+
+			u, err := batchPath(base, op)
+			if err != nil {
+				return restcore.WriteError(writer, logger, http.StatusBadRequest, fmt.Errorf("Operation %d: %s", i, err.Error()), false)
+			}
+			if b.Atomic && !undoable(op.Method, u) {
+				return restcore.WriteError(writer, logger, http.StatusBadRequest, fmt.Errorf("Operation %d: %s %s can't be undone, so it can't be part of an all-or-nothing batch", i, op.Method, op.Path), false)
+			}
+			urls[i] = u
+		}
+
+		var tx *connectordb.Database
+		if b.Atomic {
+			db, ok := o.AdminOperator().(*connectordb.Database)
+			if !ok {
+				return restcore.WriteError(writer, logger, http.StatusInternalServerError, ErrBatchTransaction, true)
+			}
+			u, err := o.User()
+			if err != nil {
+				return restcore.WriteError(writer, logger, http.StatusInternalServerError, err, true)
+			}
+			tx, err = db.Begin()
+			if err != nil {
+				return restcore.WriteError(writer, logger, http.StatusInternalServerError, err, true)
+			}
+			//Rolling back a transaction which was already committed does nothing, so this only matters on a panic
+			defer tx.Rollback()
+
+			//Like the database that the device logged in to, the transaction writes the changes to the user's meta log
+			ml, err := connectordb.AddMetaLog(u.UserID, tx)
+			if err != nil {
+				return restcore.WriteError(writer, logger, http.StatusInternalServerError, err, true)
+			}
+			o = o.WithOperator(ml)
+		}
+
+		res := BatchResponse{Committed: true, Results: make([]BatchResult, len(b.Operations))}
+		failed := false
+		for i, op := range b.Operations {
+			if failed {
+				res.Results[i] = errorResult(http.StatusFailedDependency, ErrNotRun)
+				continue
+			}
+			sub, err := http.NewRequest(op.Method, urls[i].String(), bytes.NewReader(op.Body))
+			if err != nil {
+				res.Results[i] = errorResult(http.StatusBadRequest, err)
+			} else {
+				sub.RemoteAddr = request.RemoteAddr
+				sub.Header.Set("X-Real-IP", request.Header.Get("X-Real-IP"))
+				rec := &batchRecorder{header: make(http.Header)}
+				api.ServeHTTP(rec, restcore.WithOperator(sub.WithContext(request.Context()), o))
+				res.Results[i] = rec.result()
+			}
+			failed = b.Atomic && res.Results[i].Status >= http.StatusMultipleChoices
+		}
+
+		if tx != nil {
+			var err error
+			if failed {
+				res.Committed = false
+				err = tx.Rollback()
+			} else {
+				err = tx.Commit()
+			}
+			if err != nil {
+				return restcore.WriteError(writer, logger, http.StatusInternalServerError, err, true)
+			}
+		}
+
+		restcore.JSONWriter(writer, res, logger, nil)
+		return webcore.INFO, fmt.Sprintf("%d operations", len(b.Operations))
+	}
+}
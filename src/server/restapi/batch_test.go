@@ -0,0 +1,135 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package restapi
+
+import (
+	"bytes"
+	"connectordb/users"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+func runBatch(t *testing.T, api *mux.Router, b Batch) (int, *BatchResponse) {
+	body, err := json.Marshal(b)
+	require.NoError(t, err)
+	request, err := http.NewRequest("POST", "/api/v1/batch", bytes.NewReader(body))
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	RunBatch(api)(nil, rec, request, log.WithField("test", "batch"))
+	if rec.Code != http.StatusOK {
+		return rec.Code, nil
+	}
+	var res BatchResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	return rec.Code, &res
+}
+
+func TestBatch(t *testing.T) {
+	api := mux.NewRouter()
+	api.HandleFunc("/api/v1/crud/{user}", func(writer http.ResponseWriter, request *http.Request) {
+		writer.Header().Set("Content-Type", "application/json; charset=utf-8")
+		writer.Write([]byte(`{"name":"` + mux.Vars(request)["user"] + `"}`))
+	}).Methods("GET")
+	api.HandleFunc("/api/v1/crud/{user}", func(writer http.ResponseWriter, request *http.Request) {
+		writer.WriteHeader(http.StatusForbidden)
+		writer.Write([]byte("no"))
+	}).Methods("DELETE")
+
+	code, res := runBatch(t, api, Batch{Operations: []BatchOperation{
+		{Path: "crud/myuser"},
+		{Method: "delete", Path: "/crud/myuser"},
+		{Method: "GET", Path: "crud/../crud/otheruser"},
+	}})
+	require.Equal(t, http.StatusOK, code)
+	require.True(t, res.Committed)
+	require.Len(t, res.Results, 3)
+	require.Equal(t, http.StatusOK, res.Results[0].Status)
+	require.JSONEq(t, `{"name":"myuser"}`, string(res.Results[0].Body))
+	require.Equal(t, http.StatusForbidden, res.Results[1].Status)
+	require.JSONEq(t, `"no"`, string(res.Results[1].Body))
+	require.JSONEq(t, `{"name":"otheruser"}`, string(res.Results[2].Body))
+
+	//Only crud operations can be run
+	code, _ = runBatch(t, api, Batch{Operations: []BatchOperation{{Path: "crud/../batch"}}})
+	require.Equal(t, http.StatusBadRequest, code)
+	code, _ = runBatch(t, api, Batch{})
+	require.Equal(t, http.StatusBadRequest, code)
+
+	//Operations which can't be undone are refused before anything is run in all-or-nothing batches
+	code, _ = runBatch(t, api, Batch{Atomic: true, Operations: []BatchOperation{
+		{Method: "POST", Path: "crud/myuser/mydevice"},
+		{Method: "POST", Path: "crud/myuser/mydevice/mystream/data", Body: json.RawMessage(`[{"t":1,"d":1}]`)},
+	}})
+	require.Equal(t, http.StatusBadRequest, code)
+	code, _ = runBatch(t, api, Batch{Atomic: true, Operations: []BatchOperation{{Method: "DELETE", Path: "crud/myuser"}}})
+	require.Equal(t, http.StatusBadRequest, code)
+}
+
+func runAPIBatch(t *testing.T, api *mux.Router, b Batch) *BatchResponse {
+	body, err := json.Marshal(b)
+	require.NoError(t, err)
+	request, err := http.NewRequest("POST", "/batch", bytes.NewReader(body))
+	require.NoError(t, err)
+	request.SetBasicAuth("batchuser", "mypass")
+
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, request)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var res BatchResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	return &res
+}
+
+func TestAtomicBatch(t *testing.T) {
+	tdb.Clear()
+	require.NoError(t, tdb.CreateUser(&users.UserMaker{User: users.User{Name: "batchuser", Email: "batch@localhost", Password: "mypass", Role: "user", Public: true}}))
+	api, err := Router(tdb, nil)
+	require.NoError(t, err)
+
+	create := []BatchOperation{
+		{Method: "POST", Path: "crud/batchuser/mydevice", Body: json.RawMessage(`{}`)},
+		{Method: "POST", Path: "crud/batchuser/mydevice/mystream", Body: json.RawMessage(`{"schema": "{\"type\": \"number\"}"}`)},
+	}
+
+	//The third operation fails, so the device and stream created before it are rolled back
+	res := runAPIBatch(t, api, Batch{Atomic: true, Operations: append(create,
+		BatchOperation{Method: "POST", Path: "crud/batchuser/mydevice/mystream", Body: json.RawMessage(`{}`)},
+		BatchOperation{Method: "GET", Path: "crud/batchuser/mydevice"},
+	)})
+	require.False(t, res.Committed)
+	require.Len(t, res.Results, 4)
+	require.Equal(t, http.StatusOK, res.Results[0].Status, string(res.Results[0].Body))
+	require.Equal(t, http.StatusOK, res.Results[1].Status, string(res.Results[1].Body))
+	require.True(t, res.Results[2].Status >= http.StatusBadRequest)
+	require.Equal(t, http.StatusFailedDependency, res.Results[3].Status)
+
+	_, err = tdb.ReadDevice("batchuser/mydevice")
+	require.Error(t, err)
+	_, err = tdb.ReadStream("batchuser/mydevice/mystream")
+	require.Error(t, err)
+
+	//A batch which succeeds is committed, and its changes are written to the meta log of the user
+	loglength, err := tdb.LengthStream("batchuser/meta/log")
+	require.NoError(t, err)
+
+	res = runAPIBatch(t, api, Batch{Atomic: true, Operations: create})
+	require.True(t, res.Committed)
+	require.Equal(t, http.StatusOK, res.Results[0].Status, string(res.Results[0].Body))
+	require.Equal(t, http.StatusOK, res.Results[1].Status, string(res.Results[1].Body))
+
+	_, err = tdb.ReadStream("batchuser/mydevice/mystream")
+	require.NoError(t, err)
+	l, err := tdb.LengthStream("batchuser/meta/log")
+	require.NoError(t, err)
+	require.Equal(t, loglength+2, l)
+}
@@ -0,0 +1,29 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package crud
+
+import (
+	"connectordb/authoperator"
+	"server/restapi/restcore"
+
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/gorilla/mux"
+)
+
+// ReadUserAudit returns the audit log of accesses to the user's data. The log can be limited to a time
+// range with t1, t2 and limit, just like a stream's data. Without a range, the entire log is returned.
+func ReadUserAudit(o *authoperator.AuthOperator, writer http.ResponseWriter, request *http.Request, logger *log.Entry) (int, string) {
+	usrname := mux.Vars(request)["user"]
+
+	t1, t2, limit, err := restcore.ParseTRange(request.URL.Query())
+	if err != nil && err != restcore.ErrCantParse {
+		return restcore.WriteError(writer, logger, http.StatusBadRequest, err, false)
+	}
+	entries, err := o.ReadUserAudit(usrname, t1, t2, limit)
+	return restcore.JSONWriter(writer, entries, logger, err)
+}
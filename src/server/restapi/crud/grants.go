@@ -0,0 +1,72 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package crud
+
+import (
+	"connectordb/authoperator"
+	"connectordb/users"
+	"errors"
+	"server/restapi/restcore"
+	"server/webcore"
+	"strconv"
+	"strings"
+
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/gorilla/mux"
+)
+
+//ListGrants lists the grants given on the user and its devices and streams
+func ListGrants(o *authoperator.AuthOperator, writer http.ResponseWriter, request *http.Request, logger *log.Entry) (int, string) {
+	usrname := mux.Vars(request)["user"]
+	g, err := o.ReadUserGrants(usrname)
+	return restcore.JSONWriter(writer, g, logger, err)
+}
+
+//CreateGrant gives the grantee in the request access to the target in the request, which must be the user or one of its devices or streams
+func CreateGrant(o *authoperator.AuthOperator, writer http.ResponseWriter, request *http.Request, logger *log.Entry) (int, string) {
+	usrname := mux.Vars(request)["user"]
+
+	var g users.Grant
+	err := restcore.UnmarshalRequest(request, &g)
+	if err != nil {
+		return restcore.WriteError(writer, logger, http.StatusBadRequest, err, false)
+	}
+	if g.Target != usrname && !strings.HasPrefix(g.Target, usrname+"/") {
+		return restcore.WriteError(writer, logger, http.StatusBadRequest, errors.New("The target of the grant must belong to "+usrname), false)
+	}
+	if err = o.CreateGrant(g.Grantee, g.Target, &g); err != nil {
+		return restcore.WriteError(writer, logger, http.StatusForbidden, err, false)
+	}
+	return ListGrants(o, writer, request, logger)
+}
+
+//DeleteGrant removes the grant with the id given in the query
+func DeleteGrant(o *authoperator.AuthOperator, writer http.ResponseWriter, request *http.Request, logger *log.Entry) (int, string) {
+	usrname := mux.Vars(request)["user"]
+
+	id, err := strconv.ParseInt(request.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		return restcore.WriteError(writer, logger, http.StatusBadRequest, errors.New("Could not parse the grant id"), false)
+	}
+	u, err := o.ReadUser(usrname)
+	if err != nil {
+		return restcore.WriteError(writer, logger, http.StatusForbidden, err, false)
+	}
+	g, err := o.ReadGrantByID(id)
+	if err == nil && g.OwnerID != u.UserID {
+		err = users.ErrGrantNotFound
+	}
+	if err == nil {
+		err = o.DeleteGrantByID(id)
+	}
+	if err != nil {
+		return restcore.WriteError(writer, logger, http.StatusForbidden, err, false)
+	}
+	restcore.OK(writer)
+	return webcore.INFO, ""
+}
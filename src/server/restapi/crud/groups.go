@@ -0,0 +1,93 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package crud
+
+import (
+	"connectordb/authoperator"
+	"connectordb/users"
+	"errors"
+	"server/restapi/restcore"
+	"server/webcore"
+
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/gorilla/mux"
+)
+
+//ListGroups lists the groups that the user is a member of, along with the user's role in each
+func ListGroups(o *authoperator.AuthOperator, writer http.ResponseWriter, request *http.Request, logger *log.Entry) (int, string) {
+	usrname := mux.Vars(request)["user"]
+	g, err := o.ReadUserGroups(usrname)
+	return restcore.JSONWriter(writer, g, logger, err)
+}
+
+//CreateGroup creates a group with the name in the path, owned by the logged in user
+func CreateGroup(o *authoperator.AuthOperator, writer http.ResponseWriter, request *http.Request, logger *log.Entry) (int, string) {
+	groupname := mux.Vars(request)["user"]
+	if err := restcore.ValidName(groupname, nil); err != nil {
+		return restcore.WriteError(writer, logger, http.StatusBadRequest, err, false)
+	}
+	u, err := o.User()
+	if err != nil {
+		return restcore.WriteError(writer, logger, http.StatusForbidden, err, false)
+	}
+	if err = o.CreateGroup(groupname, u.Name); err != nil {
+		return restcore.WriteError(writer, logger, http.StatusForbidden, err, false)
+	}
+	return ListGroupMembers(o, writer, request, logger)
+}
+
+//DeleteGroup deletes the group in the path, along with its devices and streams
+func DeleteGroup(o *authoperator.AuthOperator, writer http.ResponseWriter, request *http.Request, logger *log.Entry) (int, string) {
+	groupname := mux.Vars(request)["user"]
+	if err := o.DeleteGroup(groupname); err != nil {
+		return restcore.WriteError(writer, logger, http.StatusForbidden, err, false)
+	}
+	restcore.OK(writer)
+	return webcore.INFO, ""
+}
+
+//ListGroupMembers lists the members of the group in the path
+func ListGroupMembers(o *authoperator.AuthOperator, writer http.ResponseWriter, request *http.Request, logger *log.Entry) (int, string) {
+	groupname := mux.Vars(request)["user"]
+	m, err := o.ReadGroupMembers(groupname)
+	return restcore.JSONWriter(writer, m, logger, err)
+}
+
+//SetGroupMember invites the user in the request to the group in the path, or changes the user's role in the group.
+//Users accept their invitations by setting their own membership.
+func SetGroupMember(o *authoperator.AuthOperator, writer http.ResponseWriter, request *http.Request, logger *log.Entry) (int, string) {
+	groupname := mux.Vars(request)["user"]
+
+	var m users.GroupMember
+	err := restcore.UnmarshalRequest(request, &m)
+	if err != nil {
+		return restcore.WriteError(writer, logger, http.StatusBadRequest, err, false)
+	}
+	if m.Role == "" {
+		m.Role = users.GroupRoleMember
+	}
+	if err = o.SetGroupMember(groupname, m.User, m.Role); err != nil {
+		return restcore.WriteError(writer, logger, http.StatusForbidden, err, false)
+	}
+	return ListGroupMembers(o, writer, request, logger)
+}
+
+//DeleteGroupMember removes the user given in the query from the group in the path
+func DeleteGroupMember(o *authoperator.AuthOperator, writer http.ResponseWriter, request *http.Request, logger *log.Entry) (int, string) {
+	groupname := mux.Vars(request)["user"]
+
+	member := request.URL.Query().Get("member")
+	if member == "" {
+		return restcore.WriteError(writer, logger, http.StatusBadRequest, errors.New("The member to remove was not given"), false)
+	}
+	if err := o.DeleteGroupMember(groupname, member); err != nil {
+		return restcore.WriteError(writer, logger, http.StatusForbidden, err, false)
+	}
+	restcore.OK(writer)
+	return webcore.INFO, ""
+}
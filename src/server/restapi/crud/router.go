@@ -6,11 +6,30 @@ package crud
 
 import (
 	"connectordb"
+	"connectordb/audit"
+	"connectordb/datastream"
+	"connectordb/users"
 	"server/restapi/restcore"
 
 	"github.com/gorilla/mux"
 )
 
+//The parameters of the routes which read the streams of a user
+var userStreamParams = []restcore.Param{
+	{Name: "public", Type: "boolean", Description: "Only list public streams"},
+	{Name: "downlink", Type: "boolean", Description: "Only list downlink streams"},
+	{Name: "visible", Type: "boolean", Description: "Only list visible streams"},
+}
+
+//The parameters of the route which reads the data of a stream
+var streamRangeParams = append(append([]restcore.Param{restcore.DownlinkParam, restcore.TransformParam, restcore.ExplainParam},
+	restcore.IRangeParams...), restcore.TRangeParams...)
+
+//idParam gives the id of the object to delete in the query
+func idParam(object string) restcore.Param {
+	return restcore.Param{Name: "id", Type: "integer", Description: "The id of the " + object, Required: true}
+}
+
 //Router returns a fully formed Gorilla router given an optional prefix
 func Router(db *connectordb.Database, prefix *mux.Router) *mux.Router {
 	if prefix == nil {
@@ -20,36 +39,97 @@ func Router(db *connectordb.Database, prefix *mux.Router) *mux.Router {
 	//Allow for the application to match /path and /path/ to the same place.
 	prefix.StrictSlash(true)
 
-	prefix.HandleFunc("/", restcore.Authenticator(ListUsers, db)).Queries("q", "ls")
+	restcore.Describe(prefix.HandleFunc("/", restcore.Authenticator(ListUsers, db)).Queries("q", "ls"),
+		restcore.Endpoint{Summary: "List the users", Response: []users.User{}})
 
 	//User CRUD
-	prefix.HandleFunc("/{user}", restcore.Authenticator(ListDevices, db)).Methods("GET").Queries("q", "ls")
-	prefix.HandleFunc("/{user}", restcore.Authenticator(ListDevices, db)).Methods("GET").Queries("q", "devices")
-	prefix.HandleFunc("/{user}", restcore.Authenticator(ListUserStreams, db)).Methods("GET").Queries("q", "streams")
-	prefix.HandleFunc("/{user}", restcore.Authenticator(ReadUser, db)).Methods("GET")
-	prefix.HandleFunc("/{user}", restcore.Authenticator(CreateUser, db)).Methods("POST")
-	prefix.HandleFunc("/{user}", restcore.Authenticator(UpdateUser, db)).Methods("PUT")
-	prefix.HandleFunc("/{user}", restcore.Authenticator(DeleteUser, db)).Methods("DELETE")
+	restcore.Describe(prefix.HandleFunc("/{user}", restcore.Authenticator(ListDevices, db)).Methods("GET").Queries("q", "ls"),
+		restcore.Endpoint{Summary: "List the devices of the user", Response: []users.Device{}})
+	restcore.Describe(prefix.HandleFunc("/{user}", restcore.Authenticator(ListDevices, db)).Methods("GET").Queries("q", "devices"),
+		restcore.Endpoint{Summary: "List the devices of the user", Response: []users.Device{}})
+	restcore.Describe(prefix.HandleFunc("/{user}", restcore.Authenticator(ListUserStreams, db)).Methods("GET").Queries("q", "streams"),
+		restcore.Endpoint{Summary: "List the streams of all the devices of the user", Params: userStreamParams, Response: []users.Stream{}})
+	restcore.Describe(prefix.HandleFunc("/{user}", restcore.Authenticator(ListGrants, db)).Methods("GET").Queries("q", "grants"),
+		restcore.Endpoint{Summary: "List the grants which the user gave to others", Response: []users.Grant{}})
+	restcore.Describe(prefix.HandleFunc("/{user}", restcore.Authenticator(CreateGrant, db)).Methods("POST").Queries("q", "grants"),
+		restcore.Endpoint{Summary: "Grant another user or device access to the data of the user, returning all of its grants", Request: users.Grant{}, Response: []users.Grant{}})
+	restcore.Describe(prefix.HandleFunc("/{user}", restcore.Authenticator(DeleteGrant, db)).Methods("DELETE").Queries("q", "grants"),
+		restcore.Endpoint{Summary: "Revoke a grant of the user", Params: []restcore.Param{idParam("grant")}})
+	restcore.Describe(prefix.HandleFunc("/{user}", restcore.Authenticator(ListWebhooks, db)).Methods("GET").Queries("q", "webhooks"),
+		restcore.Endpoint{Summary: "List the webhooks of the user", Response: []users.Webhook{}})
+	restcore.Describe(prefix.HandleFunc("/{user}", restcore.Authenticator(CreateWebhook, db)).Methods("POST").Queries("q", "webhooks"),
+		restcore.Endpoint{Summary: "Add a webhook which is called when data is inserted, returning all of the webhooks of the user", Request: users.Webhook{}, Response: []users.Webhook{}})
+	restcore.Describe(prefix.HandleFunc("/{user}", restcore.Authenticator(DeleteWebhook, db)).Methods("DELETE").Queries("q", "webhooks"),
+		restcore.Endpoint{Summary: "Remove a webhook of the user", Params: []restcore.Param{idParam("webhook")}})
+	restcore.Describe(prefix.HandleFunc("/{user}", restcore.Authenticator(ListGroups, db)).Methods("GET").Queries("q", "groups"),
+		restcore.Endpoint{Summary: "List the groups that the user is a member of", Response: []users.GroupMember{}})
+	restcore.Describe(prefix.HandleFunc("/{user}", restcore.Authenticator(CreateGroup, db)).Methods("POST").Queries("q", "group"),
+		restcore.Endpoint{Summary: "Create a group with the name in the path, owned by the logged in user", Response: []users.GroupMember{}})
+	restcore.Describe(prefix.HandleFunc("/{user}", restcore.Authenticator(DeleteGroup, db)).Methods("DELETE").Queries("q", "group"),
+		restcore.Endpoint{Summary: "Delete the group, along with its devices and streams"})
+	restcore.Describe(prefix.HandleFunc("/{user}", restcore.Authenticator(ListGroupMembers, db)).Methods("GET").Queries("q", "members"),
+		restcore.Endpoint{Summary: "List the members of the group", Response: []users.GroupMember{}})
+	restcore.Describe(prefix.HandleFunc("/{user}", restcore.Authenticator(SetGroupMember, db)).Methods("PUT").Queries("q", "members"),
+		restcore.Endpoint{Summary: "Invite a user to the group, accept an invitation to it, or change a member's role", Request: users.GroupMember{}, Response: []users.GroupMember{}})
+	restcore.Describe(prefix.HandleFunc("/{user}", restcore.Authenticator(DeleteGroupMember, db)).Methods("DELETE").Queries("q", "members"),
+		restcore.Endpoint{Summary: "Remove a user from the group", Params: []restcore.Param{{Name: "member", Type: "string", Description: "The name of the user to remove", Required: true}}})
+	restcore.Describe(prefix.HandleFunc("/{user}", restcore.Authenticator(ReadUserAudit, db)).Methods("GET").Queries("q", "audit"),
+		restcore.Endpoint{Summary: "Read the log of accesses to the user's data, optionally limited to a time range", Params: restcore.TRangeParams, Response: []audit.Entry{}})
+	restcore.Describe(prefix.HandleFunc("/{user}", restcore.Authenticator(EnrollTOTP, db)).Methods("POST").Queries("q", "totp"),
+		restcore.Endpoint{Summary: "Start setting up two-factor authentication, returning the secret to add to an authenticator app", Response: TOTPEnrollment{}})
+	restcore.Describe(prefix.HandleFunc("/{user}", restcore.Authenticator(ConfirmTOTP, db)).Methods("PUT").Queries("q", "totp"),
+		restcore.Endpoint{Summary: "Enable two-factor authentication with a code from the authenticator app, returning the recovery codes", Request: TOTPConfirmation{}, Response: []string{}})
+	restcore.Describe(prefix.HandleFunc("/{user}", restcore.Authenticator(ResetTOTP, db)).Methods("DELETE").Queries("q", "totp"),
+		restcore.Endpoint{Summary: "Disable two-factor authentication"})
+	restcore.Describe(prefix.HandleFunc("/{user}", restcore.Authenticator(ReadUser, db)).Methods("GET"),
+		restcore.Endpoint{Summary: "Read the user", Response: users.User{}})
+	restcore.Describe(prefix.HandleFunc("/{user}", restcore.Authenticator(CreateUser, db)).Methods("POST"),
+		restcore.Endpoint{Summary: "Create the user, along with the devices and streams in the request", Request: users.UserMaker{}, Response: users.User{}})
+	restcore.Describe(prefix.HandleFunc("/{user}", restcore.Authenticator(UpdateUser, db)).Methods("PUT"),
+		restcore.Endpoint{Summary: "Update the fields of the user given in the request", Request: users.User{}, Response: users.User{}})
+	restcore.Describe(prefix.HandleFunc("/{user}", restcore.Authenticator(DeleteUser, db)).Methods("DELETE"),
+		restcore.Endpoint{Summary: "Delete the user, along with all of its devices and streams"})
 
 	//Device CRUD
-	prefix.HandleFunc("/{user}/{device}", restcore.Authenticator(ListStreams, db)).Methods("GET").Queries("q", "ls")
-	prefix.HandleFunc("/{user}/{device}", restcore.Authenticator(ListStreams, db)).Methods("GET").Queries("q", "streams")
-	prefix.HandleFunc("/{user}/{device}", restcore.Authenticator(ReadDevice, db)).Methods("GET")
-	prefix.HandleFunc("/{user}/{device}", restcore.Authenticator(CreateDevice, db)).Methods("POST")
-	prefix.HandleFunc("/{user}/{device}", restcore.Authenticator(UpdateDevice, db)).Methods("PUT")
-	prefix.HandleFunc("/{user}/{device}", restcore.Authenticator(DeleteDevice, db)).Methods("DELETE")
+	restcore.Describe(prefix.HandleFunc("/{user}/{device}", restcore.Authenticator(ListStreams, db)).Methods("GET").Queries("q", "ls"),
+		restcore.Endpoint{Summary: "List the streams of the device", Response: []users.Stream{}})
+	restcore.Describe(prefix.HandleFunc("/{user}/{device}", restcore.Authenticator(ListStreams, db)).Methods("GET").Queries("q", "streams"),
+		restcore.Endpoint{Summary: "List the streams of the device", Response: []users.Stream{}})
+	restcore.Describe(prefix.HandleFunc("/{user}/{device}", restcore.Authenticator(ListTokens, db)).Methods("GET").Queries("q", "tokens"),
+		restcore.Endpoint{Summary: "List the access tokens of the device", Response: []users.Token{}})
+	restcore.Describe(prefix.HandleFunc("/{user}/{device}", restcore.Authenticator(CreateToken, db)).Methods("POST").Queries("q", "tokens"),
+		restcore.Endpoint{Summary: "Create an access token which logs in as the device", Request: users.Token{}, Response: users.Token{}})
+	restcore.Describe(prefix.HandleFunc("/{user}/{device}", restcore.Authenticator(DeleteToken, db)).Methods("DELETE").Queries("q", "tokens"),
+		restcore.Endpoint{Summary: "Revoke an access token of the device", Params: []restcore.Param{idParam("token")}})
+	restcore.Describe(prefix.HandleFunc("/{user}/{device}", restcore.Authenticator(ReadDevice, db)).Methods("GET"),
+		restcore.Endpoint{Summary: "Read the device", Response: users.Device{}})
+	restcore.Describe(prefix.HandleFunc("/{user}/{device}", restcore.Authenticator(CreateDevice, db)).Methods("POST"),
+		restcore.Endpoint{Summary: "Create the device, along with the streams in the request", Request: users.DeviceMaker{}, Response: users.Device{}})
+	restcore.Describe(prefix.HandleFunc("/{user}/{device}", restcore.Authenticator(UpdateDevice, db)).Methods("PUT"),
+		restcore.Endpoint{Summary: "Update the fields of the device given in the request", Request: users.Device{}, Response: users.Device{}})
+	restcore.Describe(prefix.HandleFunc("/{user}/{device}", restcore.Authenticator(DeleteDevice, db)).Methods("DELETE"),
+		restcore.Endpoint{Summary: "Delete the device, along with all of its streams"})
 
 	//Stream CRUD
-	prefix.HandleFunc("/{user}/{device}/{stream}", restcore.Authenticator(ReadStream, db)).Methods("GET")
-	prefix.HandleFunc("/{user}/{device}/{stream}", restcore.Authenticator(CreateStream, db)).Methods("POST")
-	prefix.HandleFunc("/{user}/{device}/{stream}", restcore.Authenticator(UpdateStream, db)).Methods("PUT")
-	prefix.HandleFunc("/{user}/{device}/{stream}", restcore.Authenticator(DeleteStream, db)).Methods("DELETE")
-
-	prefix.HandleFunc("/{user}/{device}/{stream}/data", restcore.Authenticator(StreamLength, db)).Methods("GET").Queries("q", "length")
-	prefix.HandleFunc("/{user}/{device}/{stream}/data", restcore.Authenticator(StreamTime2Index, db)).Methods("GET").Queries("q", "time2index")
-	prefix.HandleFunc("/{user}/{device}/{stream}/data", restcore.Authenticator(StreamRange, db)).Methods("GET")
-	prefix.HandleFunc("/{user}/{device}/{stream}/data", restcore.Authenticator(WriteStream, db)).Methods("POST") //Restamp off
-	prefix.HandleFunc("/{user}/{device}/{stream}/data", restcore.Authenticator(WriteStream, db)).Methods("PUT")  //Restamp on
+	restcore.Describe(prefix.HandleFunc("/{user}/{device}/{stream}", restcore.Authenticator(ReadStream, db)).Methods("GET"),
+		restcore.Endpoint{Summary: "Read the stream", Params: []restcore.Param{restcore.DownlinkParam}, Response: users.Stream{}})
+	restcore.Describe(prefix.HandleFunc("/{user}/{device}/{stream}", restcore.Authenticator(CreateStream, db)).Methods("POST"),
+		restcore.Endpoint{Summary: "Create the stream", Request: users.StreamMaker{}, Response: users.Stream{}})
+	restcore.Describe(prefix.HandleFunc("/{user}/{device}/{stream}", restcore.Authenticator(UpdateStream, db)).Methods("PUT"),
+		restcore.Endpoint{Summary: "Update the fields of the stream given in the request", Params: []restcore.Param{restcore.DownlinkParam}, Request: users.Stream{}, Response: users.Stream{}})
+	restcore.Describe(prefix.HandleFunc("/{user}/{device}/{stream}", restcore.Authenticator(DeleteStream, db)).Methods("DELETE"),
+		restcore.Endpoint{Summary: "Delete the stream and all of its data", Params: []restcore.Param{restcore.DownlinkParam}})
+
+	restcore.Describe(prefix.HandleFunc("/{user}/{device}/{stream}/data", restcore.Authenticator(StreamLength, db)).Methods("GET").Queries("q", "length"),
+		restcore.Endpoint{Summary: "Get the number of datapoints in the stream", Params: []restcore.Param{restcore.DownlinkParam}, Response: int64(0)})
+	restcore.Describe(prefix.HandleFunc("/{user}/{device}/{stream}/data", restcore.Authenticator(StreamTime2Index, db)).Methods("GET").Queries("q", "time2index"),
+		restcore.Endpoint{Summary: "Get the index of the first datapoint at or after the time t", Params: []restcore.Param{restcore.DownlinkParam, {Name: "t", Type: "number", Description: "The unix time to find", Required: true}}, Response: int64(0)})
+	restcore.Describe(prefix.HandleFunc("/{user}/{device}/{stream}/data", restcore.Authenticator(StreamRange, db)).Methods("GET"),
+		restcore.Endpoint{Summary: "Read a range of the datapoints of the stream, given either by index with i1 and i2, or by time with t1, t2 and limit", Params: streamRangeParams, Response: datastream.DatapointArray{}})
+	restcore.Describe(prefix.HandleFunc("/{user}/{device}/{stream}/data", restcore.Authenticator(WriteStream, db)).Methods("POST"),
+		restcore.Endpoint{Summary: "Insert the datapoints. Their timestamps must be after the end of the stream.", Params: []restcore.Param{restcore.DownlinkParam}, Request: datastream.DatapointArray{}})
+	restcore.Describe(prefix.HandleFunc("/{user}/{device}/{stream}/data", restcore.Authenticator(WriteStream, db)).Methods("PUT"),
+		restcore.Endpoint{Summary: "Insert the datapoints, changing the timestamps which are before the end of the stream to its end", Params: []restcore.Param{restcore.DownlinkParam}, Request: datastream.DatapointArray{}})
 
 	return prefix
 }
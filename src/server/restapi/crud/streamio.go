@@ -77,13 +77,23 @@ func StreamRange(o *authoperator.AuthOperator, writer http.ResponseWriter, reque
 	q := request.URL.Query()
 	transform := q.Get("transform")
 
+	qo, err := restcore.QueryOperator(o, request)
+	if err != nil {
+		return restcore.WriteError(writer, logger, http.StatusInternalServerError, err, true)
+	}
+	defer qo.Close()
+
 	i1, i2, err := restcore.ParseIRange(q)
 	if err == nil {
 		querylog := fmt.Sprintf("irange [%d,%d)", i1, i2)
-		dr, err := o.GetStreamIndexRange(streampath, i1, i2, transform)
+		start := time.Now()
+		dr, err := qo.GetStreamIndexRange(streampath, i1, i2, transform)
 		if err == nil {
 			defer dr.Close()
 		}
+		if restcore.IsExplain(request) {
+			return restcore.WriteExplainResult(writer, dr, time.Since(start), logger, err)
+		}
 		lvl, _ := restcore.WriteJSONResult(writer, dr, logger, err)
 		return lvl, querylog
 	} else if err != restcore.ErrCantParse {
@@ -95,10 +105,14 @@ func StreamRange(o *authoperator.AuthOperator, writer http.ResponseWriter, reque
 	t1, t2, lim, err := restcore.ParseTRange(q)
 	if err == nil {
 		querylog := fmt.Sprintf("trange [%.1f,%.1f) limit=%d", t1, t2, lim)
-		dr, err := o.GetStreamTimeRange(streampath, t1, t2, lim, transform)
+		start := time.Now()
+		dr, err := qo.GetStreamTimeRange(streampath, t1, t2, lim, transform)
 		if err == nil {
 			defer dr.Close()
 		}
+		if restcore.IsExplain(request) {
+			return restcore.WriteExplainResult(writer, dr, time.Since(start), logger, err)
+		}
 		lvl, _ := restcore.WriteJSONResult(writer, dr, logger, err)
 		return lvl, querylog
 	}
@@ -0,0 +1,67 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package crud
+
+import (
+	"connectordb/authoperator"
+	"connectordb/users"
+	"errors"
+	"server/restapi/restcore"
+	"server/webcore"
+	"strconv"
+
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+//ListTokens lists the tokens of the device
+func ListTokens(o *authoperator.AuthOperator, writer http.ResponseWriter, request *http.Request, logger *log.Entry) (int, string) {
+	_, _, devpath := getDevicePath(request)
+	t, err := o.ReadDeviceTokens(devpath)
+	return restcore.JSONWriter(writer, t, logger, err)
+}
+
+//CreateToken creates a new token for the device. The new token is returned, including its key.
+func CreateToken(o *authoperator.AuthOperator, writer http.ResponseWriter, request *http.Request, logger *log.Entry) (int, string) {
+	_, _, devpath := getDevicePath(request)
+
+	var t users.Token
+	err := restcore.UnmarshalRequest(request, &t)
+	if err != nil {
+		return restcore.WriteError(writer, logger, http.StatusBadRequest, err, false)
+	}
+	t.Key = ""
+	if err = o.CreateToken(devpath, &t); err != nil {
+		return restcore.WriteError(writer, logger, http.StatusForbidden, err, false)
+	}
+	return restcore.JSONWriter(writer, &t, logger, nil)
+}
+
+//DeleteToken revokes the token with the id given in the query
+func DeleteToken(o *authoperator.AuthOperator, writer http.ResponseWriter, request *http.Request, logger *log.Entry) (int, string) {
+	_, _, devpath := getDevicePath(request)
+
+	id, err := strconv.ParseInt(request.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		return restcore.WriteError(writer, logger, http.StatusBadRequest, errors.New("Could not parse the token id"), false)
+	}
+	dev, err := o.ReadDevice(devpath)
+	if err != nil {
+		return restcore.WriteError(writer, logger, http.StatusForbidden, err, false)
+	}
+	t, err := o.ReadTokenByID(id)
+	if err == nil && t.DeviceID != dev.DeviceID {
+		err = users.ErrTokenNotFound
+	}
+	if err == nil {
+		err = o.DeleteTokenByID(id)
+	}
+	if err != nil {
+		return restcore.WriteError(writer, logger, http.StatusForbidden, err, false)
+	}
+	restcore.OK(writer)
+	return webcore.INFO, ""
+}
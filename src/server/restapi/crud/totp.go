@@ -0,0 +1,64 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package crud
+
+import (
+	"connectordb/authoperator"
+	"connectordb/users"
+	"net/http"
+	"server/restapi/restcore"
+	"server/webcore"
+
+	"github.com/gorilla/mux"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// TOTPEnrollment is returned when starting two-factor enrollment. The uri can be shown as a QR code for authenticator apps.
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// TOTPConfirmation is sent with a code from the authenticator app to enable two-factor authentication
+type TOTPConfirmation struct {
+	Code string `json:"code"`
+}
+
+//EnrollTOTP starts two-factor enrollment for the user, returning the new secret
+func EnrollTOTP(o *authoperator.AuthOperator, writer http.ResponseWriter, request *http.Request, logger *log.Entry) (int, string) {
+	usrname := mux.Vars(request)["user"]
+	secret, err := o.EnrollTOTP(usrname)
+	if err != nil {
+		return restcore.WriteError(writer, logger, http.StatusForbidden, err, false)
+	}
+	return restcore.JSONWriter(writer, &TOTPEnrollment{secret, users.TOTPURI("ConnectorDB", usrname, secret)}, logger, nil)
+}
+
+//ConfirmTOTP enables two-factor authentication for the user, returning the recovery codes
+func ConfirmTOTP(o *authoperator.AuthOperator, writer http.ResponseWriter, request *http.Request, logger *log.Entry) (int, string) {
+	usrname := mux.Vars(request)["user"]
+
+	var c TOTPConfirmation
+	err := restcore.UnmarshalRequest(request, &c)
+	if err != nil {
+		return restcore.WriteError(writer, logger, http.StatusBadRequest, err, false)
+	}
+	codes, err := o.ConfirmTOTP(usrname, c.Code)
+	if err != nil {
+		return restcore.WriteError(writer, logger, http.StatusForbidden, err, false)
+	}
+	return restcore.JSONWriter(writer, codes, logger, nil)
+}
+
+//ResetTOTP removes two-factor authentication from the user
+func ResetTOTP(o *authoperator.AuthOperator, writer http.ResponseWriter, request *http.Request, logger *log.Entry) (int, string) {
+	usrname := mux.Vars(request)["user"]
+	if err := o.ResetTOTP(usrname); err != nil {
+		return restcore.WriteError(writer, logger, http.StatusForbidden, err, false)
+	}
+	restcore.OK(writer)
+	return webcore.INFO, ""
+}
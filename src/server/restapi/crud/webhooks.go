@@ -0,0 +1,72 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package crud
+
+import (
+	"connectordb/authoperator"
+	"connectordb/users"
+	"errors"
+	"server/restapi/restcore"
+	"server/webcore"
+	"strconv"
+	"strings"
+
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/gorilla/mux"
+)
+
+//ListWebhooks lists the webhooks on the user's devices and streams
+func ListWebhooks(o *authoperator.AuthOperator, writer http.ResponseWriter, request *http.Request, logger *log.Entry) (int, string) {
+	usrname := mux.Vars(request)["user"]
+	w, err := o.ReadUserWebhooks(usrname)
+	return restcore.JSONWriter(writer, w, logger, err)
+}
+
+//CreateWebhook adds the webhook in the request on its target, which must be one of the user's devices or streams
+func CreateWebhook(o *authoperator.AuthOperator, writer http.ResponseWriter, request *http.Request, logger *log.Entry) (int, string) {
+	usrname := mux.Vars(request)["user"]
+
+	var w users.Webhook
+	err := restcore.UnmarshalRequest(request, &w)
+	if err != nil {
+		return restcore.WriteError(writer, logger, http.StatusBadRequest, err, false)
+	}
+	if !strings.HasPrefix(w.Target, usrname+"/") {
+		return restcore.WriteError(writer, logger, http.StatusBadRequest, errors.New("The target of the webhook must belong to "+usrname), false)
+	}
+	if err = o.CreateWebhook(w.Target, &w); err != nil {
+		return restcore.WriteError(writer, logger, http.StatusForbidden, err, false)
+	}
+	return ListWebhooks(o, writer, request, logger)
+}
+
+//DeleteWebhook removes the webhook with the id given in the query
+func DeleteWebhook(o *authoperator.AuthOperator, writer http.ResponseWriter, request *http.Request, logger *log.Entry) (int, string) {
+	usrname := mux.Vars(request)["user"]
+
+	id, err := strconv.ParseInt(request.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		return restcore.WriteError(writer, logger, http.StatusBadRequest, errors.New("Could not parse the webhook id"), false)
+	}
+	u, err := o.ReadUser(usrname)
+	if err != nil {
+		return restcore.WriteError(writer, logger, http.StatusForbidden, err, false)
+	}
+	w, err := o.ReadWebhookByID(id)
+	if err == nil && w.UserID != u.UserID {
+		err = users.ErrWebhookNotFound
+	}
+	if err == nil {
+		err = o.DeleteWebhookByID(id)
+	}
+	if err != nil {
+		return restcore.WriteError(writer, logger, http.StatusForbidden, err, false)
+	}
+	restcore.OK(writer)
+	return webcore.INFO, ""
+}
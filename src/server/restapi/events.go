@@ -0,0 +1,406 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package restapi
+
+import (
+	"config"
+	"connectordb"
+	"connectordb/authoperator"
+	"connectordb/messenger"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"server/restapi/restcore"
+	"server/webcore"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"util"
+
+	"github.com/connectordb/pipescript"
+	"github.com/gorilla/mux"
+	"github.com/nats-io/nats"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+var (
+	//ErrStreamingUnsupported is returned when the response can't be flushed, so events can't be sent as they happen
+	ErrStreamingUnsupported = errors.New("The connection does not support streaming events")
+
+	//ErrChangesUnsupported is returned when the database can't tell an event stream that its streams changed
+	ErrChangesUnsupported = errors.New("The database does not support watching for new streams")
+
+	//eventStreamWaitGroup is the WaitGroup of event streams that are currently open
+	eventStreamWaitGroup = sync.WaitGroup{}
+)
+
+//eventSource is a stream that an event stream is subscribed to
+type eventSource struct {
+	nats *nats.Subscription
+	tf   *pipescript.Script //Each stream gets its own transform, since transforms keep state between datapoints
+
+	//The index of the next datapoint to read from the database. The messages of the stream are only used as
+	//a trigger to read its new data, so that the event ids can be used to resume without gaps or duplicates.
+	//Ephemeral streams don't save their data, so they have an index of -1, and their messages are sent as they are.
+	index int64
+}
+
+//EventStream sends the data of a stream, or of all the streams of a device or user, as server-sent events.
+//It is an alternative to subscribing through a websocket for clients which can't use websockets.
+//Each event holds a message in the same format as the websocket's messages, and its id holds the index
+//of the next datapoint of each stream. A client which reconnects with the Last-Event-ID header gets the data
+//that it missed before the live data.
+type EventStream struct {
+	path      string
+	transform string
+
+	c       chan messenger.Message
+	sources map[string]*eventSource
+
+	//The meta log of the path's user, which tells when the streams of a device or user need to be listed again
+	changes chan messenger.Message
+	meta    *nats.Subscription
+
+	writer  http.ResponseWriter
+	flusher http.Flusher
+
+	logger *log.Entry
+	o      *authoperator.AuthOperator
+}
+
+//NewEventStream subscribes to the streams of the given path, starting at the indices of the given last event id
+func NewEventStream(o *authoperator.AuthOperator, writer http.ResponseWriter, path, transform, lastEventID string, logger *log.Entry) (*EventStream, error) {
+	flusher, ok := writer.(http.Flusher)
+	if !ok {
+		return nil, ErrStreamingUnsupported
+	}
+	if transform != "" {
+		//Make sure that the transform is valid before subscribing
+		if _, err := pipescript.Parse(transform); err != nil {
+			return nil, err
+		}
+	}
+	cursors, err := parseEventID(lastEventID)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &EventStream{
+		path:      path,
+		transform: transform,
+		c:         make(chan messenger.Message, config.Get().Websocket.MessageBuffer),
+		sources:   make(map[string]*eventSource),
+		changes:   make(chan messenger.Message, config.Get().Websocket.MessageBuffer),
+		writer:    writer,
+		flusher:   flusher,
+		logger:    logger,
+		o:         o,
+	}
+	if strings.Count(path, "/") < 2 {
+		//Watching for changes before listing the streams makes sure that no new stream is missed
+		if err = e.watchChanges(); err != nil {
+			e.Close()
+			return nil, err
+		}
+	}
+	if err = e.subscribe(cursors); err != nil {
+		e.Close()
+		return nil, err
+	}
+	return e, nil
+}
+
+//watchChanges subscribes to the meta log of the path's user, which gets a datapoint each time that one of its devices
+//or streams is created or deleted, so that the streams of a device or user are only listed again when they change
+func (e *EventStream) watchChanges() (err error) {
+	db, ok := e.o.AdminOperator().(*connectordb.Database)
+	if !ok {
+		return ErrChangesUnsupported
+	}
+	e.meta, err = db.SubscribeMetaLog(strings.SplitN(e.path, "/", 2)[0], e.changes)
+	return err
+}
+
+//changedStreams returns whether the meta log message changed the devices or streams of the event stream's path.
+//The streams that were created are returned with an index of 0, so that all of their data is sent.
+func (e *EventStream) changedStreams(msg messenger.Message) (map[string]int64, bool) {
+	cursors := make(map[string]int64)
+	changed := false
+	for _, dp := range msg.Data {
+		d, ok := dp.Data.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		arg, _ := d["arg"].(string)
+		if arg != e.path && !strings.HasPrefix(arg, e.path+"/") {
+			continue
+		}
+		changed = true
+		if d["cmd"] == "CreateStream" {
+			cursors[arg] = 0
+		}
+	}
+	return cursors, changed
+}
+
+//parseEventID returns the index of each stream given in an event id
+func parseEventID(id string) (map[string]int64, error) {
+	cursors := make(map[string]int64)
+	v, err := url.ParseQuery(id)
+	if err != nil {
+		return nil, err
+	}
+	for stream := range v {
+		index, err := strconv.ParseInt(v.Get(stream), 10, 64)
+		if err != nil || index < 0 {
+			return nil, fmt.Errorf("Invalid event id '%s'", id)
+		}
+		cursors[stream] = index
+	}
+	return cursors, nil
+}
+
+//eventID returns the id of the current position in each of the streams
+func (e *EventStream) eventID() string {
+	v := url.Values{}
+	for stream, s := range e.sources {
+		if s.index >= 0 {
+			v.Set(stream, strconv.FormatInt(s.index, 10))
+		}
+	}
+	return v.Encode()
+}
+
+//streams returns the paths of the streams that the event stream's path refers to
+func (e *EventStream) streams() ([]string, error) {
+	switch strings.Count(e.path, "/") {
+	case 0:
+		devs, err := e.o.ReadUserDevices(e.path)
+		if err != nil {
+			return nil, err
+		}
+		var streams []string
+		for _, d := range devs {
+			s, err := e.o.ReadDeviceStreams(e.path + "/" + d.Name)
+			if err != nil {
+				//The device might not allow listing its streams, which only means that they are skipped
+				continue
+			}
+			for _, strm := range s {
+				streams = append(streams, e.path+"/"+d.Name+"/"+strm.Name)
+			}
+		}
+		return streams, nil
+	case 1:
+		s, err := e.o.ReadDeviceStreams(e.path)
+		if err != nil {
+			return nil, err
+		}
+		streams := make([]string, 0, len(s))
+		for _, strm := range s {
+			streams = append(streams, e.path+"/"+strm.Name)
+		}
+		return streams, nil
+	}
+	return []string{e.path}, nil
+}
+
+//subscribe subscribes to the streams of the path which aren't subscribed to yet, and unsubscribes from the streams
+//which are gone. The data of each stream starts at the index given in cursors, or at the end of the stream. When
+//subscribing to a device or user, the streams which can't be subscribed to are skipped, while for a single stream,
+//the error is returned.
+func (e *EventStream) subscribe(cursors map[string]int64) error {
+	streams, err := e.streams()
+	if err != nil {
+		return err
+	}
+	single := strings.Count(e.path, "/") >= 2
+	listed := make(map[string]bool, len(streams))
+	for _, stream := range streams {
+		listed[stream] = true
+		if _, ok := e.sources[stream]; ok {
+			continue
+		}
+		s, err := e.newSource(stream, cursors)
+		if err != nil {
+			if single {
+				return err
+			}
+			e.logger.WithField("stream", stream).Debugln("Skipping stream: ", err)
+			continue
+		}
+		e.sources[stream] = s
+	}
+	for stream, s := range e.sources {
+		if !listed[stream] {
+			s.nats.Unsubscribe()
+			delete(e.sources, stream)
+		}
+	}
+	return nil
+}
+
+//newSource subscribes to the stream. Its data is read from the database only if the device can read it: a device
+//which can only subscribe to the stream, such as through a subscribe grant, gets its messages as they are sent,
+//in the same way as those of ephemeral streams, and can't resume from an event id.
+func (e *EventStream) newSource(stream string, cursors map[string]int64) (*eventSource, error) {
+	_, _, streampath, _, substream, err := util.SplitStreamPath(stream)
+	if err != nil {
+		return nil, err
+	}
+	strm, err := e.o.ReadStream(streampath)
+	if err != nil {
+		return nil, err
+	}
+	s := &eventSource{index: -1}
+	if !strm.Ephemeral && e.o.ErrorIfNoIOReadAccess(strm.StreamID, substream) == nil {
+		if index, ok := cursors[stream]; ok {
+			s.index = index
+		} else if s.index, err = e.o.LengthStream(stream); err != nil {
+			return nil, err
+		}
+	}
+	if e.transform != "" {
+		if s.tf, err = pipescript.Parse(e.transform); err != nil {
+			return nil, err
+		}
+	}
+	if s.nats, err = e.o.Subscribe(stream, e.c); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+//write sends the message as an event
+func (e *EventStream) write(msg messenger.Message) error {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if _, err = fmt.Fprintf(e.writer, "id: %s\ndata: %s\n\n", e.eventID(), b); err != nil {
+		return err
+	}
+	e.flusher.Flush()
+	return nil
+}
+
+//send sends the new data of the stream that the message was published to
+func (e *EventStream) send(msg messenger.Message) error {
+	s, ok := e.sources[msg.Stream]
+	if !ok {
+		return nil
+	}
+	logger := e.logger.WithField("stream", msg.Stream)
+	if s.index >= 0 {
+		next, err := replayStream(e.o, msg.Stream, e.transform, s.tf, s.index, logger, func(m messenger.Message, next int64) error {
+			//The index is advanced before writing, since the event's id is the position after its data
+			s.index = next
+			return e.write(m)
+		})
+		s.index = next
+		return err
+	}
+	if len(msg.Data) == 0 {
+		return nil
+	}
+	m, err := transformMessage(msg, e.transform, s.tf)
+	if err != nil || m == nil {
+		return err
+	}
+	logger.Debugln("<- send")
+	return e.write(*m)
+}
+
+//Run sends the events until the client disconnects or the server shuts down. The streams which have data after their
+//starting index are sent right away, and the streams created while running are subscribed to as the meta log shows them.
+func (e *EventStream) Run(done <-chan struct{}) error {
+	eventStreamWaitGroup.Add(1)
+	defer eventStreamWaitGroup.Done()
+
+	e.writer.Header().Set("Content-Type", "text/event-stream")
+	e.writer.Header().Set("Cache-Control", "no-cache")
+	e.writer.Header().Set("Connection", "keep-alive")
+	e.writer.Header().Set("X-Accel-Buffering", "no") //Stops proxies such as nginx from buffering the events
+	e.writer.WriteHeader(http.StatusOK)
+	e.flusher.Flush()
+
+	for stream, s := range e.sources {
+		if s.index >= 0 {
+			if err := e.send(messenger.Message{Stream: stream}); err != nil {
+				return err
+			}
+		}
+	}
+
+	ticker := time.NewTicker(config.Get().Websocket.PingPeriod * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case msg := <-e.c:
+			if err := e.send(msg); err != nil {
+				return err
+			}
+		case <-ticker.C:
+			//Comments keep proxies from closing the connection, and let us notice clients which are gone
+			if _, err := fmt.Fprint(e.writer, ": ping\n\n"); err != nil {
+				return err
+			}
+			e.flusher.Flush()
+		case msg := <-e.changes:
+			cursors, ok := e.changedStreams(msg)
+			if !ok {
+				continue
+			}
+			if err := e.subscribe(cursors); err != nil {
+				return err
+			}
+			//The new streams might have gotten data before they were subscribed to
+			for stream := range cursors {
+				if err := e.send(messenger.Message{Stream: stream}); err != nil {
+					return err
+				}
+			}
+		case <-done:
+			return nil
+		case <-webcore.ShutdownChannel:
+			webcore.ShutdownChannel <- true
+			return nil
+		}
+	}
+}
+
+//Close unsubscribes from all of the streams
+func (e *EventStream) Close() {
+	if e.meta != nil {
+		e.meta.Unsubscribe()
+	}
+	for _, s := range e.sources {
+		s.nats.Unsubscribe()
+	}
+}
+
+//RunEventStream sends the data of the stream, device or user given in the path as server-sent events
+func RunEventStream(o *authoperator.AuthOperator, writer http.ResponseWriter, request *http.Request, logger *log.Entry) (int, string) {
+	path := mux.Vars(request)["path"]
+	logger = logger.WithFields(log.Fields{"cmd": "events", "arg": path})
+
+	e, err := NewEventStream(o, writer, path, request.URL.Query().Get("transform"), request.Header.Get("Last-Event-ID"), logger)
+	if err != nil {
+		status := restcore.QueryErrorStatus(err)
+		if err == ErrStreamingUnsupported || err == ErrChangesUnsupported {
+			status = http.StatusInternalServerError
+		}
+		return restcore.WriteError(writer, logger, status, err, status == http.StatusInternalServerError)
+	}
+	defer e.Close()
+	if err = e.Run(request.Context().Done()); err != nil {
+		return 2, err.Error()
+	}
+	return 0, "Event stream closed"
+}
@@ -0,0 +1,265 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package restapi
+
+import (
+	"connectordb/authoperator"
+	"connectordb/datastream"
+	"connectordb/messenger"
+	"connectordb/users"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+//eventWriter is a ResponseWriter which hands each write of an event stream to the test
+type eventWriter struct {
+	header http.Header
+	events chan string
+}
+
+func newEventWriter() *eventWriter {
+	return &eventWriter{header: make(http.Header), events: make(chan string, 100)}
+}
+
+func (w *eventWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *eventWriter) WriteHeader(status int) {}
+
+func (w *eventWriter) Write(b []byte) (int, error) {
+	w.events <- string(b)
+	return len(b), nil
+}
+
+func (w *eventWriter) Flush() {}
+
+//next returns the id and message of the next event, skipping the heartbeats
+func (w *eventWriter) next(t *testing.T) (string, messenger.Message) {
+	for {
+		select {
+		case ev := <-w.events:
+			if strings.HasPrefix(ev, ":") {
+				continue
+			}
+			lines := strings.Split(strings.TrimSuffix(ev, "\n\n"), "\n")
+			require.Len(t, lines, 2, ev)
+			require.True(t, strings.HasPrefix(lines[0], "id: "), ev)
+			require.True(t, strings.HasPrefix(lines[1], "data: "), ev)
+			var msg messenger.Message
+			require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(lines[1], "data: ")), &msg))
+			return strings.TrimPrefix(lines[0], "id: "), msg
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "No event was sent")
+		}
+	}
+}
+
+//runEventStream starts an event stream, returning the function that stops it
+func runEventStream(t *testing.T, o *authoperator.AuthOperator, path, transform, lastEventID string) (*eventWriter, func()) {
+	w := newEventWriter()
+	e, err := NewEventStream(o, w, path, transform, lastEventID, log.WithField("test", "events"))
+	require.NoError(t, err)
+	done := make(chan struct{})
+	stopped := make(chan error)
+	go func() {
+		stopped <- e.Run(done)
+	}()
+	return w, func() {
+		close(done)
+		require.NoError(t, <-stopped)
+		e.Close()
+	}
+}
+
+//lastInsert is the timestamp of the last datapoint inserted by insertNumbers
+var lastInsert float64
+
+//insertNumbers inserts the values into the stream, with timestamps that increase across all of the inserts
+func insertNumbers(t *testing.T, o *authoperator.AuthOperator, stream string, values ...float64) {
+	ts := float64(time.Now().UnixNano()) / 1e9
+	if ts <= lastInsert {
+		ts = lastInsert + 1e-3
+	}
+	dpa := make(datastream.DatapointArray, len(values))
+	for i, v := range values {
+		dpa[i] = datastream.Datapoint{Timestamp: ts, Data: v}
+		lastInsert = ts
+		ts += 1e-3
+	}
+	require.NoError(t, o.InsertStream(stream, dpa, false))
+}
+
+func TestEventID(t *testing.T) {
+	cursors, err := parseEventID("")
+	require.NoError(t, err)
+	require.Empty(t, cursors)
+
+	cursors, err = parseEventID("u%2Fd%2Fs1=5&u%2Fd%2Fs2=0")
+	require.NoError(t, err)
+	require.Equal(t, map[string]int64{"u/d/s1": 5, "u/d/s2": 0}, cursors)
+
+	for _, id := range []string{"u%2Fd%2Fs1=-1", "u%2Fd%2Fs1=x", "u%2Fd%2Fs1=1.5", "%zz=1"} {
+		_, err = parseEventID(id)
+		require.Error(t, err, id)
+	}
+
+	//Ephemeral streams have no position, so they are left out of the id
+	e := &EventStream{sources: map[string]*eventSource{
+		"u/d/s1": {index: 5},
+		"u/d/s2": {index: 0},
+		"u/d/s3": {index: -1},
+	}}
+	cursors, err = parseEventID(e.eventID())
+	require.NoError(t, err)
+	require.Equal(t, map[string]int64{"u/d/s1": 5, "u/d/s2": 0}, cursors)
+}
+
+func TestEventStream(t *testing.T) {
+	tdb.Clear()
+	require.NoError(t, tdb.CreateUser(&users.UserMaker{User: users.User{Name: "eventuser", Email: "event@localhost", Password: "mypass", Role: "user", Public: true}}))
+	require.NoError(t, tdb.CreateDevice("eventuser/eventdevice", &users.DeviceMaker{}))
+	o, err := tdb.AsUser("eventuser")
+	require.NoError(t, err)
+
+	schema := users.Stream{Schema: `{"type":"number"}`}
+	require.NoError(t, o.CreateStream("eventuser/eventdevice/s1", &users.StreamMaker{Stream: schema}))
+	insertNumbers(t, o, "eventuser/eventdevice/s1", 1, 2, 3)
+
+	//A new event stream starts at the end of the stream
+	w, stop := runEventStream(t, o, "eventuser/eventdevice/s1", "", "")
+	insertNumbers(t, o, "eventuser/eventdevice/s1", 4)
+	id, msg := w.next(t)
+	require.Equal(t, "eventuser%2Feventdevice%2Fs1=4", id)
+	require.Equal(t, "eventuser/eventdevice/s1", msg.Stream)
+	require.Len(t, msg.Data, 1)
+	require.Equal(t, 4.0, msg.Data[0].Data)
+	stop()
+
+	//Reconnecting with the last event id sends the missed data first
+	w, stop = runEventStream(t, o, "eventuser/eventdevice/s1", "", "eventuser%2Feventdevice%2Fs1=1")
+	id, msg = w.next(t)
+	require.Equal(t, "eventuser%2Feventdevice%2Fs1=4", id)
+	require.Len(t, msg.Data, 3)
+	require.Equal(t, 2.0, msg.Data[0].Data)
+	require.Equal(t, 4.0, msg.Data[2].Data)
+	insertNumbers(t, o, "eventuser/eventdevice/s1", 5)
+	id, msg = w.next(t)
+	require.Equal(t, "eventuser%2Feventdevice%2Fs1=5", id)
+	require.Equal(t, 5.0, msg.Data[0].Data)
+	stop()
+
+	//The transform runs on both the replayed and the live data, and the id still counts the filtered datapoints
+	w, stop = runEventStream(t, o, "eventuser/eventdevice/s1", "if $ > 3", "eventuser%2Feventdevice%2Fs1=0")
+	id, msg = w.next(t)
+	require.Equal(t, "eventuser%2Feventdevice%2Fs1=5", id)
+	require.Equal(t, "if $ > 3", msg.Transform)
+	require.Len(t, msg.Data, 2)
+	require.Equal(t, 4.0, msg.Data[0].Data)
+	insertNumbers(t, o, "eventuser/eventdevice/s1", 1, 6)
+	id, msg = w.next(t)
+	require.Equal(t, "eventuser%2Feventdevice%2Fs1=7", id)
+	require.Len(t, msg.Data, 1)
+	require.Equal(t, 6.0, msg.Data[0].Data)
+	stop()
+
+	_, err = NewEventStream(o, newEventWriter(), "eventuser/eventdevice/s1", "if $ >", "", log.WithField("test", "events"))
+	require.Error(t, err)
+	_, err = NewEventStream(o, newEventWriter(), "eventuser/eventdevice/s1", "", "eventuser%2Feventdevice%2Fs1=-1", log.WithField("test", "events"))
+	require.Error(t, err)
+
+	//Ephemeral streams send their messages as they are, and have no position in the id
+	require.NoError(t, o.CreateStream("eventuser/eventdevice/eph", &users.StreamMaker{Stream: users.Stream{Schema: `{"type":"number"}`, Ephemeral: true}}))
+	w, stop = runEventStream(t, o, "eventuser/eventdevice/eph", "", "")
+	insertNumbers(t, o, "eventuser/eventdevice/eph", 7)
+	id, msg = w.next(t)
+	require.Equal(t, "", id)
+	require.Equal(t, "eventuser/eventdevice/eph", msg.Stream)
+	require.Equal(t, 7.0, msg.Data[0].Data)
+	stop()
+}
+
+func TestEventStreamChanges(t *testing.T) {
+	tdb.Clear()
+	require.NoError(t, tdb.CreateUser(&users.UserMaker{User: users.User{Name: "eventuser", Email: "event@localhost", Password: "mypass", Role: "user", Public: true}}))
+	require.NoError(t, tdb.CreateDevice("eventuser/eventdevice", &users.DeviceMaker{}))
+	o, err := tdb.AsUser("eventuser")
+	require.NoError(t, err)
+
+	schema := users.Stream{Schema: `{"type":"number"}`}
+	require.NoError(t, o.CreateStream("eventuser/eventdevice/s1", &users.StreamMaker{Stream: schema}))
+	w, stop := runEventStream(t, o, "eventuser/eventdevice", "", "")
+	defer stop()
+
+	//The streams created while the event stream runs are subscribed to from their start
+	require.NoError(t, o.CreateStream("eventuser/eventdevice/s2", &users.StreamMaker{Stream: schema}))
+	insertNumbers(t, o, "eventuser/eventdevice/s2", 1)
+	id, msg := w.next(t)
+	require.Equal(t, "eventuser/eventdevice/s2", msg.Stream)
+	require.Equal(t, 1.0, msg.Data[0].Data)
+	cursors, err := parseEventID(id)
+	require.NoError(t, err)
+	require.Equal(t, map[string]int64{"eventuser/eventdevice/s1": 0, "eventuser/eventdevice/s2": 1}, cursors)
+
+	//Deleted streams are left out of the id. The changes are handled in order, so the data of the stream created after
+	//the delete is only sent once the delete was handled.
+	require.NoError(t, o.DeleteStream("eventuser/eventdevice/s2"))
+	require.NoError(t, o.CreateStream("eventuser/eventdevice/s3", &users.StreamMaker{Stream: schema}))
+	insertNumbers(t, o, "eventuser/eventdevice/s3", 2)
+	id, msg = w.next(t)
+	require.Equal(t, "eventuser/eventdevice/s3", msg.Stream)
+	require.Equal(t, 2.0, msg.Data[0].Data)
+	cursors, err = parseEventID(id)
+	require.NoError(t, err)
+	require.Equal(t, map[string]int64{"eventuser/eventdevice/s1": 0, "eventuser/eventdevice/s3": 1}, cursors)
+
+	//Changes to the streams of other devices don't matter
+	e := &EventStream{path: "eventuser/eventdevice"}
+	change := func(cmd, arg string) messenger.Message {
+		return messenger.Message{Data: datastream.DatapointArray{{Data: map[string]interface{}{"cmd": cmd, "arg": arg}}}}
+	}
+	cursors, ok := e.changedStreams(change("CreateStream", "eventuser/eventdevice/s3"))
+	require.True(t, ok)
+	require.Equal(t, map[string]int64{"eventuser/eventdevice/s3": 0}, cursors)
+	cursors, ok = e.changedStreams(change("DeleteStream", "eventuser/eventdevice/s3"))
+	require.True(t, ok)
+	require.Empty(t, cursors)
+	_, ok = e.changedStreams(change("CreateStream", "eventuser/eventdevice2/s3"))
+	require.False(t, ok)
+	_, ok = e.changedStreams(change("CreateDevice", "eventuser/other"))
+	require.False(t, ok)
+}
+
+func TestEventStreamSubscribeGrant(t *testing.T) {
+	tdb.Clear()
+	require.NoError(t, tdb.CreateUser(&users.UserMaker{User: users.User{Name: "eventuser", Email: "event@localhost", Password: "mypass", Role: "user", Public: false}}))
+	require.NoError(t, tdb.CreateDevice("eventuser/eventdevice", &users.DeviceMaker{}))
+	require.NoError(t, tdb.CreateStream("eventuser/eventdevice/s1", &users.StreamMaker{Stream: users.Stream{Schema: `{"type":"number"}`}}))
+	require.NoError(t, tdb.CreateUser(&users.UserMaker{User: users.User{Name: "otheruser", Email: "other@localhost", Password: "mypass", Role: "user", Public: false}}))
+	require.NoError(t, tdb.CreateDevice("otheruser/dev", &users.DeviceMaker{Device: users.Device{Role: "user"}}))
+	o, err := tdb.AsUser("eventuser")
+	require.NoError(t, err)
+	insertNumbers(t, o, "eventuser/eventdevice/s1", 1)
+	require.NoError(t, o.CreateGrant("otheruser/dev", "eventuser/eventdevice/s1", &users.Grant{Subscribe: true}))
+	og, err := tdb.AsDevice("otheruser/dev")
+	require.NoError(t, err)
+
+	//A device which can only subscribe gets the live messages, but can't read the stream's data to resume
+	w, stop := runEventStream(t, og, "eventuser/eventdevice/s1", "", "eventuser%2Feventdevice%2Fs1=0")
+	defer stop()
+	insertNumbers(t, o, "eventuser/eventdevice/s1", 2)
+	id, msg := w.next(t)
+	require.Equal(t, "", id)
+	require.Equal(t, "eventuser/eventdevice/s1", msg.Stream)
+	require.Len(t, msg.Data, 1)
+	require.Equal(t, 2.0, msg.Data[0].Data)
+}
@@ -42,6 +42,13 @@ func getFeedData(o *authoperator.AuthOperator, writer http.ResponseWriter, reque
 	return s, dr, err
 }
 
+//atomEndpoint describes the routes of the Atom feed
+var atomEndpoint = restcore.Endpoint{
+	Summary:     "Read the most recent datapoints of the stream as an Atom feed",
+	Params:      []restcore.Param{restcore.TransformParam},
+	ContentType: "application/atom+xml",
+}
+
 //Router returns a fully formed Gorilla router given an optional prefix
 func Router(db *connectordb.Database, prefix *mux.Router) *mux.Router {
 	if prefix == nil {
@@ -51,8 +58,10 @@ func Router(db *connectordb.Database, prefix *mux.Router) *mux.Router {
 	//Allow for the application to match /path and /path/ to the same place.
 	prefix.StrictSlash(true)
 
-	prefix.HandleFunc("/{user}/{device}/{stream}.atom", restcore.Authenticator(GetAtom, db)).Methods("GET")
-	prefix.HandleFunc("/{user}/{device}/{stream}", restcore.Authenticator(GetAtom, db)).Methods("GET")
+	restcore.Describe(prefix.HandleFunc("/{user}/{device}/{stream}.atom", restcore.Authenticator(GetAtom, db)).Methods("GET"),
+		atomEndpoint)
+	restcore.Describe(prefix.HandleFunc("/{user}/{device}/{stream}", restcore.Authenticator(GetAtom, db)).Methods("GET"),
+		atomEndpoint)
 
 	return prefix
 }
@@ -0,0 +1,190 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package ingest
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//ErrPrecision is returned when the precision of line protocol timestamps is not known
+var ErrPrecision = errors.New("The precision must be one of n, ns, u, us, ms, s, m and h")
+
+//precisions gives the number of seconds in each unit of a line protocol timestamp
+var precisions = map[string]float64{
+	"":   1e-9,
+	"n":  1e-9,
+	"ns": 1e-9,
+	"u":  1e-6,
+	"us": 1e-6,
+	"ms": 1e-3,
+	"s":  1,
+	"m":  60,
+	"h":  60 * 60,
+}
+
+//The tag which holds the machine that telegraf runs on. It is left out of the stream names, since the device stands for the machine.
+const influxHostTag = "host"
+
+//ParseLineProtocol parses data in the InfluxDB line protocol, where each line holds the fields of a measurement:
+//	measurement,tag1=a,tag2=b field1=1.5,field2="text" 1465839830100400200
+//Each field becomes a point of the stream named by the measurement, the values of its tags and the field's name.
+//Lines without a timestamp are given the current time.
+func ParseLineProtocol(body string, precision string, now time.Time) ([]Point, error) {
+	unit, ok := precisions[precision]
+	if !ok {
+		return nil, ErrPrecision
+	}
+	var points []Point
+	for n, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		p, err := parseLine(line, unit, now)
+		if err != nil {
+			return nil, fmt.Errorf("Line %d: %s", n+1, err.Error())
+		}
+		points = append(points, p...)
+	}
+	return points, nil
+}
+
+func parseLine(line string, unit float64, now time.Time) ([]Point, error) {
+	measurement, i := scanToken(line, 0, ", ")
+	if measurement == "" {
+		return nil, errors.New("Missing measurement")
+	}
+	tags := make(map[string]string)
+	for i < len(line) && line[i] == ',' {
+		var key, value string
+		key, i = scanToken(line, i+1, "=")
+		if i >= len(line) {
+			return nil, errors.New("Missing tag value")
+		}
+		value, i = scanToken(line, i+1, ", ")
+		if key == "" || value == "" {
+			return nil, errors.New("Invalid tag")
+		}
+		if key != influxHostTag {
+			tags[key] = value
+		}
+	}
+
+	type field struct {
+		name  string
+		value interface{}
+	}
+	var fields []field
+	for {
+		i = skipSpaces(line, i)
+		var key string
+		key, i = scanToken(line, i, "=")
+		if key == "" || i >= len(line) {
+			return nil, errors.New("Missing field")
+		}
+		var value interface{}
+		var err error
+		if value, i, err = scanFieldValue(line, i+1); err != nil {
+			return nil, err
+		}
+		fields = append(fields, field{key, value})
+		if i >= len(line) || line[i] != ',' {
+			break
+		}
+		i++
+	}
+
+	timestamp := float64(now.UnixNano()) * 1e-9
+	if rest := strings.TrimSpace(line[i:]); rest != "" {
+		t, err := strconv.ParseInt(rest, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid timestamp '%s'", rest)
+		}
+		timestamp = float64(t) * unit
+	}
+
+	points := make([]Point, 0, len(fields))
+	for _, f := range fields {
+		points = append(points, Point{streamName(measurement, tags, f.name), timestamp, f.value})
+	}
+	return points, nil
+}
+
+//scanToken reads the line from i up to the first unescaped character of stop, returning the unescaped
+//token, and the index of the stop character, or the length of the line if there is none
+func scanToken(line string, i int, stop string) (string, int) {
+	var token []byte
+	for ; i < len(line); i++ {
+		c := line[i]
+		if c == '\\' && i+1 < len(line) {
+			i++
+			token = append(token, line[i])
+			continue
+		}
+		if strings.IndexByte(stop, c) >= 0 {
+			break
+		}
+		token = append(token, c)
+	}
+	return string(token), i
+}
+
+func skipSpaces(line string, i int) int {
+	for i < len(line) && line[i] == ' ' {
+		i++
+	}
+	return i
+}
+
+//scanFieldValue reads the value of a field starting at i. Strings are quoted, integers end with i,
+//unsigned integers with u, booleans are t, f, true or false in any case, and anything else is a float.
+func scanFieldValue(line string, i int) (interface{}, int, error) {
+	if i < len(line) && line[i] == '"' {
+		var s []byte
+		for i++; i < len(line); i++ {
+			c := line[i]
+			if c == '\\' && i+1 < len(line) && (line[i+1] == '"' || line[i+1] == '\\') {
+				i++
+				s = append(s, line[i])
+				continue
+			}
+			if c == '"' {
+				return string(s), i + 1, nil
+			}
+			s = append(s, c)
+		}
+		return nil, i, errors.New("Unterminated string")
+	}
+
+	raw, i := scanToken(line, i, ", ")
+	if raw == "" {
+		return nil, i, errors.New("Missing field value")
+	}
+	var v interface{}
+	var err error
+	switch last := raw[len(raw)-1]; {
+	case last == 'i':
+		v, err = strconv.ParseInt(raw[:len(raw)-1], 10, 64)
+	case last == 'u':
+		v, err = strconv.ParseUint(raw[:len(raw)-1], 10, 64)
+	default:
+		switch strings.ToLower(raw) {
+		case "t", "true":
+			v = true
+		case "f", "false":
+			v = false
+		default:
+			v, err = strconv.ParseFloat(raw, 64)
+		}
+	}
+	if err != nil {
+		return nil, i, fmt.Errorf("Invalid field value '%s'", raw)
+	}
+	return v, i, nil
+}
@@ -0,0 +1,87 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package ingest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLineProtocol(t *testing.T) {
+	now := time.Unix(1000, 0)
+	tests := []struct {
+		name      string
+		body      string
+		precision string
+		points    []Point
+	}{
+		{"fields", "cpu,host=myhost,cpu=cpu0 usage_idle=1.5,usage_user=2i 1465839830000000000", "",
+			[]Point{{"cpu_cpu0_usage_idle", 1465839830, 1.5}, {"cpu_cpu0_usage_user", 1465839830, int64(2)}}},
+		{"tags sorted by key", "disk,path=/,device=sda1 free=3i 0", "",
+			[]Point{{"disk_sda1_free", 0, int64(3)}}},
+		{"no timestamp", "mem used=5", "",
+			[]Point{{"mem_used", 1000, 5.0}}},
+		{"comments and blank lines", "# a comment\n\nmem used=5 1\r\n\n", "s",
+			[]Point{{"mem_used", 1, 5.0}}},
+		{"integer types", "m a=-5i,b=5u,c=18446744073709551615u,d=-1e3", "",
+			[]Point{{"m_a", 1000, int64(-5)}, {"m_b", 1000, uint64(5)}, {"m_c", 1000, uint64(18446744073709551615)}, {"m_d", 1000, -1000.0}}},
+		{"booleans", "m a=t,b=TRUE,c=f,d=False", "",
+			[]Point{{"m_a", 1000, true}, {"m_b", 1000, true}, {"m_c", 1000, false}, {"m_d", 1000, false}}},
+		{"quoted strings", `m s="hello, \"world\" x=1",t="a\\b",u="" 1`, "s",
+			[]Point{{"m_s", 1, `hello, "world" x=1`}, {"m_t", 1, `a\b`}, {"m_u", 1, ""}}},
+		{"escaped names", `my\ m,tag\,key=va\ lue,other=x\=y f\ 1=1 1`, "s",
+			[]Point{{"my_m_x_y_va_lue_f_1", 1, 1.0}}},
+		{"spaces between fields and timestamp", "m  a=1   7", "ms",
+			[]Point{{"m_a", 0.007, 1.0}}},
+		{"precision u", "m a=1 1500000", "u", []Point{{"m_a", 1.5, 1.0}}},
+		{"precision us", "m a=1 1500000", "us", []Point{{"m_a", 1.5, 1.0}}},
+		{"precision m", "m a=1 2", "m", []Point{{"m_a", 120, 1.0}}},
+		{"precision h", "m a=1 2", "h", []Point{{"m_a", 7200, 1.0}}},
+		{"precision n", "m a=1 1500000000", "n", []Point{{"m_a", 1.5, 1.0}}},
+		{"numeric measurement", "1m a=1 1", "s", []Point{{"m_1m_a", 1, 1.0}}},
+	}
+	for _, test := range tests {
+		points, err := ParseLineProtocol(test.body, test.precision, now)
+		require.NoError(t, err, test.name)
+		require.Len(t, points, len(test.points), test.name)
+		for i, p := range test.points {
+			require.Equal(t, p.Stream, points[i].Stream, test.name)
+			require.InDelta(t, p.Timestamp, points[i].Timestamp, 1e-6, test.name)
+			require.Equal(t, p.Value, points[i].Value, test.name)
+		}
+	}
+}
+
+func TestParseLineProtocolErrors(t *testing.T) {
+	_, err := ParseLineProtocol("m a=1", "ps", time.Now())
+	require.Equal(t, ErrPrecision, err)
+
+	tests := []struct {
+		name string
+		body string
+		err  string
+	}{
+		{"no measurement", ",t=1 a=1", "Line 1: Missing measurement"},
+		{"no fields", "m", "Line 1: Missing field"},
+		{"no field value", "m a=", "Line 1: Missing field value"},
+		{"missing tag value", "m,t", "Line 1: Missing tag value"},
+		{"empty tag value", "m,t= a=1", "Line 1: Invalid tag"},
+		{"unterminated string", `m a="abc`, "Line 1: Unterminated string"},
+		{"bad float", "m a=abc", "Line 1: Invalid field value 'abc'"},
+		{"float with integer suffix", "m a=1.5i", "Line 1: Invalid field value '1.5i'"},
+		{"negative unsigned", "m a=-1u", "Line 1: Invalid field value '-1u'"},
+		{"integer overflow", "m a=9223372036854775808i", "Line 1: Invalid field value '9223372036854775808i'"},
+		{"bad timestamp", "m a=1 abc", "Line 1: Invalid timestamp 'abc'"},
+		{"float timestamp", "m a=1 1.5", "Line 1: Invalid timestamp '1.5'"},
+		{"error on a later line", "m a=1\n\nm b", "Line 3: Missing field"},
+	}
+	for _, test := range tests {
+		_, err := ParseLineProtocol(test.body, "", time.Now())
+		require.Error(t, err, test.name)
+		require.Equal(t, test.err, err.Error(), test.name)
+	}
+}
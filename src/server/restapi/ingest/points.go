@@ -0,0 +1,168 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+
+Package ingest accepts data in the formats of other time series databases, so that the tools which
+already write to them, such as telegraf and Prometheus, can write into the streams of a device.
+**/
+package ingest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"config"
+	"connectordb/authoperator"
+	"connectordb/datastream"
+	"connectordb/users"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+//The longest stream name that can be made. Longer names are shortened, and given a hash to keep them unique.
+const maxNameLength = 29
+
+//invalidNameChars are the characters which can't be used in stream names
+var invalidNameChars = regexp.MustCompile("[^a-zA-Z0-9_-]+")
+
+//ErrBodyTooLarge is returned when the body of a request, or the body once it is decompressed, is larger than the insert limit
+var ErrBodyTooLarge = errors.New("The request is larger than the insert limit")
+
+//Point is a single value, which is inserted into the stream of its name within the device
+type Point struct {
+	Stream    string
+	Timestamp float64
+	Value     interface{}
+}
+
+//streamName joins the parts into a valid stream name. The name of a measurement is followed by the values of
+//its tags, sorted by their keys, and then by the name of its field, such as "cpu_cpu0_usage_idle".
+func streamName(measurement string, tags map[string]string, field string) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := []string{measurement}
+	for _, k := range keys {
+		parts = append(parts, tags[k])
+	}
+	if field != "" {
+		parts = append(parts, field)
+	}
+
+	//The parts are cleaned one by one, so that a part made only of invalid characters doesn't leave a run of underscores
+	clean := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p = strings.Trim(invalidNameChars.ReplaceAllString(p, "_"), "_"); p != "" {
+			clean = append(clean, p)
+		}
+	}
+	name := strings.Join(clean, "_")
+	if name == "" || !('a' <= name[0] && name[0] <= 'z' || 'A' <= name[0] && name[0] <= 'Z') {
+		name = "m_" + name
+	}
+	if len(name) > maxNameLength {
+		h := fnv.New32a()
+		h.Write([]byte(name))
+		name = fmt.Sprintf("%s_%08x", name[:maxNameLength-9], h.Sum32())
+	}
+	return name
+}
+
+//schemaOf returns the schema of a stream created for the value
+func schemaOf(v interface{}) string {
+	switch v.(type) {
+	case int64, uint64:
+		return `{"type":"integer"}`
+	case float64:
+		return `{"type":"number"}`
+	case bool:
+		return `{"type":"boolean"}`
+	case string:
+		return `{"type":"string"}`
+	}
+	return `{}`
+}
+
+//readBody reads the request's body, decompressing it if it was gzipped. Bodies larger than the insert limit are refused.
+func readBody(request *http.Request) ([]byte, error) {
+	limit := config.Get().InsertLimitBytes
+	b, err := readLimited(request.Body, limit)
+	if err != nil || request.Header.Get("Content-Encoding") != "gzip" {
+		return b, err
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	return readLimited(gz, limit)
+}
+
+//readLimited reads r to the end, returning ErrBodyTooLarge if it holds more than limit bytes
+func readLimited(r io.Reader, limit int64) ([]byte, error) {
+	b, err := ioutil.ReadAll(io.LimitReader(r, limit+1))
+	if err == nil && int64(len(b)) > limit {
+		return nil, ErrBodyTooLarge
+	}
+	return b, err
+}
+
+//insertPoints inserts the points into the streams of the device, creating the streams which don't exist
+//yet with the schema of their first value. The device needs to be allowed to create streams for them to be
+//created. All of the streams are inserted into even if some of them fail, and the first failure is returned.
+func insertPoints(o *authoperator.AuthOperator, devicepath, description string, points []Point) (int, error) {
+	var order []string
+	streams := make(map[string]datastream.DatapointArray)
+	for _, p := range points {
+		if f, ok := p.Value.(float64); ok && (math.IsNaN(f) || math.IsInf(f, 0)) {
+			//Json has no way of writing these values, and Prometheus uses NaN to mark stale series
+			continue
+		}
+		if _, ok := streams[p.Stream]; !ok {
+			order = append(order, p.Stream)
+		}
+		streams[p.Stream] = append(streams[p.Stream], datastream.Datapoint{Timestamp: p.Timestamp, Data: p.Value})
+	}
+
+	var firstErr error
+	inserted := 0
+	for _, name := range order {
+		dpa := streams[name]
+		sort.Stable(byTimestamp(dpa))
+
+		streampath := devicepath + "/" + name
+		err := o.InsertStream(streampath, dpa, false)
+		if err == users.ErrStreamNotFound {
+			err = o.CreateStream(streampath, &users.StreamMaker{Stream: users.Stream{
+				Description: description,
+				Schema:      schemaOf(dpa[0].Data),
+			}})
+			if err == nil {
+				err = o.InsertStream(streampath, dpa, false)
+			}
+		}
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("%s: %s", name, err.Error())
+			}
+			continue
+		}
+		inserted += len(dpa)
+	}
+	return inserted, firstErr
+}
+
+type byTimestamp datastream.DatapointArray
+
+func (b byTimestamp) Len() int           { return len(b) }
+func (b byTimestamp) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byTimestamp) Less(i, j int) bool { return b[i].Timestamp < b[j].Timestamp }
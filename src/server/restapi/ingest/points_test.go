@@ -0,0 +1,70 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package ingest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"config"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamName(t *testing.T) {
+	require.Equal(t, "cpu_usage", streamName("cpu", nil, "usage"))
+	require.Equal(t, "cpu_a_b_usage", streamName("cpu", map[string]string{"y": "b", "x": "a"}, "usage"))
+	require.Equal(t, "up", streamName("up", map[string]string{}, ""))
+	require.Equal(t, "disk_dev_sda1_used", streamName("disk", map[string]string{"device": "/dev/sda1"}, "used"))
+	require.Equal(t, "m_9lives", streamName("9lives", nil, ""))
+	require.Equal(t, "m_", streamName("", nil, ""))
+	require.Equal(t, "disk_free", streamName("disk", map[string]string{"path": "/"}, "free"), "Tags without valid characters are left out")
+
+	long := streamName(strings.Repeat("a", 40), nil, "")
+	require.Len(t, long, maxNameLength)
+	require.NotEqual(t, long, streamName(strings.Repeat("a", 41), nil, ""), "Long names are kept unique by their hash")
+}
+
+func TestReadBody(t *testing.T) {
+	limit := config.Get().InsertLimitBytes
+
+	request, err := http.NewRequest("POST", "/", bytes.NewReader(make([]byte, limit)))
+	require.NoError(t, err)
+	b, err := readBody(request)
+	require.NoError(t, err)
+	require.Len(t, b, int(limit))
+
+	request, err = http.NewRequest("POST", "/", bytes.NewReader(make([]byte, limit+1)))
+	require.NoError(t, err)
+	_, err = readBody(request)
+	require.Equal(t, ErrBodyTooLarge, err)
+
+	// The limit also applies to the decompressed body, which can be far larger than the request
+	gzipped := func(size int64) *http.Request {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		_, err := gz.Write(make([]byte, size))
+		require.NoError(t, err)
+		require.NoError(t, gz.Close())
+		request, err := http.NewRequest("POST", "/", &buf)
+		require.NoError(t, err)
+		request.Header.Set("Content-Encoding", "gzip")
+		return request
+	}
+	b, err = readBody(gzipped(limit))
+	require.NoError(t, err)
+	require.Len(t, b, int(limit))
+	_, err = readBody(gzipped(limit + 1))
+	require.Equal(t, ErrBodyTooLarge, err)
+
+	request, err = http.NewRequest("POST", "/", strings.NewReader("not gzip"))
+	require.NoError(t, err)
+	request.Header.Set("Content-Encoding", "gzip")
+	_, err = readBody(request)
+	require.Error(t, err)
+	require.NotEqual(t, ErrBodyTooLarge, err)
+}
@@ -0,0 +1,161 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package ingest
+
+import (
+	"config"
+	"encoding/binary"
+	"errors"
+	"math"
+
+	"github.com/golang/snappy"
+)
+
+//ErrMalformedWriteRequest is returned when a remote write request can't be decoded
+var ErrMalformedWriteRequest = errors.New("Malformed Prometheus remote write request")
+
+//The labels which identify the target that Prometheus scraped. They are left out of the stream names, since the device stands for the target.
+var prometheusTargetLabels = map[string]bool{"job": true, "instance": true}
+
+//The protobuf wire types used by remote write requests
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+//ParseRemoteWrite decodes a Prometheus remote write request, which is a snappy compressed protobuf WriteRequest.
+//Each sample becomes a point of the stream named by its metric's name and the values of its labels.
+//Only the samples of the time series are read: metadata, exemplars and native histograms are skipped.
+//Requests which decompress to more than the insert limit are refused with ErrBodyTooLarge before they are decompressed.
+func ParseRemoteWrite(body []byte) ([]Point, error) {
+	n, err := snappy.DecodedLen(body)
+	if err != nil {
+		return nil, err
+	}
+	if int64(n) > config.Get().InsertLimitBytes {
+		return nil, ErrBodyTooLarge
+	}
+	b, err := snappy.Decode(nil, body)
+	if err != nil {
+		return nil, err
+	}
+	var points []Point
+	err = readMessage(b, func(field uint64, value []byte) error {
+		if field != 1 {
+			return nil
+		}
+		p, err := parseTimeSeries(value)
+		points = append(points, p...)
+		return err
+	})
+	return points, err
+}
+
+//parseTimeSeries reads the labels and samples of a TimeSeries
+func parseTimeSeries(b []byte) ([]Point, error) {
+	var name string
+	labels := make(map[string]string)
+	type sample struct {
+		value     float64
+		timestamp int64
+	}
+	var samples []sample
+	err := readMessage(b, func(field uint64, value []byte) error {
+		switch field {
+		case 1:
+			var lname, lvalue string
+			err := readMessage(value, func(field uint64, value []byte) error {
+				switch field {
+				case 1:
+					lname = string(value)
+				case 2:
+					lvalue = string(value)
+				}
+				return nil
+			})
+			if lname == "__name__" {
+				name = lvalue
+			} else if !prometheusTargetLabels[lname] {
+				labels[lname] = lvalue
+			}
+			return err
+		case 2:
+			var s sample
+			err := readMessage(value, func(field uint64, value []byte) error {
+				switch field {
+				case 1:
+					if len(value) != 8 {
+						return ErrMalformedWriteRequest
+					}
+					s.value = math.Float64frombits(binary.LittleEndian.Uint64(value))
+				case 2:
+					t, _ := binary.Uvarint(value)
+					s.timestamp = int64(t)
+				}
+				return nil
+			})
+			samples = append(samples, s)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		return nil, ErrMalformedWriteRequest
+	}
+
+	stream := streamName(name, labels, "")
+	points := make([]Point, 0, len(samples))
+	for _, s := range samples {
+		//Prometheus timestamps are in milliseconds
+		points = append(points, Point{stream, float64(s.timestamp) * 1e-3, s.value})
+	}
+	return points, nil
+}
+
+//readMessage calls fn with each field of the protobuf message. Varints are given in their encoded form,
+//so that fn can decode them with binary.Uvarint, and fixed size values are given as their little endian bytes.
+func readMessage(b []byte, fn func(field uint64, value []byte) error) error {
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return ErrMalformedWriteRequest
+		}
+		b = b[n:]
+
+		var size int
+		switch key & 0x7 {
+		case wireVarint:
+			if _, size = binary.Uvarint(b); size <= 0 {
+				return ErrMalformedWriteRequest
+			}
+		case wireFixed64:
+			size = 8
+		case wireFixed32:
+			size = 4
+		case wireBytes:
+			l, n := binary.Uvarint(b)
+			if n <= 0 || l > uint64(len(b)-n) {
+				return ErrMalformedWriteRequest
+			}
+			b = b[n:]
+			size = int(l)
+		default:
+			return ErrMalformedWriteRequest
+		}
+		if size > len(b) {
+			return ErrMalformedWriteRequest
+		}
+		if err := fn(key>>3, b[:size]); err != nil {
+			return err
+		}
+		b = b[size:]
+	}
+	return nil
+}
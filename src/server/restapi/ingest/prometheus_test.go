@@ -0,0 +1,116 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package ingest
+
+import (
+	"config"
+	"encoding/binary"
+	"encoding/hex"
+	"math"
+	"testing"
+
+	"github.com/golang/snappy"
+	"github.com/stretchr/testify/require"
+)
+
+//The protobuf of a WriteRequest as sent by Prometheus, holding the samples of up{job="node",instance="localhost:9100"}
+//and node_cpu_seconds_total{cpu="0",mode="idle",job="node",instance="localhost:9100"}, followed by the metadata of up
+const exampleWriteRequest = "0a5d0a0e0a085f5f6e616d655f5f120275700a0b0a036a6f6212046e6f64650a1a0a08696e7374616e6365120e6c6f63616c686f73743a39" +
+	"31303012100900000000000000001080b0def7d32b121009000000000000f03f1098a5dff7d32b0a770a220a085f5f6e616d655f5f12166e" +
+	"6f64655f6370755f7365636f6e64735f746f74616c0a080a036370751201300a0c0a046d6f6465120469646c650a0b0a036a6f6212046e6f" +
+	"64650a1a0a08696e7374616e6365120e6c6f63616c686f73743a39313030121009666666666674ac4010b09ae0f7d32b1a2a080212027570" +
+	"22223120696620746865207461726765742069732075702c2030206f7468657277697365"
+
+//pbVarint encodes a protobuf varint
+func pbVarint(v uint64) []byte {
+	b := make([]byte, binary.MaxVarintLen64)
+	return b[:binary.PutUvarint(b, v)]
+}
+
+//pbKey returns the key of a protobuf field
+func pbKey(field, wire uint64) []byte {
+	return pbVarint(field<<3 | wire)
+}
+
+//pbBytes encodes a length delimited protobuf field
+func pbBytes(field uint64, b []byte) []byte {
+	return append(append(pbKey(field, wireBytes), pbVarint(uint64(len(b)))...), b...)
+}
+
+//pbSample encodes a Sample of a TimeSeries
+func pbSample(value float64, timestamp int64) []byte {
+	v := make([]byte, 8)
+	binary.LittleEndian.PutUint64(v, math.Float64bits(value))
+	b := append(pbKey(1, wireFixed64), v...)
+	b = append(b, pbKey(2, wireVarint)...)
+	return pbBytes(2, append(b, pbVarint(uint64(timestamp))...))
+}
+
+//pbLabel encodes a Label of a TimeSeries
+func pbLabel(name, value string) []byte {
+	return pbBytes(1, append(pbBytes(1, []byte(name)), pbBytes(2, []byte(value))...))
+}
+
+func TestParseRemoteWrite(t *testing.T) {
+	b, err := hex.DecodeString(exampleWriteRequest)
+	require.NoError(t, err)
+	points, err := ParseRemoteWrite(snappy.Encode(nil, b))
+	require.NoError(t, err)
+	require.Equal(t, []Point{
+		{"up", 1500000000, 0.0},
+		{"up", 1500000015, 1.0},
+		{"node_cpu_seconds_total_0_idle", 1500000030, 3642.2},
+	}, points)
+
+	// Samples can come before the labels, and the labels of other series don't carry over
+	series := append(pbSample(2, -1500), pbLabel("b", "y")...)
+	series = append(series, pbLabel("__name__", "m")...)
+	series = append(series, pbLabel("a", "x")...)
+	req := append(pbBytes(1, series), pbBytes(1, append(pbLabel("__name__", "n"), pbSample(math.NaN(), 0)...))...)
+	points, err = ParseRemoteWrite(snappy.Encode(nil, req))
+	require.NoError(t, err)
+	require.Len(t, points, 2)
+	require.Equal(t, Point{"m_x_y", -1.5, 2.0}, points[0])
+	require.Equal(t, "n", points[1].Stream)
+	require.True(t, math.IsNaN(points[1].Value.(float64)))
+
+	points, err = ParseRemoteWrite(snappy.Encode(nil, nil))
+	require.NoError(t, err)
+	require.Empty(t, points)
+}
+
+func TestParseRemoteWriteErrors(t *testing.T) {
+	_, err := ParseRemoteWrite([]byte("not snappy"))
+	require.Error(t, err)
+
+	// The decoded length is checked against the insert limit before the body is decoded
+	_, err = ParseRemoteWrite(snappy.Encode(nil, make([]byte, config.Get().InsertLimitBytes+1)))
+	require.Equal(t, ErrBodyTooLarge, err)
+	_, err = ParseRemoteWrite([]byte{0xff, 0xff, 0xff, 0xff, 0x0f})
+	require.Equal(t, ErrBodyTooLarge, err)
+
+	valid := pbBytes(1, append(pbLabel("__name__", "m"), pbSample(1, 1)...))
+	tests := []struct {
+		name string
+		body []byte
+	}{
+		{"truncated key", []byte{0x80}},
+		{"truncated varint", []byte{0x08, 0xff, 0xff}},
+		{"truncated length", []byte{0x0a, 0x80}},
+		{"length past the end", []byte{0x0a, 0x05, 'a'}},
+		{"truncated fixed64", []byte{0x09, 1, 2, 3}},
+		{"truncated fixed32", []byte{0x0d, 1, 2}},
+		{"group wire type", []byte{0x0b}},
+		{"truncated series", valid[:len(valid)-1]},
+		{"series without a name", pbBytes(1, append(pbLabel("a", "x"), pbSample(1, 1)...))},
+		{"sample value as a varint", pbBytes(1, append(pbLabel("__name__", "m"), pbBytes(2, append(pbKey(1, wireVarint), 1))...))},
+		{"truncated label", pbBytes(1, pbBytes(1, []byte{0x0a, 0x03, 'm'}))},
+		{"valid series followed by garbage", append(append([]byte{}, valid...), 0x80)},
+	}
+	for _, test := range tests {
+		_, err := ParseRemoteWrite(snappy.Encode(nil, test.body))
+		require.Equal(t, ErrMalformedWriteRequest, err, test.name)
+	}
+}
@@ -0,0 +1,97 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package ingest
+
+import (
+	"connectordb"
+	"connectordb/authoperator"
+	"fmt"
+	"net/http"
+	"server/restapi/restcore"
+	"server/webcore"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+//writePoints inserts the points into the device of the request, and responds with 204 No Content like the databases that it stands in for
+func writePoints(o *authoperator.AuthOperator, writer http.ResponseWriter, request *http.Request, logger *log.Entry, description string, points []Point) (int, string) {
+	devicepath := mux.Vars(request)["user"] + "/" + mux.Vars(request)["device"]
+
+	inserted, err := insertPoints(o, devicepath, description, points)
+	atomic.AddUint32(&webcore.StatsInserts, uint32(inserted))
+	if err != nil {
+		return restcore.WriteError(writer, logger, http.StatusForbidden, err, false)
+	}
+	writer.WriteHeader(http.StatusNoContent)
+	return webcore.DEBUG, fmt.Sprintf("Insert %d", inserted)
+}
+
+//bodyError writes the error of reading the body of a request
+func bodyError(writer http.ResponseWriter, logger *log.Entry, err error) (int, string) {
+	if err == ErrBodyTooLarge {
+		return restcore.WriteError(writer, logger, http.StatusRequestEntityTooLarge, err, false)
+	}
+	return restcore.WriteError(writer, logger, http.StatusBadRequest, err, false)
+}
+
+//WriteInflux inserts data given in the InfluxDB line protocol. The precision of the timestamps is given in the precision query parameter.
+func WriteInflux(o *authoperator.AuthOperator, writer http.ResponseWriter, request *http.Request, logger *log.Entry) (int, string) {
+	body, err := readBody(request)
+	if err != nil {
+		return bodyError(writer, logger, err)
+	}
+	points, err := ParseLineProtocol(string(body), request.URL.Query().Get("precision"), time.Now())
+	if err != nil {
+		return restcore.WriteError(writer, logger, http.StatusBadRequest, err, false)
+	}
+	return writePoints(o, writer, request, logger, "Created by the InfluxDB line protocol endpoint", points)
+}
+
+//PingInflux responds to the pings that InfluxDB clients use to check that the database is up
+func PingInflux(o *authoperator.AuthOperator, writer http.ResponseWriter, request *http.Request, logger *log.Entry) (int, string) {
+	writer.WriteHeader(http.StatusNoContent)
+	return webcore.DEBUG, ""
+}
+
+//WritePrometheus inserts the samples of a Prometheus remote write request
+func WritePrometheus(o *authoperator.AuthOperator, writer http.ResponseWriter, request *http.Request, logger *log.Entry) (int, string) {
+	body, err := readBody(request)
+	if err != nil {
+		return bodyError(writer, logger, err)
+	}
+	points, err := ParseRemoteWrite(body)
+	if err != nil {
+		return bodyError(writer, logger, err)
+	}
+	return writePoints(o, writer, request, logger, "Created by the Prometheus remote write endpoint", points)
+}
+
+//Router returns a fully formed Gorilla router given an optional prefix. Data is written into the device in the path,
+//so telegraf's InfluxDB output is given the url /api/v1/ingest/influx/{user}/{device}, to which it adds /write,
+//and Prometheus' remote_write is given the url /api/v1/ingest/prometheus/{user}/{device}/write.
+func Router(db *connectordb.Database, prefix *mux.Router) *mux.Router {
+	if prefix == nil {
+		prefix = mux.NewRouter()
+	}
+
+	//Allow for the application to match /path and /path/ to the same place.
+	prefix.StrictSlash(true)
+
+	restcore.Describe(prefix.HandleFunc("/influx/{user}/{device}/write", restcore.Authenticator(WriteInflux, db)).Methods("POST"),
+		restcore.Endpoint{Summary: "Insert data written in the InfluxDB line protocol into the streams of the device, creating the streams which don't exist",
+			Params: []restcore.Param{{Name: "precision", Type: "string", Description: "The unit of the timestamps: n, ns, u, us, ms, s, m or h. It is ns if not given."}},
+			Status: http.StatusNoContent})
+	restcore.Describe(prefix.HandleFunc("/influx/{user}/{device}/ping", restcore.Authenticator(PingInflux, db)).Methods("GET", "HEAD"),
+		restcore.Endpoint{Summary: "Respond to the pings of InfluxDB clients", Status: http.StatusNoContent})
+	restcore.Describe(prefix.HandleFunc("/prometheus/{user}/{device}/write", restcore.Authenticator(WritePrometheus, db)).Methods("POST"),
+		restcore.Endpoint{Summary: "Insert the samples of a Prometheus remote write request into the streams of the device, creating the streams which don't exist",
+			Status: http.StatusNoContent})
+
+	return prefix
+}
@@ -0,0 +1,155 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package restapi
+
+import (
+	"config"
+	"connectordb/datastream"
+	"connectordb/messenger"
+	"connectordb/query"
+	"context"
+	"server/restapi/restcore"
+
+	"github.com/nats-io/nats"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+//liveQueryMessage is sent over the websocket with the results of a live query. The first message
+//of a query holds its historical result, and each following message holds the newly added results.
+type liveQueryMessage struct {
+	Query string                    `json:"query"`
+	Data  datastream.DatapointArray `json:"data"`
+	Error string                    `json:"error,omitempty"`
+}
+
+//LiveQuery runs a merge or dataset query for a websocket, and keeps sending the new results as data is inserted
+//into the query's streams. Inserts are only used as a trigger for reading the new data from the database,
+//so several inserts that arrive while the query is running are handled with a single update.
+type LiveQuery struct {
+	name  string
+	query *query.LiveQuery
+	conn  *WebsocketConnection
+
+	c      chan messenger.Message
+	subs   []*nats.Subscription
+	ctx    context.Context
+	cancel context.CancelFunc
+	stop   chan struct{}
+	done   chan struct{}
+
+	logger *log.Entry
+}
+
+//NewLiveQuery subscribes to the source streams of the given query. The query is started by calling Run.
+func NewLiveQuery(conn *WebsocketConnection, name string, q *query.LiveQuery) (*LiveQuery, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	lq := &LiveQuery{
+		name:   name,
+		query:  q,
+		conn:   conn,
+		c:      make(chan messenger.Message, config.Get().Websocket.MessageBuffer),
+		ctx:    ctx,
+		cancel: cancel,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+		logger: conn.logger.WithFields(log.Fields{"cmd": "query", "arg": name}),
+	}
+
+	//The streams are subscribed to before the query starts, so that no inserts are missed
+	for _, s := range q.Sources() {
+		subs, err := conn.o.Subscribe(s, lq.c)
+		if err != nil {
+			lq.unsubscribe()
+			cancel()
+			return nil, err
+		}
+		lq.subs = append(lq.subs, subs)
+	}
+	return lq, nil
+}
+
+func (lq *LiveQuery) unsubscribe() {
+	for _, s := range lq.subs {
+		s.Unsubscribe()
+	}
+}
+
+//send reads the range, and sends its datapoints to the websocket. It returns false if the query is to stop.
+func (lq *LiveQuery) send(dr datastream.DataRange, err error, first bool) bool {
+	msg := liveQueryMessage{Query: lq.name, Data: datastream.DatapointArray{}}
+	if err == nil {
+		var dp *datastream.Datapoint
+		for dp, err = dr.Next(); dp != nil && err == nil; dp, err = dr.Next() {
+			msg.Data = append(msg.Data, *dp)
+		}
+		dr.Close()
+	}
+	if err != nil {
+		lq.logger.Warningln(err)
+		msg.Error = err.Error()
+	} else if len(msg.Data) == 0 && !first {
+		return true
+	}
+
+	select {
+	case lq.conn.results <- msg:
+	case <-lq.stop:
+		return false
+	}
+	return err == nil
+}
+
+//Run sends the historical result of the query, followed by its new results each time one of its streams is inserted into.
+//It returns once the query is closed, or if the query fails.
+func (lq *LiveQuery) Run() {
+	defer close(lq.done)
+
+	qo, err := restcore.ContextQueryOperator(lq.conn.o, lq.ctx)
+	if err == nil {
+		dr, err := lq.query.Start(qo, lq.conn.o)
+		ok := lq.send(dr, err, true)
+		qo.Close()
+		if !ok {
+			return
+		}
+	} else if !lq.send(nil, err, true) {
+		return
+	}
+
+	for {
+		select {
+		case <-lq.stop:
+			return
+		case <-lq.c:
+		}
+		//Empty the channel, since a single update reads all of the new data
+		for len(lq.c) > 0 {
+			<-lq.c
+		}
+
+		//Each update gets its own query budget
+		qo, err := restcore.ContextQueryOperator(lq.conn.o, lq.ctx)
+		if err == nil {
+			dr, err := lq.query.Update(qo, lq.conn.o)
+			ok := lq.send(dr, err, false)
+			qo.Close()
+			if !ok {
+				return
+			}
+		} else if !lq.send(nil, err, false) {
+			return
+		}
+	}
+}
+
+//Close stops the live query, and waits for it to exit
+func (lq *LiveQuery) Close() {
+	lq.unsubscribe()
+	lq.cancel()
+	close(lq.stop)
+	<-lq.done
+	close(lq.c)
+}
@@ -10,6 +10,7 @@ import (
 	"server/restapi/restcore"
 	"server/webcore"
 	"strconv"
+	"sync"
 
 	"github.com/connectordb/pipescript"
 	"github.com/connectordb/pipescript/interpolator"
@@ -50,6 +51,23 @@ func Version(writer http.ResponseWriter, request *http.Request) {
 	writer.Write([]byte(connectordb.Version))
 }
 
+//OpenAPI returns the handler of the OpenAPI document which describes the routes of the api router.
+//The document is built on the first request, once all of the routes are registered.
+func OpenAPI(api *mux.Router) http.HandlerFunc {
+	var once sync.Once
+	var doc interface{}
+	var err error
+	return func(writer http.ResponseWriter, request *http.Request) {
+		l := webcore.GetRequestLogger(request, "OpenAPI")
+
+		webcore.WriteAccessControlHeaders(writer, request)
+		once.Do(func() {
+			doc, err = restcore.OpenAPI(api, connectordb.Name+" REST API", connectordb.Version)
+		})
+		restcore.JSONWriter(writer, doc, l, err)
+	}
+}
+
 //Router returns a fully formed Gorilla router given an optional prefix
 func Router(db *connectordb.Database, prefix *mux.Router) *mux.Router {
 	if prefix == nil {
@@ -59,9 +77,12 @@ func Router(db *connectordb.Database, prefix *mux.Router) *mux.Router {
 	//Allow for the application to match /path and /path/ to the same place.
 	prefix.StrictSlash(true)
 
-	prefix.HandleFunc("/transforms", http.HandlerFunc(TransformList)).Methods("GET")
-	prefix.HandleFunc("/interpolators", http.HandlerFunc(InterpolatorList)).Methods("GET")
-	prefix.HandleFunc("/version", http.HandlerFunc(Version)).Methods("GET")
+	restcore.Describe(prefix.HandleFunc("/transforms", http.HandlerFunc(TransformList)).Methods("GET"),
+		restcore.Endpoint{Summary: "List the PipeScript transforms, with their descriptions", Response: map[string]interface{}{}})
+	restcore.Describe(prefix.HandleFunc("/interpolators", http.HandlerFunc(InterpolatorList)).Methods("GET"),
+		restcore.Endpoint{Summary: "List the interpolators used by datasets, with their descriptions", Response: map[string]interface{}{}})
+	restcore.Describe(prefix.HandleFunc("/version", http.HandlerFunc(Version)).Methods("GET"),
+		restcore.Endpoint{Summary: "Get the version of ConnectorDB"})
 
 	return prefix
 }
@@ -0,0 +1,45 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package restapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"server/restapi/restcore"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAPI(t *testing.T) {
+	r, err := Router(nil, nil)
+	require.NoError(t, err)
+
+	doc, err := restcore.OpenAPI(r, "ConnectorDB REST API", "test")
+	require.NoError(t, err, "Every route of the REST API needs to be described with restcore.Describe")
+
+	b, err := json.Marshal(doc)
+	require.NoError(t, err)
+	var d struct {
+		Paths      map[string]map[string]map[string]interface{} `json:"paths"`
+		Components map[string]map[string]interface{}            `json:"components"`
+	}
+	require.NoError(t, json.Unmarshal(b, &d))
+
+	require.Contains(t, d.Paths, "/crud/{user}/{device}/{stream}/data")
+	data := d.Paths["/crud/{user}/{device}/{stream}/data"]
+	require.Contains(t, data, "get")
+	require.Contains(t, data, "post")
+	require.Contains(t, data, "put")
+	require.Contains(t, d.Paths, "/events/{path}")
+	require.Contains(t, d.Paths, "/meta/openapi.json")
+	require.Contains(t, d.Components["schemas"], "users.User")
+	require.Contains(t, d.Components["schemas"], "datastream.Datapoint")
+
+	//A route without a description fails the document
+	r.HandleFunc("/undescribed", func(writer http.ResponseWriter, request *http.Request) {}).Methods("GET")
+	_, err = restcore.OpenAPI(r, "ConnectorDB REST API", "test")
+	require.Error(t, err)
+}
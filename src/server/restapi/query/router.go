@@ -7,10 +7,12 @@ package query
 import (
 	"connectordb"
 	"connectordb/authoperator"
+	"connectordb/datastream"
 	"connectordb/query"
 	"fmt"
 	"net/http"
 	"server/restapi/restcore"
+	"time"
 
 	"github.com/gorilla/mux"
 
@@ -24,7 +26,19 @@ func GenerateDataset(o *authoperator.AuthOperator, writer http.ResponseWriter, r
 	if err != nil {
 		return restcore.WriteError(writer, logger, http.StatusBadRequest, err, false)
 	}
-	dr, err := datasetquery.Run(o)
+	qo, err := restcore.QueryOperator(o, request)
+	if err != nil {
+		return restcore.WriteError(writer, logger, http.StatusInternalServerError, err, true)
+	}
+	defer qo.Close()
+	start := time.Now()
+	dr, err := datasetquery.Run(qo)
+	if err == nil {
+		defer dr.Close()
+	}
+	if restcore.IsExplain(request) {
+		return restcore.WriteExplainResult(writer, dr, time.Since(start), logger, err)
+	}
 	return restcore.WriteJSONResult(writer, dr, logger, err)
 }
 
@@ -35,7 +49,19 @@ func MergeStreams(o *authoperator.AuthOperator, writer http.ResponseWriter, requ
 	if err != nil {
 		return restcore.WriteError(writer, logger, http.StatusBadRequest, err, false)
 	}
-	dr, err := query.Merge(o, mergequery)
+	qo, err := restcore.QueryOperator(o, request)
+	if err != nil {
+		return restcore.WriteError(writer, logger, http.StatusInternalServerError, err, true)
+	}
+	defer qo.Close()
+	start := time.Now()
+	dr, err := query.Merge(qo, mergequery)
+	if err == nil {
+		defer dr.Close()
+	}
+	if restcore.IsExplain(request) {
+		return restcore.WriteExplainResult(writer, dr, time.Since(start), logger, err)
+	}
 	lvl, _ := restcore.WriteJSONResult(writer, dr, logger, err)
 	return lvl, fmt.Sprintf("Merging %d streams", len(mergequery))
 }
@@ -49,8 +75,12 @@ func Router(db *connectordb.Database, prefix *mux.Router) *mux.Router {
 	//Allow for the application to match /path and /path/ to the same place.
 	prefix.StrictSlash(true)
 
-	prefix.HandleFunc("/dataset", restcore.Authenticator(GenerateDataset, db)).Methods("POST")
-	prefix.HandleFunc("/merge", restcore.Authenticator(MergeStreams, db)).Methods("POST")
+	restcore.Describe(prefix.HandleFunc("/dataset", restcore.Authenticator(GenerateDataset, db)).Methods("POST"),
+		restcore.Endpoint{Summary: "Generate a dataset of multiple streams, with the values of the streams at each time or datapoint of a reference stream",
+			Params: []restcore.Param{restcore.ExplainParam}, Request: query.DatasetQuery{}, Response: datastream.DatapointArray{}})
+	restcore.Describe(prefix.HandleFunc("/merge", restcore.Authenticator(MergeStreams, db)).Methods("POST"),
+		restcore.Endpoint{Summary: "Merge the data of multiple streams into one, ordered by timestamp",
+			Params: []restcore.Param{restcore.ExplainParam}, Request: []query.StreamQuery{}, Response: datastream.DatapointArray{}})
 
 	return prefix
 }
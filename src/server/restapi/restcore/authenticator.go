@@ -6,12 +6,24 @@ package restcore
 
 import (
 	"connectordb"
+	"connectordb/authoperator"
+	"connectordb/users"
+	"context"
 	"net/http"
 	"server/webcore"
 	"sync/atomic"
 	"time"
 )
 
+//operatorKey is the key of the request context which holds the operator given by WithOperator
+type operatorKey struct{}
+
+//WithOperator returns a copy of the request which is run as the given operator, without being authenticated again.
+//It allows a handler to run requests of its own through the REST API, as the device that it is run by.
+func WithOperator(request *http.Request, o *authoperator.AuthOperator) *http.Request {
+	return request.WithContext(context.WithValue(request.Context(), operatorKey{}, o))
+}
+
 //Authenticator runs authentication on a request, making sure that the REST API can handle it
 func Authenticator(apifunc webcore.APIHandler, db *connectordb.Database) http.HandlerFunc {
 	funcname := webcore.GetFuncName(apifunc)
@@ -36,9 +48,21 @@ func Authenticator(apifunc webcore.APIHandler, db *connectordb.Database) http.Ha
 
 		webcore.WriteAccessControlHeaders(writer,request)
 
-		o, err := webcore.Authenticate(db, request)
+		o, ok := request.Context().Value(operatorKey{}).(*authoperator.AuthOperator)
+		var err error
+		if !ok {
+			o, err = webcore.Authenticate(db, request)
+		}
 		if err != nil {
-			WriteError(writer, logger, http.StatusUnauthorized, err, false)
+			if err == users.ErrTOTPRequired || err == users.ErrInvalidTOTP {
+				// Let the client know that it needs to ask for the user's two-factor authentication code
+				writer.Header().Set(webcore.TOTPRequiredHeader, "true")
+			}
+			status := http.StatusUnauthorized
+			if err == webcore.ErrLoginBackoff || err == webcore.ErrLoginLocked {
+				status = http.StatusTooManyRequests
+			}
+			WriteError(writer, logger, status, err, false)
 			return
 		}
 		l := logger.WithField("dev", o.Name())
@@ -0,0 +1,307 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package restcore
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/mux"
+)
+
+//Param describes a query parameter that a route reads. The variables in the path, and the queries that the route
+//is matched on, such as q=ls, are found from the route itself, so they are not given as params.
+type Param struct {
+	Name        string
+	Description string
+	Type        string // The json type of the parameter: string, integer, number or boolean
+	Required    bool
+}
+
+//Endpoint describes what a route does, so that it can be included in the OpenAPI document of the REST API
+type Endpoint struct {
+	Summary string
+	Params  []Param
+
+	Request  interface{} // A value of the type that the json body of the request is unmarshalled into, or nil if the body is not read
+	Response interface{} // A value of the type written as json in the response, or nil if the response is not json
+
+	ContentType string // The content type of the response when it is not json. It is "text/plain" if not set.
+	Status      int    // The status code of a successful response. It is 200 if not set.
+}
+
+//The parameters shared by the routes which query data
+var (
+	TransformParam = Param{Name: "transform", Type: "string", Description: "A PipeScript transform run on the data"}
+	ExplainParam   = Param{Name: "explain", Type: "boolean", Description: "Return where the time was spent running the query instead of its data"}
+	DownlinkParam  = Param{Name: "downlink", Type: "boolean", Description: "Use the downlink of the stream, which holds the values that the owner wants the device to take on"}
+	IRangeParams   = []Param{
+		{Name: "i1", Type: "integer", Description: "The index of the first datapoint. Negative indices count from the end of the stream."},
+		{Name: "i2", Type: "integer", Description: "The index one past the last datapoint. Negative indices count from the end, and 0 is the end of the stream."},
+	}
+	TRangeParams = []Param{
+		{Name: "t1", Type: "number", Description: "The unix time of the first datapoint"},
+		{Name: "t2", Type: "number", Description: "The unix time at which the range ends. 0 is the end."},
+		{Name: "limit", Type: "integer", Description: "The largest number of datapoints to return"},
+	}
+)
+
+var (
+	endpointLock sync.RWMutex
+	endpoints    = make(map[*mux.Route]*Endpoint)
+
+	//pathVariable matches the variables of a path template, along with their optional patterns, such as {path:.+}
+	pathVariable = regexp.MustCompile(`{([^}:]+)(:[^}]*)?}`)
+)
+
+//Describe adds the description of the route to the OpenAPI document. Every route of the REST API needs to be described.
+func Describe(route *mux.Route, e Endpoint) *mux.Route {
+	endpointLock.Lock()
+	endpoints[route] = &e
+	endpointLock.Unlock()
+	return route
+}
+
+//openAPIDocument is the top level of an OpenAPI 3 document
+type openAPIDocument struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       map[string]string                       `json:"info"`
+	Paths      map[string]map[string]*openAPIOperation `json:"paths"`
+	Components map[string]map[string]Schema            `json:"components"`
+}
+
+type openAPIOperation struct {
+	Summary     string                      `json:"summary"`
+	Description string                      `json:"description,omitempty"`
+	Parameters  []*openAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *openAPIBody                `json:"requestBody,omitempty"`
+	Responses   map[string]*openAPIResponse `json:"responses"`
+}
+
+type openAPIParameter struct {
+	Name        string `json:"name"`
+	In          string `json:"in"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required"`
+	Schema      Schema `json:"schema"`
+}
+
+type openAPIBody struct {
+	Required bool                         `json:"required"`
+	Content  map[string]map[string]Schema `json:"content"`
+}
+
+type openAPIResponse struct {
+	Description string                       `json:"description"`
+	Content     map[string]map[string]Schema `json:"content,omitempty"`
+}
+
+//routeVariant is a single route, which shares its path and method with the routes that are matched on different queries
+type routeVariant struct {
+	queries  [][2]string
+	endpoint *Endpoint
+}
+
+//OpenAPI returns the OpenAPI document of all the routes of the router. Routes which are matched by the same path and
+//method, but different values of the query, such as q=ls and q=streams, are joined into one operation.
+//An error is returned if any of the routes has not been described.
+func OpenAPI(router *mux.Router, title, version string) (interface{}, error) {
+	endpointLock.RLock()
+	defer endpointLock.RUnlock()
+
+	variants := make(map[string]map[string][]routeVariant)
+	err := router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		if route.GetHandler() == nil {
+			//The route only holds a subrouter
+			return nil
+		}
+		tpl, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			methods = []string{"GET"}
+		}
+		queries, _ := route.GetQueriesTemplates()
+
+		e, ok := endpoints[route]
+		if !ok {
+			if len(queries) > 0 {
+				tpl += "?" + strings.Join(queries, "&")
+			}
+			return fmt.Errorf("The route %s %s has no description", strings.Join(methods, ","), tpl)
+		}
+
+		v := routeVariant{endpoint: e}
+		for _, q := range queries {
+			kv := strings.SplitN(q, "=", 2)
+			if len(kv) == 2 {
+				v.queries = append(v.queries, [2]string{kv[0], kv[1]})
+			}
+		}
+		tpl = pathVariable.ReplaceAllString(tpl, "{$1}")
+		if variants[tpl] == nil {
+			variants[tpl] = make(map[string][]routeVariant)
+		}
+		for _, m := range methods {
+			m = strings.ToLower(m)
+			variants[tpl][m] = append(variants[tpl][m], v)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	b := &schemaBuilder{components: make(map[string]Schema)}
+	doc := &openAPIDocument{
+		OpenAPI:    "3.0.0",
+		Info:       map[string]string{"title": title, "version": version},
+		Paths:      make(map[string]map[string]*openAPIOperation),
+		Components: map[string]map[string]Schema{"schemas": b.components},
+	}
+	for tpl, methods := range variants {
+		doc.Paths[tpl] = make(map[string]*openAPIOperation)
+		for m, v := range methods {
+			doc.Paths[tpl][m] = b.operation(tpl, v)
+		}
+	}
+	return doc, nil
+}
+
+//operation builds the operation of the routes which share a path and method
+func (b *schemaBuilder) operation(tpl string, variants []routeVariant) *openAPIOperation {
+	op := &openAPIOperation{Responses: make(map[string]*openAPIResponse)}
+
+	for _, v := range pathVariable.FindAllStringSubmatch(tpl, -1) {
+		op.Parameters = append(op.Parameters, &openAPIParameter{Name: v[1], In: "path", Required: true, Schema: Schema{"type": "string"}})
+	}
+
+	//The queries that the routes are matched on become parameters which take one of the matched values.
+	//They are only required if every route has them.
+	queryValues := make(map[string][]interface{})
+	var queryNames []string
+	hasDefault := false
+	for _, v := range variants {
+		if len(v.queries) == 0 {
+			hasDefault = true
+		}
+		for _, q := range v.queries {
+			if _, ok := queryValues[q[0]]; !ok {
+				queryNames = append(queryNames, q[0])
+			}
+			queryValues[q[0]] = append(queryValues[q[0]], q[1])
+		}
+	}
+	for _, name := range queryNames {
+		op.Parameters = append(op.Parameters, &openAPIParameter{
+			Name:     name,
+			In:       "query",
+			Required: !hasDefault && len(queryValues[name]) == len(variants),
+			Schema:   Schema{"type": "string", "enum": queryValues[name]},
+		})
+	}
+
+	seen := make(map[string]bool)
+	var requests []Schema
+	responses := make(map[string][]Schema)
+	var descriptions []string
+	for _, v := range variants {
+		e := v.endpoint
+		for _, p := range e.Params {
+			if seen[p.Name] || queryValues[p.Name] != nil {
+				continue
+			}
+			seen[p.Name] = true
+			op.Parameters = append(op.Parameters, &openAPIParameter{Name: p.Name, In: "query", Description: p.Description, Required: p.Required, Schema: Schema{"type": p.Type}})
+		}
+
+		if len(v.queries) == 0 || op.Summary == "" {
+			op.Summary = e.Summary
+		}
+		if len(variants) > 1 {
+			var q []string
+			for _, kv := range v.queries {
+				q = append(q, kv[0]+"="+kv[1])
+			}
+			if len(q) == 0 {
+				q = []string{"no query"}
+			}
+			descriptions = append(descriptions, fmt.Sprintf("- %s: %s", strings.Join(q, "&"), e.Summary))
+		}
+
+		if s := b.schemaOf(e.Request); s != nil {
+			requests = append(requests, s)
+		}
+
+		status := e.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		code := fmt.Sprint(status)
+		res, ok := op.Responses[code]
+		if !ok {
+			res = &openAPIResponse{Description: http.StatusText(status)}
+			op.Responses[code] = res
+		}
+		if status == http.StatusNoContent || status == http.StatusSwitchingProtocols {
+			continue
+		}
+		if s := b.schemaOf(e.Response); s != nil {
+			responses[code] = append(responses[code], s)
+			continue
+		}
+		contentType := e.ContentType
+		if contentType == "" {
+			contentType = "text/plain"
+		}
+		if res.Content == nil {
+			res.Content = make(map[string]map[string]Schema)
+		}
+		res.Content[contentType] = map[string]Schema{"schema": {"type": "string"}}
+	}
+	if len(descriptions) > 0 {
+		sort.Strings(descriptions)
+		op.Description = "The query chooses what the request does:\n" + strings.Join(descriptions, "\n")
+	}
+
+	if len(requests) > 0 {
+		op.RequestBody = &openAPIBody{Required: len(requests) == len(variants), Content: map[string]map[string]Schema{
+			"application/json": {"schema": oneOf(requests)},
+		}}
+	}
+	for code, schemas := range responses {
+		res := op.Responses[code]
+		if res.Content == nil {
+			res.Content = make(map[string]map[string]Schema)
+		}
+		res.Content["application/json"] = map[string]Schema{"schema": oneOf(schemas)}
+	}
+	return op
+}
+
+//oneOf returns the schema which is any of the given schemas, leaving out the repeated ones
+func oneOf(schemas []Schema) Schema {
+	var unique []Schema
+	seen := make(map[string]bool)
+	for _, s := range schemas {
+		//The json of maps has sorted keys, so equal schemas have equal json
+		b, _ := json.Marshal(s)
+		if !seen[string(b)] {
+			seen[string(b)] = true
+			unique = append(unique, s)
+		}
+	}
+	if len(unique) == 1 {
+		return unique[0]
+	}
+	return Schema{"oneOf": unique}
+}
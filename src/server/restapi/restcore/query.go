@@ -0,0 +1,104 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package restcore
+
+import (
+	pconfig "config/permissions"
+	"connectordb"
+	"connectordb/authoperator"
+	"connectordb/authoperator/permissions"
+	"connectordb/datastream"
+	"connectordb/query"
+	"connectordb/users"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// QueryOperator returns the operator used to run range, merge and dataset queries for the given request.
+// The queries are limited by the query budget of the logged in device's role, and stop reading data
+// as soon as the request is canceled (such as when the client closes the connection).
+// If the database has a query cache, the stream data is read through the cache. The returned operator
+// needs to be closed once its ranges are closed.
+func QueryOperator(o *authoperator.AuthOperator, request *http.Request) (*query.BudgetOperator, error) {
+	return ContextQueryOperator(o, request.Context())
+}
+
+// ContextQueryOperator is the same as QueryOperator, but for queries which are not tied to a single request,
+// such as the live queries of a websocket. The queries stop reading data once ctx is done, or once they run out
+// of time. The returned operator needs to be closed once its ranges are closed.
+func ContextQueryOperator(o *authoperator.AuthOperator, ctx context.Context) (*query.BudgetOperator, error) {
+	u, d, err := o.UserAndDevice()
+	if err != nil {
+		return nil, err
+	}
+
+	// The time limit is given to the context, so that a slow read from the databases is stopped as soon as
+	// the query runs out of time, rather than once the read returns
+	datapoints, querytime, streams := permissions.GetQueryBudget(pconfig.Get(), u, d)
+	var cancel context.CancelFunc
+	if querytime > 0 {
+		ctx, cancel = context.WithTimeout(ctx, querytime)
+	}
+
+	var qo query.Operator = o
+	if db, ok := o.AdminOperator().(*connectordb.Database); ok {
+		// The queries only read, so they can skip the operator's meta log, and read through a database
+		// which stops the sql queries of the streams once ctx is done
+		o = o.WithOperator(db.WithContext(ctx))
+		qo = o
+		if db.QueryCache != nil {
+			qo = query.NewCachedOperator(o, db.QueryCache)
+		}
+	}
+
+	bo := query.NewBudgetOperator(qo, query.NewBudget(ctx, datapoints, querytime, streams))
+	bo.Cancel = cancel
+	return bo, nil
+}
+
+// IsExplain returns true if the request has explain=true set in its query, meaning that the
+// profile of the query is to be returned instead of its data.
+func IsExplain(request *http.Request) bool {
+	v, err := strconv.ParseBool(request.URL.Query().Get("explain"))
+	return err == nil && v
+}
+
+// QueryErrorStatus returns the status code of a query which failed to start. Queries of resources that the device
+// can't access are forbidden, and failures of the databases are internal errors. Any other error is in the query itself.
+func QueryErrorStatus(err error) int {
+	switch err {
+	case permissions.ErrNoAccess, authoperator.ErrTokenRestricted:
+		return http.StatusForbidden
+	case users.ErrUserNotFound, users.ErrDeviceNotFound, users.ErrStreamNotFound:
+		return http.StatusNotFound
+	case datastream.ErrorDatabaseCorrupted, datastream.ErrWTF, datastream.ErrDecompress, datastream.ErrorVersion,
+		sql.ErrTxDone, driver.ErrBadConn:
+		return http.StatusInternalServerError
+	}
+	if _, ok := err.(net.Error); ok {
+		return http.StatusInternalServerError
+	}
+	return http.StatusBadRequest
+}
+
+// WriteExplainResult is WriteJSONResult's counterpart for queries with explain set. It runs the entire query,
+// and writes where the time was spent in each part of the range stack. setup is the time it took to create dr.
+func WriteExplainResult(writer http.ResponseWriter, dr datastream.DataRange, setup time.Duration, logger *log.Entry, err error) (int, string) {
+	if err != nil {
+		status := QueryErrorStatus(err)
+		return WriteError(writer, logger, status, err, status == http.StatusInternalServerError)
+	}
+	e := query.Explain(dr, setup)
+	lvl, _ := JSONWriter(writer, e, logger, nil)
+	return lvl, fmt.Sprintf("explain (%.3fs, %d datapoints)", e.Time, e.Datapoints)
+}
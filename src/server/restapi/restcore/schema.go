@@ -0,0 +1,125 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package restcore
+
+import (
+	"encoding/json"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+//Schema is a json schema in the dialect used by OpenAPI
+type Schema map[string]interface{}
+
+//schemaBuilder builds the schemas of go types from the way that encoding/json marshals them. Named structs are
+//added to the components of the document, and referred to, so that each is written once and recursive types work.
+type schemaBuilder struct {
+	components map[string]Schema
+}
+
+//componentName gives the name of a struct within the components of the document, such as "users.User"
+func componentName(t reflect.Type) string {
+	return path.Base(t.PkgPath()) + "." + t.Name()
+}
+
+//schemaOf returns the schema of the json of the value, or nil if v is nil
+func (b *schemaBuilder) schemaOf(v interface{}) Schema {
+	if v == nil {
+		return nil
+	}
+	return b.schema(reflect.TypeOf(v))
+}
+
+func (b *schemaBuilder) schema(t reflect.Type) Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return Schema{"type": "string", "format": "date-time"}
+	}
+	if t.Implements(marshalerType) || reflect.PtrTo(t).Implements(marshalerType) {
+		//The type writes its own json, so nothing can be said about it
+		return Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return Schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return Schema{"type": "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return Schema{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return Schema{"type": "number"}
+	case reflect.String:
+		return Schema{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return Schema{"type": "string", "format": "byte"}
+		}
+		return Schema{"type": "array", "items": b.schema(t.Elem())}
+	case reflect.Map:
+		return Schema{"type": "object", "additionalProperties": b.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.structSchema(t)
+		}
+		name := componentName(t)
+		if _, ok := b.components[name]; !ok {
+			//The component is added before its fields are built, so that a struct which contains itself refers to itself
+			b.components[name] = Schema{}
+			b.components[name] = b.structSchema(t)
+		}
+		return Schema{"$ref": "#/components/schemas/" + name}
+	}
+	//Interfaces can hold any json
+	return Schema{}
+}
+
+//structSchema returns the schema of the object that a struct is marshalled to
+func (b *schemaBuilder) structSchema(t reflect.Type) Schema {
+	properties := make(map[string]Schema)
+	b.addFields(t, properties, false)
+	return Schema{"type": "object", "properties": properties}
+}
+
+//addFields adds the fields of the struct to properties. Like in encoding/json, the fields of embedded
+//structs without a json name are promoted to the outer object, unless the outer object has a field of the same name.
+func (b *schemaBuilder) addFields(t reflect.Type, properties map[string]Schema, embedded bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			b.addFields(ft, properties, true)
+			continue
+		}
+		if f.PkgPath != "" {
+			//The field is unexported
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if _, ok := properties[name]; ok && embedded {
+			continue
+		}
+		properties[name] = b.schema(f.Type)
+	}
+}
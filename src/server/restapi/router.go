@@ -18,6 +18,7 @@ import (
 
 	"server/restapi/crud"
 	"server/restapi/feed"
+	"server/restapi/ingest"
 	"server/restapi/meta"
 	"server/restapi/query"
 	"server/restapi/restcore"
@@ -76,11 +77,12 @@ func Logout(o *authoperator.AuthOperator, writer http.ResponseWriter, request *h
 //Allows to fit the Closer interface
 type restcloser struct{}
 
-//CLose shuts down the rest server, and makes sure all websockets have exited
+//CLose shuts down the rest server, and makes sure all websockets and event streams have exited
 func (r restcloser) Close() {
 	webcore.Shutdown() //This is a sort of roundabout way of shutting everything down.
 	//might want to refactor the above down a directory level at some point
 	websocketWaitGroup.Wait()
+	eventStreamWaitGroup.Wait()
 }
 
 //Router returns a fully formed Gorilla router given an optional prefix
@@ -101,22 +103,44 @@ func Router(db *connectordb.Database, prefix *mux.Router) (*mux.Router, error) {
 	//Allow for the application to match /path and /path/ to the same place.
 	prefix.StrictSlash(true)
 
-	prefix.HandleFunc("/", restcore.Authenticator(GetThis, db)).Queries("q", "this").Methods("GET")
-	prefix.HandleFunc("/", restcore.Authenticator(CountAllUsers, db)).Queries("q", "countusers").Methods("GET")
-	prefix.HandleFunc("/", restcore.Authenticator(CountAllDevices, db)).Queries("q", "countdevices").Methods("GET")
-	prefix.HandleFunc("/", restcore.Authenticator(CountAllStreams, db)).Queries("q", "countstreams").Methods("GET")
+	restcore.Describe(prefix.HandleFunc("/", restcore.Authenticator(GetThis, db)).Queries("q", "this").Methods("GET"),
+		restcore.Endpoint{Summary: "Get the path of the logged in device, as user/device"})
+	restcore.Describe(prefix.HandleFunc("/", restcore.Authenticator(CountAllUsers, db)).Queries("q", "countusers").Methods("GET"),
+		restcore.Endpoint{Summary: "Count the users in the database"})
+	restcore.Describe(prefix.HandleFunc("/", restcore.Authenticator(CountAllDevices, db)).Queries("q", "countdevices").Methods("GET"),
+		restcore.Endpoint{Summary: "Count the devices in the database"})
+	restcore.Describe(prefix.HandleFunc("/", restcore.Authenticator(CountAllStreams, db)).Queries("q", "countstreams").Methods("GET"),
+		restcore.Endpoint{Summary: "Count the streams in the database"})
 
 	// The websocket is run straight from here
-	prefix.HandleFunc("/websocket", restcore.Authenticator(RunWebsocket, db)).Headers("Upgrade", "websocket").Methods("GET")
+	restcore.Describe(prefix.HandleFunc("/websocket", restcore.Authenticator(RunWebsocket, db)).Headers("Upgrade", "websocket").Methods("GET"),
+		restcore.Endpoint{Summary: "Open a websocket, through which data is inserted, and streams are subscribed to", Status: http.StatusSwitchingProtocols})
+
+	// Server-sent events are an alternative to the websocket for subscribing to streams
+	restcore.Describe(prefix.HandleFunc("/events/{path:.+}", restcore.Authenticator(RunEventStream, db)).Methods("GET"),
+		restcore.Endpoint{Summary: "Subscribe to the stream, device or user in the path, receiving its datapoints as server-sent events",
+			Params: []restcore.Param{restcore.TransformParam}, ContentType: "text/event-stream"})
 
 	crud.Router(db, prefix.PathPrefix("/crud").Subrouter())
 	query.Router(db, prefix.PathPrefix("/query").Subrouter())
 	feed.Router(db, prefix.PathPrefix("/feed").Subrouter())
-	meta.Router(db, prefix.PathPrefix("/meta").Subrouter())
+	m := meta.Router(db, prefix.PathPrefix("/meta").Subrouter())
+	ingest.Router(db, prefix.PathPrefix("/ingest").Subrouter())
+
+	//Batches run their operations through the rest of the api
+	restcore.Describe(prefix.HandleFunc("/batch", restcore.Authenticator(RunBatch(prefix), db)).Methods("POST"),
+		restcore.Endpoint{Summary: "Run a list of crud operations in order, optionally all-or-nothing, returning the result of each",
+			Request: Batch{}, Response: BatchResponse{}})
 
 	//login and Logout of the system
-	prefix.HandleFunc("/login", restcore.Authenticator(Login, db)).Methods("GET")
-	prefix.HandleFunc("/logout", restcore.Authenticator(Logout, db)).Methods("GET")
+	restcore.Describe(prefix.HandleFunc("/login", restcore.Authenticator(Login, db)).Methods("GET"),
+		restcore.Endpoint{Summary: "Log in to the web interface, setting the session cookie"})
+	restcore.Describe(prefix.HandleFunc("/logout", restcore.Authenticator(Logout, db)).Methods("GET"),
+		restcore.Endpoint{Summary: "Log out of the web interface, deleting the session cookie"})
+
+	//The OpenAPI document describes all of the routes, so it is added once the rest are registered
+	restcore.Describe(m.HandleFunc("/openapi.json", meta.OpenAPI(prefix)).Methods("GET"),
+		restcore.Endpoint{Summary: "Get the OpenAPI document of the REST API", Response: map[string]interface{}{}})
 
 	//Now that things are running, we want the ability to do a clean shutdown of REST
 	util.CloseOnExit(restcloser{})
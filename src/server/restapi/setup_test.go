@@ -0,0 +1,22 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package restapi
+
+import (
+	"config"
+	"connectordb"
+	"log"
+)
+
+var tdb *connectordb.Database
+
+func init() {
+	db, err := connectordb.Open(config.TestConfiguration.Options())
+	if err != nil {
+		log.Fatal(err)
+	}
+	tdb = db
+	go db.RunWriter()
+}
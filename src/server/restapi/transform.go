@@ -0,0 +1,78 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package restapi
+
+import (
+	"connectordb/authoperator"
+	"connectordb/datastream"
+	"connectordb/messenger"
+	"connectordb/query"
+
+	"github.com/connectordb/pipescript"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+//The maximum number of datapoints sent in a single message when replaying a stream's data
+const replayBatch = 1000
+
+//transformMessage runs the transform on the data of the message. It returns nil if the transform filtered out all of the data.
+func transformMessage(msg messenger.Message, transform string, tf *pipescript.Script) (*messenger.Message, error) {
+	if tf == nil {
+		return &msg, nil
+	}
+	dpa, err := query.TransformArray(tf, &msg.Data)
+	if err != nil {
+		return nil, err
+	}
+	if dpa.Length() <= 0 {
+		return nil, nil
+	}
+	return &messenger.Message{msg.Stream, transform, *dpa}, nil
+}
+
+//replayStream reads the stream's data starting at the given index, and sends it in batches with the transform applied.
+//Each batch is sent along with the index of the datapoint that follows it. It returns the index of the next datapoint to read.
+//Failed reads are only logged, so that they are retried the next time, while the errors of the transform and of send are returned.
+func replayStream(o *authoperator.AuthOperator, stream, transform string, tf *pipescript.Script, index int64,
+	logger *log.Entry, send func(msg messenger.Message, next int64) error) (int64, error) {
+
+	dr, err := o.GetStreamIndexRange(stream, index, 0, "")
+	if err != nil {
+		logger.Warningln("Replay failed: ", err)
+		return index, nil
+	}
+	defer dr.Close()
+
+	for {
+		dpa := make(datastream.DatapointArray, 0, replayBatch)
+		dp, err := dr.Next()
+		for ; dp != nil && err == nil; dp, err = dr.Next() {
+			dpa = append(dpa, *dp)
+			if len(dpa) >= replayBatch {
+				break
+			}
+		}
+		if err != nil {
+			logger.Warningln("Replay failed: ", err)
+			return index, nil
+		}
+		if len(dpa) == 0 {
+			return index, nil
+		}
+		index += int64(len(dpa))
+
+		msg, err := transformMessage(messenger.Message{stream, "", dpa}, transform, tf)
+		if err != nil {
+			return index, err
+		}
+		if msg != nil {
+			logger.Debugf("<- replay %d", msg.Data.Length())
+			if err = send(*msg, index); err != nil {
+				return index, err
+			}
+		}
+	}
+}
@@ -12,12 +12,15 @@ import (
 	"connectordb/query"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"server/webcore"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
+	"util"
 
 	"github.com/connectordb/pipescript"
 	"github.com/gorilla/websocket"
@@ -36,6 +39,14 @@ const (
 	webSocketClosedNonClean = "@EXIT"
 )
 
+//The versions of the websocket protocol. In the legacy version, only the commands which have an id are replied to.
+//From version 2, every command is replied to, so clients know whether each insert and subscription succeeded.
+//Clients choose the version with the "version" command.
+const (
+	websocketLegacyVersion   = 1
+	WebsocketProtocolVersion = 2
+)
+
 //The websocket upgrader
 var (
 	// upgrader is initialized in the router
@@ -51,12 +62,22 @@ type Subscription struct {
 	nats *nats.Subscription //The nats subscription
 
 	transform map[string]*pipescript.Script //the transforms associated with the subscription - this allows us to run transforms on the data!
+
+	//The transforms which were subscribed with a replay get their data from the database rather than from the messages,
+	//until the replay catches up with the stream. This holds the index of the next datapoint to read for each of them.
+	replay map[string]int64
+
+	//The messages which were queued while a replay caught up hold data that the replay already sent. This holds the
+	//timestamp of the last replayed datapoint of each transform, until a message holds data after it.
+	replayed map[string]float64
 }
 
 func NewSubscription(subs *nats.Subscription) *Subscription {
 	return &Subscription{
 		nats:      subs,
 		transform: make(map[string]*pipescript.Script),
+		replay:    make(map[string]int64),
+		replayed:  make(map[string]float64),
 	}
 }
 
@@ -78,6 +99,21 @@ func (s *Subscription) Size() int {
 func (s *Subscription) AddTransform(transform string) (err error) {
 	s.Lock()
 	defer s.Unlock()
+	return s.addTransform(transform)
+}
+
+//AddReplayTransform adds a transform subscription which first gets the stream's data starting at the given index,
+//and then continues with the data as it is inserted
+func (s *Subscription) AddReplayTransform(transform string, index int64) (err error) {
+	s.Lock()
+	defer s.Unlock()
+	if err = s.addTransform(transform); err == nil {
+		s.replay[transform] = index
+	}
+	return err
+}
+
+func (s *Subscription) addTransform(transform string) (err error) {
 	if _, ok := s.transform[transform]; ok {
 		return errors.New("Subscription to the transform already exists")
 	}
@@ -100,10 +136,51 @@ func (s *Subscription) AddTransform(transform string) (err error) {
 func (s *Subscription) RemTransform(transform string) (err error) {
 	s.Lock()
 	delete(s.transform, transform)
+	delete(s.replay, transform)
+	delete(s.replayed, transform)
 	s.Unlock()
 	return nil
 }
 
+//endReplay makes the transform use the data of the messages, once its replay caught up with the stream at the given
+//index. If the stream has more data, such as when the read failed, or the last replayed datapoint can't be read,
+//the transform keeps reading the stream.
+func (s *Subscription) endReplay(o *authoperator.AuthOperator, stream, transform string, index int64) {
+	if length, err := o.LengthStream(stream); err != nil || length != index {
+		return
+	}
+	if index > 0 {
+		dr, err := o.GetStreamIndexRange(stream, index-1, index, "")
+		if err != nil {
+			return
+		}
+		defer dr.Close()
+		dp, err := dr.Next()
+		if err != nil || dp == nil {
+			return
+		}
+		s.replayed[transform] = dp.Timestamp
+	}
+	delete(s.replay, transform)
+}
+
+//skipReplayed removes the datapoints of the message which the transform's replay already sent
+func (s *Subscription) skipReplayed(msg messenger.Message, transform string) messenger.Message {
+	last, ok := s.replayed[transform]
+	if !ok {
+		return msg
+	}
+	i := 0
+	for i < len(msg.Data) && msg.Data[i].Timestamp <= last {
+		i++
+	}
+	if i < len(msg.Data) {
+		delete(s.replayed, transform)
+	}
+	msg.Data = msg.Data[i:]
+	return msg
+}
+
 //WebsocketConnection is the general connection with a websocket that is run.
 //Loosely based on github.com/gorilla/websocket/blob/master/examples/chat/conn.go
 //No need for mutex because only reader reads and implements commands
@@ -113,11 +190,16 @@ type WebsocketConnection struct {
 	ws *websocket.Conn
 
 	subscriptions map[string]*Subscription
+	queries       map[string]*LiveQuery
 
-	c chan messenger.Message
+	c       chan messenger.Message
+	results chan liveQueryMessage //The results of live queries, which are sent by the writer
 
 	logger *log.Entry //logrus uses a mutex internally
 	o      *authoperator.AuthOperator
+
+	version int                 //The protocol version used by the client. It is only used by the reader.
+	replies chan websocketReply //The replies to commands, which are sent by the writer
 }
 
 //NewWebsocketConnection creates a new websocket connection based on the operators and stuff
@@ -131,7 +213,9 @@ func NewWebsocketConnection(o *authoperator.AuthOperator, writer http.ResponseWr
 
 	ws.SetReadLimit(config.Get().Websocket.MessageLimitBytes)
 
-	return &WebsocketConnection{sync.RWMutex{}, ws, make(map[string]*Subscription), make(chan messenger.Message, config.Get().Websocket.MessageBuffer), logger, o}, nil
+	return &WebsocketConnection{sync.RWMutex{}, ws, make(map[string]*Subscription), make(map[string]*LiveQuery),
+		make(chan messenger.Message, config.Get().Websocket.MessageBuffer), make(chan liveQueryMessage, config.Get().Websocket.MessageBuffer), logger, o,
+		websocketLegacyVersion, make(chan websocketReply, config.Get().Websocket.MessageBuffer)}, nil
 }
 
 func (c *WebsocketConnection) write(obj interface{}) error {
@@ -151,6 +235,7 @@ func (c *WebsocketConnection) write(obj interface{}) error {
 //Close the websocket connection
 func (c *WebsocketConnection) Close() {
 	c.UnsubscribeAll()
+	c.StopQueries()
 	close(c.c)
 	c.ws.Close()
 	c.logger.WithField("cmd", "close").Debugln()
@@ -172,22 +257,33 @@ func (c *WebsocketConnection) CheckSubscriptions() error {
 }
 
 //Insert a datapoint using the websocket
-func (c *WebsocketConnection) Insert(ws *websocketCommand) {
+func (c *WebsocketConnection) Insert(ws *websocketCommand) error {
 	logger := c.logger.WithFields(log.Fields{"cmd": "insert", "arg": ws.Arg})
 	logger.Debugln("-> insert ", len(ws.D), "dp")
 	err := c.o.InsertStream(ws.Arg, ws.D, true)
 	if err != nil {
-		//TODO: Notify user of insert failure
 		logger.Warn(err.Error())
-	} else {
-		atomic.AddUint32(&webcore.StatsInserts, uint32(len(ws.D)))
+		return err
 	}
+	atomic.AddUint32(&webcore.StatsInserts, uint32(len(ws.D)))
+	return nil
 }
 
-//Subscribe to the given data stream
-func (c *WebsocketConnection) Subscribe(s, transform string) {
+//Subscribe to the given data stream. If a starting index or timestamp is given, the subscription first replays
+//the stream's data from that point on, and then continues with the live data.
+func (c *WebsocketConnection) Subscribe(s, transform string, i1 *int64, t1 *float64) error {
 	logger := c.logger.WithFields(log.Fields{"cmd": "subscribe", "arg": s})
 
+	replay := i1 != nil || t1 != nil
+	var index int64
+	if replay {
+		var err error
+		if index, err = c.replayIndex(s, i1, t1); err != nil {
+			logger.Warningln(err)
+			return err
+		}
+	}
+
 	//Next check if nats is subscribed
 	c.RLock()
 	_, ok := c.subscriptions[s]
@@ -196,23 +292,77 @@ func (c *WebsocketConnection) Subscribe(s, transform string) {
 		subs, err := c.o.Subscribe(s, c.c)
 		if err != nil {
 			logger.Warningln(err)
-		} else {
-			logger.Debugln("Initializing subscription")
-			c.Lock()
-			c.subscriptions[s] = NewSubscription(subs)
-			c.Unlock()
+			return err
 		}
+		logger.Debugln("Initializing subscription")
+		c.Lock()
+		c.subscriptions[s] = NewSubscription(subs)
+		c.Unlock()
 	}
 	c.Lock()
-	err := c.subscriptions[s].AddTransform(transform)
+	var err error
+	if replay {
+		err = c.subscriptions[s].AddReplayTransform(transform, index)
+	} else {
+		err = c.subscriptions[s].AddTransform(transform)
+	}
 	c.Unlock()
 	if err != nil {
 		logger.Warningln(err)
+		return err
+	}
+
+	if replay {
+		//A message without data makes the writer send the replayed data without waiting for an insert
+		logger.Debugln("Replaying from index ", index)
+		select {
+		case c.c <- messenger.Message{Stream: s}:
+		case <-time.After(config.Get().Websocket.WriteWait * time.Second):
+			logger.Warningln("The writer is not keeping up: replay delayed until the next insert")
+		}
 	}
+	return nil
+}
+
+//replayIndex returns the index of the stream's datapoint that a replay starts at. Negative indices count from the end of the stream.
+func (c *WebsocketConnection) replayIndex(s string, i1 *int64, t1 *float64) (int64, error) {
+	if i1 != nil && t1 != nil {
+		return 0, errors.New("A replay can start either at an index or at a timestamp, not both")
+	}
+	_, _, streampath, _, _, err := util.SplitStreamPath(s)
+	if err != nil {
+		return 0, err
+	}
+	strm, err := c.o.ReadStream(streampath)
+	if err != nil {
+		return 0, err
+	}
+	if strm.Ephemeral {
+		return 0, errors.New("Ephemeral streams don't save their data, so they can't be replayed")
+	}
+	if t1 != nil {
+		return c.o.TimeToIndexStream(s, *t1)
+	}
+
+	length, err := c.o.LengthStream(s)
+	if err != nil {
+		return 0, err
+	}
+	index := *i1
+	if index < 0 {
+		index += length
+		if index < 0 {
+			index = 0
+		}
+	}
+	if index > length {
+		index = length
+	}
+	return index, nil
 }
 
 //Unsubscribe from the given data stream
-func (c *WebsocketConnection) Unsubscribe(s, transform string) {
+func (c *WebsocketConnection) Unsubscribe(s, transform string) error {
 	logger := c.logger.WithFields(log.Fields{"cmd": "unsubscribe", "arg": s})
 	c.RLock()
 	val, ok := c.subscriptions[s]
@@ -228,9 +378,10 @@ func (c *WebsocketConnection) Unsubscribe(s, transform string) {
 			logger.Debugln()
 		}
 		c.Unlock()
-	} else {
-		logger.Warningln("subscription DNE")
+		return nil
 	}
+	logger.Warningln("subscription DNE")
+	return errors.New("Not subscribed to the stream")
 }
 
 //UnsubscribeAll from all streams of data
@@ -244,15 +395,118 @@ func (c *WebsocketConnection) UnsubscribeAll() {
 	c.Unlock()
 }
 
+//StartQuery starts a live merge or dataset query with the given name
+func (c *WebsocketConnection) StartQuery(name string, q *query.LiveQuery) error {
+	logger := c.logger.WithFields(log.Fields{"cmd": "subscribe_query", "arg": name})
+	if q == nil {
+		logger.Warningln("No query given")
+		return errors.New("No query given")
+	}
+	c.Lock()
+	defer c.Unlock()
+	if _, ok := c.queries[name]; ok {
+		logger.Warningln("Live query already exists")
+		return errors.New("Live query already exists")
+	}
+	lq, err := NewLiveQuery(c, name, q)
+	if err != nil {
+		logger.Warningln(err)
+		return err
+	}
+	logger.Debugln("Starting live query")
+	c.queries[name] = lq
+	go lq.Run()
+	return nil
+}
+
+//StopQuery stops the live query with the given name
+func (c *WebsocketConnection) StopQuery(name string) error {
+	logger := c.logger.WithFields(log.Fields{"cmd": "unsubscribe_query", "arg": name})
+	c.Lock()
+	lq, ok := c.queries[name]
+	delete(c.queries, name)
+	c.Unlock()
+	if !ok {
+		logger.Warningln("live query DNE")
+		return errors.New("Live query does not exist")
+	}
+	logger.Debugln("stop live query")
+	lq.Close()
+	return nil
+}
+
+//SetVersion sets the protocol version used by the client. If the client supports a newer version than
+//the server, the server's version is used.
+func (c *WebsocketConnection) SetVersion(version string) error {
+	v, err := strconv.Atoi(version)
+	if err != nil || v < websocketLegacyVersion {
+		return fmt.Errorf("Invalid protocol version '%s'", version)
+	}
+	if v > WebsocketProtocolVersion {
+		v = WebsocketProtocolVersion
+	}
+	c.version = v
+	return nil
+}
+
+//StopQueries stops all of the live queries
+func (c *WebsocketConnection) StopQueries() {
+	c.Lock()
+	queries := c.queries
+	c.queries = make(map[string]*LiveQuery)
+	c.Unlock()
+	for key, lq := range queries {
+		c.logger.Debugf("Stop live query: %s", key)
+		lq.Close()
+	}
+}
+
 //A command is a cmd and the arg operation
 type websocketCommand struct {
-	Cmd       string `json:"cmd"`
-	Arg       string `json:"arg"`
-	Transform string `json:"transform"` //Allows subscribing with a transform
+	ID        interface{} `json:"id,omitempty"` //An optional id chosen by the client, which is included in the command's reply
+	Cmd       string      `json:"cmd"`
+	Arg       string      `json:"arg"`
+	Transform string      `json:"transform"` //Allows subscribing with a transform
+
+	//If the command is "subscribe", the subscription can replay the stream's data starting at the given index or timestamp
+	I1 *int64   `json:"i1,omitempty"`
+	T1 *float64 `json:"t1,omitempty"`
+
+	Query *query.LiveQuery `json:"query,omitempty"` //If the command is "subscribe_query", the merge or dataset to run live
 
 	D []datastream.Datapoint `json:"d"` //If the command is "insert", it needs an additional datapoint
 }
 
+//A reply tells the client whether its command succeeded
+type websocketReply struct {
+	ID      interface{} `json:"id,omitempty"`
+	Reply   string      `json:"reply"` //The cmd of the command which is replied to
+	Arg     string      `json:"arg"`
+	OK      bool        `json:"ok"`
+	Error   string      `json:"error,omitempty"`
+	Version int         `json:"version,omitempty"` //The protocol version, which is given in the reply to the "version" command
+}
+
+//reply sends the outcome of the command to the client. Legacy clients only get replies to the commands which
+//have an id, since they don't expect any other messages than data.
+func (c *WebsocketConnection) reply(cmd *websocketCommand, err error) {
+	if cmd.ID == nil && c.version < WebsocketProtocolVersion && cmd.Cmd != "version" {
+		return
+	}
+	r := websocketReply{ID: cmd.ID, Reply: cmd.Cmd, Arg: cmd.Arg, OK: err == nil}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	if cmd.Cmd == "version" {
+		r.Version = c.version
+	}
+	select {
+	case c.replies <- r:
+	case <-time.After(config.Get().Websocket.WriteWait * time.Second):
+		c.logger.WithField("cmd", cmd.Cmd).Warningln("Dropping reply: the writer is not keeping up")
+	}
+}
+
 //RunReader runs the reading routine. It also maps the commands to actual subscriptions
 func (c *WebsocketConnection) RunReader(readmessenger chan string) {
 
@@ -266,8 +520,9 @@ func (c *WebsocketConnection) RunReader(readmessenger chan string) {
 		return nil
 	})
 
-	var cmd websocketCommand
 	for {
+		//Each command is decoded into a new struct, so that the fields of the previous command don't carry over
+		var cmd websocketCommand
 		err := c.ws.ReadJSON(&cmd)
 		if err != nil {
 			if err == io.EOF {
@@ -288,16 +543,23 @@ func (c *WebsocketConnection) RunReader(readmessenger chan string) {
 		switch cmd.Cmd {
 		default:
 			c.logger.Warningln("Command not recognized:", cmd.Cmd)
-			//Do nothing - the command is not recognized
+			err = fmt.Errorf("Command '%s' not recognized", cmd.Cmd)
+		case "version":
+			err = c.SetVersion(cmd.Arg)
 		case "insert":
-			c.Insert(&cmd)
+			err = c.Insert(&cmd)
 		case "subscribe":
-			c.Subscribe(cmd.Arg, cmd.Transform)
+			err = c.Subscribe(cmd.Arg, cmd.Transform, cmd.I1, cmd.T1)
 		case "unsubscribe":
-			c.Unsubscribe(cmd.Arg, cmd.Transform)
+			err = c.Unsubscribe(cmd.Arg, cmd.Transform)
 		case "unsubscribe_all":
 			c.UnsubscribeAll()
+		case "subscribe_query":
+			err = c.StartQuery(cmd.Arg, cmd.Query)
+		case "unsubscribe_query":
+			err = c.StopQuery(cmd.Arg)
 		}
+		c.reply(&cmd, err)
 	}
 	//Since the reader is exiting, notify the writer to send close message
 	readmessenger <- webSocketClosedNonClean
@@ -318,6 +580,9 @@ func (c *WebsocketConnection) processDatapoint(datapoint messenger.Message) erro
 	subs, ok := c.subscriptions[datapoint.Stream]
 	c.RUnlock()
 	if !ok {
+		if len(datapoint.Data) == 0 {
+			return nil
+		}
 		return c.write(datapoint)
 	}
 
@@ -325,30 +590,34 @@ func (c *WebsocketConnection) processDatapoint(datapoint messenger.Message) erro
 	defer subs.Unlock()
 
 	for transform, tf := range subs.transform {
-		if transform == "" {
-			logger.Debugln("<- send")
-			if err := c.write(datapoint); err != nil {
+		if index, ok := subs.replay[transform]; ok {
+			//The message is only a trigger to read the new data of the stream, which guarantees that
+			//the replayed data continues into the live data without gaps or duplicates
+			next, err := replayStream(c.o, datapoint.Stream, transform, tf, index, logger, func(msg messenger.Message, next int64) error {
+				return c.write(msg)
+			})
+			subs.replay[transform] = next
+			if err != nil {
 				return err
 			}
+			//The replay reads until it finds nothing new, so the data of the following messages wasn't sent yet
+			subs.endReplay(c.o, datapoint.Stream, transform, next)
 			continue
 		}
-
-		datapointArray, err := query.TransformArray(tf, &datapoint.Data)
+		msg := subs.skipReplayed(datapoint, transform)
+		if len(msg.Data) == 0 {
+			continue
+		}
+		message, err := transformMessage(msg, transform, tf)
 		logger.Debugf("<- send %s", transform)
 		if err != nil {
 			return err
 		}
-		if datapointArray.Length() <= 0 {
+		if message == nil {
 			continue
 		}
 
-		message := messenger.Message{
-			datapoint.Stream,
-			transform,
-			*datapointArray,
-		}
-
-		if err := c.write(message); err != nil {
+		if err := c.write(*message); err != nil {
 			return err
 		}
 	}
@@ -376,6 +645,16 @@ func (c *WebsocketConnection) RunWriter(readmessenger chan string, exitchan chan
 				c.logger.Errorf("Writing failed: %s. Killing connection.", err.Error())
 			}
 
+		case msg := <-c.results:
+			if err := c.write(msg); err != nil {
+				c.logger.Errorf("Writing failed: %s. Killing connection.", err.Error())
+			}
+
+		case r := <-c.replies:
+			if err := c.write(r); err != nil {
+				c.logger.Errorf("Writing failed: %s. Killing connection.", err.Error())
+			}
+
 		case <-ticker.C:
 			if VerboseWebsocket {
 				c.logger.Debug("PING")
@@ -412,6 +691,7 @@ func (c *WebsocketConnection) RunWriter(readmessenger chan string, exitchan chan
 func (c *WebsocketConnection) Run() error {
 	c.logger.Debugln("Running websocket...")
 	websocketWaitGroup.Add(1)
+	atomic.AddInt32(&webcore.StatsWebsockets, 1)
 
 	//The reader can communicate with the writer through the channel
 	msgchn := make(chan string, 1)
@@ -427,6 +707,7 @@ func (c *WebsocketConnection) Run() error {
 	if !<-exitchan {
 		c.logger.Error("writer exit timeout")
 	}
+	atomic.AddInt32(&webcore.StatsWebsockets, -1)
 	websocketWaitGroup.Done()
 	return nil
 }
@@ -0,0 +1,249 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package restapi
+
+import (
+	"connectordb/authoperator"
+	"connectordb/datastream"
+	"connectordb/messenger"
+	"connectordb/users"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+//runWebsocket connects a websocket client to a server that runs the websocket of the operator. It returns the client,
+//the server's connection, and the function which closes both.
+func runWebsocket(t *testing.T, o *authoperator.AuthOperator) (*websocket.Conn, *WebsocketConnection, func()) {
+	conns := make(chan *WebsocketConnection, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		c, err := NewWebsocketConnection(o, writer, request, log.WithField("test", "websocket"))
+		if err != nil {
+			close(conns)
+			return
+		}
+		conns <- c
+		defer c.Close()
+		c.Run()
+	}))
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	require.NoError(t, err)
+	c, ok := <-conns
+	require.True(t, ok)
+	return ws, c, func() {
+		ws.Close()
+		srv.Close()
+	}
+}
+
+//readMessage reads the next data message sent to the websocket client
+func readMessage(t *testing.T, ws *websocket.Conn) messenger.Message {
+	var msg messenger.Message
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	require.NoError(t, ws.ReadJSON(&msg))
+	return msg
+}
+
+func createWebsocketUser(t *testing.T) *authoperator.AuthOperator {
+	tdb.Clear()
+	require.NoError(t, tdb.CreateUser(&users.UserMaker{User: users.User{Name: "wsuser", Email: "ws@localhost", Password: "mypass", Role: "user", Public: true}}))
+	require.NoError(t, tdb.CreateDevice("wsuser/wsdevice", &users.DeviceMaker{}))
+	o, err := tdb.AsUser("wsuser")
+	require.NoError(t, err)
+	return o
+}
+
+func TestReplayIndex(t *testing.T) {
+	o := createWebsocketUser(t)
+	require.NoError(t, o.CreateStream("wsuser/wsdevice/s1", &users.StreamMaker{Stream: users.Stream{Schema: `{"type":"number"}`}}))
+	require.NoError(t, o.CreateStream("wsuser/wsdevice/eph", &users.StreamMaker{Stream: users.Stream{Schema: `{"type":"number"}`, Ephemeral: true}}))
+	insertNumbers(t, o, "wsuser/wsdevice/s1", 1, 2, 3, 4, 5)
+
+	c := &WebsocketConnection{o: o}
+	i := func(v int64) *int64 { return &v }
+	tm := func(v float64) *float64 { return &v }
+
+	//Negative indices count from the end, and indices are clamped to the stream
+	for _, test := range []struct{ i1, index int64 }{{2, 2}, {0, 0}, {-2, 3}, {-10, 0}, {5, 5}, {10, 5}} {
+		index, err := c.replayIndex("wsuser/wsdevice/s1", i(test.i1), nil)
+		require.NoError(t, err)
+		require.Equal(t, test.index, index, "i1=%d", test.i1)
+	}
+	index, err := c.replayIndex("wsuser/wsdevice/s1", nil, tm(0))
+	require.NoError(t, err)
+	require.Equal(t, int64(0), index)
+	index, err = c.replayIndex("wsuser/wsdevice/s1", nil, tm(float64(time.Now().Unix()+1000)))
+	require.NoError(t, err)
+	require.Equal(t, int64(5), index)
+
+	_, err = c.replayIndex("wsuser/wsdevice/s1", i(0), tm(0))
+	require.Error(t, err)
+	_, err = c.replayIndex("wsuser/wsdevice/eph", i(0), nil)
+	require.Error(t, err)
+	_, err = c.replayIndex("wsuser/wsdevice/nonexistent", i(0), nil)
+	require.Error(t, err)
+}
+
+func TestSkipReplayed(t *testing.T) {
+	s := NewSubscription(nil)
+	msg := func(timestamps ...float64) messenger.Message {
+		dpa := make(datastream.DatapointArray, len(timestamps))
+		for i, ts := range timestamps {
+			dpa[i] = datastream.Datapoint{Timestamp: ts, Data: ts}
+		}
+		return messenger.Message{Stream: "u/d/s", Data: dpa}
+	}
+
+	require.Len(t, s.skipReplayed(msg(1, 2), "").Data, 2)
+
+	//The messages queued during the replay only have their new data sent, and once there is new data,
+	//the data of the messages is sent as it is
+	s.replayed[""] = 5
+	require.Len(t, s.skipReplayed(msg(3, 4, 5), "").Data, 0)
+	require.Len(t, s.skipReplayed(msg(3, 4, 5), "if $ > 1").Data, 3)
+	require.Equal(t, datastream.DatapointArray{{Timestamp: 6, Data: 6.0}}, s.skipReplayed(msg(5, 6), "").Data)
+	require.Len(t, s.skipReplayed(msg(1, 2), "").Data, 2)
+}
+
+func TestWebsocketReplay(t *testing.T) {
+	o := createWebsocketUser(t)
+	require.NoError(t, o.CreateStream("wsuser/wsdevice/s1", &users.StreamMaker{Stream: users.Stream{Schema: `{"type":"number"}`}}))
+	values := make([]float64, replayBatch+5)
+	for i := range values {
+		values[i] = float64(i)
+	}
+	insertNumbers(t, o, "wsuser/wsdevice/s1", values...)
+
+	ws, c, stop := runWebsocket(t, o)
+	defer stop()
+
+	//The replay is sent in batches
+	require.NoError(t, ws.WriteJSON(map[string]interface{}{"cmd": "subscribe", "arg": "wsuser/wsdevice/s1", "i1": 0}))
+	msg := readMessage(t, ws)
+	require.Equal(t, "wsuser/wsdevice/s1", msg.Stream)
+	require.Len(t, msg.Data, replayBatch)
+	require.Equal(t, 0.0, msg.Data[0].Data)
+	msg = readMessage(t, ws)
+	require.Len(t, msg.Data, 5)
+	require.Equal(t, float64(replayBatch+4), msg.Data[4].Data)
+
+	//Once the replay caught up, the data of the messages is sent
+	insertNumbers(t, o, "wsuser/wsdevice/s1", -1)
+	msg = readMessage(t, ws)
+	require.Len(t, msg.Data, 1)
+	require.Equal(t, -1.0, msg.Data[0].Data)
+
+	c.RLock()
+	subs := c.subscriptions["wsuser/wsdevice/s1"]
+	c.RUnlock()
+	subs.Lock()
+	_, replaying := subs.replay[""]
+	subs.Unlock()
+	require.False(t, replaying)
+
+	//A replay with a transform continues with the live data of the transform
+	require.NoError(t, ws.WriteJSON(map[string]interface{}{"cmd": "subscribe", "arg": "wsuser/wsdevice/s1", "transform": "if $ < 0", "i1": -3}))
+	msg = readMessage(t, ws)
+	require.Equal(t, "if $ < 0", msg.Transform)
+	require.Len(t, msg.Data, 1)
+	require.Equal(t, -1.0, msg.Data[0].Data)
+	insertNumbers(t, o, "wsuser/wsdevice/s1", -2)
+	for _, transform := range []string{"", "if $ < 0"} {
+		msg = readMessage(t, ws)
+		require.Len(t, msg.Data, 1)
+		require.Equal(t, -2.0, msg.Data[0].Data, transform)
+	}
+}
+
+func TestSetVersion(t *testing.T) {
+	c := &WebsocketConnection{version: websocketLegacyVersion}
+	require.NoError(t, c.SetVersion("2"))
+	require.Equal(t, 2, c.version)
+	require.NoError(t, c.SetVersion("1"))
+	require.Equal(t, websocketLegacyVersion, c.version)
+
+	//Clients which support newer versions get the server's version
+	require.NoError(t, c.SetVersion("99"))
+	require.Equal(t, WebsocketProtocolVersion, c.version)
+
+	for _, v := range []string{"0", "-1", "abc", "", "1.5"} {
+		require.Error(t, c.SetVersion(v), v)
+	}
+	require.Equal(t, WebsocketProtocolVersion, c.version)
+}
+
+//readReply reads the next reply sent to the websocket client
+func readReply(t *testing.T, ws *websocket.Conn) websocketReply {
+	var r websocketReply
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	require.NoError(t, ws.ReadJSON(&r))
+	require.NotEmpty(t, r.Reply)
+	return r
+}
+
+func TestWebsocketReplies(t *testing.T) {
+	o := createWebsocketUser(t)
+	require.NoError(t, o.CreateStream("wsuser/wsdevice/s1", &users.StreamMaker{Stream: users.Stream{Schema: `{"type":"number"}`}}))
+	ws, _, stop := runWebsocket(t, o)
+	defer stop()
+
+	badInsert := map[string]interface{}{"cmd": "insert", "arg": "wsuser/wsdevice/s1", "d": []map[string]interface{}{{"t": 1, "d": "notanumber"}}}
+	badSubscribe := map[string]interface{}{"cmd": "subscribe", "arg": "wsuser/wsdevice/nonexistent"}
+
+	//Legacy clients only get replies to the commands with an id
+	require.NoError(t, ws.WriteJSON(badInsert))
+	require.NoError(t, ws.WriteJSON(badSubscribe))
+	badSubscribe["id"] = "mysub"
+	require.NoError(t, ws.WriteJSON(badSubscribe))
+	r := readReply(t, ws)
+	require.Equal(t, "mysub", r.ID)
+	require.Equal(t, "subscribe", r.Reply)
+	require.Equal(t, "wsuser/wsdevice/nonexistent", r.Arg)
+	require.False(t, r.OK)
+	require.NotEmpty(t, r.Error)
+
+	//The version command is always replied to, with the version that the server uses
+	require.NoError(t, ws.WriteJSON(map[string]interface{}{"cmd": "version", "arg": "abc"}))
+	r = readReply(t, ws)
+	require.Equal(t, "version", r.Reply)
+	require.False(t, r.OK)
+	require.Equal(t, websocketLegacyVersion, r.Version)
+	require.NoError(t, ws.WriteJSON(map[string]interface{}{"cmd": "version", "arg": "3"}))
+	r = readReply(t, ws)
+	require.True(t, r.OK)
+	require.Equal(t, WebsocketProtocolVersion, r.Version)
+
+	//From version 2, every command is replied to
+	require.NoError(t, ws.WriteJSON(badInsert))
+	r = readReply(t, ws)
+	require.Nil(t, r.ID)
+	require.Equal(t, "insert", r.Reply)
+	require.False(t, r.OK)
+	require.NotEmpty(t, r.Error)
+
+	delete(badSubscribe, "id")
+	require.NoError(t, ws.WriteJSON(badSubscribe))
+	r = readReply(t, ws)
+	require.Equal(t, "subscribe", r.Reply)
+	require.False(t, r.OK)
+
+	require.NoError(t, ws.WriteJSON(map[string]interface{}{"id": 5, "cmd": "insert", "arg": "wsuser/wsdevice/s1", "d": []map[string]interface{}{{"t": 1, "d": 1}}}))
+	r = readReply(t, ws)
+	require.Equal(t, 5.0, r.ID)
+	require.True(t, r.OK)
+	require.Empty(t, r.Error)
+
+	require.NoError(t, ws.WriteJSON(map[string]interface{}{"cmd": "dance"}))
+	r = readReply(t, ws)
+	require.Equal(t, "dance", r.Reply)
+	require.False(t, r.OK)
+}
@@ -14,6 +14,7 @@ import (
 	"net/http/httptest"
 	"net/http/httputil"
 	"net/http/pprof"
+	"server/mqtt"
 	"server/restapi"
 	"server/restapi/restcore"
 	"server/webcore"
@@ -149,6 +150,28 @@ func MakeHandler(h http.Handler, verbose bool) http.Handler {
 	return h
 }
 
+// RunMQTT starts the MQTT listener if it is enabled. It is closed when the server shuts down.
+func RunMQTT(db *connectordb.Database, c *config.MQTT, tlsConfig *tls.Config) error {
+	if !c.Enabled {
+		return nil
+	}
+	m, err := mqtt.Listen(c, tlsConfig, db)
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-webcore.ShutdownChannel
+		webcore.ShutdownChannel <- true
+		m.Close()
+	}()
+	go func() {
+		if err := m.Serve(); err != nil {
+			log.Debug("MQTT listener stopped: ", err)
+		}
+	}()
+	return nil
+}
+
 //RunServer runs the ConnectorDB frontend server
 func RunServer(verbose, profiling bool) error {
 	OSSpecificSetup()
@@ -177,6 +200,9 @@ func RunServer(verbose, profiling bool) error {
 	if err != nil {
 		return err
 	}
+	if err = db.StartWebhooks(&c.Webhooks); err != nil {
+		return err
+	}
 
 	r := mux.NewRouter()
 
@@ -199,6 +225,13 @@ func RunServer(verbose, profiling bool) error {
 		r.HandleFunc("/debug/pprof/{something}", pprof.Index)
 	}
 
+	//The statistics of the server are exported for Prometheus
+	r.HandleFunc("/metrics", webcore.MetricsHandler(db)).Methods("GET")
+
+	//Load balancers and supervisors check whether the server is up, and whether it can handle requests
+	r.HandleFunc("/health", webcore.HealthHandler).Methods("GET", "HEAD")
+	r.HandleFunc("/ready", webcore.ReadyHandler(db)).Methods("GET", "HEAD")
+
 	//The rest api has its own versioned url
 	s := r.PathPrefix("/api/v1").Subrouter()
 	_, err = restapi.Router(db, s)
@@ -219,6 +252,11 @@ func RunServer(verbose, profiling bool) error {
 	go webcore.RunStats()
 	go webcore.RunQueryTimers()
 
+	//Unlock logins locked out by the login limiter when admins ask for it
+	if err = webcore.RunLoginLimiter(db); err != nil {
+		return err
+	}
+
 	//Run the dbwriter
 	go db.RunWriter()
 
@@ -256,6 +294,10 @@ func RunServer(verbose, profiling bool) error {
 		}
 		server.TLSConfig = w.TLSConfig()
 
+		if err = RunMQTT(db, &c.MQTT, server.TLSConfig); err != nil {
+			return err
+		}
+
 		listener, err := tls.Listen("tcp", listenhost, server.TLSConfig)
 		if err != nil {
 			return err
@@ -270,6 +312,9 @@ func RunServer(verbose, profiling bool) error {
 
 		return server.Serve(listener)
 	}
+	if err = RunMQTT(db, &c.MQTT, nil); err != nil {
+		return err
+	}
 	log.Infof("Running ConnectorDB v%s at %s (%s)", connectordb.Version, c.GetSiteURL(), listenhost)
 
 	return server.ListenAndServe()
@@ -8,8 +8,10 @@ import (
 	"config"
 	"connectordb"
 	"connectordb/authoperator"
+	"connectordb/users"
 	"errors"
 	"net/http"
+	"strings"
 	"sync/atomic"
 	"time"
 
@@ -25,52 +27,151 @@ var (
 	CookieMonster *securecookie.SecureCookie
 )
 
-// Authenticate gets the authenticated device Operator given an http.Request
+const (
+	// TOTPHeader is the header in which a user login gives its two-factor authentication code
+	TOTPHeader = "X-TOTP"
+	// TOTPRequiredHeader is set on failed user logins which need a (valid) two-factor authentication code
+	TOTPRequiredHeader = "X-TOTP-Required"
+)
+
+// KeyLogin logs in with the given key, which is either the API key of a device, or a token
+func KeyLogin(db *connectordb.Database, key string) (*authoperator.AuthOperator, error) {
+	o, err := db.DeviceLogin(key)
+	if err == nil {
+		return o, nil
+	}
+	if o, terr := db.TokenLogin(key); terr == nil {
+		return o, nil
+	}
+	return nil, err
+}
+
+// bearerToken returns the key given in a "Authorization: Bearer" header, if there is one
+func bearerToken(request *http.Request) string {
+	auth := request.Header.Get("Authorization")
+	if len(auth) > 7 && strings.EqualFold(auth[:7], "bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	return ""
+}
+
+// Authenticate gets the authenticated device Operator given an http.Request. Failed logins are counted
+// by the login limiter, which refuses further logins from the same user, api key or client for a while.
 func Authenticate(db *connectordb.Database, request *http.Request) (o *authoperator.AuthOperator, err error) {
+	// The login to perform, along with the username or api key that it uses
+	var login func() (*authoperator.AuthOperator, error)
+	var username, apikey string
+
 	//Basic auth overrides all other auth
 	authUser, authPass, ok := request.BasicAuth()
 
 	if ok {
 		if authUser != "" {
-			o, err = db.UserLogin(authUser, authPass)
-
+			username = authUser
+			login = func() (*authoperator.AuthOperator, error) {
+				return db.UserLoginTOTP(authUser, authPass, request.Header.Get(TOTPHeader))
+			}
 		} else {
-			o, err = db.DeviceLogin(authPass)
+			apikey = authPass
 		}
+	} else if authPass = bearerToken(request); authPass != "" {
+		apikey = authPass
 	} else {
 		//Basic auth is unavailable.
 
 		//Check if there is an apikey parameter in the query itself
 		authPass = request.URL.Query().Get("apikey")
 		if len(authPass) != 0 {
-			o, err = db.DeviceLogin(authPass)
+			apikey = authPass
 		} else {
-			var cookie *http.Cookie
-			cookie, err = request.Cookie("connectordb-session")
-			if err == nil {
-				err = CookieMonster.Decode("connectordb-session", cookie.Value, &authPass)
-				if err == nil {
-					o, err = db.DeviceLogin(authPass)
-				} else {
-					//If the cookie is invalid, log in as nobody
-					err = nil
-					o = db.Nobody()
-				}
-			} else {
-				err = nil
-				// No authentication was given - use nobody
-				o = db.Nobody()
+			cookie, err := request.Cookie("connectordb-session")
+			if err != nil || CookieMonster.Decode("connectordb-session", cookie.Value, &authPass) != nil {
+				// No authentication was given, or the cookie is invalid - use nobody
+				return db.Nobody(), nil
+			}
+			apikey = authPass
+			login = func() (*authoperator.AuthOperator, error) {
+				return db.DeviceLogin(authPass)
 			}
 		}
 	}
+	if login == nil {
+		login = func() (*authoperator.AuthOperator, error) {
+			return KeyLogin(db, apikey)
+		}
+	}
 
-	if err != nil {
-		c := config.Get()
-		if c.FailedLoginDelay > 0 {
-			time.Sleep(c.FailedLoginDelay * time.Millisecond)
+	return limitLogin(db, loginKeys(request, username, apikey), username, login)
+}
+
+// DeviceKeyLogin logs in with the API key of a device, given over a protocol other than http, such as MQTT.
+// Failed logins are counted by the login limiter in the same way as the logins of the http api.
+func DeviceKeyLogin(db *connectordb.Database, remoteAddr, apikey string) (*authoperator.AuthOperator, error) {
+	return limitLogin(db, addrLoginKeys(remoteAddr, "", apikey), "", func() (*authoperator.AuthOperator, error) {
+		return db.DeviceLogin(apikey)
+	})
+}
+
+// SecondFactorLogin logs in as a user who already proved their identity in another way, such as through an
+// OpenID Connect provider, checking the user's two-factor code. Wrong codes are counted by the login limiter
+// in the same way as wrong passwords.
+func SecondFactorLogin(db *connectordb.Database, request *http.Request, username, code string) (*authoperator.AuthOperator, error) {
+	return limitLogin(db, loginKeys(request, username, ""), username, func() (*authoperator.AuthOperator, error) {
+		u, err := db.ReadUser(username)
+		if err != nil {
+			return nil, err
 		}
+		recovered, err := u.CheckSecondFactor(code)
+		if err != nil {
+			return nil, err
+		}
+		if recovered {
+			// The recovery code can't be used again
+			if err = db.Userdb.UpdateUser(u); err != nil {
+				return nil, err
+			}
+		}
+		return db.AsUser(username)
+	})
+}
 
+// limitLogin performs the login unless the login limiter refuses its keys, and counts its failure
+func limitLogin(db *connectordb.Database, keys []string, username string, login func() (*authoperator.AuthOperator, error)) (o *authoperator.AuthOperator, err error) {
+	c := config.Get()
+	if !c.LoginLimit.Enabled {
+		o, err = login()
+		if err != nil {
+			atomic.AddUint32(&StatsAuthFails, 1)
+			if err != users.ErrTOTPRequired && c.FailedLoginDelay > 0 {
+				time.Sleep(c.FailedLoginDelay * time.Millisecond)
+			}
+		}
+		return o, err
+	}
+
+	// The attempt counts as a failure while the login is checked, so that concurrent guesses are refused
+	locked, err := loginLimiter.Attempt(&c.LoginLimit, keys)
+	if err != nil {
 		atomic.AddUint32(&StatsAuthFails, 1)
+		return nil, err
+	}
+
+	o, err = login()
+	if err == nil {
+		// The failures of the client are kept, so that logging in to one's own account between guesses doesn't reset them
+		loginLimiter.Release(keys[:1])
+		loginLimiter.Success(keys[1:])
+		return o, nil
+	}
+
+	atomic.AddUint32(&StatsAuthFails, 1)
+	if err == users.ErrTOTPRequired {
+		// The password was correct, and the user is asked for their two-factor code
+		loginLimiter.Release(keys)
+		return o, err
+	}
+	if len(locked) > 0 {
+		logLockout(db, username, locked)
 	}
 	return o, err
 }
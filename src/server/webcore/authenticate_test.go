@@ -0,0 +1,55 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package webcore
+
+import (
+	"config"
+	"connectordb"
+	"connectordb/users"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecondFactorLogin(t *testing.T) {
+	db, err := connectordb.Open(config.TestConfiguration.Options())
+	require.NoError(t, err)
+	defer db.Close()
+	db.Clear()
+	require.NoError(t, db.CreateUser(&users.UserMaker{User: users.User{Name: "tst", Email: "root@localhost", Password: "mypass", Role: "user", Public: true}}))
+
+	request, err := http.NewRequest("POST", "/oidc/totp", nil)
+	require.NoError(t, err)
+	request.RemoteAddr = "10.0.0.1:5555"
+
+	// Users without two-factor authentication need no code
+	o, err := SecondFactorLogin(db, request, "tst", "")
+	require.NoError(t, err)
+	require.Equal(t, "tst/user", o.Name())
+
+	o, err = db.UserLogin("tst", "mypass")
+	require.NoError(t, err)
+	secret, err := o.EnrollTOTP("tst")
+	require.NoError(t, err)
+	code, err := users.TOTPCode(secret, time.Now())
+	require.NoError(t, err)
+	codes, err := o.ConfirmTOTP("tst", code)
+	require.NoError(t, err)
+
+	_, err = SecondFactorLogin(db, request, "tst", "")
+	require.Equal(t, users.ErrTOTPRequired, err)
+	_, err = SecondFactorLogin(db, request, "tst", "000000x")
+	require.Equal(t, users.ErrInvalidTOTP, err)
+	_, err = SecondFactorLogin(db, request, "tst", code)
+	require.NoError(t, err)
+
+	// Recovery codes can only be used once
+	_, err = SecondFactorLogin(db, request, "tst", codes[0])
+	require.NoError(t, err)
+	_, err = SecondFactorLogin(db, request, "tst", codes[0])
+	require.Equal(t, users.ErrInvalidTOTP, err)
+}
@@ -0,0 +1,44 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package webcore
+
+import (
+	"connectordb"
+	"encoding/json"
+	"net/http"
+)
+
+// writeHealth writes the json of a health check with the given status code. The checks are never cached.
+func writeHealth(writer http.ResponseWriter, status int, v interface{}) {
+	res, err := json.Marshal(v)
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writer.Header().Set("Content-Type", "application/json; charset=utf-8")
+	writer.Header().Set("Cache-Control", "no-cache, no-store")
+	writer.WriteHeader(status)
+	writer.Write(res)
+}
+
+// HealthHandler responds to liveness checks. It succeeds as long as the server can handle requests,
+// so that supervisors don't restart the server when one of the services it depends on is down.
+func HealthHandler(writer http.ResponseWriter, request *http.Request) {
+	writeHealth(writer, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// ReadyHandler responds to readiness checks with the state of each of the services that the server depends on.
+// It responds with 503 Service Unavailable if any of them is unhealthy, so that load balancers stop sending requests.
+func ReadyHandler(db *connectordb.Database) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		r := db.Readiness()
+		status := http.StatusOK
+		if !r.Ready {
+			status = http.StatusServiceUnavailable
+			GetRequestLogger(request, "ready").Warn("Not ready")
+		}
+		writeHealth(writer, status, r)
+	}
+}
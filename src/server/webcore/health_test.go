@@ -0,0 +1,51 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package webcore
+
+import (
+	"config"
+	"connectordb"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// checkHealth runs the health handler, returning the status and the decoded response
+func checkHealth(t *testing.T, handler http.HandlerFunc) (int, map[string]interface{}) {
+	request, err := http.NewRequest("GET", "/", nil)
+	require.NoError(t, err)
+	rec := httptest.NewRecorder()
+	handler(rec, request)
+	require.Equal(t, "no-cache, no-store", rec.Header().Get("Cache-Control"))
+
+	var res map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	return rec.Code, res
+}
+
+func TestReadyHandler(t *testing.T) {
+	db, err := connectordb.Open(config.TestConfiguration.Options())
+	require.NoError(t, err)
+	defer db.Close()
+
+	code, res := checkHealth(t, ReadyHandler(db))
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, true, res["ready"])
+
+	// Once the sql database is unreachable, the server is not ready, but it is still alive
+	db.Sqldb.Close()
+	code, res = checkHealth(t, ReadyHandler(db))
+	require.Equal(t, http.StatusServiceUnavailable, code)
+	require.Equal(t, false, res["ready"])
+	require.Equal(t, false, res["sql"].(map[string]interface{})["healthy"])
+	require.Equal(t, true, res["redis"].(map[string]interface{})["healthy"])
+
+	code, res = checkHealth(t, HealthHandler)
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "ok", res["status"])
+}
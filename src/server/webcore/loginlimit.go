@@ -0,0 +1,256 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package webcore
+
+import (
+	"config"
+	"connectordb"
+	"connectordb/messenger"
+	"connectordb/users"
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+var (
+	// ErrLoginBackoff is returned when a login is refused because the previous login failed a moment ago
+	ErrLoginBackoff = errors.New("Too many failed logins. Please wait a moment before trying again.")
+	// ErrLoginLocked is returned when logins are locked out after too many failures
+	ErrLoginLocked = errors.New("Too many failed logins. Logins are locked out for a while, unless an admin unlocks them.")
+
+	// loginLimiter holds the failed logins of this server
+	loginLimiter = NewLoginLimiter()
+)
+
+// The length of the prefix of api keys by which failures are counted
+const loginKeyPrefix = 8
+
+// loginFailures holds the recent consecutive failed logins of a username, api key prefix or ip address
+type loginFailures struct {
+	Failures int
+	Last     time.Time // The time of the last failure
+	Retry    time.Time // Logins are refused until this time
+	Locked   bool      // Whether logins are refused due to a lockout, rather than a backoff
+}
+
+// LoginLimiter counts failed logins, refusing further logins with exponential backoff, and locking
+// them out after too many consecutive failures. Failures are counted by keys, which are "user:<name>",
+// "key:<api key prefix>" and "ip:<address>". A login is refused if any of its keys is refused.
+type LoginLimiter struct {
+	lock     sync.Mutex
+	failures map[string]*loginFailures
+}
+
+// NewLoginLimiter creates a LoginLimiter with no failures
+func NewLoginLimiter() *LoginLimiter {
+	return &LoginLimiter{failures: make(map[string]*loginFailures)}
+}
+
+// Check returns an error if logins with any of the given keys are currently refused
+func (l *LoginLimiter) Check(keys []string) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.check(keys, time.Now())
+}
+
+// check returns an error if logins with any of the keys are refused at the given time. The lock must be held.
+func (l *LoginLimiter) check(keys []string, now time.Time) error {
+	for _, k := range keys {
+		if f, ok := l.failures[k]; ok && now.Before(f.Retry) {
+			if f.Locked {
+				return ErrLoginLocked
+			}
+			return ErrLoginBackoff
+		}
+	}
+	return nil
+}
+
+// Attempt reserves a login with the given keys, returning an error if logins with any of them are currently refused.
+// The attempt is counted as a failure right away, so that concurrent logins can't all be checked before the first
+// of them fails, and it must be released with Release or Success if the login succeeds. The keys which the
+// attempt locked out are returned.
+func (l *LoginLimiter) Attempt(c *config.LoginLimit, keys []string) (locked []string, err error) {
+	now := time.Now()
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if err = l.check(keys, now); err != nil {
+		return nil, err
+	}
+	return l.fail(c, keys, now), nil
+}
+
+// Release gives back an attempt with the given keys, which no longer counts as a failure
+func (l *LoginLimiter) Release(keys []string) {
+	l.lock.Lock()
+	for _, k := range keys {
+		f, ok := l.failures[k]
+		if !ok {
+			continue
+		}
+		f.Failures--
+		if f.Failures <= 0 {
+			delete(l.failures, k)
+			continue
+		}
+		// Logins weren't refused when the attempt was made
+		f.Locked = false
+		f.Retry = time.Time{}
+	}
+	l.lock.Unlock()
+}
+
+// Failure counts a failed login with the given keys. The keys which got locked out by this failure are returned.
+func (l *LoginLimiter) Failure(c *config.LoginLimit, keys []string) (locked []string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.fail(c, keys, time.Now())
+}
+
+// fail counts a failed login with the given keys. The lock must be held.
+func (l *LoginLimiter) fail(c *config.LoginLimit, keys []string, now time.Time) (locked []string) {
+	for _, k := range keys {
+		f, ok := l.failures[k]
+		if !ok || f.Locked {
+			// A lockout that is over starts the count from scratch
+			f = &loginFailures{}
+			l.failures[k] = f
+		}
+		f.Failures++
+		f.Last = now
+		if f.Failures >= c.LockoutAttempts {
+			f.Locked = true
+			f.Retry = now.Add(time.Duration(c.LockoutTime) * time.Second)
+			locked = append(locked, k)
+			continue
+		}
+		backoff := float64(c.Backoff) * math.Pow(2, float64(f.Failures-1))
+		f.Retry = now.Add(time.Duration(math.Min(backoff, float64(c.MaxBackoff))) * time.Millisecond)
+	}
+	return locked
+}
+
+// Success resets the failures of the given keys
+func (l *LoginLimiter) Success(keys []string) {
+	l.lock.Lock()
+	for _, k := range keys {
+		delete(l.failures, k)
+	}
+	l.lock.Unlock()
+}
+
+// Unlock removes the failures of the given username, api key prefix or ip address
+func (l *LoginLimiter) Unlock(target string) {
+	l.Success([]string{"user:" + target, "key:" + target, "ip:" + target})
+}
+
+// Forget removes the failures which are no longer refused, and which happened long enough ago
+// that further failures start counting from scratch
+func (l *LoginLimiter) Forget(c *config.LoginLimit) {
+	now := time.Now()
+	l.lock.Lock()
+	for k, f := range l.failures {
+		if !now.Before(f.Retry) && now.Sub(f.Last) > time.Duration(c.LockoutTime)*time.Second {
+			delete(l.failures, k)
+		}
+	}
+	l.lock.Unlock()
+}
+
+// loginKeys returns the keys by which the failures of a login are counted. Either the username
+// or the api key is given.
+func loginKeys(request *http.Request, username, apikey string) []string {
+	return addrLoginKeys(clientAddr(&config.Get().LoginLimit, request), username, apikey)
+}
+
+// clientAddr returns the address of the client which sent the request. Requests from a trusted proxy
+// are counted by the address that the proxy gives in the X-Real-IP header.
+func clientAddr(c *config.LoginLimit, request *http.Request) string {
+	realIP := request.Header.Get("X-Real-IP")
+	if realIP == "" {
+		return request.RemoteAddr
+	}
+	host, _, err := net.SplitHostPort(request.RemoteAddr)
+	if err != nil {
+		host = request.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return request.RemoteAddr
+	}
+	nets, err := c.ProxyNets()
+	if err != nil {
+		return request.RemoteAddr
+	}
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return realIP
+		}
+	}
+	return request.RemoteAddr
+}
+
+// addrLoginKeys returns the keys of a login from the given remote address
+func addrLoginKeys(addr, username, apikey string) []string {
+	ip, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		ip = addr
+	}
+	keys := []string{"ip:" + ip}
+	if username != "" {
+		keys = append(keys, "user:"+username)
+	}
+	if apikey != "" {
+		if len(apikey) > loginKeyPrefix {
+			apikey = apikey[:loginKeyPrefix]
+		}
+		keys = append(keys, "key:"+apikey)
+	}
+	return keys
+}
+
+// logLockout writes the lockout of the given keys to the log, and to the meta/log stream of the user
+// whose login was locked out
+func logLockout(db *connectordb.Database, username string, locked []string) {
+	log.WithField("user", username).Warnf("Logins locked out: %s", strings.Join(locked, ", "))
+	if username == "" {
+		return
+	}
+	if err := db.LogLoginEvent(username, "LoginLockout"); err != nil && err != users.ErrUserNotFound {
+		log.Errorf("Could not log lockout of %s: %v", username, err)
+	}
+}
+
+// UnlockLogin removes the failed logins of the given username, api key prefix or ip address from this server
+func UnlockLogin(target string) {
+	loginLimiter.Unlock(target)
+	log.Infof("Logins unlocked: %s", target)
+}
+
+// RunLoginLimiter unlocks logins when admins ask for it, and periodically forgets old failed logins.
+// Unlocks are sent over the messenger, so that they reach all servers.
+func RunLoginLimiter(db *connectordb.Database) error {
+	chn := make(chan messenger.Message, 10)
+	if _, err := db.SubscribeLoginUnlock(chn); err != nil {
+		return err
+	}
+	go func() {
+		for m := range chn {
+			UnlockLogin(m.Stream)
+		}
+	}()
+	go func() {
+		for range time.Tick(time.Minute) {
+			loginLimiter.Forget(&config.Get().LoginLimit)
+		}
+	}()
+	return nil
+}
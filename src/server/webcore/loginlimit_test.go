@@ -0,0 +1,126 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package webcore
+
+import (
+	"config"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoginLimiter(t *testing.T) {
+	c := &config.LoginLimit{Enabled: true, Backoff: 50, MaxBackoff: 100, LockoutAttempts: 3, LockoutTime: 1}
+	l := NewLoginLimiter()
+
+	req, err := http.NewRequest("GET", "/api/v1/login", nil)
+	require.NoError(t, err)
+	req.RemoteAddr = "10.0.0.1:5555"
+	keys := loginKeys(req, "myuser", "")
+	require.Equal(t, []string{"ip:10.0.0.1", "user:myuser"}, keys)
+	require.Equal(t, []string{"ip:10.0.0.1", "key:abcdefgh"}, loginKeys(req, "", "abcdefghijklmnop"))
+
+	require.NoError(t, l.Check(keys))
+	require.Empty(t, l.Failure(c, keys))
+	require.Equal(t, ErrLoginBackoff, l.Check(keys))
+	require.Equal(t, ErrLoginBackoff, l.Check([]string{"user:myuser"}))
+	require.NoError(t, l.Check([]string{"user:otheruser"}))
+
+	time.Sleep(70 * time.Millisecond)
+	require.NoError(t, l.Check(keys))
+	require.Empty(t, l.Failure(c, keys))
+	time.Sleep(70 * time.Millisecond)
+	require.Equal(t, ErrLoginBackoff, l.Check(keys), "The backoff doubles")
+
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, keys, l.Failure(c, keys))
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, ErrLoginLocked, l.Check(keys))
+
+	// Unlocking the user leaves the client locked
+	l.Unlock("myuser")
+	require.NoError(t, l.Check([]string{"user:myuser"}))
+	require.Equal(t, ErrLoginLocked, l.Check(keys))
+	l.Unlock("10.0.0.1")
+	require.NoError(t, l.Check(keys))
+
+	// The lockout ends by itself, and old failures are forgotten
+	require.Empty(t, l.Failure(c, keys))
+	l.Success(keys[1:])
+	require.Equal(t, ErrLoginBackoff, l.Check(keys))
+	time.Sleep(1100 * time.Millisecond)
+	l.Forget(c)
+	require.Empty(t, l.failures)
+}
+
+func TestLoginAttempt(t *testing.T) {
+	c := &config.LoginLimit{Enabled: true, Backoff: 1000, MaxBackoff: 1000, LockoutAttempts: 2, LockoutTime: 1}
+	l := NewLoginLimiter()
+	keys := []string{"ip:10.0.0.1", "user:myuser"}
+
+	// Only one of concurrent attempts gets to check its password
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	refused := 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := l.Attempt(c, keys); err != nil {
+				lock.Lock()
+				refused++
+				lock.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, 9, refused)
+	require.Equal(t, ErrLoginBackoff, l.Check(keys), "The attempt counts as a failure until it is released")
+
+	// Releasing the attempt leaves the earlier failures
+	l.Release(keys)
+	require.NoError(t, l.Check(keys))
+	require.Empty(t, l.failures)
+	require.Empty(t, l.Failure(c, keys))
+	require.Equal(t, ErrLoginBackoff, l.Check(keys))
+	l.Unlock("myuser")
+	l.Unlock("10.0.0.1")
+
+	require.Empty(t, l.Failure(c, keys))
+	l.failures["ip:10.0.0.1"].Retry = time.Now()
+	l.failures["user:myuser"].Retry = time.Now()
+	locked, err := l.Attempt(c, keys)
+	require.NoError(t, err)
+	require.Equal(t, keys, locked)
+	require.Equal(t, ErrLoginLocked, l.Check(keys))
+	l.Release(keys)
+	require.NoError(t, l.Check(keys), "A lockout by an attempt which was released is undone")
+	require.Equal(t, 1, l.failures["user:myuser"].Failures)
+}
+
+func TestClientAddr(t *testing.T) {
+	c := &config.LoginLimit{TrustedProxies: []string{"127.0.0.1", "10.1.0.0/16"}}
+	req, err := http.NewRequest("GET", "/api/v1/login", nil)
+	require.NoError(t, err)
+
+	req.RemoteAddr = "127.0.0.1:5555"
+	require.Equal(t, "127.0.0.1:5555", clientAddr(c, req))
+	req.Header.Set("X-Real-IP", "8.8.8.8")
+	require.Equal(t, "8.8.8.8", clientAddr(c, req))
+	req.RemoteAddr = "10.1.3.4:5555"
+	require.Equal(t, "8.8.8.8", clientAddr(c, req))
+
+	// Other clients can't pick the ip by which their failures are counted
+	req.RemoteAddr = "10.2.3.4:5555"
+	require.Equal(t, "10.2.3.4:5555", clientAddr(c, req))
+	req.RemoteAddr = "[::1]:5555"
+	require.Equal(t, "[::1]:5555", clientAddr(c, req))
+
+	_, err = (&config.LoginLimit{TrustedProxies: []string{"nginx"}}).ProxyNets()
+	require.Error(t, err)
+}
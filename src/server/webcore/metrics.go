@@ -0,0 +1,136 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package webcore
+
+import (
+	"bytes"
+	"config"
+	"connectordb"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// labelEscaper escapes the values of labels
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// metricsWriter writes metrics in the Prometheus text format
+type metricsWriter struct {
+	bytes.Buffer
+}
+
+// header writes the help and type lines of a metric
+func (m *metricsWriter) header(name, help, metricType string) {
+	fmt.Fprintf(m, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, metricType)
+}
+
+// sample writes a value of the metric. The labels are given as alternating names and values.
+func (m *metricsWriter) sample(name string, value float64, labels ...string) {
+	m.WriteString(name)
+	if len(labels) > 0 {
+		pairs := make([]string, 0, len(labels)/2)
+		for i := 0; i+1 < len(labels); i += 2 {
+			pairs = append(pairs, fmt.Sprintf(`%s="%s"`, labels[i], labelEscaper.Replace(labels[i+1])))
+		}
+		m.WriteString("{" + strings.Join(pairs, ",") + "}")
+	}
+	fmt.Fprintf(m, " %v\n", value)
+}
+
+// metric writes a metric with a single value
+func (m *metricsWriter) metric(name, help, metricType string, value float64) {
+	m.header(name, help, metricType)
+	m.sample(name, value)
+}
+
+// writeQueryHistograms writes the latency histogram of each of the query handlers
+func (m *metricsWriter) writeQueryHistograms() {
+	name := "connectordb_request_duration_seconds"
+	m.header(name, "The time taken by the requests to each handler.", "histogram")
+
+	handlers := make([]string, 0, len(QueryTimers))
+	for h := range QueryTimers {
+		handlers = append(handlers, h)
+	}
+	sort.Strings(handlers)
+	for _, h := range handlers {
+		buckets, count, sum := QueryTimers[h].Histogram()
+		for i, b := range buckets {
+			m.sample(name+"_bucket", float64(b), "handler", h, "le", fmt.Sprint(LatencyBuckets[i]))
+		}
+		m.sample(name+"_bucket", float64(count), "handler", h, "le", "+Inf")
+		m.sample(name+"_sum", sum, "handler", h)
+		m.sample(name+"_count", float64(count), "handler", h)
+	}
+}
+
+// writeDatabase writes the state of the database's writer and sql connections
+func (m *metricsWriter) writeDatabase(db *connectordb.Database) {
+	m.metric("connectordb_sql_open_connections", "The number of open connections to the sql database.", "gauge",
+		float64(db.Sqldb.Stats().OpenConnections))
+
+	w, err := db.WriterStatus()
+	if err != nil {
+		log.Warn("Could not get the status of the DBWriter: ", err)
+		return
+	}
+	running := 0.0
+	if w.Running {
+		running = 1
+	}
+	m.metric("connectordb_cache_batch_queue", "The number of batches in the cache waiting to be written to the sql database.", "gauge", float64(w.Queue))
+	m.metric("connectordb_dbwriter_running", "Whether the DBWriter is run by this server.", "gauge", running)
+	m.metric("connectordb_dbwriter_errors_total", "The number of times that the DBWriter of this server failed.", "counter", float64(w.Errors))
+
+	// The writer only writes once a whole chunk of batches is queued up, so it is only behind if there is at least a chunk
+	lag := 0.0
+	if w.Running && w.Queue >= int64(config.Get().ChunkSize) {
+		lag = time.Since(w.LastWrite).Seconds()
+	}
+	m.metric("connectordb_dbwriter_lag_seconds", "The time since the DBWriter last wrote, while a chunk of batches is waiting to be written.", "gauge", lag)
+}
+
+// allowMetrics returns whether the request may read the metrics, which is only the case if it gives the configured token.
+// Requests from localhost need the token too, since they might have been passed on by a proxy.
+func allowMetrics(c *config.Metrics, request *http.Request) bool {
+	return c.Token != "" && subtle.ConstantTimeCompare([]byte(bearerToken(request)), []byte(c.Token)) == 1
+}
+
+// MetricsHandler serves the statistics of the server in the Prometheus text format
+func MetricsHandler(db *connectordb.Database) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		c := &config.Get().Metrics
+		if !c.Enabled {
+			http.NotFound(writer, request)
+			return
+		}
+		if !allowMetrics(c, request) {
+			GetRequestLogger(request, "metrics").Warn("Metrics request denied")
+			http.Error(writer, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		m := &metricsWriter{}
+		m.metric("connectordb_rest_queries_total", "The number of requests to the REST API.", "counter", float64(StatTotal(&StatsRESTQueries)))
+		m.metric("connectordb_web_queries_total", "The number of requests to the website.", "counter", float64(StatTotal(&StatsWebQueries)))
+		m.metric("connectordb_auth_failures_total", "The number of failed logins.", "counter", float64(StatTotal(&StatsAuthFails)))
+		m.metric("connectordb_inserted_datapoints_total", "The number of datapoints inserted through the server.", "counter", float64(StatTotal(&StatsInserts)))
+		m.metric("connectordb_errors_total", "The number of requests which failed with an error.", "counter", float64(StatTotal(&StatsErrors)))
+		m.metric("connectordb_panics_total", "The number of requests which panicked.", "counter", float64(atomic.LoadUint32(&StatsPanics)))
+		m.metric("connectordb_active_requests", "The number of requests being handled.", "gauge", float64(atomic.LoadInt32(&StatsActive)))
+		m.metric("connectordb_open_websockets", "The number of open websockets.", "gauge", float64(atomic.LoadInt32(&StatsWebsockets)))
+		m.writeQueryHistograms()
+		m.writeDatabase(db)
+
+		writer.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		writer.Write(m.Bytes())
+	}
+}
@@ -0,0 +1,74 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package webcore
+
+import (
+	"config"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryTimerHistogram(t *testing.T) {
+	qt := &QueryTimer{}
+	buckets, count, _ := qt.Histogram()
+	require.Len(t, buckets, len(LatencyBuckets))
+	require.EqualValues(t, 0, count)
+
+	qt.Add(3 * time.Millisecond)
+	qt.Add(70 * time.Millisecond)
+	qt.Add(time.Minute)
+	qt.GetClear()
+
+	// The histogram is not cleared along with the statistics of the period
+	buckets, count, sum := qt.Histogram()
+	require.EqualValues(t, 3, count)
+	require.InDelta(t, 60.073, sum, 1e-9)
+	require.EqualValues(t, 1, buckets[0])
+	require.EqualValues(t, 1, buckets[3], "0.05s")
+	require.EqualValues(t, 2, buckets[4], "0.1s")
+	require.EqualValues(t, 2, buckets[len(buckets)-1], "The minute long query is only counted in +Inf")
+}
+
+func TestStatTotal(t *testing.T) {
+	var stat uint32
+	atomic.AddUint32(&stat, 3)
+	require.EqualValues(t, 3, StatTotal(&stat))
+	require.EqualValues(t, 3, swapStat(&stat))
+	atomic.AddUint32(&stat, 2)
+	require.EqualValues(t, 5, StatTotal(&stat))
+}
+
+func TestAllowMetrics(t *testing.T) {
+	req, err := http.NewRequest("GET", "/metrics", nil)
+	require.NoError(t, err)
+
+	// Requests from localhost need the token too, since a proxy on the same machine passes on requests from anywhere
+	req.RemoteAddr = "127.0.0.1:5555"
+	require.False(t, allowMetrics(&config.Metrics{Enabled: true}, req))
+	req.RemoteAddr = "[::1]:5555"
+	require.False(t, allowMetrics(&config.Metrics{Enabled: true}, req))
+	req.Header.Set("Authorization", "Bearer ")
+	require.False(t, allowMetrics(&config.Metrics{Enabled: true}, req))
+	req.Header.Del("Authorization")
+
+	c := &config.Metrics{Enabled: true, Token: "secret"}
+	require.False(t, allowMetrics(c, req))
+	req.Header.Set("Authorization", "Bearer secret")
+	require.True(t, allowMetrics(c, req))
+	req.Header.Set("Authorization", "Bearer wrong")
+	require.False(t, allowMetrics(c, req))
+}
+
+func TestMetricsWriter(t *testing.T) {
+	m := &metricsWriter{}
+	m.header("x", "help", "gauge")
+	m.sample("x", 12345678, "handler", `a"b\`)
+	m.sample("y", 0.5)
+	require.Equal(t, "# HELP x help\n# TYPE x gauge\nx{handler=\"a\\\"b\\\\\"} 1.2345678e+07\ny 0.5\n", m.String())
+}
@@ -24,10 +24,34 @@ var (
 	StatsErrors      = uint32(0)
 	StatsPanics      = uint32(0)
 	StatsActive      = int32(0)
+	StatsWebsockets  = int32(0)
 
 	QueryTimers = make(map[string]*QueryTimer)
+
+	//LatencyBuckets are the upper bounds in seconds of the buckets of the query latency histograms
+	LatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+	//statsTotals holds the counts that RunStats took out of the stats globals, so that the running totals can be exported
+	statsTotals = make(map[*uint32]uint64)
+	statsLock   sync.Mutex
 )
 
+//swapStat clears the given stat for the next period, returning its count in the period that ended
+func swapStat(stat *uint32) uint32 {
+	statsLock.Lock()
+	defer statsLock.Unlock()
+	v := atomic.SwapUint32(stat, 0)
+	statsTotals[stat] += uint64(v)
+	return v
+}
+
+//StatTotal returns the total count of the given stat since the server started
+func StatTotal(stat *uint32) uint64 {
+	statsLock.Lock()
+	defer statsLock.Unlock()
+	return statsTotals[stat] + uint64(atomic.LoadUint32(stat))
+}
+
 //QueryTimer holds timing statistics for a specific query
 type QueryTimer struct {
 	sync.Mutex
@@ -40,6 +64,12 @@ type QueryTimer struct {
 
 	//NumQueries is the number of queries that were handled in the given time period
 	NumQueries int32
+
+	//The number of queries in each of the LatencyBuckets, with the number of queries slower than all of them last,
+	//along with the total number and time of the queries. These are never cleared, so that they can be exported as a histogram.
+	buckets    []uint64
+	totalCount uint64
+	totalSum   float64
 }
 
 //Clear resets the QueryTimer to reload data
@@ -58,9 +88,36 @@ func (qt *QueryTimer) Add(t time.Duration) {
 	tdiff := float64(t.Nanoseconds()) * 1e-9
 	qt.TimeSum += tdiff
 	qt.TimeVarSum += tdiff * tdiff
+
+	if qt.buckets == nil {
+		qt.buckets = make([]uint64, len(LatencyBuckets)+1)
+	}
+	i := 0
+	for i < len(LatencyBuckets) && tdiff > LatencyBuckets[i] {
+		i++
+	}
+	qt.buckets[i]++
+	qt.totalCount++
+	qt.totalSum += tdiff
 	qt.Unlock()
 }
 
+//Histogram returns the cumulative number of queries which took at most each of the LatencyBuckets,
+//along with the total number of queries and the total time that they took, since the server started
+func (qt *QueryTimer) Histogram() (buckets []uint64, count uint64, sum float64) {
+	qt.Lock()
+	defer qt.Unlock()
+	buckets = make([]uint64, len(LatencyBuckets))
+	var c uint64
+	for i := range buckets {
+		if qt.buckets != nil {
+			c += qt.buckets[i]
+		}
+		buckets[i] = c
+	}
+	return buckets, qt.totalCount, qt.totalSum
+}
+
 //GetClear gets the internal variance, and then clears the values
 func (qt *QueryTimer) GetClear() (num int32, mean float64, variance float64) {
 	qt.Lock()
@@ -143,11 +200,11 @@ func RunStats() {
 		if st > 0 {
 			time.Sleep(time.Duration(st) * time.Second)
 
-			q := atomic.SwapUint32(&StatsRESTQueries, 0)
-			w := atomic.SwapUint32(&StatsWebQueries, 0)
-			a := atomic.SwapUint32(&StatsAuthFails, 0)
-			i := atomic.SwapUint32(&StatsInserts, 0)
-			e := atomic.SwapUint32(&StatsErrors, 0)
+			q := swapStat(&StatsRESTQueries)
+			w := swapStat(&StatsWebQueries)
+			a := swapStat(&StatsAuthFails)
+			i := swapStat(&StatsInserts)
+			e := swapStat(&StatsErrors)
 			p := atomic.LoadUint32(&StatsPanics)
 			act := atomic.LoadInt32(&StatsActive)
 
@@ -0,0 +1,301 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package website
+
+/**
+This file implements password resets and email verification. Both send the user a link with a signed,
+expiring token. The token holds what it acts upon - a hash of the user's password for resets, so that
+a reset link can only be used once, and the email address for verification - so that it stops working
+once the user changes either.
+**/
+
+import (
+	"config"
+	"connectordb"
+	"connectordb/authoperator"
+	"connectordb/users"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"server/webcore"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	resetPurpose  = "connectordb-reset"
+	verifyPurpose = "connectordb-verify"
+
+	// The minimum time between emails sent to a user, so that the forms can't be used to flood inboxes
+	accountMailInterval = time.Minute
+)
+
+var (
+	// ErrMailDisabled is returned when the server can't send emails
+	ErrMailDisabled = errors.New("This server can't send emails. Please ask an admin to reset your password.")
+	// ErrAccountToken is returned when a reset or verification link is invalid or expired
+	ErrAccountToken = errors.New("The link is invalid or has expired. Please ask for a new one.")
+	// ErrMailInterval is returned when a user was sent an email a moment ago
+	ErrMailInterval = errors.New("An email was just sent. Please check your inbox, or try again in a minute.")
+
+	// The times at which users were last sent an email
+	accountMailSent = make(map[string]time.Time)
+	accountMailLock sync.Mutex
+)
+
+// accountToken is the content of the token in password reset and email verification links
+type accountToken struct {
+	User    string
+	Check   string
+	Expires int64
+}
+
+// passwordCheck returns a short hash of the user's password hash, which changes when the password changes
+func passwordCheck(u *users.User) string {
+	h := sha256.Sum256([]byte(u.Password + u.PasswordSalt))
+	return hex.EncodeToString(h[:8])
+}
+
+// emailCheck returns the user's email address
+func emailCheck(u *users.User) string {
+	return strings.ToLower(u.Email)
+}
+
+// accountLink returns the link to the given account page, with a new signed token for the user
+func accountLink(page, purpose string, u *users.User, check func(*users.User) string, expire int64) (string, error) {
+	token, err := webcore.CookieMonster.Encode(purpose, accountToken{u.Name, check(u), time.Now().Unix() + expire})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(config.Get().GetSiteURL(), "/") + "/account/" + page + "?" + url.Values{"token": {token}}.Encode(), nil
+}
+
+// readAccountToken returns the user of the given token, if it is valid
+func readAccountToken(purpose, token string, check func(*users.User) string) (*users.User, error) {
+	var t accountToken
+	if err := webcore.CookieMonster.Decode(purpose, token, &t); err != nil || t.Expires < time.Now().Unix() {
+		return nil, ErrAccountToken
+	}
+	u, err := Database.ReadUser(t.User)
+	if err != nil || subtle.ConstantTimeCompare([]byte(check(u)), []byte(t.Check)) != 1 {
+		return nil, ErrAccountToken
+	}
+	return u, nil
+}
+
+// sendAccountMail sends an email to the user
+func sendAccountMail(u *users.User, subject, body string) error {
+	c := config.Get().Mail
+	if !c.Enabled() {
+		return ErrMailDisabled
+	}
+	accountMailLock.Lock()
+	if time.Since(accountMailSent[u.Name]) < accountMailInterval {
+		accountMailLock.Unlock()
+		return ErrMailInterval
+	}
+	accountMailSent[u.Name] = time.Now()
+	for name, t := range accountMailSent {
+		if time.Since(t) >= accountMailInterval {
+			delete(accountMailSent, name)
+		}
+	}
+	accountMailLock.Unlock()
+
+	s, err := c.GetSender()
+	if err != nil {
+		return err
+	}
+	return s.Send(u.Email, subject, body)
+}
+
+// sendVerification sends the user a link to verify their email address
+func sendVerification(u *users.User) error {
+	link, err := accountLink("verify", verifyPurpose, u, emailCheck, config.Get().Mail.VerifyExpire)
+	if err != nil {
+		return err
+	}
+	return sendAccountMail(u, "Verify your email address", fmt.Sprintf(`Hi %s,
+
+Please verify your email address by opening the following link:
+
+%s
+
+If you did not create a ConnectorDB account, you can ignore this email.
+`, u.Name, link))
+}
+
+// writeAccountPage writes the account page with the given form and message
+func writeAccountPage(writer http.ResponseWriter, status int, form string, data map[string]interface{}) {
+	if data == nil {
+		data = make(map[string]interface{})
+	}
+	data["Version"] = connectordb.Version
+	data["Form"] = form
+	writer.Header().Set("X-Frame-Options", "DENY")
+	writer.WriteHeader(status)
+	WWWAccount.Execute(writer, data)
+}
+
+// ForgotPasswordGET shows the form to ask for a password reset link
+func ForgotPasswordGET(writer http.ResponseWriter, request *http.Request) {
+	tstart := time.Now()
+	logger := webcore.GetRequestLogger(request, "forgot")
+	delete(logger.Data, "op")
+
+	writeAccountPage(writer, http.StatusOK, "forgot", nil)
+	webcore.LogRequest(logger, webcore.DEBUG, "", time.Since(tstart))
+}
+
+// ForgotPasswordPOST sends a password reset link to the email address of the given user. The response is
+// the same whether or not the user exists, so that the form can't be used to find usernames.
+func ForgotPasswordPOST(writer http.ResponseWriter, request *http.Request) {
+	tstart := time.Now()
+	logger := webcore.GetRequestLogger(request, "forgot")
+	delete(logger.Data, "op")
+
+	if !config.Get().Mail.Enabled() {
+		WriteError(logger, writer, http.StatusServiceUnavailable, ErrMailDisabled, false, nil)
+		return
+	}
+	name := strings.TrimSpace(request.PostFormValue("username"))
+	if u, err := Database.ReadUser(name); err == nil {
+		go func() {
+			link, err := accountLink("reset", resetPurpose, u, passwordCheck, config.Get().Mail.ResetExpire)
+			if err == nil {
+				err = sendAccountMail(u, "Reset your password", fmt.Sprintf(`Hi %s,
+
+Someone asked to reset the password of your ConnectorDB account. To choose a new password, open the following link:
+
+%s
+
+If you did not ask for this, you can ignore this email, and your password will stay the same.
+`, u.Name, link))
+			}
+			if err != nil && err != ErrMailInterval {
+				log.WithField("user", u.Name).Errorf("Failed to send password reset email: %v", err)
+			}
+		}()
+	}
+
+	writeAccountPage(writer, http.StatusOK, "", map[string]interface{}{
+		"Message": "If the user exists, a link to reset the password was sent to its email address.",
+	})
+	webcore.LogRequest(logger, webcore.INFO, name, time.Since(tstart))
+}
+
+// ResetPasswordGET shows the form to choose a new password, if the reset link is valid
+func ResetPasswordGET(writer http.ResponseWriter, request *http.Request) {
+	tstart := time.Now()
+	logger := webcore.GetRequestLogger(request, "reset")
+	delete(logger.Data, "op")
+
+	token := request.URL.Query().Get("token")
+	if _, err := readAccountToken(resetPurpose, token, passwordCheck); err != nil {
+		WriteError(logger, writer, http.StatusBadRequest, err, false, nil)
+		return
+	}
+	writeAccountPage(writer, http.StatusOK, "reset", map[string]interface{}{"Token": token})
+	webcore.LogRequest(logger, webcore.DEBUG, "", time.Since(tstart))
+}
+
+// ResetPasswordPOST sets the new password of the user whose reset link was followed
+func ResetPasswordPOST(writer http.ResponseWriter, request *http.Request) {
+	tstart := time.Now()
+	logger := webcore.GetRequestLogger(request, "reset")
+	delete(logger.Data, "op")
+
+	token := request.PostFormValue("token")
+	u, err := readAccountToken(resetPurpose, token, passwordCheck)
+	if err != nil {
+		WriteError(logger, writer, http.StatusBadRequest, err, false, nil)
+		return
+	}
+	password := request.PostFormValue("password")
+	if password == "" || password != request.PostFormValue("password2") {
+		writeAccountPage(writer, http.StatusBadRequest, "reset", map[string]interface{}{
+			"Token":   token,
+			"Message": "The passwords are empty or do not match.",
+		})
+		return
+	}
+
+	// Following the link shows that the user owns the email address
+	err = Database.UpdateUserByID(u.UserID, map[string]interface{}{"password": password, "email_verified": true})
+	if err != nil {
+		WriteError(logger, writer, http.StatusInternalServerError, err, true, nil)
+		return
+	}
+	webcore.UnlockLogin(u.Name)
+
+	writeAccountPage(writer, http.StatusOK, "", map[string]interface{}{
+		"Message": "Your password was changed. You can now log in with your new password.",
+	})
+	webcore.LogRequest(logger, webcore.INFO, u.Name, time.Since(tstart))
+}
+
+// VerifyEmailGET verifies the email address of the user whose verification link was followed
+func VerifyEmailGET(writer http.ResponseWriter, request *http.Request) {
+	tstart := time.Now()
+	logger := webcore.GetRequestLogger(request, "verify")
+	delete(logger.Data, "op")
+
+	u, err := readAccountToken(verifyPurpose, request.URL.Query().Get("token"), emailCheck)
+	if err != nil {
+		WriteError(logger, writer, http.StatusBadRequest, err, false, nil)
+		return
+	}
+	if !u.EmailVerified {
+		u.EmailVerified = true
+		if err = Database.Userdb.UpdateUser(u); err != nil {
+			WriteError(logger, writer, http.StatusInternalServerError, err, true, nil)
+			return
+		}
+	}
+
+	writeAccountPage(writer, http.StatusOK, "", map[string]interface{}{
+		"Message": fmt.Sprintf("Thank you! The email address %s was verified.", u.Email),
+	})
+	webcore.LogRequest(logger, webcore.INFO, u.Name, time.Since(tstart))
+}
+
+// VerifyEmailPOST sends the logged in user a new link to verify their email address
+func VerifyEmailPOST(o *authoperator.AuthOperator, writer http.ResponseWriter, request *http.Request, logger *log.Entry) (int, string) {
+	if o.Name() == "nobody" {
+		return -1, ""
+	}
+	if _, err := userDevice(o); err != nil {
+		return WriteError(logger, writer, http.StatusForbidden, err, false, nil)
+	}
+	u, err := o.User()
+	if err != nil {
+		return WriteError(logger, writer, http.StatusInternalServerError, err, true, nil)
+	}
+	if u.EmailVerified {
+		writeAccountPage(writer, http.StatusOK, "", map[string]interface{}{"Message": "Your email address is already verified."})
+		return webcore.DEBUG, ""
+	}
+	if err = sendVerification(u); err != nil {
+		switch err {
+		case ErrMailDisabled:
+			return WriteError(logger, writer, http.StatusServiceUnavailable, err, false, nil)
+		case ErrMailInterval:
+			return WriteError(logger, writer, http.StatusTooManyRequests, err, false, nil)
+		}
+		return WriteError(logger, writer, http.StatusInternalServerError, err, true, nil)
+	}
+
+	writeAccountPage(writer, http.StatusOK, "", map[string]interface{}{
+		"Message": fmt.Sprintf("A link to verify your email address was sent to %s.", u.Email),
+	})
+	return webcore.INFO, u.Name
+}
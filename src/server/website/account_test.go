@@ -0,0 +1,135 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package website
+
+import (
+	"config"
+	"connectordb/users"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// createAccountUser clears the database and creates the user whose account links are tested
+func createAccountUser(t *testing.T) *users.User {
+	Database.Clear()
+	require.NoError(t, Database.CreateUser(&users.UserMaker{User: users.User{Name: "accountuser", Email: "account@localhost", Password: "mypass", Role: "user", Public: true}}))
+	u, err := Database.ReadUser("accountuser")
+	require.NoError(t, err)
+	return u
+}
+
+// linkToken returns the token of an account link, ensuring that the link goes to the given page of the site
+func linkToken(t *testing.T, page, link string) string {
+	require.True(t, strings.HasPrefix(link, strings.TrimSuffix(config.Get().GetSiteURL(), "/")+"/account/"+page+"?"), link)
+	u, err := url.Parse(link)
+	require.NoError(t, err)
+	token := u.Query().Get("token")
+	require.NotEmpty(t, token)
+	return token
+}
+
+func TestResetToken(t *testing.T) {
+	setConfig(t, func(c *config.Configuration) {})
+	u := createAccountUser(t)
+
+	link, err := accountLink("reset", resetPurpose, u, passwordCheck, 60)
+	require.NoError(t, err)
+	token := linkToken(t, "reset", link)
+	u2, err := readAccountToken(resetPurpose, token, passwordCheck)
+	require.NoError(t, err)
+	require.Equal(t, u.UserID, u2.UserID)
+
+	// A reset token is not a verification token
+	_, err = readAccountToken(verifyPurpose, token, emailCheck)
+	require.Equal(t, ErrAccountToken, err)
+	_, err = readAccountToken(resetPurpose, "notatoken", passwordCheck)
+	require.Equal(t, ErrAccountToken, err)
+
+	// Tokens expire
+	link, err = accountLink("reset", resetPurpose, u, passwordCheck, -1)
+	require.NoError(t, err)
+	_, err = readAccountToken(resetPurpose, linkToken(t, "reset", link), passwordCheck)
+	require.Equal(t, ErrAccountToken, err)
+
+	// The token works until the password changes, so that it can only be used once
+	require.NoError(t, Database.UpdateUserByID(u.UserID, map[string]interface{}{"password": "newpass", "email_verified": true}))
+	_, err = readAccountToken(resetPurpose, token, passwordCheck)
+	require.Equal(t, ErrAccountToken, err)
+	_, err = Database.UserLogin("accountuser", "newpass")
+	require.NoError(t, err)
+
+	u, err = Database.ReadUser("accountuser")
+	require.NoError(t, err)
+	link, err = accountLink("reset", resetPurpose, u, passwordCheck, 60)
+	require.NoError(t, err)
+	_, err = readAccountToken(resetPurpose, linkToken(t, "reset", link), passwordCheck)
+	require.NoError(t, err)
+}
+
+func TestVerifyToken(t *testing.T) {
+	setConfig(t, func(c *config.Configuration) {})
+	u := createAccountUser(t)
+
+	link, err := accountLink("verify", verifyPurpose, u, emailCheck, 60)
+	require.NoError(t, err)
+	token := linkToken(t, "verify", link)
+	_, err = readAccountToken(verifyPurpose, token, emailCheck)
+	require.NoError(t, err)
+	_, err = readAccountToken(resetPurpose, token, passwordCheck)
+	require.Equal(t, ErrAccountToken, err)
+
+	// Changing the password doesn't matter, but changing the email address makes the token invalid
+	require.NoError(t, Database.UpdateUserByID(u.UserID, map[string]interface{}{"password": "newpass"}))
+	_, err = readAccountToken(verifyPurpose, token, emailCheck)
+	require.NoError(t, err)
+	require.NoError(t, Database.UpdateUserByID(u.UserID, map[string]interface{}{"email": "other@localhost"}))
+	_, err = readAccountToken(verifyPurpose, token, emailCheck)
+	require.Equal(t, ErrAccountToken, err)
+}
+
+func TestSendAccountMail(t *testing.T) {
+	dir, err := ioutil.TempDir("", "connectordb_mail")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	setConfig(t, func(c *config.Configuration) {})
+	u := createAccountUser(t)
+	require.Equal(t, ErrMailDisabled, sendVerification(u))
+
+	setConfig(t, func(c *config.Configuration) {
+		c.Mail.Sender = "file"
+		c.Mail.From = "connectordb@localhost"
+		c.Mail.Directory = dir
+	})
+	accountMailLock.Lock()
+	accountMailSent = make(map[string]time.Time)
+	accountMailLock.Unlock()
+
+	// The email holds a working verification link
+	require.NoError(t, sendVerification(u))
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	msg, err := ioutil.ReadFile(filepath.Join(dir, files[0].Name()))
+	require.NoError(t, err)
+	require.Contains(t, string(msg), "To: account@localhost\r\n")
+	link := regexp.MustCompile(`https?://\S+`).FindString(string(msg))
+	_, err = readAccountToken(verifyPurpose, linkToken(t, "verify", link), emailCheck)
+	require.NoError(t, err)
+
+	// Users are sent at most one email a minute
+	require.Equal(t, ErrMailInterval, sendAccountMail(u, "Hello", "Hi"))
+	files, err = ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+}
@@ -83,16 +83,26 @@ func Authenticator(www wwwtemplatebookmark, apifunc webcore.APIHandler, db *conn
 		//If we got here, the user is not logged in. We therefore execute the "www" template given
 		cfg := config.Get()
 
+		// The identity providers that can be used to log in, by their id
+		providers := make(map[string]string)
+		for id, p := range cfg.OIDC {
+			providers[id] = p.Name
+		}
+
 		www.Execute(writer, struct {
-			Version string
-			Join    bool
-			Captcha bool
-			SiteKey string
+			Version   string
+			Join      bool
+			Captcha   bool
+			SiteKey   string
+			Providers map[string]string
+			Mail      bool
 		}{
-			Version: connectordb.Version,
-			Join:    pconfig.Get().UserRoles["nobody"].Join,
-			Captcha: cfg.Captcha.Enabled,
-			SiteKey: cfg.Captcha.SiteKey,
+			Version:   connectordb.Version,
+			Join:      pconfig.Get().UserRoles["nobody"].Join,
+			Captcha:   cfg.Captcha.Enabled,
+			SiteKey:   cfg.Captcha.SiteKey,
+			Providers: providers,
+			Mail:      cfg.Mail.Enabled(),
 		})
 
 		webcore.LogRequest(logger, webcore.DEBUG, "", time.Since(tstart))
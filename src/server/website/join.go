@@ -176,6 +176,17 @@ func JoinHandlePOST(writer http.ResponseWriter, request *http.Request) {
 		return
 	}
 
+	// New users are asked to verify their email address, if the server can send emails
+	if cfg.Mail.Enabled() {
+		if u, err := Database.ReadUser(j.Name); err == nil {
+			go func() {
+				if err := sendVerification(u); err != nil {
+					logger.Errorf("Failed to send verification email: %v", err)
+				}
+			}()
+		}
+	}
+
 	// Great success! The user was created successfully. We now write the cookie for the user
 	webcore.CreateSessionCookie(uo, writer, request)
 	webcore.LogRequest(logger, webcore.INFO, fmt.Sprintf("User '%s' Joined", j.Name), time.Since(tstart))
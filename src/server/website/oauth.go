@@ -0,0 +1,472 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package website
+
+/**
+This file implements an OAuth2 authorization server (RFC 6749), with support for PKCE (RFC 7636).
+
+When a user gives consent to an app, a device named oauth_<clientid> is created for them, with the
+device role configured for the app. Access tokens are short-lived tokens of that device, so they work directly
+in webcore.Authenticate. Refresh tokens are tokens of the device which can't log in, and are replaced by a new one
+each time that they are used. Users revoke an app's access by deleting its refresh token, or its device.
+**/
+
+import (
+	"config"
+	"connectordb"
+	"connectordb/authoperator"
+	"connectordb/users"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"server/webcore"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nu7hatch/gouuid"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+var (
+	// ErrOAuthDisabled is returned when OAuth is not enabled in the configuration
+	ErrOAuthDisabled = errors.New("OAuth is not enabled on this server")
+	// ErrOAuthClient is returned when the given client id is not registered
+	ErrOAuthClient = errors.New("Unknown OAuth client")
+	// ErrOAuthRedirect is returned when the redirect uri was not registered for the client
+	ErrOAuthRedirect = errors.New("The redirect uri is not registered for this client")
+	// ErrOAuthConsent is returned when the consent form is invalid or expired
+	ErrOAuthConsent = errors.New("The authorization request is invalid or has expired. Please try again.")
+	// ErrOAuthRefresh is returned when the refresh token is not one given to the client
+	ErrOAuthRefresh = errors.New("The refresh token is invalid or was revoked")
+
+	// The authorization codes that were given out, and were not yet exchanged for tokens
+	oauthCodes    = make(map[string]*oauthCode)
+	oauthCodeLock sync.Mutex
+)
+
+// oauthRequest is an authorization request which was shown to the user. It is signed and sent along
+// with the consent form, so that the approval can't be forged for another request or another user.
+type oauthRequest struct {
+	ClientID      string
+	RedirectURI   string
+	State         string
+	Challenge     string
+	ChallengeType string
+	DeviceID      int64
+	Expires       int64
+}
+
+// oauthCode is an authorization code given to a client after the user's approval
+type oauthCode struct {
+	ClientID      string
+	RedirectURI   string
+	Challenge     string
+	ChallengeType string
+	DeviceID      int64 // The device that was created for the client
+	Expires       time.Time
+}
+
+// oauthTokenResponse is the response of the token endpoint
+type oauthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// oauthClient returns the registered client with the given id
+func oauthClient(clientID string) (*config.OAuthClient, error) {
+	cfg := config.Get().OAuth
+	if !cfg.Enabled {
+		return nil, ErrOAuthDisabled
+	}
+	c, ok := cfg.Clients[clientID]
+	if !ok {
+		return nil, ErrOAuthClient
+	}
+	return c, nil
+}
+
+// oauthRedirectURI returns the client and the redirect uri to use, ensuring that the uri is registered for the client.
+// An empty redirect uri is permitted when the client has only one registered uri.
+func oauthRedirectURI(clientID, redirectURI string) (*config.OAuthClient, string, error) {
+	c, err := oauthClient(clientID)
+	if err != nil {
+		return nil, "", err
+	}
+	if redirectURI == "" && len(c.RedirectURIs) == 1 {
+		return c, c.RedirectURIs[0], nil
+	}
+	for _, u := range c.RedirectURIs {
+		if u == redirectURI {
+			return c, redirectURI, nil
+		}
+	}
+	return nil, "", ErrOAuthRedirect
+}
+
+// oauthRedirect redirects back to the client with the given query values
+func oauthRedirect(writer http.ResponseWriter, request *http.Request, redirectURI string, v url.Values) {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+	q := u.Query()
+	for k := range v {
+		q.Set(k, v.Get(k))
+	}
+	u.RawQuery = q.Encode()
+	http.Redirect(writer, request, u.String(), http.StatusFound)
+}
+
+// userDevice returns the device of the operator, making sure that it is the user device. Only a user logged
+// in to their own user device can give others access to their account, such as when giving consent to an app.
+func userDevice(o *authoperator.AuthOperator) (*users.Device, error) {
+	if o.Token() != nil {
+		return nil, authoperator.ErrTokenRestricted
+	}
+	dev, err := o.Device()
+	if err != nil {
+		return nil, err
+	}
+	if dev.Name != "user" {
+		return nil, errors.New("Only a user logged in to their own account can do this")
+	}
+	return dev, nil
+}
+
+// verifyPKCE checks the code verifier sent to the token endpoint against the challenge given during authorization
+func verifyPKCE(verifier, challenge, method string) bool {
+	if challenge == "" {
+		return true
+	}
+	if method == "S256" {
+		h := sha256.Sum256([]byte(verifier))
+		verifier = base64.RawURLEncoding.EncodeToString(h[:])
+	}
+	return subtle.ConstantTimeCompare([]byte(verifier), []byte(challenge)) == 1
+}
+
+// addOAuthCode generates an authorization code for the given request
+func addOAuthCode(r *oauthRequest, deviceID int64) (string, error) {
+	u, err := uuid.NewV4()
+	if err != nil {
+		return "", err
+	}
+	code := u.String()
+	now := time.Now()
+
+	oauthCodeLock.Lock()
+	defer oauthCodeLock.Unlock()
+
+	// Clear out the codes that were never used
+	for k, v := range oauthCodes {
+		if v.Expires.Before(now) {
+			delete(oauthCodes, k)
+		}
+	}
+	oauthCodes[code] = &oauthCode{
+		ClientID:      r.ClientID,
+		RedirectURI:   r.RedirectURI,
+		Challenge:     r.Challenge,
+		ChallengeType: r.ChallengeType,
+		DeviceID:      deviceID,
+		Expires:       now.Add(time.Duration(config.Get().OAuth.CodeExpire) * time.Second),
+	}
+	return code, nil
+}
+
+// takeOAuthCode returns the given authorization code, removing it, since codes can only be used once
+func takeOAuthCode(code string) (*oauthCode, bool) {
+	oauthCodeLock.Lock()
+	defer oauthCodeLock.Unlock()
+	c, ok := oauthCodes[code]
+	if !ok {
+		return nil, false
+	}
+	delete(oauthCodes, code)
+	if c.Expires.Before(time.Now()) {
+		return nil, false
+	}
+	return c, true
+}
+
+// OAuthAuthorize shows the consent page for an app requesting access to the logged in user's data
+func OAuthAuthorize(o *authoperator.AuthOperator, writer http.ResponseWriter, request *http.Request, logger *log.Entry) (int, string) {
+	if o.Name() == "nobody" {
+		// The user needs to log in before giving consent
+		return -1, ""
+	}
+	q := request.URL.Query()
+	clientID := q.Get("client_id")
+	c, redirectURI, err := oauthRedirectURI(clientID, q.Get("redirect_uri"))
+	if err != nil {
+		// The redirect uri can't be trusted, so the error is shown directly to the user
+		return WriteError(logger, writer, http.StatusBadRequest, err, false, nil)
+	}
+	state := q.Get("state")
+	fail := func(e, desc string) (int, string) {
+		oauthRedirect(writer, request, redirectURI, url.Values{"error": {e}, "error_description": {desc}, "state": {state}})
+		return webcore.INFO, e
+	}
+
+	if q.Get("response_type") != "code" {
+		return fail("unsupported_response_type", "Only the authorization code flow is supported")
+	}
+	challenge := q.Get("code_challenge")
+	method := q.Get("code_challenge_method")
+	if method == "" {
+		method = "plain"
+	}
+	if challenge == "" && c.Secret == "" {
+		return fail("invalid_request", "Public clients must use PKCE")
+	}
+	if method != "plain" && method != "S256" {
+		return fail("invalid_request", "Unsupported code challenge method")
+	}
+
+	dev, err := userDevice(o)
+	if err != nil {
+		return fail("access_denied", err.Error())
+	}
+	u, err := o.User()
+	if err != nil {
+		return WriteError(logger, writer, http.StatusInternalServerError, err, true, nil)
+	}
+
+	consent, err := webcore.CookieMonster.Encode("oauth-consent", &oauthRequest{
+		ClientID:      clientID,
+		RedirectURI:   redirectURI,
+		State:         state,
+		Challenge:     challenge,
+		ChallengeType: method,
+		DeviceID:      dev.DeviceID,
+		Expires:       time.Now().Unix() + config.Get().OAuth.CodeExpire*10,
+	})
+	if err != nil {
+		return WriteError(logger, writer, http.StatusInternalServerError, err, true, nil)
+	}
+
+	// The consent page must not be framed, so that users can't be tricked into clicking "allow"
+	writer.Header().Set("X-Frame-Options", "DENY")
+	writer.WriteHeader(http.StatusOK)
+	WWWOAuth.Execute(writer, map[string]interface{}{
+		"Version":  connectordb.Version,
+		"Join":     false,
+		"Captcha":  false,
+		"App":      c.Name,
+		"User":     u.Name,
+		"Redirect": redirectURI,
+		"Consent":  consent,
+	})
+	return webcore.DEBUG, clientID
+}
+
+// OAuthApprove handles the consent form, redirecting back to the app with an authorization code if the user approved
+func OAuthApprove(o *authoperator.AuthOperator, writer http.ResponseWriter, request *http.Request, logger *log.Entry) (int, string) {
+	if o.Name() == "nobody" {
+		return -1, ""
+	}
+	var r oauthRequest
+	err := webcore.CookieMonster.Decode("oauth-consent", request.PostFormValue("consent"), &r)
+	if err != nil || r.Expires < time.Now().Unix() {
+		return WriteError(logger, writer, http.StatusBadRequest, ErrOAuthConsent, false, nil)
+	}
+	dev, err := userDevice(o)
+	if err != nil || dev.DeviceID != r.DeviceID {
+		return WriteError(logger, writer, http.StatusForbidden, ErrOAuthConsent, false, nil)
+	}
+	c, _, err := oauthRedirectURI(r.ClientID, r.RedirectURI)
+	if err != nil {
+		return WriteError(logger, writer, http.StatusBadRequest, err, false, nil)
+	}
+
+	if request.PostFormValue("approve") != "true" {
+		oauthRedirect(writer, request, r.RedirectURI, url.Values{"error": {"access_denied"}, "state": {r.State}})
+		return webcore.INFO, "denied " + r.ClientID
+	}
+
+	// The app gets its own device, which is reused if the user already gave it consent before.
+	// The device is created by the server, since its role is set by the app's configuration.
+	u, err := o.User()
+	if err != nil {
+		return WriteError(logger, writer, http.StatusInternalServerError, err, true, nil)
+	}
+	devpath := u.Name + "/oauth_" + r.ClientID
+	appdev, err := Database.ReadDevice(devpath)
+	if err == nil && appdev.Role != c.Role {
+		// The device might have been made by the user, or the app's role changed since it was made,
+		// so the app is only given the access of its configured role
+		if err = Database.UpdateDevice(devpath, map[string]interface{}{"role": c.Role}); err != nil {
+			return WriteError(logger, writer, http.StatusInternalServerError, err, true, nil)
+		}
+	}
+	if err != nil {
+		dm := &users.DeviceMaker{}
+		dm.Nickname = c.Name
+		dm.Description = "Created for " + c.Name + " through OAuth"
+		dm.Role = c.Role
+		if err = Database.CreateDevice(devpath, dm); err != nil {
+			return WriteError(logger, writer, http.StatusInternalServerError, err, true, nil)
+		}
+		if appdev, err = Database.ReadDevice(devpath); err != nil {
+			return WriteError(logger, writer, http.StatusInternalServerError, err, true, nil)
+		}
+	}
+
+	code, err := addOAuthCode(&r, appdev.DeviceID)
+	if err != nil {
+		return WriteError(logger, writer, http.StatusInternalServerError, err, true, nil)
+	}
+	oauthRedirect(writer, request, r.RedirectURI, url.Values{"code": {code}, "state": {r.State}})
+	return webcore.INFO, "approved " + r.ClientID
+}
+
+// writeOAuthError writes an error response of the token endpoint
+func writeOAuthError(writer http.ResponseWriter, status int, e, desc string) {
+	writer.Header().Set("Content-Type", "application/json; charset=utf-8")
+	writer.Header().Set("Cache-Control", "no-store")
+	writer.WriteHeader(status)
+	json.NewEncoder(writer).Encode(map[string]string{"error": e, "error_description": desc})
+}
+
+// takeRefreshToken returns the refresh token of the client, deleting it, since refresh tokens can only be used once
+func takeRefreshToken(clientID, key string) (*users.Token, error) {
+	t, err := Database.Userdb.ReadTokenByKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if t.Access != users.TokenRefresh || t.IsExpired() {
+		return nil, ErrOAuthRefresh
+	}
+	dev, err := Database.ReadDeviceByID(t.DeviceID)
+	if err != nil {
+		return nil, err
+	}
+	if dev.Name != "oauth_"+clientID {
+		return nil, ErrOAuthRefresh
+	}
+	// Only the request which deletes the token gets to use it
+	if err = Database.DeleteTokenByID(t.TokenID); err != nil {
+		return nil, ErrOAuthRefresh
+	}
+	return t, nil
+}
+
+// issueOAuthToken creates a new access token and refresh token for the given device, and writes them
+func issueOAuthToken(writer http.ResponseWriter, dev *users.Device) error {
+	expire := config.Get().OAuth.AccessTokenExpire
+	now := time.Now()
+
+	// Expired access tokens are no longer useful, so they are removed as new ones are issued
+	tokens, err := Database.ReadAllTokensByDeviceID(dev.DeviceID)
+	if err != nil {
+		return err
+	}
+	for _, t := range tokens {
+		if t.IsExpired() {
+			Database.DeleteTokenByID(t.TokenID)
+		}
+	}
+
+	t := &users.Token{
+		DeviceID: dev.DeviceID,
+		Name:     "access_" + strconv.FormatInt(now.UnixNano(), 36),
+		Access:   users.TokenReadWrite,
+		Expires:  float64(now.Unix() + expire),
+	}
+	if err = Database.CreateTokenByID(t); err != nil {
+		return err
+	}
+	refresh := &users.Token{
+		DeviceID: dev.DeviceID,
+		Name:     "refresh_" + strconv.FormatInt(now.UnixNano(), 36),
+		Access:   users.TokenRefresh,
+	}
+	if err = Database.CreateTokenByID(refresh); err != nil {
+		return err
+	}
+
+	writer.Header().Set("Content-Type", "application/json; charset=utf-8")
+	writer.Header().Set("Cache-Control", "no-store")
+	writer.WriteHeader(http.StatusOK)
+	return json.NewEncoder(writer).Encode(&oauthTokenResponse{
+		AccessToken:  t.Key,
+		TokenType:    "bearer",
+		ExpiresIn:    expire,
+		RefreshToken: refresh.Key,
+	})
+}
+
+// OAuthToken is the token endpoint, which exchanges authorization codes and refresh tokens for access tokens
+func OAuthToken(writer http.ResponseWriter, request *http.Request) {
+	tstart := time.Now()
+	logger := webcore.GetRequestLogger(request, "oauth_token")
+	delete(logger.Data, "op")
+
+	if !webcore.IsActive {
+		writeOAuthError(writer, http.StatusServiceUnavailable, "temporarily_unavailable", "ConnectorDB is currently disabled.")
+		return
+	}
+
+	// The client can authenticate either with basic auth or in the form
+	clientID, secret, ok := request.BasicAuth()
+	if !ok {
+		clientID = request.PostFormValue("client_id")
+		secret = request.PostFormValue("client_secret")
+	}
+	c, err := oauthClient(clientID)
+	if err != nil || subtle.ConstantTimeCompare([]byte(secret), []byte(c.Secret)) != 1 {
+		writeOAuthError(writer, http.StatusUnauthorized, "invalid_client", "Client authentication failed")
+		webcore.LogRequest(logger, webcore.INFO, "invalid_client", time.Since(tstart))
+		return
+	}
+	logger = logger.WithField("dev", "oauth_"+clientID)
+
+	var dev *users.Device
+	switch request.PostFormValue("grant_type") {
+	case "authorization_code":
+		code, ok := takeOAuthCode(request.PostFormValue("code"))
+		if !ok || code.ClientID != clientID || !verifyPKCE(request.PostFormValue("code_verifier"), code.Challenge, code.ChallengeType) {
+			writeOAuthError(writer, http.StatusBadRequest, "invalid_grant", "The authorization code is invalid or expired")
+			webcore.LogRequest(logger, webcore.INFO, "invalid_grant", time.Since(tstart))
+			return
+		}
+		if ru := request.PostFormValue("redirect_uri"); ru != "" && ru != code.RedirectURI {
+			writeOAuthError(writer, http.StatusBadRequest, "invalid_grant", "The redirect uri does not match")
+			webcore.LogRequest(logger, webcore.INFO, "invalid_grant", time.Since(tstart))
+			return
+		}
+		dev, err = Database.ReadDeviceByID(code.DeviceID)
+	case "refresh_token":
+		var t *users.Token
+		if t, err = takeRefreshToken(clientID, request.PostFormValue("refresh_token")); err == nil {
+			dev, err = Database.ReadDeviceByID(t.DeviceID)
+		}
+	default:
+		writeOAuthError(writer, http.StatusBadRequest, "unsupported_grant_type", "Only authorization_code and refresh_token grants are supported")
+		webcore.LogRequest(logger, webcore.INFO, "unsupported_grant_type", time.Since(tstart))
+		return
+	}
+	if err != nil {
+		writeOAuthError(writer, http.StatusBadRequest, "invalid_grant", "The grant is invalid or was revoked")
+		webcore.LogRequest(logger, webcore.INFO, "invalid_grant", time.Since(tstart))
+		return
+	}
+
+	if err = issueOAuthToken(writer, dev); err != nil {
+		writeOAuthError(writer, http.StatusInternalServerError, "server_error", err.Error())
+		webcore.LogRequest(logger, webcore.WARNING, err.Error(), time.Since(tstart))
+		return
+	}
+	webcore.LogRequest(logger, webcore.INFO, "", time.Since(tstart))
+}
@@ -0,0 +1,191 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package website
+
+import (
+	"config"
+	"connectordb/authoperator"
+	"connectordb/users"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"server/webcore"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+func setOAuthConfig(t *testing.T) {
+	setConfig(t, func(c *config.Configuration) {
+		c.OAuth.Enabled = true
+		c.OAuth.Clients = map[string]*config.OAuthClient{
+			"myapp":  {RedirectURIs: []string{"https://myapp.com/callback"}},
+			"webapp": {Secret: "mysecret", RedirectURIs: []string{"https://webapp.com/a", "https://webapp.com/b"}},
+		}
+	})
+}
+
+func TestOAuthRedirectURI(t *testing.T) {
+	setOAuthConfig(t)
+
+	_, u, err := oauthRedirectURI("myapp", "")
+	require.NoError(t, err)
+	require.Equal(t, "https://myapp.com/callback", u)
+	_, u, err = oauthRedirectURI("webapp", "https://webapp.com/b")
+	require.NoError(t, err)
+	require.Equal(t, "https://webapp.com/b", u)
+
+	// The uri must match exactly, and clients with several uris must give one
+	_, _, err = oauthRedirectURI("webapp", "")
+	require.Equal(t, ErrOAuthRedirect, err)
+	_, _, err = oauthRedirectURI("myapp", "https://myapp.com/callback/evil")
+	require.Equal(t, ErrOAuthRedirect, err)
+	_, _, err = oauthRedirectURI("myapp", "https://myapp.com/callback?next=evil")
+	require.Equal(t, ErrOAuthRedirect, err)
+	_, _, err = oauthRedirectURI("otherapp", "https://myapp.com/callback")
+	require.Equal(t, ErrOAuthClient, err)
+}
+
+func TestVerifyPKCE(t *testing.T) {
+	h := sha256.Sum256([]byte("myverifier"))
+	challenge := base64.RawURLEncoding.EncodeToString(h[:])
+
+	require.True(t, verifyPKCE("myverifier", challenge, "S256"))
+	require.False(t, verifyPKCE("otherverifier", challenge, "S256"))
+	require.False(t, verifyPKCE(challenge, challenge, "S256"))
+	require.True(t, verifyPKCE("myverifier", "myverifier", "plain"))
+	require.False(t, verifyPKCE("", "myverifier", "plain"))
+	require.True(t, verifyPKCE("", "", ""))
+}
+
+// oauthApprove gives the consent of the operator's user to myapp, returning the authorization code
+func oauthApprove(t *testing.T, o *authoperator.AuthOperator, challenge string) string {
+	dev, err := o.Device()
+	require.NoError(t, err)
+	consent, err := webcore.CookieMonster.Encode("oauth-consent", &oauthRequest{
+		ClientID:      "myapp",
+		RedirectURI:   "https://myapp.com/callback",
+		State:         "mystate",
+		Challenge:     challenge,
+		ChallengeType: "S256",
+		DeviceID:      dev.DeviceID,
+		Expires:       time.Now().Unix() + 60,
+	})
+	require.NoError(t, err)
+
+	form := url.Values{"consent": {consent}, "approve": {"true"}}
+	request, err := http.NewRequest("POST", "/oauth/authorize", strings.NewReader(form.Encode()))
+	require.NoError(t, err)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	OAuthApprove(o, rec, request, log.WithField("test", "oauth"))
+	require.Equal(t, http.StatusFound, rec.Code)
+
+	loc, err := url.Parse(rec.Header().Get("Location"))
+	require.NoError(t, err)
+	require.Equal(t, "myapp.com", loc.Host)
+	require.Equal(t, "mystate", loc.Query().Get("state"))
+	code := loc.Query().Get("code")
+	require.NotEmpty(t, code)
+	return code
+}
+
+// oauthTokenRequest posts the form to the token endpoint, returning the status and the response
+func oauthTokenRequest(t *testing.T, form url.Values) (int, map[string]interface{}) {
+	request, err := http.NewRequest("POST", "/oauth/token", strings.NewReader(form.Encode()))
+	require.NoError(t, err)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	OAuthToken(rec, request)
+
+	var res map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	return rec.Code, res
+}
+
+func TestOAuthFlow(t *testing.T) {
+	setOAuthConfig(t)
+	Database.Clear()
+	require.NoError(t, Database.CreateUser(&users.UserMaker{User: users.User{Name: "oauthuser", Email: "oauth@localhost", Password: "mypass", Role: "user", Public: true}}))
+
+	// A device that the user made with the app's device name doesn't give the app more access
+	require.NoError(t, Database.CreateDevice("oauthuser/oauth_myapp", &users.DeviceMaker{Device: users.Device{Role: "user"}}))
+
+	o, err := Database.AsUser("oauthuser")
+	require.NoError(t, err)
+
+	h := sha256.Sum256([]byte("myverifier"))
+	challenge := base64.RawURLEncoding.EncodeToString(h[:])
+
+	// The code needs the verifier of its challenge, and is gone after a failed exchange
+	code := oauthApprove(t, o, challenge)
+	status, res := oauthTokenRequest(t, url.Values{"grant_type": {"authorization_code"}, "client_id": {"myapp"}, "code": {code}, "code_verifier": {"otherverifier"}})
+	require.Equal(t, http.StatusBadRequest, status)
+	require.Equal(t, "invalid_grant", res["error"])
+	status, _ = oauthTokenRequest(t, url.Values{"grant_type": {"authorization_code"}, "client_id": {"myapp"}, "code": {code}, "code_verifier": {"myverifier"}})
+	require.Equal(t, http.StatusBadRequest, status)
+
+	// The redirect uri must match the one that the code was given to
+	code = oauthApprove(t, o, challenge)
+	status, _ = oauthTokenRequest(t, url.Values{"grant_type": {"authorization_code"}, "client_id": {"myapp"}, "code": {code}, "code_verifier": {"myverifier"}, "redirect_uri": {"https://myapp.com/other"}})
+	require.Equal(t, http.StatusBadRequest, status)
+
+	// The code is exchanged once for an access token and refresh token
+	code = oauthApprove(t, o, challenge)
+	form := url.Values{"grant_type": {"authorization_code"}, "client_id": {"myapp"}, "code": {code}, "code_verifier": {"myverifier"}}
+	status, res = oauthTokenRequest(t, form)
+	require.Equal(t, http.StatusOK, status, "%v", res)
+	status, _ = oauthTokenRequest(t, form)
+	require.Equal(t, http.StatusBadRequest, status)
+
+	access := res["access_token"].(string)
+	refresh := res["refresh_token"].(string)
+	ao, err := Database.TokenLogin(access)
+	require.NoError(t, err)
+	require.Equal(t, "oauthuser/oauth_myapp", ao.Name())
+	dev, err := ao.Device()
+	require.NoError(t, err)
+	require.Equal(t, "oauth", dev.Role)
+	require.NotEqual(t, dev.APIKey, refresh)
+
+	// The refresh token is not a credential
+	_, err = Database.TokenLogin(refresh)
+	require.Equal(t, users.ErrRefreshToken, err)
+	_, err = Database.DeviceLogin(refresh)
+	require.Error(t, err)
+
+	// Refresh tokens are replaced each time that they are used, and only work for their client
+	status, _ = oauthTokenRequest(t, url.Values{"grant_type": {"refresh_token"}, "client_id": {"webapp"}, "client_secret": {"mysecret"}, "refresh_token": {refresh}})
+	require.Equal(t, http.StatusBadRequest, status)
+	status, res = oauthTokenRequest(t, url.Values{"grant_type": {"refresh_token"}, "client_id": {"myapp"}, "refresh_token": {refresh}})
+	require.Equal(t, http.StatusOK, status, "%v", res)
+	require.NotEqual(t, refresh, res["refresh_token"])
+	status, _ = oauthTokenRequest(t, url.Values{"grant_type": {"refresh_token"}, "client_id": {"myapp"}, "refresh_token": {refresh}})
+	require.Equal(t, http.StatusBadRequest, status)
+
+	// Deleting the refresh token revokes the app's access
+	refresh = res["refresh_token"].(string)
+	tokens, err := Database.ReadAllTokensByDeviceID(dev.DeviceID)
+	require.NoError(t, err)
+	for _, tk := range tokens {
+		if tk.Access == users.TokenRefresh {
+			require.NoError(t, Database.DeleteTokenByID(tk.TokenID))
+		}
+	}
+	status, _ = oauthTokenRequest(t, url.Values{"grant_type": {"refresh_token"}, "client_id": {"myapp"}, "refresh_token": {refresh}})
+	require.Equal(t, http.StatusBadRequest, status)
+
+	// The client secret is checked
+	status, res = oauthTokenRequest(t, url.Values{"grant_type": {"refresh_token"}, "client_id": {"webapp"}, "client_secret": {"wrong"}, "refresh_token": {refresh}})
+	require.Equal(t, http.StatusUnauthorized, status)
+	require.Equal(t, "invalid_client", res["error"])
+}
@@ -0,0 +1,309 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package website
+
+import (
+	"config"
+	"connectordb/authoperator"
+	"connectordb/users"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"server/webcore"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/nu7hatch/gouuid"
+)
+
+const (
+	oidcCookie     = "connectordb-oidc"
+	oidcTOTPCookie = "connectordb-oidc-totp"
+)
+
+var (
+	// ErrOIDCLogin is returned when the user returns from the identity provider without a valid login in progress
+	ErrOIDCLogin = errors.New("The login is invalid or has expired. Please try again.")
+
+	// Characters which are not permitted in usernames
+	oidcNameReplacer = regexp.MustCompile("[^a-zA-Z0-9_-]")
+)
+
+// oidcLogin is a login in progress at an identity provider. It is kept in a signed cookie until the user returns.
+type oidcLogin struct {
+	Provider string
+	State    string
+	Nonce    string
+	Verifier string
+	Expires  int64
+}
+
+// oidcTOTPLogin is a login through an identity provider by a user with two-factor authentication, which waits
+// for the user's two-factor code. It is kept in a signed cookie until the code is given.
+type oidcTOTPLogin struct {
+	UserID  int64
+	Expires int64
+}
+
+// randomID returns a random string which can be used for states, nonces and passwords
+func randomID() (string, error) {
+	u, err := uuid.NewV4()
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+// oidcRedirectURI is the url to which the identity provider sends the user back
+func oidcRedirectURI(id string) string {
+	return strings.TrimSuffix(config.Get().GetSiteURL(), "/") + "/oidc/" + id + "/callback"
+}
+
+// oidcUsername returns the name to use for a new user with the given claims
+func oidcUsername(claims oidcClaims) string {
+	name := claims.String("preferred_username")
+	if name == "" {
+		name = strings.Split(claims.String("email"), "@")[0]
+	}
+	name = oidcNameReplacer.ReplaceAllString(name, "_")
+	if name == "" || !(name[0] >= 'a' && name[0] <= 'z' || name[0] >= 'A' && name[0] <= 'Z') {
+		name = "u" + name
+	}
+	if len(name) > 29 {
+		name = name[:29]
+	}
+	return name
+}
+
+// oidcUser returns the operator of the user with the given identity. If the identity is not yet known,
+// it is linked to the logged in user, or a new user joins with it.
+func oidcUser(p *oidcProvider, claims oidcClaims, request *http.Request) (*authoperator.AuthOperator, error) {
+	sub := claims.String("sub")
+	role := p.Role(claims)
+
+	i, err := Database.Userdb.ReadIdentity(p.Issuer, sub)
+	if err == nil {
+		u, err := Database.ReadUserByID(i.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if role != "" && u.Role != role {
+			// The roles of users who log in through the provider follow their roles at the provider
+			if err = Database.UpdateUserByID(u.UserID, map[string]interface{}{"role": role}); err != nil {
+				return nil, err
+			}
+		}
+		return Database.AsUser(u.Name)
+	}
+	if err != users.ErrIdentityNotFound {
+		return nil, err
+	}
+
+	o, err := webcore.Authenticate(Database, request)
+	if err != nil {
+		return nil, err
+	}
+	if o.Name() != "nobody" {
+		// A logged in user links the identity to their account
+		if _, err = userDevice(o); err != nil {
+			return nil, err
+		}
+		u, err := o.User()
+		if err != nil {
+			return nil, err
+		}
+		if err = Database.Userdb.CreateIdentity(&users.Identity{UserID: u.UserID, Issuer: p.Issuer, Subject: sub}); err != nil {
+			return nil, err
+		}
+		return Database.AsUser(u.Name)
+	}
+
+	// Nobody is logged in, so a new user joins, following the same rules as the join page
+	r, err := checkIfJoinAllowed(request)
+	if err != nil {
+		return nil, err
+	}
+	if role == "" {
+		role = r.JoinRole
+	}
+	email := claims.String("email")
+	if verified, ok := claims["email_verified"].(bool); email == "" || ok && !verified {
+		return nil, errors.New("The identity provider did not give a verified email address")
+	}
+	password, err := randomID()
+	if err != nil {
+		return nil, err
+	}
+	name := oidcUsername(claims)
+	if _, err = Database.ReadUser(name); err == nil {
+		return nil, fmt.Errorf("The username '%s' is taken. If this is you, log in with your password and then log in with %s to link your accounts.", name, p.Name)
+	}
+
+	um := &users.UserMaker{}
+	um.Name = name
+	um.Email = email
+	um.Password = password
+	um.Role = role
+	um.Public = r.CreateUserDefaults.Public
+	um.Nickname = claims.String("name")
+	um.Description = r.CreateUserDefaults.Description
+	um.Icon = r.CreateUserDefaults.Icon
+	if err = Database.CreateUser(um); err != nil {
+		return nil, err
+	}
+	u, err := Database.ReadUser(name)
+	if err != nil {
+		return nil, err
+	}
+	if err = Database.Userdb.CreateIdentity(&users.Identity{UserID: u.UserID, Issuer: p.Issuer, Subject: sub}); err != nil {
+		return nil, err
+	}
+	return Database.AsUser(name)
+}
+
+// OIDCLogin sends the user to log in at the identity provider
+func OIDCLogin(writer http.ResponseWriter, request *http.Request) {
+	tstart := time.Now()
+	logger := webcore.GetRequestLogger(request, "oidc_login")
+	delete(logger.Data, "op")
+
+	id := mux.Vars(request)["provider"]
+	p, err := getOIDCProvider(id)
+	if err != nil {
+		WriteError(logger, writer, http.StatusBadRequest, err, false, nil)
+		return
+	}
+
+	l := &oidcLogin{Provider: id, Expires: time.Now().Unix() + 10*60}
+	for _, v := range []*string{&l.State, &l.Nonce, &l.Verifier} {
+		if *v, err = randomID(); err != nil {
+			WriteError(logger, writer, http.StatusInternalServerError, err, true, nil)
+			return
+		}
+	}
+	encoded, err := webcore.CookieMonster.Encode(oidcCookie, l)
+	if err != nil {
+		WriteError(logger, writer, http.StatusInternalServerError, err, true, nil)
+		return
+	}
+	http.SetCookie(writer, &http.Cookie{Name: oidcCookie, Value: encoded, Path: "/oidc", MaxAge: 10 * 60, HttpOnly: true})
+	http.Redirect(writer, request, p.AuthURL(oidcRedirectURI(id), l.State, l.Nonce, l.Verifier), http.StatusFound)
+
+	webcore.LogRequest(logger, webcore.DEBUG, id, time.Since(tstart))
+}
+
+// OIDCCallback handles the user returning from the identity provider, logging them in
+func OIDCCallback(writer http.ResponseWriter, request *http.Request) {
+	tstart := time.Now()
+	logger := webcore.GetRequestLogger(request, "oidc_callback")
+	delete(logger.Data, "op")
+
+	if !webcore.IsActive {
+		WriteError(logger, writer, http.StatusServiceUnavailable, errors.New("ConnectorDB is currently disabled."), false, nil)
+		return
+	}
+
+	id := mux.Vars(request)["provider"]
+	var l oidcLogin
+	cookie, err := request.Cookie(oidcCookie)
+	if err == nil {
+		err = webcore.CookieMonster.Decode(oidcCookie, cookie.Value, &l)
+	}
+	// The login state can only be used once
+	http.SetCookie(writer, &http.Cookie{Name: oidcCookie, Path: "/oidc", MaxAge: -1})
+
+	q := request.URL.Query()
+	if err != nil || l.Provider != id || l.State != q.Get("state") || l.Expires < time.Now().Unix() {
+		WriteError(logger, writer, http.StatusBadRequest, ErrOIDCLogin, false, nil)
+		return
+	}
+	if e := q.Get("error"); e != "" {
+		WriteError(logger, writer, http.StatusForbidden, fmt.Errorf("The identity provider refused the login: %s", e), false, nil)
+		return
+	}
+
+	p, err := getOIDCProvider(id)
+	if err != nil {
+		WriteError(logger, writer, http.StatusBadRequest, err, false, nil)
+		return
+	}
+	claims, err := p.Exchange(q.Get("code"), oidcRedirectURI(id), l.Verifier, l.Nonce)
+	if err != nil {
+		WriteError(logger, writer, http.StatusForbidden, err, false, nil)
+		return
+	}
+	o, err := oidcUser(p, claims, request)
+	if err != nil {
+		WriteError(logger, writer, http.StatusForbidden, err, false, nil)
+		return
+	}
+
+	u, err := o.User()
+	if err != nil {
+		WriteError(logger, writer, http.StatusInternalServerError, err, true, nil)
+		return
+	}
+	if u.TOTPEnabled {
+		// The identity provider replaces the password, but not the two-factor code
+		encoded, err := webcore.CookieMonster.Encode(oidcTOTPCookie, oidcTOTPLogin{u.UserID, time.Now().Unix() + 10*60})
+		if err != nil {
+			WriteError(logger, writer, http.StatusInternalServerError, err, true, nil)
+			return
+		}
+		http.SetCookie(writer, &http.Cookie{Name: oidcTOTPCookie, Value: encoded, Path: "/oidc", MaxAge: 10 * 60, HttpOnly: true})
+		writeAccountPage(writer, http.StatusOK, "totp", nil)
+		webcore.LogRequest(logger.WithField("dev", o.Name()), webcore.DEBUG, id, time.Since(tstart))
+		return
+	}
+
+	webcore.CreateSessionCookie(o, writer, request)
+	http.Redirect(writer, request, "/", http.StatusFound)
+	webcore.LogRequest(logger.WithField("dev", o.Name()), webcore.INFO, id, time.Since(tstart))
+}
+
+// OIDCTOTP finishes the login through an identity provider of a user with two-factor authentication,
+// once the user gives their two-factor code
+func OIDCTOTP(writer http.ResponseWriter, request *http.Request) {
+	tstart := time.Now()
+	logger := webcore.GetRequestLogger(request, "oidc_totp")
+	delete(logger.Data, "op")
+
+	if !webcore.IsActive {
+		WriteError(logger, writer, http.StatusServiceUnavailable, errors.New("ConnectorDB is currently disabled."), false, nil)
+		return
+	}
+
+	var l oidcTOTPLogin
+	cookie, err := request.Cookie(oidcTOTPCookie)
+	if err == nil {
+		err = webcore.CookieMonster.Decode(oidcTOTPCookie, cookie.Value, &l)
+	}
+	if err != nil || l.Expires < time.Now().Unix() {
+		http.SetCookie(writer, &http.Cookie{Name: oidcTOTPCookie, Path: "/oidc", MaxAge: -1})
+		WriteError(logger, writer, http.StatusBadRequest, ErrOIDCLogin, false, nil)
+		return
+	}
+	u, err := Database.ReadUserByID(l.UserID)
+	if err != nil {
+		http.SetCookie(writer, &http.Cookie{Name: oidcTOTPCookie, Path: "/oidc", MaxAge: -1})
+		WriteError(logger, writer, http.StatusBadRequest, ErrOIDCLogin, false, nil)
+		return
+	}
+
+	o, err := webcore.SecondFactorLogin(Database, request, u.Name, strings.TrimSpace(request.PostFormValue("code")))
+	if err != nil {
+		// The user can try again until the login expires, as long as the login limiter allows it
+		writeAccountPage(writer, http.StatusForbidden, "totp", map[string]interface{}{"Message": err.Error()})
+		webcore.LogRequest(logger.WithField("dev", u.Name), webcore.WARNING, err.Error(), time.Since(tstart))
+		return
+	}
+
+	http.SetCookie(writer, &http.Cookie{Name: oidcTOTPCookie, Path: "/oidc", MaxAge: -1})
+	webcore.CreateSessionCookie(o, writer, request)
+	http.Redirect(writer, request, "/", http.StatusFound)
+	webcore.LogRequest(logger.WithField("dev", o.Name()), webcore.INFO, u.Name, time.Since(tstart))
+}
@@ -0,0 +1,348 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package website
+
+import (
+	"config"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrOIDCProvider is returned when the given identity provider is not configured
+	ErrOIDCProvider = errors.New("Unknown identity provider")
+	// ErrInvalidIDToken is returned when the ID token given by the provider can't be verified
+	ErrInvalidIDToken = errors.New("The identity provider gave an invalid ID token")
+
+	// The providers are discovered when first used, and their keys are cached. The lock is not held while
+	// fetching from a provider, so the discoveries in progress are kept by issuer.
+	oidcProviders     = make(map[string]*oidcProvider)
+	oidcLoads         = make(map[string]*oidcLoad)
+	oidcProvidersLock sync.Mutex
+
+	// How long discovered endpoints and keys are cached before they are fetched again
+	oidcCacheTime = time.Hour
+
+	oidcClient = &http.Client{Timeout: 10 * time.Second}
+)
+
+// oidcDiscovery is the part of the provider's /.well-known/openid-configuration that is used
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// oidcKey is a JSON web key of the provider. Only RSA keys are supported.
+type oidcKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// oidcClaims are the claims of a verified ID token
+type oidcClaims map[string]interface{}
+
+// oidcProvider is an identity provider, along with its discovered endpoints and keys
+type oidcProvider struct {
+	*config.OIDCProvider
+
+	discovery oidcDiscovery
+	keys      map[string]*rsa.PublicKey
+	keyLock   sync.RWMutex
+	loaded    time.Time
+}
+
+// oidcLoad is the discovery of an issuer that is in progress. The logins which need the issuer while it is
+// discovered wait for its result, instead of all fetching it at once.
+type oidcLoad struct {
+	done chan struct{}
+	p    *oidcProvider
+	err  error
+}
+
+// getOIDCProvider returns the configured identity provider with the given id, discovering its endpoints if necessary
+func getOIDCProvider(id string) (*oidcProvider, error) {
+	c, ok := config.Get().OIDC[id]
+	if !ok {
+		return nil, ErrOIDCProvider
+	}
+
+	oidcProvidersLock.Lock()
+	p, ok := oidcProviders[id]
+	if ok && p.OIDCProvider == c && time.Since(p.loaded) < oidcCacheTime {
+		oidcProvidersLock.Unlock()
+		return p, nil
+	}
+	l, loading := oidcLoads[c.Issuer]
+	if !loading {
+		l = &oidcLoad{done: make(chan struct{})}
+		oidcLoads[c.Issuer] = l
+	}
+	oidcProvidersLock.Unlock()
+
+	if !loading {
+		p = &oidcProvider{OIDCProvider: c}
+		l.err = p.load()
+		if l.err == nil {
+			l.p = p
+		}
+		oidcProvidersLock.Lock()
+		delete(oidcLoads, c.Issuer)
+		oidcProvidersLock.Unlock()
+		close(l.done)
+	} else {
+		<-l.done
+	}
+	if l.err != nil {
+		return nil, l.err
+	}
+
+	// Providers with the same issuer share its endpoints and keys, but each has its own client
+	p = l.p
+	if p.OIDCProvider != c {
+		p = p.withConfig(c)
+	}
+	oidcProvidersLock.Lock()
+	oidcProviders[id] = p
+	oidcProvidersLock.Unlock()
+	return p, nil
+}
+
+// withConfig returns a provider with the given configuration, which uses the endpoints and keys discovered by p
+func (p *oidcProvider) withConfig(c *config.OIDCProvider) *oidcProvider {
+	p.keyLock.RLock()
+	defer p.keyLock.RUnlock()
+	return &oidcProvider{OIDCProvider: c, discovery: p.discovery, keys: p.keys, loaded: p.loaded}
+}
+
+// getJSON reads the JSON at the given url into v
+func getJSON(u string, v interface{}) error {
+	resp, err := oidcClient.Get(u)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Request to %s failed with status %d", u, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// load discovers the provider's endpoints, and reads its signing keys
+func (p *oidcProvider) load() error {
+	if err := getJSON(p.Issuer+"/.well-known/openid-configuration", &p.discovery); err != nil {
+		return err
+	}
+	if strings.TrimSuffix(p.discovery.Issuer, "/") != p.Issuer {
+		return fmt.Errorf("The identity provider's issuer '%s' does not match the configured issuer", p.discovery.Issuer)
+	}
+	p.loaded = time.Now()
+	return p.loadKeys()
+}
+
+// loadKeys reads the provider's signing keys
+func (p *oidcProvider) loadKeys() error {
+	var jwks struct {
+		Keys []oidcKey `json:"keys"`
+	}
+	if err := getJSON(p.discovery.JWKSURI, &jwks); err != nil {
+		return err
+	}
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range jwks.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return err
+		}
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	p.keyLock.Lock()
+	p.keys = keys
+	p.keyLock.Unlock()
+	return nil
+}
+
+// key returns the signing key with the given id
+func (p *oidcProvider) key(kid string) (*rsa.PublicKey, bool) {
+	p.keyLock.RLock()
+	defer p.keyLock.RUnlock()
+	k, ok := p.keys[kid]
+	return k, ok
+}
+
+// AuthURL returns the url to which the user is sent to log in at the provider
+func (p *oidcProvider) AuthURL(redirectURI, state, nonce, verifier string) string {
+	h := sha256.Sum256([]byte(verifier))
+	v := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.ClientID},
+		"redirect_uri":          {redirectURI},
+		"scope":                 {strings.Join(append([]string{"openid"}, p.Scopes...), " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(h[:])},
+		"code_challenge_method": {"S256"},
+	}
+	sep := "?"
+	if strings.Contains(p.discovery.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return p.discovery.AuthorizationEndpoint + sep + v.Encode()
+}
+
+// Exchange exchanges the authorization code given by the provider for the user's verified claims
+func (p *oidcProvider) Exchange(code, redirectURI, verifier, nonce string) (oidcClaims, error) {
+	v := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURI},
+		"code_verifier": {verifier},
+	}
+	req, err := http.NewRequest("POST", p.discovery.TokenEndpoint, strings.NewReader(v.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+
+	resp, err := oidcClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var tr struct {
+		IDToken string `json:"id_token"`
+		Error   string `json:"error"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&tr); err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK || tr.IDToken == "" {
+		return nil, fmt.Errorf("The identity provider refused the login: %s", tr.Error)
+	}
+	return p.Verify(tr.IDToken, nonce)
+}
+
+// Verify checks the signature and claims of the given ID token, returning its claims
+func (p *oidcProvider) Verify(idtoken, nonce string) (oidcClaims, error) {
+	parts := strings.Split(idtoken, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidIDToken
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil || header.Alg != "RS256" {
+		return nil, ErrInvalidIDToken
+	}
+	key, ok := p.key(header.Kid)
+	if !ok {
+		// The provider might have rotated its keys since they were read
+		if err := p.loadKeys(); err != nil {
+			return nil, err
+		}
+		if key, ok = p.key(header.Kid); !ok {
+			return nil, ErrInvalidIDToken
+		}
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidIDToken
+	}
+	h := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if rsa.VerifyPKCS1v15(key, crypto.SHA256, h[:], sig) != nil {
+		return nil, ErrInvalidIDToken
+	}
+
+	var claims oidcClaims
+	if err = decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, ErrInvalidIDToken
+	}
+	exp, _ := claims["exp"].(float64)
+	if claims.String("iss") != p.discovery.Issuer || !claims.Has("aud", p.ClientID) || claims.String("sub") == "" ||
+		exp < float64(time.Now().Unix()) || claims.String("nonce") != nonce {
+		return nil, ErrInvalidIDToken
+	}
+	return claims, nil
+}
+
+// Role returns the ConnectorDB user role that the claims map to, or "" if none do
+func (p *oidcProvider) Role(claims oidcClaims) string {
+	if p.RoleClaim == "" {
+		return ""
+	}
+	for _, v := range claims.Strings(p.RoleClaim) {
+		if r, ok := p.Roles[v]; ok {
+			return r
+		}
+	}
+	return ""
+}
+
+// decodeJWTPart decodes the given base64 JSON part of a JWT
+func decodeJWTPart(part string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// String returns the given claim if it is a string
+func (c oidcClaims) String(claim string) string {
+	s, _ := c[claim].(string)
+	return s
+}
+
+// Strings returns the given claim as a list of strings. Claims can be either a string or an array of strings.
+func (c oidcClaims) Strings(claim string) []string {
+	switch v := c[claim].(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		res := make([]string, 0, len(v))
+		for _, s := range v {
+			if str, ok := s.(string); ok {
+				res = append(res, str)
+			}
+		}
+		return res
+	}
+	return nil
+}
+
+// Has returns whether the given value is one of the values of the claim
+func (c oidcClaims) Has(claim, value string) bool {
+	for _, v := range c.Strings(claim) {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,178 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package website
+
+import (
+	"config"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testIssuer is a stand-in identity provider, which gives out the ID token with the claims that it is set up with
+type testIssuer struct {
+	*httptest.Server
+	key    *rsa.PrivateKey
+	claims map[string]interface{}
+
+	discoveries int32 // The number of times that the issuer was discovered, accessed atomically
+}
+
+func (ti *testIssuer) sign(claims map[string]interface{}) string {
+	enc := func(v interface{}) string {
+		b, _ := json.Marshal(v)
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	data := enc(map[string]string{"alg": "RS256", "kid": "testkey"}) + "." + enc(claims)
+	h := sha256.Sum256([]byte(data))
+	sig, _ := rsa.SignPKCS1v15(rand.Reader, ti.key, crypto.SHA256, h[:])
+	return data + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ti := &testIssuer{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&ti.discoveries, 1)
+		time.Sleep(50 * time.Millisecond)
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 ti.URL,
+			"authorization_endpoint": ti.URL + "/authorize",
+			"token_endpoint":         ti.URL + "/token",
+			"jwks_uri":               ti.URL + "/keys",
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []oidcKey{{
+			Kty: "RSA",
+			Kid: "testkey",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if user, _, _ := r.BasicAuth(); user != "connectordb" || r.PostFormValue("code") != "testcode" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": ti.sign(ti.claims)})
+	})
+	ti.Server = httptest.NewServer(mux)
+	return ti
+}
+
+func TestOIDCProvider(t *testing.T) {
+	ti := newTestIssuer(t)
+	defer ti.Close()
+
+	p := &oidcProvider{OIDCProvider: &config.OIDCProvider{
+		Issuer:    ti.URL,
+		ClientID:  "connectordb",
+		RoleClaim: "groups",
+		Roles:     map[string]string{"cdbadmins": "admin"},
+	}}
+	require.NoError(t, p.load())
+	require.Contains(t, p.AuthURL("http://localhost/cb", "mystate", "mynonce", "verifier"), ti.URL+"/authorize?")
+
+	ti.claims = map[string]interface{}{
+		"iss":                ti.URL,
+		"aud":                "connectordb",
+		"sub":                "1234",
+		"exp":                time.Now().Unix() + 60,
+		"nonce":              "mynonce",
+		"preferred_username": "jane.doe",
+		"groups":             []string{"staff", "cdbadmins"},
+	}
+	claims, err := p.Exchange("testcode", "http://localhost/cb", "verifier", "mynonce")
+	require.NoError(t, err)
+	require.Equal(t, "1234", claims.String("sub"))
+	require.Equal(t, "admin", p.Role(claims))
+	require.Equal(t, "jane_doe", oidcUsername(claims))
+
+	_, err = p.Exchange("wrongcode", "http://localhost/cb", "verifier", "mynonce")
+	require.Error(t, err)
+	_, err = p.Exchange("testcode", "http://localhost/cb", "verifier", "othernonce")
+	require.Equal(t, ErrInvalidIDToken, err)
+
+	ti.claims["aud"] = []string{"otherclient"}
+	_, err = p.Exchange("testcode", "http://localhost/cb", "verifier", "mynonce")
+	require.Equal(t, ErrInvalidIDToken, err)
+	ti.claims["aud"] = "connectordb"
+
+	ti.claims["exp"] = time.Now().Unix() - 10
+	_, err = p.Exchange("testcode", "http://localhost/cb", "verifier", "mynonce")
+	require.Equal(t, ErrInvalidIDToken, err)
+
+	// A token signed by anyone but the issuer is refused
+	ti.claims["exp"] = time.Now().Unix() + 60
+	tok := ti.sign(ti.claims)
+	ti.key, err = rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	_, err = p.Verify(ti.sign(ti.claims), "mynonce")
+	require.Equal(t, ErrInvalidIDToken, err)
+	_, err = p.Verify(tok, "mynonce")
+	require.NoError(t, err)
+}
+
+func TestGetOIDCProvider(t *testing.T) {
+	ti := newTestIssuer(t)
+	defer ti.Close()
+	setConfig(t, func(c *config.Configuration) {
+		c.OIDC = map[string]*config.OIDCProvider{
+			"test":  {Issuer: ti.URL, ClientID: "connectordb"},
+			"other": {Issuer: ti.URL, ClientID: "otherclient"},
+		}
+	})
+	_, err := getOIDCProvider("nonexistent")
+	require.Equal(t, ErrOIDCProvider, err)
+
+	// The logins which need the issuer while it is discovered wait for the discovery, rather than fetching it again
+	var wg sync.WaitGroup
+	providers := make([]*oidcProvider, 10)
+	errs := make([]error, 10)
+	for i := range providers {
+		id := "test"
+		if i%2 == 1 {
+			id = "other"
+		}
+		wg.Add(1)
+		go func(i int, id string) {
+			defer wg.Done()
+			providers[i], errs[i] = getOIDCProvider(id)
+		}(i, id)
+	}
+	wg.Wait()
+	for i, p := range providers {
+		require.NoError(t, errs[i])
+		require.Equal(t, ti.URL+"/token", p.discovery.TokenEndpoint)
+		_, ok := p.key("testkey")
+		require.True(t, ok)
+	}
+	require.Equal(t, int32(1), atomic.LoadInt32(&ti.discoveries))
+	require.Equal(t, "connectordb", providers[0].ClientID)
+	require.Equal(t, "otherclient", providers[1].ClientID)
+
+	// Discovered providers are cached
+	p, err := getOIDCProvider("other")
+	require.NoError(t, err)
+	require.Equal(t, "otherclient", p.ClientID)
+	require.Equal(t, int32(1), atomic.LoadInt32(&ti.discoveries))
+}
@@ -103,6 +103,24 @@ func Router(db *connectordb.Database, r *mux.Router) (*mux.Router, error) {
 	r.Handle("/join", http.HandlerFunc(JoinHandleGET)).Methods("GET")
 	r.Handle("/join", http.HandlerFunc(JoinHandlePOST)).Methods("POST")
 
+	// Password resets and email verification, through links sent by email
+	r.Handle("/account/forgot", http.HandlerFunc(ForgotPasswordGET)).Methods("GET")
+	r.Handle("/account/forgot", http.HandlerFunc(ForgotPasswordPOST)).Methods("POST")
+	r.Handle("/account/reset", http.HandlerFunc(ResetPasswordGET)).Methods("GET")
+	r.Handle("/account/reset", http.HandlerFunc(ResetPasswordPOST)).Methods("POST")
+	r.Handle("/account/verify", http.HandlerFunc(VerifyEmailGET)).Methods("GET")
+	r.Handle("/account/verify", Authenticator(WWWLogin, VerifyEmailPOST, db)).Methods("POST")
+
+	// Allow third party apps to get access to users' data through OAuth2
+	r.Handle("/oauth/authorize", Authenticator(WWWLogin, OAuthAuthorize, db)).Methods("GET")
+	r.Handle("/oauth/authorize", Authenticator(WWWLogin, OAuthApprove, db)).Methods("POST")
+	r.Handle("/oauth/token", http.HandlerFunc(OAuthToken)).Methods("POST")
+
+	// Log in through external OpenID Connect identity providers
+	r.Handle("/oidc/{provider}/login", http.HandlerFunc(OIDCLogin)).Methods("GET")
+	r.Handle("/oidc/{provider}/callback", http.HandlerFunc(OIDCCallback)).Methods("GET")
+	r.Handle("/oidc/totp", http.HandlerFunc(OIDCTOTP)).Methods("POST")
+
 	//Now load the user/device/stream paths
 	r.HandleFunc("/", Authenticator(WWWIndex, Index, db)).Methods("GET")
 	r.HandleFunc("/{user}", Authenticator(WWWLogin, User, db)).Methods("GET")
@@ -0,0 +1,44 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package website
+
+import (
+	"config"
+	"connectordb"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"server/webcore"
+	"testing"
+
+	"github.com/gorilla/securecookie"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	db, err := connectordb.Open(config.TestConfiguration.Options())
+	if err != nil {
+		log.Fatal(err)
+	}
+	Database = db
+	go db.RunWriter()
+
+	webcore.CookieMonster = securecookie.New(securecookie.GenerateRandomKey(64), securecookie.GenerateRandomKey(32))
+}
+
+// setConfig sets the global configuration to the default configuration, changed by the given function
+func setConfig(t *testing.T, change func(c *config.Configuration)) {
+	dir, err := ioutil.TempDir("", "connectordb_website")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c := config.NewConfiguration()
+	c.Watch = false
+	change(c)
+	filename := filepath.Join(dir, "connectordb.conf")
+	require.NoError(t, c.Save(filename))
+	require.NoError(t, config.SetPath(filename))
+}
@@ -44,11 +44,15 @@ var (
 	WWWIndex  wwwtemplatebookmark = "index.html"
 	WWW404    wwwtemplatebookmark = "404.html"
 	WWWJoin   wwwtemplatebookmark = "join.html"
+	WWWOAuth  wwwtemplatebookmark = "oauth.html"
 	AppIndex  apptemplatebookmark = "index.html"
 	AppUser   apptemplatebookmark = "user.html"
 	AppDevice apptemplatebookmark = "device.html"
 	AppStream apptemplatebookmark = "stream.html"
 	AppError  apptemplatebookmark = "error.html"
+
+	// The page of password resets and email verifications
+	WWWAccount wwwtemplatebookmark = "account.html"
 )
 
 func (w wwwtemplatebookmark) Execute(wr io.Writer, data interface{}) (err error) {
@@ -0,0 +1,48 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package shell
+
+/* Adds users to groups
+
+Copyright 2015 - The ConnectorDB Contributors; see AUTHORS for a list of authors.
+All Rights Reserved
+*/
+
+import (
+	"connectordb/users"
+	"fmt"
+)
+
+func init() {
+	help := "Invites a user to a group, or changes its role: 'addmember group user [role]'"
+	usage := `Usage: addmember group user [role]
+
+The role is one of owner, admin and member (the default). Owners and admins
+manage the group and its devices, while members get the group access level of
+their user role to the group's devices, and to each other's group-public streams.
+The user only becomes a member once it accepts the invitation.`
+	name := "addmember"
+
+	main := func(shell *Shell, args []string) uint8 {
+		if len(args) < 3 {
+			fmt.Println(Red + "Must supply a group and user" + Reset)
+			return 1
+		}
+		role := users.GroupRoleMember
+		if len(args) > 3 {
+			role = args[3]
+		}
+
+		err := shell.operator.SetGroupMember(args[1], args[2], role)
+		if shell.PrintError(err) {
+			return 1
+		}
+
+		fmt.Println(Green + "Set " + args[2] + " as " + role + " of " + args[1] + Reset)
+		return 0
+	}
+
+	registerShellCommand(help, usage, name, main)
+}
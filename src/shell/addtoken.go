@@ -0,0 +1,58 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package shell
+
+/* Creates tokens, which give a subset of the rights of a device's api key
+
+Copyright 2015 - The ConnectorDB Contributors; see AUTHORS for a list of authors.
+All Rights Reserved
+*/
+
+import (
+	"connectordb/users"
+	"fmt"
+	"strings"
+	"time"
+)
+
+func init() {
+	help := "Creates a token for a device: 'addtoken user/dev name access [duration [streams...]]'"
+	usage := `Usage: addtoken user/dev name access [duration [streams...]]
+
+The access is one of read, write or readwrite. The token expires after the
+duration (such as 720h), or never if the duration is "never". If stream paths
+are given, the token can only access the data of those streams.`
+	name := "addtoken"
+
+	main := func(shell *Shell, args []string) uint8 {
+		if len(args) < 4 {
+			fmt.Printf(Red + "Error: Wrong number of args\n" + Reset)
+			return 1
+		}
+
+		t := &users.Token{Name: args[2], Access: args[3]}
+		if len(args) > 4 && args[4] != "never" {
+			d, err := time.ParseDuration(args[4])
+			if shell.PrintError(err) {
+				return 1
+			}
+			t.Expires = float64(time.Now().Add(d).Unix())
+		}
+		if len(args) > 5 {
+			t.Streams = strings.Join(args[5:], ",")
+		}
+
+		path := shell.ResolvePath(args[1])
+		err := shell.operator.CreateToken(path, t)
+		if shell.PrintError(err) {
+			return 1
+		}
+
+		fmt.Printf("Token created: %v\n", t.Key)
+		return 0
+	}
+
+	registerShellCommand(help, usage, name, main)
+}
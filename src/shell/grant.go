@@ -0,0 +1,64 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package shell
+
+/* Gives users and devices access to the streams of other users
+
+Copyright 2015 - The ConnectorDB Contributors; see AUTHORS for a list of authors.
+All Rights Reserved
+*/
+
+import (
+	"connectordb/users"
+	"fmt"
+	"strings"
+	"time"
+)
+
+func init() {
+	help := "Gives a user or device access to a user/device/stream: 'grant grantee target rws [duration]'"
+	usage := `Usage: grant grantee target permissions [duration]
+
+The grantee is a user or device, and the target is the user, device or stream
+which it is given access to. The permissions are any of the letters r (read),
+w (write) and s (subscribe). The optional duration (such as 24h) makes the grant expire.`
+	name := "grant"
+
+	main := func(shell *Shell, args []string) uint8 {
+		if len(args) < 4 {
+			fmt.Println(Red + "Must supply a grantee, target and permissions" + Reset)
+			return 1
+		}
+
+		g := &users.Grant{
+			Read:      strings.Contains(args[3], "r"),
+			Write:     strings.Contains(args[3], "w"),
+			Subscribe: strings.Contains(args[3], "s"),
+		}
+		if strings.Trim(args[3], "rws") != "" {
+			fmt.Println(Red + "Permissions must be made of the letters r, w and s" + Reset)
+			return 1
+		}
+
+		if len(args) > 4 {
+			d, err := time.ParseDuration(args[4])
+			if shell.PrintError(err) {
+				return 1
+			}
+			g.Expires = float64(time.Now().Add(d).Unix())
+		}
+
+		target := shell.ResolvePath(args[2])
+		err := shell.operator.CreateGrant(args[1], target, g)
+		if shell.PrintError(err) {
+			return 1
+		}
+
+		fmt.Println(Green + "Gave " + args[1] + " access to " + target + Reset)
+		return 0
+	}
+
+	registerShellCommand(help, usage, name, main)
+}
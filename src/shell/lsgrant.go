@@ -0,0 +1,59 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package shell
+
+/* Lists the grants given on a user
+
+Copyright 2015 - The ConnectorDB Contributors; see AUTHORS for a list of authors.
+All Rights Reserved
+*/
+
+import (
+	"fmt"
+	"time"
+)
+
+func init() {
+	help := "Lists the grants given on a user and its devices and streams: 'lsgrant username'"
+	usage := `Usage: lsgrant username`
+	name := "lsgrant"
+
+	main := func(shell *Shell, args []string) uint8 {
+		if len(args) < 2 {
+			fmt.Println(Red + "Must supply a username" + Reset)
+			return 1
+		}
+
+		grants, err := shell.operator.ReadUserGrants(args[1])
+		if shell.PrintError(err) {
+			return 1
+		}
+
+		for _, g := range grants {
+			perms := ""
+			if g.Read {
+				perms += "r"
+			}
+			if g.Write {
+				perms += "w"
+			}
+			if g.Subscribe {
+				perms += "s"
+			}
+
+			expires := "never"
+			if g.IsExpired() {
+				expires = "expired"
+			} else if g.Expires > 0 {
+				expires = time.Unix(int64(g.Expires), 0).Format(time.RFC3339)
+			}
+			fmt.Printf("%d\t%s\t%s\t%s\t%s\n", g.GrantID, g.Grantee, g.Target, perms, expires)
+		}
+
+		return 0
+	}
+
+	registerShellCommand(help, usage, name, main)
+}
@@ -0,0 +1,45 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package shell
+
+/* Lists the members of a group, or the groups of a user
+
+Copyright 2015 - The ConnectorDB Contributors; see AUTHORS for a list of authors.
+All Rights Reserved
+*/
+
+import (
+	"connectordb/users"
+	"fmt"
+)
+
+func init() {
+	help := "Lists the members of a group, or the groups of a user: 'lsgroup name'"
+	usage := `Usage: lsgroup name`
+	name := "lsgroup"
+
+	main := func(shell *Shell, args []string) uint8 {
+		if len(args) < 2 {
+			fmt.Println(Red + "Must supply a group or user" + Reset)
+			return 1
+		}
+
+		members, err := shell.operator.ReadGroupMembers(args[1])
+		if err == users.ErrGroupNotFound {
+			members, err = shell.operator.ReadUserGroups(args[1])
+		}
+		if shell.PrintError(err) {
+			return 1
+		}
+
+		for _, m := range members {
+			fmt.Printf("%s\t%s\t%s\n", m.Group, m.User, m.Role)
+		}
+
+		return 0
+	}
+
+	registerShellCommand(help, usage, name, main)
+}
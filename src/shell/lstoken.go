@@ -0,0 +1,52 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package shell
+
+/* Lists the tokens of a device
+
+Copyright 2015 - The ConnectorDB Contributors; see AUTHORS for a list of authors.
+All Rights Reserved
+*/
+
+import (
+	"fmt"
+	"time"
+)
+
+func init() {
+	help := "Lists the tokens of a device: 'lstoken user/dev'"
+	usage := `Usage: lstoken user/dev`
+	name := "lstoken"
+
+	main := func(shell *Shell, args []string) uint8 {
+		if len(args) < 2 {
+			fmt.Println(Red + "Must supply a device path" + Reset)
+			return 1
+		}
+
+		tokens, err := shell.operator.ReadDeviceTokens(shell.ResolvePath(args[1]))
+		if shell.PrintError(err) {
+			return 1
+		}
+
+		for _, t := range tokens {
+			expires := "never"
+			if t.IsExpired() {
+				expires = "expired"
+			} else if t.Expires > 0 {
+				expires = time.Unix(int64(t.Expires), 0).Format(time.RFC3339)
+			}
+			streams := t.Streams
+			if streams == "" {
+				streams = "*"
+			}
+			fmt.Printf("%d\t%s\t%s\t%s\t%s\t%s\n", t.TokenID, t.Name, t.Key, t.Access, expires, streams)
+		}
+
+		return 0
+	}
+
+	registerShellCommand(help, usage, name, main)
+}
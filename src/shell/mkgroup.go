@@ -0,0 +1,40 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package shell
+
+/* Creates groups
+
+Copyright 2015 - The ConnectorDB Contributors; see AUTHORS for a list of authors.
+All Rights Reserved
+*/
+
+import "fmt"
+
+func init() {
+	help := "Creates a group of users: 'mkgroup groupname owner'"
+	usage := `Usage: mkgroup groupname owner
+
+A group is backed by a user with the group's name, which owns the group's devices.
+The owner manages the group's members with addmember and rmmember, and the group
+is deleted along with its devices by removing its user with rm.`
+	name := "mkgroup"
+
+	main := func(shell *Shell, args []string) uint8 {
+		if len(args) < 3 {
+			fmt.Println(Red + "Must supply a group name and its owner" + Reset)
+			return 1
+		}
+
+		err := shell.operator.CreateGroup(args[1], args[2])
+		if shell.PrintError(err) {
+			return 1
+		}
+
+		fmt.Println(Green + "Created group " + args[1] + " owned by " + args[2] + Reset)
+		return 0
+	}
+
+	registerShellCommand(help, usage, name, main)
+}
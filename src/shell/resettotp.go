@@ -0,0 +1,38 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package shell
+
+/* Removes the two-factor authentication of a user, for users who lost their authenticator and recovery codes
+
+Copyright 2015 - The ConnectorDB Contributors; see AUTHORS for a list of authors.
+All Rights Reserved
+*/
+
+import "fmt"
+
+func init() {
+	help := "Removes two-factor authentication from a user: 'resettotp username'"
+	usage := `Usage: resettotp username
+
+The user can log in with only their password until they enroll again.`
+	name := "resettotp"
+
+	main := func(shell *Shell, args []string) uint8 {
+		if len(args) < 2 {
+			fmt.Println(Red + "Must supply a username" + Reset)
+			return 1
+		}
+
+		err := shell.operator.ResetTOTP(args[1])
+		if shell.PrintError(err) {
+			return 1
+		}
+
+		fmt.Println(Green + "Removed two-factor authentication from " + args[1] + Reset)
+		return 0
+	}
+
+	registerShellCommand(help, usage, name, main)
+}
@@ -0,0 +1,46 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package shell
+
+/* Removes grants given with the grant command
+
+Copyright 2015 - The ConnectorDB Contributors; see AUTHORS for a list of authors.
+All Rights Reserved
+*/
+
+import (
+	"fmt"
+	"strconv"
+)
+
+func init() {
+	help := "Removes a grant: 'revoke grantid'"
+	usage := `Usage: revoke grantid
+
+The ids of grants are shown by lsgrant`
+	name := "revoke"
+
+	main := func(shell *Shell, args []string) uint8 {
+		if len(args) < 2 {
+			fmt.Println(Red + "Must supply a grant id" + Reset)
+			return 1
+		}
+
+		id, err := strconv.ParseInt(args[1], 10, 64)
+		if shell.PrintError(err) {
+			return 1
+		}
+
+		err = shell.operator.DeleteGrantByID(id)
+		if shell.PrintError(err) {
+			return 1
+		}
+
+		fmt.Println(Green + "Revoked grant " + args[1] + Reset)
+		return 0
+	}
+
+	registerShellCommand(help, usage, name, main)
+}
@@ -0,0 +1,36 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package shell
+
+/* Removes users from groups
+
+Copyright 2015 - The ConnectorDB Contributors; see AUTHORS for a list of authors.
+All Rights Reserved
+*/
+
+import "fmt"
+
+func init() {
+	help := "Removes a user from a group: 'rmmember group user'"
+	usage := `Usage: rmmember group user`
+	name := "rmmember"
+
+	main := func(shell *Shell, args []string) uint8 {
+		if len(args) < 3 {
+			fmt.Println(Red + "Must supply a group and user" + Reset)
+			return 1
+		}
+
+		err := shell.operator.DeleteGroupMember(args[1], args[2])
+		if shell.PrintError(err) {
+			return 1
+		}
+
+		fmt.Println(Green + "Removed " + args[2] + " from " + args[1] + Reset)
+		return 0
+	}
+
+	registerShellCommand(help, usage, name, main)
+}
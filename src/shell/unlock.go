@@ -0,0 +1,46 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package shell
+
+/* Unlocks logins which were locked out after too many failed attempts
+
+Copyright 2015 - The ConnectorDB Contributors; see AUTHORS for a list of authors.
+All Rights Reserved
+*/
+
+import "fmt"
+
+func init() {
+	help := "Unlocks logins locked out after failed attempts: 'unlock username|ip|keyprefix'"
+	usage := `Usage: unlock username|ip|keyprefix
+
+Removes the failed logins of the given username, client ip address or the first
+8 characters of an api key from all running servers, so that logins are accepted again.`
+	name := "unlock"
+
+	main := func(shell *Shell, args []string) uint8 {
+		if len(args) < 2 {
+			fmt.Println(Red + "Must supply a username, ip address or api key prefix" + Reset)
+			return 1
+		}
+		if shell.operator != shell.sdb {
+			fmt.Println(Red + "Only an admin can unlock logins" + Reset)
+			return 1
+		}
+
+		err := shell.sdb.UnlockLogin(args[1])
+		if shell.PrintError(err) {
+			return 1
+		}
+		if _, err = shell.sdb.ReadUser(args[1]); err == nil {
+			shell.PrintError(shell.sdb.LogLoginEvent(args[1], "LoginUnlock"))
+		}
+
+		fmt.Println(Green + "Unlocked logins of " + args[1] + Reset)
+		return 0
+	}
+
+	registerShellCommand(help, usage, name, main)
+}